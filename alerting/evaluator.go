@@ -0,0 +1,47 @@
+// Package alerting periodically evaluates the alert rules (stale cameras,
+// over-capacity, spikes) against stored readings.
+package alerting
+
+import (
+	"context"
+	"log"
+	"time"
+	"web_backend_v2/config"
+	"web_backend_v2/models"
+)
+
+// Evaluator runs AlertStore.EvaluateAlerts every cfg.Interval.
+type Evaluator struct {
+	store models.AlertStore
+	cfg   config.AlertConfig
+}
+
+// NewEvaluator creates an evaluator; Run starts it.
+func NewEvaluator(store models.AlertStore, cfg config.AlertConfig) *Evaluator {
+	return &Evaluator{store: store, cfg: cfg}
+}
+
+// Run evaluates the rules right away and then every interval until ctx is done.
+func (e *Evaluator) Run(ctx context.Context) {
+	rules := models.AlertRules{
+		StaleAfter:   e.cfg.StaleAfter,
+		SpikePercent: e.cfg.SpikePercent,
+		SpikeWindow:  e.cfg.SpikeWindow,
+	}
+	ticker := time.NewTicker(e.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		raised, resolved, err := e.store.EvaluateAlerts(time.Now().UTC(), rules)
+		switch {
+		case err != nil:
+			log.Printf("alerts: %v", err)
+		case raised > 0 || resolved > 0:
+			log.Printf("alerts: %d raised, %d resolved", raised, resolved)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// minAPIKeyLength is the shortest plain API key accepted in the keys file.
+const minAPIKeyLength = 16
+
+// apiKeysFile is the format of AUTH_API_KEYS_FILE, e.g.
+//
+//	keys:
+//	  - name: dashboard
+//	    role: viewer
+//	    sha256: 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
+//	  - name: perm-admin
+//	    role: facility-admin
+//	    cities: [3]
+//	    buildings: [14, 15]
+//	    sha256: ...
+//
+// Each key is given either in plain text (key) or as the hex SHA-256 of the
+// key (sha256), so that the file does not have to hold the secret itself.
+// Keys with cities or buildings are limited to them (see Scope).
+type apiKeysFile struct {
+	Keys []apiKeyEntry `json:"keys"`
+}
+
+type apiKeyEntry struct {
+	Name      string `json:"name"`
+	Role      string `json:"role"`
+	Key       string `json:"key"`
+	SHA256    string `json:"sha256"`
+	Cities    []uint `json:"cities"`
+	Buildings []uint `json:"buildings"`
+}
+
+// apiKeys maps the SHA-256 of each key to its principal; looking up the hash
+// rather than the key keeps the comparison independent of the key bytes.
+type apiKeys struct {
+	byHash map[[sha256.Size]byte]*Principal
+}
+
+// loadAPIKeys reads the keys from a .json, .yaml or .yml file. Unknown fields
+// are rejected.
+func loadAPIKeys(path string) (*apiKeys, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API keys %s: %w", path, err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+	case ".yaml", ".yml":
+		if data, err = yaml.YAMLToJSON(data); err != nil {
+			return nil, fmt.Errorf("failed to parse API keys %s: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported API keys format %q (want .json, .yaml or .yml)", filepath.Ext(path))
+	}
+
+	var file apiKeysFile
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to parse API keys %s: %w", path, err)
+	}
+
+	keys := &apiKeys{byHash: make(map[[sha256.Size]byte]*Principal, len(file.Keys))}
+	names := make(map[string]bool, len(file.Keys))
+	for i, entry := range file.Keys {
+		if entry.Name == "" {
+			return nil, fmt.Errorf("invalid API keys %s: key %d has no name", path, i+1)
+		}
+		if names[entry.Name] {
+			return nil, fmt.Errorf("invalid API keys %s: duplicate name %q", path, entry.Name)
+		}
+		names[entry.Name] = true
+		if !validRole(entry.Role) {
+			return nil, fmt.Errorf("invalid API keys %s: key %q has role %q, expected viewer, facility-admin or device", path, entry.Name, entry.Role)
+		}
+
+		if containsID(entry.Cities, 0) || containsID(entry.Buildings, 0) {
+			return nil, fmt.Errorf("invalid API keys %s: key %q has a city or building id 0", path, entry.Name)
+		}
+
+		var hash [sha256.Size]byte
+		switch {
+		case entry.Key != "" && entry.SHA256 != "":
+			return nil, fmt.Errorf("invalid API keys %s: key %q has both key and sha256", path, entry.Name)
+		case entry.Key != "":
+			if len(entry.Key) < minAPIKeyLength {
+				return nil, fmt.Errorf("invalid API keys %s: key %q is shorter than %d characters", path, entry.Name, minAPIKeyLength)
+			}
+			hash = sha256.Sum256([]byte(entry.Key))
+		default:
+			raw, err := hex.DecodeString(entry.SHA256)
+			if err != nil || len(raw) != sha256.Size {
+				return nil, fmt.Errorf("invalid API keys %s: key %q needs key or a hex sha256", path, entry.Name)
+			}
+			copy(hash[:], raw)
+		}
+		if _, ok := keys.byHash[hash]; ok {
+			return nil, fmt.Errorf("invalid API keys %s: key %q is the same as another key", path, entry.Name)
+		}
+		keys.byHash[hash] = &Principal{
+			Subject: entry.Name,
+			Roles:   []string{entry.Role},
+			Method:  MethodAPIKey,
+			Scope:   Scope{CityIDs: entry.Cities, BuildingIDs: entry.Buildings},
+		}
+	}
+	return keys, nil
+}
+
+func (k *apiKeys) authenticate(key string) (*Principal, error) {
+	principal, ok := k.byHash[sha256.Sum256([]byte(key))]
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+	return principal, nil
+}
@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"web_backend_v2/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	plainKey  = "dashboard-key-0123456789"
+	hashedKey = "perm-admin-key-0123456789"
+)
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func apiKeyRequest(key string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/v1/cities/", nil)
+	if key != "" {
+		r.Header.Set("X-API-Key", key)
+	}
+	return r
+}
+
+func TestAPIKeys(t *testing.T) {
+	files := map[string]string{
+		"keys.yaml": `keys:
+  - name: dashboard
+    role: viewer
+    key: ` + plainKey + `
+  - name: perm-admin
+    role: facility-admin
+    cities: [3]
+    buildings: [14, 15]
+    sha256: ` + sha256Hex(hashedKey) + `
+`,
+		"keys.json": `{"keys": [
+  {"name": "dashboard", "role": "viewer", "key": "` + plainKey + `"},
+  {"name": "perm-admin", "role": "facility-admin", "cities": [3], "buildings": [14, 15], "sha256": "` + sha256Hex(hashedKey) + `"}
+]}`,
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			a, err := New(config.AuthConfig{Enabled: true, APIKeysFile: writeFile(t, name, []byte(content))})
+			require.NoError(t, err)
+
+			principal, err := a.Authenticate(apiKeyRequest(plainKey))
+			require.NoError(t, err)
+			assert.Equal(t, &Principal{Subject: "dashboard", Roles: []string{RoleViewer}, Method: MethodAPIKey}, principal)
+
+			principal, err = a.Authenticate(apiKeyRequest(hashedKey))
+			require.NoError(t, err)
+			assert.Equal(t, &Principal{
+				Subject: "perm-admin",
+				Roles:   []string{RoleFacilityAdmin},
+				Method:  MethodAPIKey,
+				Scope:   Scope{CityIDs: []uint{3}, BuildingIDs: []uint{14, 15}},
+			}, principal)
+
+			// The hash in the file is not a key by itself.
+			_, err = a.Authenticate(apiKeyRequest(sha256Hex(hashedKey)))
+			assert.ErrorIs(t, err, ErrInvalidCredentials)
+			_, err = a.Authenticate(apiKeyRequest("unknown-key-0123456789"))
+			assert.ErrorIs(t, err, ErrInvalidCredentials)
+			_, err = a.Authenticate(apiKeyRequest(""))
+			assert.ErrorIs(t, err, ErrNoCredentials)
+		})
+	}
+}
+
+func TestLoadAPIKeysErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "short plain key",
+			content: "keys:\n  - {name: a, role: viewer, key: short}\n",
+			wantErr: `key "a" is shorter than 16 characters`,
+		},
+		{
+			name:    "key and sha256",
+			content: "keys:\n  - {name: a, role: viewer, key: " + plainKey + ", sha256: " + sha256Hex(plainKey) + "}\n",
+			wantErr: `key "a" has both key and sha256`,
+		},
+		{
+			name:    "malformed sha256",
+			content: "keys:\n  - {name: a, role: viewer, sha256: abc}\n",
+			wantErr: `key "a" needs key or a hex sha256`,
+		},
+		{
+			name:    "neither key nor sha256",
+			content: "keys:\n  - {name: a, role: viewer}\n",
+			wantErr: `key "a" needs key or a hex sha256`,
+		},
+		{
+			name:    "unknown role",
+			content: "keys:\n  - {name: a, role: root, key: " + plainKey + "}\n",
+			wantErr: `key "a" has role "root"`,
+		},
+		{
+			name:    "no name",
+			content: "keys:\n  - {role: viewer, key: " + plainKey + "}\n",
+			wantErr: "key 1 has no name",
+		},
+		{
+			name:    "duplicate name",
+			content: "keys:\n  - {name: a, role: viewer, key: " + plainKey + "}\n  - {name: a, role: viewer, key: " + hashedKey + "}\n",
+			wantErr: `duplicate name "a"`,
+		},
+		{
+			name:    "same key in plain text and as sha256",
+			content: "keys:\n  - {name: a, role: viewer, key: " + plainKey + "}\n  - {name: b, role: device, sha256: " + sha256Hex(plainKey) + "}\n",
+			wantErr: `key "b" is the same as another key`,
+		},
+		{
+			name:    "city id 0",
+			content: "keys:\n  - {name: a, role: viewer, cities: [0], key: " + plainKey + "}\n",
+			wantErr: `key "a" has a city or building id 0`,
+		},
+		{
+			name:    "unknown field",
+			content: "keys:\n  - {name: a, role: viewer, secret: " + plainKey + "}\n",
+			wantErr: "unknown field",
+		},
+		{
+			name:    "unsupported format",
+			file:    "keys.txt",
+			content: "keys: []\n",
+			wantErr: `unsupported API keys format ".txt"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := tt.file
+			if file == "" {
+				file = "keys.yaml"
+			}
+			_, err := loadAPIKeys(writeFile(t, file, []byte(tt.content)))
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,187 @@
+// Package auth authenticates API clients by static API keys and JWT bearer
+// tokens and describes what they may do with roles and scopes.
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"web_backend_v2/config"
+)
+
+// Roles of API clients.
+const (
+	// RoleViewer may read cities, buildings, auditoriums, occupancy, cameras and alerts.
+	RoleViewer = "viewer"
+	// RoleFacilityAdmin may do everything a viewer may, change the campus
+	// directory and cameras, manage webhooks, acknowledge alerts and use /v1/admin.
+	RoleFacilityAdmin = "facility-admin"
+	// RoleDevice may post camera events.
+	RoleDevice = "device"
+)
+
+// Authentication methods of a Principal.
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+	// MethodNone is used for every request when authentication is disabled.
+	MethodNone = "none"
+)
+
+var (
+	// ErrNoCredentials means the request carries neither an API key nor a bearer token.
+	ErrNoCredentials = errors.New("missing credentials: send X-API-Key or Authorization: Bearer <token>")
+	// ErrInvalidCredentials means the API key is unknown or the token does not verify.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Principal is an authenticated API client.
+type Principal struct {
+	Subject string
+	Roles   []string
+	Method  string
+	Scope   Scope
+}
+
+// HasRole reports whether the principal has any of the roles.
+func (p *Principal) HasRole(roles ...string) bool {
+	for _, have := range p.Roles {
+		for _, want := range roles {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Scope limits a principal to some cities and buildings: it may access a
+// building when the building or its city is listed. The zero Scope is
+// unrestricted.
+type Scope struct {
+	CityIDs     []uint
+	BuildingIDs []uint
+}
+
+// Unrestricted reports whether the scope lists no cities and no buildings.
+func (s Scope) Unrestricted() bool {
+	return len(s.CityIDs) == 0 && len(s.BuildingIDs) == 0
+}
+
+// AllowsCity reports whether the whole city is in scope.
+func (s Scope) AllowsCity(cityID uint) bool {
+	return s.Unrestricted() || containsID(s.CityIDs, cityID)
+}
+
+// AllowsBuilding reports whether the building of the city is in scope.
+func (s Scope) AllowsBuilding(cityID, buildingID uint) bool {
+	return s.AllowsCity(cityID) || containsID(s.BuildingIDs, buildingID)
+}
+
+func containsID(ids []uint, id uint) bool {
+	for _, have := range ids {
+		if have == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Anonymous is the principal of every request when authentication is disabled.
+var Anonymous = &Principal{Subject: "anonymous", Roles: []string{RoleFacilityAdmin}, Method: MethodNone}
+
+// Authenticator checks the credentials of a request.
+type Authenticator struct {
+	keys *apiKeys
+	jwt  *jwtVerifier
+}
+
+// New loads the API keys and JWT keys named by cfg. It fails when no credentials
+// would be accepted at all.
+func New(cfg config.AuthConfig) (*Authenticator, error) {
+	a := &Authenticator{}
+	if cfg.APIKeysFile != "" {
+		keys, err := loadAPIKeys(cfg.APIKeysFile)
+		if err != nil {
+			return nil, err
+		}
+		a.keys = keys
+	}
+	if cfg.JWTSecret != "" || cfg.JWKSFile != "" {
+		v, err := newJWTVerifier(cfg)
+		if err != nil {
+			return nil, err
+		}
+		a.jwt = v
+	}
+	if a.keys == nil && a.jwt == nil {
+		return nil, fmt.Errorf("authentication is enabled but no credentials are configured: set AUTH_API_KEYS_FILE, AUTH_JWT_HS256_SECRET or AUTH_JWT_JWKS_FILE, or AUTH_ENABLED=false")
+	}
+	return a, nil
+}
+
+// AccessTokenParam is the query parameter that carries the JWT of stream
+// clients; see AcceptsQueryToken.
+const AccessTokenParam = "access_token"
+
+// Authenticate returns the principal of a request: an API key from X-API-Key
+// or a JWT from "Authorization: Bearer" (or ?access_token= where
+// AcceptsQueryToken allows it).
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		if a.keys == nil {
+			return nil, ErrInvalidCredentials
+		}
+		return a.keys.authenticate(key)
+	}
+	token := BearerToken(r)
+	if token == "" && AcceptsQueryToken(r) {
+		token = r.URL.Query().Get(AccessTokenParam)
+	}
+	if token == "" {
+		return nil, ErrNoCredentials
+	}
+	if a.jwt == nil || !IsJWT(token) {
+		return nil, ErrInvalidCredentials
+	}
+	return a.jwt.verify(token)
+}
+
+// Carries reports whether the request has credentials meant for this
+// authenticator (an API key or a JWT) rather than, say, a camera token.
+func Carries(r *http.Request) bool {
+	return r.Header.Get("X-API-Key") != "" || IsJWT(BearerToken(r))
+}
+
+// AcceptsQueryToken reports whether the request opens an occupancy stream
+// (SSE or WebSocket), whose EventSource and WebSocket clients cannot set
+// headers and may send the JWT in ?access_token= instead. Other routes do not
+// accept it, so that tokens stay out of URLs that are logged or cached.
+func AcceptsQueryToken(r *http.Request) bool {
+	return r.Method == http.MethodGet &&
+		(strings.HasSuffix(r.URL.Path, "/occupancy/stream") || r.URL.Path == "/v1/occupancy/ws")
+}
+
+// BearerToken returns the token of an "Authorization: Bearer" header or "".
+func BearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if len(auth) > len("Bearer ") && strings.EqualFold(auth[:len("Bearer ")], "Bearer ") {
+		return strings.TrimSpace(auth[len("Bearer "):])
+	}
+	return ""
+}
+
+// IsJWT reports whether a token has the three dot-separated parts of a JWT.
+func IsJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// validRole reports whether role is one of the known roles.
+func validRole(role string) bool {
+	switch role {
+	case RoleViewer, RoleFacilityAdmin, RoleDevice:
+		return true
+	}
+	return false
+}
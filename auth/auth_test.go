@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessTokenQuery(t *testing.T) {
+	cfg := testAuthConfig()
+	cfg.JWTSecret = testSecret
+	a, err := New(cfg)
+	require.NoError(t, err)
+	token := signHS256(t, testSecret, map[string]any{"alg": "HS256"}, testClaims(nil))
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		wantErr error
+	}{
+		{name: "occupancy stream", method: http.MethodGet, path: "/v1/cities/1/buildings/2/occupancy/stream"},
+		{name: "occupancy socket", method: http.MethodGet, path: "/v1/occupancy/ws"},
+		{name: "other GET route", method: http.MethodGet, path: "/v1/cities/", wantErr: ErrNoCredentials},
+		{name: "export", method: http.MethodGet, path: "/v1/cities/1/buildings/2/occupancy/export", wantErr: ErrNoCredentials},
+		{name: "POST to a stream path", method: http.MethodPost, path: "/v1/occupancy/ws", wantErr: ErrNoCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path+"?"+AccessTokenParam+"="+token, nil)
+			principal, err := a.Authenticate(r)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "alice", principal.Subject)
+		})
+	}
+}
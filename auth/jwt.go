@@ -0,0 +1,304 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"web_backend_v2/config"
+)
+
+// jwtLeeway is the clock skew tolerated when checking exp and nbf.
+const jwtLeeway = time.Minute
+
+// minRSAKeyBits is the smallest RSA modulus accepted in the JWKS file.
+const minRSAKeyBits = 2048
+
+// jwtVerifier verifies HS256 tokens with a shared secret and RS256 tokens with
+// the public keys of a local JWKS file.
+type jwtVerifier struct {
+	secret         []byte
+	rsaKeys        map[string]*rsa.PublicKey // by kid
+	issuer         string
+	audience       string
+	roleClaim      string
+	citiesClaim    string
+	buildingsClaim string
+}
+
+// jwks is the JSON Web Key Set format of AUTH_JWT_JWKS_FILE (RFC 7517).
+type jwks struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		Alg string `json:"alg"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+func newJWTVerifier(cfg config.AuthConfig) (*jwtVerifier, error) {
+	v := &jwtVerifier{
+		issuer:         cfg.JWTIssuer,
+		audience:       cfg.JWTAudience,
+		roleClaim:      cfg.JWTRoleClaim,
+		citiesClaim:    cfg.JWTCitiesClaim,
+		buildingsClaim: cfg.JWTBuildingsClaim,
+	}
+	if cfg.JWTSecret != "" {
+		v.secret = []byte(cfg.JWTSecret)
+	}
+	if cfg.JWKSFile != "" {
+		keys, err := loadJWKS(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		v.rsaKeys = keys
+	}
+	return v, nil
+}
+
+// loadJWKS reads the RSA signing keys of a JWKS file; keys of other types or
+// uses are skipped, keys shorter than minRSAKeyBits are rejected.
+func loadJWKS(path string) (map[string]*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS %s: %w", path, err)
+	}
+	var set jwks
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS %s: %w", path, err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") || (k.Alg != "" && k.Alg != "RS256") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWKS %s: key %q has a malformed modulus", path, k.Kid)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("invalid JWKS %s: key %q has a malformed exponent", path, k.Kid)
+		}
+		if _, ok := keys[k.Kid]; ok {
+			return nil, fmt.Errorf("invalid JWKS %s: duplicate kid %q", path, k.Kid)
+		}
+		key := &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+		if key.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("invalid JWKS %s: key %q has %d bits, at least %d are required", path, k.Kid, key.N.BitLen(), minRSAKeyBits)
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("invalid JWKS %s: no RSA signing keys", path)
+	}
+	return keys, nil
+}
+
+// verify checks the signature and the registered claims of a token and returns
+// its principal: the subject (sub) with the known roles of the role claim,
+// which is a string or an array of strings, limited to the cities and
+// buildings of the scope claims, if present.
+func (v *jwtVerifier) verify(token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidCredentials)
+	}
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: malformed token header", ErrInvalidCredentials)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed token signature", ErrInvalidCredentials)
+	}
+	if err := v.checkSignature(header, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]json.RawMessage
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: malformed token claims", ErrInvalidCredentials)
+	}
+	if err := v.checkClaims(claims, time.Now()); err != nil {
+		return nil, err
+	}
+
+	principal := &Principal{Method: MethodJWT}
+	if raw, ok := claims["sub"]; ok {
+		json.Unmarshal(raw, &principal.Subject)
+	}
+	for _, role := range stringOrList(claims[v.roleClaim]) {
+		if validRole(role) && !principal.HasRole(role) {
+			principal.Roles = append(principal.Roles, role)
+		}
+	}
+
+	// A scope claim that cannot be read must not leave the token unrestricted.
+	if principal.Scope.CityIDs, err = idList(claims[v.citiesClaim]); err != nil {
+		return nil, fmt.Errorf("%w: malformed %s claim", ErrInvalidCredentials, v.citiesClaim)
+	}
+	if principal.Scope.BuildingIDs, err = idList(claims[v.buildingsClaim]); err != nil {
+		return nil, fmt.Errorf("%w: malformed %s claim", ErrInvalidCredentials, v.buildingsClaim)
+	}
+	_, hasCities := claims[v.citiesClaim]
+	_, hasBuildings := claims[v.buildingsClaim]
+	if (hasCities || hasBuildings) && principal.Scope.Unrestricted() {
+		return nil, fmt.Errorf("%w: token scope lists no city or building", ErrInvalidCredentials)
+	}
+	return principal, nil
+}
+
+func (v *jwtVerifier) checkSignature(header jwtHeader, signed string, sig []byte) error {
+	switch header.Alg {
+	case "HS256":
+		if v.secret == nil {
+			return fmt.Errorf("%w: HS256 tokens are not accepted", ErrInvalidCredentials)
+		}
+		mac := hmac.New(sha256.New, v.secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return fmt.Errorf("%w: bad token signature", ErrInvalidCredentials)
+		}
+	case "RS256":
+		key, ok := v.rsaKeys[header.Kid]
+		if !ok && header.Kid == "" && len(v.rsaKeys) == 1 {
+			for _, only := range v.rsaKeys {
+				key, ok = only, true
+			}
+		}
+		if !ok {
+			return fmt.Errorf("%w: unknown token key %q", ErrInvalidCredentials, header.Kid)
+		}
+		digest := sha256.Sum256([]byte(signed))
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+			return fmt.Errorf("%w: bad token signature", ErrInvalidCredentials)
+		}
+	default:
+		return fmt.Errorf("%w: unsupported token algorithm %q", ErrInvalidCredentials, header.Alg)
+	}
+	return nil
+}
+
+// checkClaims requires exp and checks nbf, and iss and aud when configured.
+func (v *jwtVerifier) checkClaims(claims map[string]json.RawMessage, now time.Time) error {
+	exp, ok := numericDate(claims["exp"])
+	if !ok {
+		return fmt.Errorf("%w: token has no exp", ErrInvalidCredentials)
+	}
+	if now.After(exp.Add(jwtLeeway)) {
+		return fmt.Errorf("%w: token expired", ErrInvalidCredentials)
+	}
+	if nbf, ok := numericDate(claims["nbf"]); ok && now.Add(jwtLeeway).Before(nbf) {
+		return fmt.Errorf("%w: token is not valid yet", ErrInvalidCredentials)
+	}
+	if v.issuer != "" {
+		var iss string
+		json.Unmarshal(claims["iss"], &iss)
+		if iss != v.issuer {
+			return fmt.Errorf("%w: wrong token issuer", ErrInvalidCredentials)
+		}
+	}
+	if v.audience != "" {
+		found := false
+		for _, aud := range stringOrList(claims["aud"]) {
+			found = found || aud == v.audience
+		}
+		if !found {
+			return fmt.Errorf("%w: wrong token audience", ErrInvalidCredentials)
+		}
+	}
+	return nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// numericDate parses a NumericDate claim (seconds since the epoch).
+func numericDate(raw json.RawMessage) (time.Time, bool) {
+	var n json.Number
+	if len(raw) == 0 || json.Unmarshal(raw, &n) != nil {
+		return time.Time{}, false
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(f), 0), true
+}
+
+// idList reads a claim that is an id or an array of ids, as numbers or
+// numeric strings; ids must be positive.
+func idList(raw json.RawMessage) ([]uint, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var list []interface{}
+	if json.Unmarshal(raw, &list) != nil {
+		var one interface{}
+		if err := json.Unmarshal(raw, &one); err != nil {
+			return nil, err
+		}
+		list = []interface{}{one}
+	}
+	ids := make([]uint, 0, len(list))
+	for _, v := range list {
+		var s string
+		switch v := v.(type) {
+		case float64:
+			s = strconv.FormatFloat(v, 'f', -1, 64)
+		case string:
+			s = v
+		default:
+			return nil, fmt.Errorf("id %v is not a number", v)
+		}
+		id, err := strconv.ParseUint(s, 10, 32)
+		if err != nil || id == 0 {
+			return nil, fmt.Errorf("id %q is not a positive integer", s)
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
+
+// stringOrList reads a claim that is a string or an array of strings.
+func stringOrList(raw json.RawMessage) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+	var one string
+	if json.Unmarshal(raw, &one) == nil {
+		return []string{one}
+	}
+	var list []string
+	json.Unmarshal(raw, &list)
+	return list
+}
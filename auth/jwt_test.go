@@ -0,0 +1,381 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"web_backend_v2/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testSecret   = "0123456789abcdef0123456789abcdef"
+	testIssuer   = "campus-sso"
+	testAudience = "occupancy-api"
+)
+
+func testAuthConfig() config.AuthConfig {
+	return config.AuthConfig{
+		Enabled:           true,
+		JWTIssuer:         testIssuer,
+		JWTAudience:       testAudience,
+		JWTRoleClaim:      "role",
+		JWTCitiesClaim:    "city_ids",
+		JWTBuildingsClaim: "building_ids",
+	}
+}
+
+// testClaims are valid claims for testAuthConfig with the changes applied; a
+// nil change removes the claim.
+func testClaims(changes map[string]any) map[string]any {
+	now := time.Now()
+	claims := map[string]any{
+		"sub":  "alice",
+		"role": RoleViewer,
+		"iss":  testIssuer,
+		"aud":  testAudience,
+		"iat":  now.Unix(),
+		"exp":  now.Add(time.Hour).Unix(),
+	}
+	for name, value := range changes {
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+	}
+	return claims
+}
+
+func encodeSegment(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func signHS256(t *testing.T, secret string, header, claims map[string]any) string {
+	t.Helper()
+	signed := encodeSegment(t, header) + "." + encodeSegment(t, claims)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+	header := map[string]any{"alg": "RS256", "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	signed := encodeSegment(t, header) + "." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func jwk(kid string, key *rsa.PublicKey) map[string]any {
+	return map[string]any{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"alg": "RS256",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+// writeFile writes data to name in a temporary directory and returns its path.
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func writeJWKS(t *testing.T, keys ...map[string]any) string {
+	t.Helper()
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	require.NoError(t, err)
+	return writeFile(t, "jwks.json", data)
+}
+
+func generateKey(t *testing.T, bits int) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	require.NoError(t, err)
+	return key
+}
+
+func bearerRequest(token string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/v1/cities/", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	return r
+}
+
+func TestJWTHS256(t *testing.T) {
+	cfg := testAuthConfig()
+	cfg.JWTSecret = testSecret
+	a, err := New(cfg)
+	require.NoError(t, err)
+
+	hs256 := map[string]any{"alg": "HS256", "typ": "JWT"}
+	now := time.Now()
+	tests := []struct {
+		name    string
+		token   string
+		wantErr string
+		want    *Principal
+	}{
+		{
+			name:  "valid",
+			token: signHS256(t, testSecret, hs256, testClaims(nil)),
+			want:  &Principal{Subject: "alice", Roles: []string{RoleViewer}, Method: MethodJWT},
+		},
+		{
+			name:    "bad signature",
+			token:   signHS256(t, "another-secret-another-secret-00", hs256, testClaims(nil)),
+			wantErr: "bad token signature",
+		},
+		{
+			name:    "alg none",
+			token:   encodeSegment(t, map[string]any{"alg": "none"}) + "." + encodeSegment(t, testClaims(nil)) + ".",
+			wantErr: `unsupported token algorithm "none"`,
+		},
+		{
+			name:    "alg in lower case",
+			token:   signHS256(t, testSecret, map[string]any{"alg": "hs256"}, testClaims(nil)),
+			wantErr: "unsupported token algorithm",
+		},
+		{
+			name:    "RS256 without a JWKS",
+			token:   signHS256(t, testSecret, map[string]any{"alg": "RS256"}, testClaims(nil)),
+			wantErr: "unknown token key",
+		},
+		{
+			name:    "no exp",
+			token:   signHS256(t, testSecret, hs256, testClaims(map[string]any{"exp": nil})),
+			wantErr: "token has no exp",
+		},
+		{
+			name:    "expired",
+			token:   signHS256(t, testSecret, hs256, testClaims(map[string]any{"exp": now.Add(-2 * time.Minute).Unix()})),
+			wantErr: "token expired",
+		},
+		{
+			name:  "expired within the leeway",
+			token: signHS256(t, testSecret, hs256, testClaims(map[string]any{"exp": now.Add(-30 * time.Second).Unix()})),
+			want:  &Principal{Subject: "alice", Roles: []string{RoleViewer}, Method: MethodJWT},
+		},
+		{
+			name:    "not valid yet",
+			token:   signHS256(t, testSecret, hs256, testClaims(map[string]any{"nbf": now.Add(2 * time.Minute).Unix()})),
+			wantErr: "token is not valid yet",
+		},
+		{
+			name:  "nbf within the leeway",
+			token: signHS256(t, testSecret, hs256, testClaims(map[string]any{"nbf": now.Add(30 * time.Second).Unix()})),
+			want:  &Principal{Subject: "alice", Roles: []string{RoleViewer}, Method: MethodJWT},
+		},
+		{
+			name:    "wrong issuer",
+			token:   signHS256(t, testSecret, hs256, testClaims(map[string]any{"iss": "other-sso"})),
+			wantErr: "wrong token issuer",
+		},
+		{
+			name:    "no issuer",
+			token:   signHS256(t, testSecret, hs256, testClaims(map[string]any{"iss": nil})),
+			wantErr: "wrong token issuer",
+		},
+		{
+			name:    "wrong audience",
+			token:   signHS256(t, testSecret, hs256, testClaims(map[string]any{"aud": "billing-api"})),
+			wantErr: "wrong token audience",
+		},
+		{
+			name:  "audience in a list",
+			token: signHS256(t, testSecret, hs256, testClaims(map[string]any{"aud": []string{"billing-api", testAudience}})),
+			want:  &Principal{Subject: "alice", Roles: []string{RoleViewer}, Method: MethodJWT},
+		},
+		{
+			name:  "unknown role",
+			token: signHS256(t, testSecret, hs256, testClaims(map[string]any{"role": "root"})),
+			want:  &Principal{Subject: "alice", Method: MethodJWT},
+		},
+		{
+			name:  "role that is not a string",
+			token: signHS256(t, testSecret, hs256, testClaims(map[string]any{"role": 1})),
+			want:  &Principal{Subject: "alice", Method: MethodJWT},
+		},
+		{
+			name:  "role list with unknown and repeated roles",
+			token: signHS256(t, testSecret, hs256, testClaims(map[string]any{"role": []string{"viewer", "root", "device", "viewer"}})),
+			want:  &Principal{Subject: "alice", Roles: []string{RoleViewer, RoleDevice}, Method: MethodJWT},
+		},
+		{
+			name:  "city and building ids as numbers and strings",
+			token: signHS256(t, testSecret, hs256, testClaims(map[string]any{"city_ids": []any{3, "4"}, "building_ids": "15"})),
+			want: &Principal{Subject: "alice", Roles: []string{RoleViewer}, Method: MethodJWT,
+				Scope: Scope{CityIDs: []uint{3, 4}, BuildingIDs: []uint{15}}},
+		},
+		{
+			name:  "single building id",
+			token: signHS256(t, testSecret, hs256, testClaims(map[string]any{"building_ids": 16})),
+			want: &Principal{Subject: "alice", Roles: []string{RoleViewer}, Method: MethodJWT,
+				Scope: Scope{BuildingIDs: []uint{16}}},
+		},
+		{
+			name:    "empty scope",
+			token:   signHS256(t, testSecret, hs256, testClaims(map[string]any{"city_ids": []any{}})),
+			wantErr: "token scope lists no city or building",
+		},
+		{
+			name:    "non-numeric city id",
+			token:   signHS256(t, testSecret, hs256, testClaims(map[string]any{"city_ids": []any{"moscow"}})),
+			wantErr: "malformed city_ids claim",
+		},
+		{
+			name:    "city id 0",
+			token:   signHS256(t, testSecret, hs256, testClaims(map[string]any{"city_ids": []any{0}})),
+			wantErr: "malformed city_ids claim",
+		},
+		{
+			name:    "fractional building id",
+			token:   signHS256(t, testSecret, hs256, testClaims(map[string]any{"building_ids": 1.5})),
+			wantErr: "malformed building_ids claim",
+		},
+		{
+			name:    "building ids as an object",
+			token:   signHS256(t, testSecret, hs256, testClaims(map[string]any{"building_ids": map[string]any{"id": 1}})),
+			wantErr: "malformed building_ids claim",
+		},
+		{
+			name:    "malformed token",
+			token:   "a.b.c",
+			wantErr: "malformed token header",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := a.Authenticate(bearerRequest(tt.token))
+			if tt.wantErr != "" {
+				assert.ErrorIs(t, err, ErrInvalidCredentials)
+				assert.ErrorContains(t, err, tt.wantErr)
+				assert.Nil(t, principal)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, principal)
+		})
+	}
+}
+
+func TestJWTRS256(t *testing.T) {
+	first, second, unknown := generateKey(t, 2048), generateKey(t, 2048), generateKey(t, 2048)
+	cfg := testAuthConfig()
+	cfg.JWKSFile = writeJWKS(t, jwk("k1", &first.PublicKey), jwk("k2", &second.PublicKey))
+	a, err := New(cfg)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr string
+	}{
+		{name: "first key", token: signRS256(t, first, "k1", testClaims(nil))},
+		{name: "second key", token: signRS256(t, second, "k2", testClaims(nil))},
+		{name: "kid of another key", token: signRS256(t, second, "k1", testClaims(nil)), wantErr: "bad token signature"},
+		{name: "key not in the JWKS", token: signRS256(t, unknown, "k1", testClaims(nil)), wantErr: "bad token signature"},
+		{name: "unknown kid", token: signRS256(t, first, "k3", testClaims(nil)), wantErr: `unknown token key "k3"`},
+		{name: "no kid with several keys", token: signRS256(t, first, "", testClaims(nil)), wantErr: `unknown token key ""`},
+		{
+			name:    "HS256 without a secret",
+			token:   signHS256(t, testSecret, map[string]any{"alg": "HS256", "kid": "k1"}, testClaims(nil)),
+			wantErr: "HS256 tokens are not accepted",
+		},
+		{
+			name:    "alg none",
+			token:   encodeSegment(t, map[string]any{"alg": "none", "kid": "k1"}) + "." + encodeSegment(t, testClaims(nil)) + ".",
+			wantErr: "unsupported token algorithm",
+		},
+		{
+			name:    "expired",
+			token:   signRS256(t, first, "k1", testClaims(map[string]any{"exp": time.Now().Add(-time.Hour).Unix()})),
+			wantErr: "token expired",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := a.Authenticate(bearerRequest(tt.token))
+			if tt.wantErr != "" {
+				assert.ErrorIs(t, err, ErrInvalidCredentials)
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "alice", principal.Subject)
+		})
+	}
+
+	t.Run("no kid with a single key", func(t *testing.T) {
+		cfg := testAuthConfig()
+		cfg.JWKSFile = writeJWKS(t, jwk("only", &first.PublicKey))
+		a, err := New(cfg)
+		require.NoError(t, err)
+		_, err = a.Authenticate(bearerRequest(signRS256(t, first, "", testClaims(nil))))
+		assert.NoError(t, err)
+	})
+}
+
+func TestLoadJWKS(t *testing.T) {
+	key := generateKey(t, 2048)
+	short := generateKey(t, 1024)
+	ec := map[string]any{"kty": "EC", "kid": "ec", "crv": "P-256", "x": "AA", "y": "AA"}
+	encryption := jwk("enc", &key.PublicKey)
+	encryption["use"] = "enc"
+	badModulus := jwk("bad", &key.PublicKey)
+	badModulus["n"] = "not base64!"
+
+	tests := []struct {
+		name     string
+		keys     []map[string]any
+		wantKids []string
+		wantErr  string
+	}{
+		{name: "other key types and uses are skipped", keys: []map[string]any{ec, encryption, jwk("sig", &key.PublicKey)}, wantKids: []string{"sig"}},
+		{name: "short RSA key", keys: []map[string]any{jwk("short", &short.PublicKey)}, wantErr: `key "short" has 1024 bits, at least 2048 are required`},
+		{name: "short key next to a good one", keys: []map[string]any{jwk("good", &key.PublicKey), jwk("short", &short.PublicKey)}, wantErr: "at least 2048"},
+		{name: "duplicate kid", keys: []map[string]any{jwk("k", &key.PublicKey), jwk("k", &key.PublicKey)}, wantErr: `duplicate kid "k"`},
+		{name: "malformed modulus", keys: []map[string]any{badModulus}, wantErr: "malformed modulus"},
+		{name: "no signing keys", keys: []map[string]any{ec, encryption}, wantErr: "no RSA signing keys"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := loadJWKS(writeJWKS(t, tt.keys...))
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			var kids []string
+			for kid := range keys {
+				kids = append(kids, kid)
+			}
+			assert.ElementsMatch(t, tt.wantKids, kids)
+		})
+	}
+}
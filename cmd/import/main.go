@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"web_backend_v2/config"
+	"web_backend_v2/db"
+	"web_backend_v2/forms"
+	"web_backend_v2/models"
+)
+
+func main() {
+	cityID := flag.Uint("city", 0, "id of the city to import into")
+	kind := flag.String("kind", "", "what the CSV contains: buildings or auditoriums")
+	file := flag.String("file", "", "CSV file to import")
+	dryRun := flag.Bool("dry-run", false, "validate and report changes without writing them")
+	flag.Parse()
+	if *cityID == 0 || *file == "" || (*kind != "buildings" && *kind != "auditoriums") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	result := importFile(uint(*cityID), *kind, *file, *dryRun)
+
+	for _, e := range result.Errors {
+		if e.Column != "" {
+			fmt.Printf("row %d, %s: %s\n", e.Row, e.Column, e.Error)
+		} else {
+			fmt.Printf("row %d: %s\n", e.Row, e.Error)
+		}
+	}
+	switch {
+	case result.Applied:
+		fmt.Printf("%d rows: %d created, %d updated, %d unchanged\n",
+			result.Rows, result.Created, result.Updated, result.Unchanged)
+	case len(result.Errors) > 0:
+		fmt.Printf("%d rows: %d to create, %d to update, %d unchanged (not applied, fix the errors above)\n",
+			result.Rows, result.Created, result.Updated, result.Unchanged)
+	default:
+		fmt.Printf("%d rows: %d to create, %d to update, %d unchanged (dry run, nothing written)\n",
+			result.Rows, result.Created, result.Updated, result.Unchanged)
+	}
+	if len(result.Errors) > 0 {
+		os.Exit(1)
+	}
+}
+
+// importFile imports the CSV into the city. The database is closed when it
+// returns, before main decides on the exit status.
+func importFile(cityID uint, kind, file string, dryRun bool) *forms.ImportResult {
+	f, err := os.Open(file)
+	if err != nil {
+		log.Fatalf("open %s: %v", file, err)
+	}
+	defer f.Close()
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("load config: %v", err)
+	}
+	if err := db.InitDB(cfg, true); err != nil {
+		log.Fatalf("init db: %v", err)
+	}
+	defer func() {
+		if err := db.CloseDB(); err != nil {
+			log.Printf("close db: %v", err)
+		}
+	}()
+
+	importer := new(models.ImportModel)
+	var result *forms.ImportResult
+	if kind == "buildings" {
+		imp, err := forms.ParseBuildingsCSV(f)
+		if err != nil {
+			log.Fatalf("parse %s: %v", file, err)
+		}
+		result, err = importer.ImportBuildings(cityID, imp, dryRun)
+		if err != nil {
+			log.Fatalf("import %s: %v", file, err)
+		}
+	} else {
+		imp, err := forms.ParseAuditoriumsCSV(f)
+		if err != nil {
+			log.Fatalf("parse %s: %v", file, err)
+		}
+		result, err = importer.ImportAuditoriums(cityID, imp, dryRun)
+		if err != nil {
+			log.Fatalf("import %s: %v", file, err)
+		}
+	}
+	return result
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"web_backend_v2/config"
+	"web_backend_v2/db"
+	"web_backend_v2/forms"
+	"web_backend_v2/models"
+)
+
+func main() {
+	file := flag.String("file", "", "fixture to load (.json, .yaml or .yml), e.g. fixtures/campus.yaml")
+	flag.Parse()
+	if *file == "" {
+		log.Fatal("-file is required")
+	}
+
+	// Fail on a bad fixture before touching the database.
+	fixture, err := forms.LoadSeedFixture(*file)
+	if err != nil {
+		log.Fatalf("load fixture: %v", err)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("load config: %v", err)
+	}
+
+	// Seeding needs the current schema, so pending migrations are applied first.
+	if err := db.InitDB(cfg, false); err != nil {
+		log.Fatalf("init db: %v", err)
+	}
+	defer func() {
+		if err := db.CloseDB(); err != nil {
+			log.Printf("close db: %v", err)
+		}
+	}()
+
+	result, err := models.Seed(fixture)
+	if err != nil {
+		log.Fatalf("seed %s: %v", *file, err)
+	}
+
+	log.Printf("seeded %s: cities %d created, %d updated; buildings %d created, %d updated; auditoriums %d created, %d updated; cameras %d created, %d attached",
+		*file,
+		result.CitiesCreated, result.CitiesUpdated,
+		result.BuildingsCreated, result.BuildingsUpdated,
+		result.AuditoriumsCreated, result.AuditoriumsUpdated,
+		result.CamerasCreated, result.CamerasAttached)
+}
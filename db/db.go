@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"time"
@@ -61,11 +62,12 @@ func InitDB(cfg *config.Config, initMigrations bool) error {
 	}
 
 	if !initMigrations {
-		// Run migrations from SQL file
-		migrationPath := "migrations/migration.sql"
-		if err := RunMigrations(sqlDB, migrationPath); err != nil {
+		// Apply pending migrations from MigrationsDir
+		applied, err := NewMigrator(sqlDB, MigrationsDir).Up(context.Background())
+		if err != nil {
 			return fmt.Errorf("failed to run migrations: %w", err)
 		}
+		log.Printf("Schema is up to date (%d migrations applied)", len(applied))
 	}
 
 	return nil
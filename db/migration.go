@@ -1,191 +1,318 @@
 package db
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"regexp"
-	"strings"
+	"sort"
+	"strconv"
+	"time"
 )
 
-// RunMigrations reads a SQL migration file and executes all statements against the database
-// It handles multi-statement scripts, comments, and DO $$ blocks correctly
-// The migration is idempotent (safe to run multiple times)
-func RunMigrations(db *sql.DB, filePath string) error {
-	log.Printf("Reading migration file: %s", filePath)
-
-	// Read the entire SQL file
-	sqlBytes, err := os.ReadFile(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to read migration file %s: %w", filePath, err)
-	}
-
-	sqlContent := string(sqlBytes)
-	log.Printf("Migration file read successfully (%d bytes)", len(sqlBytes))
+// MigrationsDir is where the service looks for migration files.
+const MigrationsDir = "migrations"
+
+// migrationLockKey is the pg_advisory_lock key held while migrating, so that
+// replicas starting at the same time apply migrations one after another.
+const migrationLockKey int64 = 0x6d6967726174 // "migrat"
+
+// migrationFileRegex matches NNNN_name.up.sql and NNNN_name.down.sql.
+var migrationFileRegex = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is one numbered schema change. Each file is executed as a whole
+// (several statements are fine) inside a transaction, so statements that cannot
+// run in a transaction, such as CREATE INDEX CONCURRENTLY, are not supported.
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // SHA-256 of Up
+}
 
-	// Remove comments before processing
-	sqlContent = removeComments(sqlContent)
+// MigrationStatus is a migration found on disk and/or in schema_migrations.
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+	// Modified is set when the applied checksum differs from the file.
+	Modified bool
+	// Missing is set when the migration is applied but its file is gone.
+	Missing bool
+}
 
-	// Split SQL into individual statements
-	statements := splitSQLStatements(sqlContent)
+type appliedMigration struct {
+	Version   int64
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
 
-	if len(statements) == 0 {
-		log.Println("No SQL statements found in migration file")
-		return nil
+// LoadMigrations reads the migration files of dir ordered by version.
+// Every version needs an up file; the down file is optional.
+func LoadMigrations(dir string) ([]Migration, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations dir %s: %w", dir, err)
 	}
 
-	log.Printf("Found %d SQL statements to execute", len(statements))
-
-	// Execute each statement
-	for i, stmt := range statements {
-		stmt = strings.TrimSpace(stmt)
-		if stmt == "" {
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		m := migrationFileRegex.FindStringSubmatch(entry.Name())
+		if m == nil {
 			continue
 		}
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration file %s: %w", entry.Name(), err)
+		}
 
-		// Log statement preview (first 100 chars)
-		preview := stmt
-		if len(preview) > 100 {
-			preview = preview[:100] + "..."
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = migration
+		} else if migration.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, m[2])
+		}
+		if m[3] == "up" {
+			migration.Up = string(content)
+			sum := sha256.Sum256(content)
+			migration.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			migration.Down = string(content)
 		}
-		log.Printf("Executing statement %d/%d: %s", i+1, len(statements), preview)
+	}
 
-		// Execute the statement
-		if _, err := db.Exec(stmt); err != nil {
-			return fmt.Errorf("failed to execute statement %d/%d: %w\nStatement: %s", i+1, len(statements), err, preview)
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Checksum == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", migration.Version, migration.Name)
 		}
+		migrations = append(migrations, *migration)
 	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
 
-	log.Println("All migration statements executed successfully")
-	return nil
+// Migrator applies and rolls back the migrations of a directory and records
+// them in schema_migrations.
+type Migrator struct {
+	db  *sql.DB
+	dir string
 }
 
-// removeComments removes SQL comments from the content
-// Handles both -- single-line comments and /* */ multi-line comments
-func removeComments(content string) string {
-	// Remove /* */ style comments (multi-line)
-	// This regex handles nested comments and comments spanning multiple lines
-	multiLineCommentRegex := regexp.MustCompile(`/\*[\s\S]*?\*/`)
-	content = multiLineCommentRegex.ReplaceAllString(content, "")
-
-	// Remove -- style comments (single-line)
-	// Split by newlines, process each line
-	lines := strings.Split(content, "\n")
-	var cleanedLines []string
-
-	for _, line := range lines {
-		// Find -- that's not inside a string literal
-		// Simple approach: find first -- that's not inside quotes
-		inSingleQuote := false
-		inDoubleQuote := false
-		commentStart := -1
-
-		for i := 0; i < len(line); i++ {
-			char := line[i]
-			prevChar := byte(0)
-			if i > 0 {
-				prevChar = line[i-1]
-			}
+// NewMigrator creates a migrator for the migration files in dir.
+func NewMigrator(db *sql.DB, dir string) *Migrator {
+	return &Migrator{db: db, dir: dir}
+}
 
-			// Handle escaped quotes
-			if prevChar == '\\' {
+// Up applies all pending migrations in version order, each in its own
+// transaction, and returns the applied ones. It refuses to run when an applied
+// migration was modified or removed.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn, migrations []Migration, applied map[int64]appliedMigration) error {
+		if err := checkApplied(migrations, applied); err != nil {
+			return err
+		}
+		for _, migration := range migrations {
+			if _, ok := applied[migration.Version]; ok {
 				continue
 			}
-
-			if char == '\'' && !inDoubleQuote {
-				inSingleQuote = !inSingleQuote
-			} else if char == '"' && !inSingleQuote {
-				inDoubleQuote = !inDoubleQuote
-			} else if char == '-' && i < len(line)-1 && line[i+1] == '-' && !inSingleQuote && !inDoubleQuote {
-				commentStart = i
-				break
+			log.Printf("Applying migration %d_%s", migration.Version, migration.Name)
+			if err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, migration.Up); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx,
+					`INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`,
+					migration.Version, migration.Name, migration.Checksum)
+				return err
+			}); err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
 			}
+			done = append(done, migration)
 		}
+		return nil
+	})
+	return done, err
+}
 
-		if commentStart >= 0 {
-			line = line[:commentStart]
+// Down rolls back the last steps applied migrations, newest first, and returns
+// the rolled back ones.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn, migrations []Migration, applied map[int64]appliedMigration) error {
+		files := make(map[int64]Migration, len(migrations))
+		for _, migration := range migrations {
+			files[migration.Version] = migration
 		}
+		versions := make([]int64, 0, len(applied))
+		for version := range applied {
+			versions = append(versions, version)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
 
-		cleanedLines = append(cleanedLines, line)
-	}
-
-	return strings.Join(cleanedLines, "\n")
-}
-
-// splitSQLStatements splits SQL content into individual statements
-// Handles DO $$ blocks correctly (they contain semicolons but shouldn't be split)
-func splitSQLStatements(content string) []string {
-	var statements []string
-	var current strings.Builder
-	inDoBlock := false
-	doBlockDelimiter := ""
-
-	// Process character by character to handle DO blocks correctly
-	contentRunes := []rune(content)
-	i := 0
-
-	for i < len(contentRunes) {
-		char := contentRunes[i]
-
-		// Check for DO block start
-		if !inDoBlock && i+2 < len(contentRunes) {
-			remaining := string(contentRunes[i:])
-			if len(remaining) > 10 && strings.HasPrefix(strings.ToUpper(remaining), "DO") {
-				// Check if followed by whitespace and delimiter
-				doBlockRegex := regexp.MustCompile(`(?i)^DO\s+(\$\$|\$[a-zA-Z_][a-zA-Z0-9_]*\$|\$\w+\$)`)
-				matches := doBlockRegex.FindStringSubmatch(remaining)
-				if len(matches) > 1 {
-					inDoBlock = true
-					doBlockDelimiter = matches[1]
-					// Write "DO " and delimiter to current
-					current.WriteString(matches[0])
-					i += len(matches[0])
-					continue
+		for _, version := range versions {
+			if len(done) == steps {
+				break
+			}
+			migration, ok := files[version]
+			if !ok {
+				return fmt.Errorf("migration %d_%s is applied but its files are missing", version, applied[version].Name)
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down file", version, migration.Name)
+			}
+			log.Printf("Rolling back migration %d_%s", migration.Version, migration.Name)
+			if err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, migration.Down); err != nil {
+					return err
 				}
+				_, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, version)
+				return err
+			}); err != nil {
+				return fmt.Errorf("rollback of migration %d_%s failed: %w", migration.Version, migration.Name, err)
 			}
+			done = append(done, migration)
 		}
+		return nil
+	})
+	return done, err
+}
 
-		// Check for DO block end
-		if inDoBlock {
-			remaining := string(contentRunes[i:])
-			// Look for delimiter followed by semicolon (with optional whitespace)
-			delimiterPattern := regexp.QuoteMeta(doBlockDelimiter) + `\s*;`
-			delimiterRegex := regexp.MustCompile(`^` + delimiterPattern)
-			if delimiterRegex.MatchString(remaining) {
-				// Found end of DO block
-				match := delimiterRegex.FindString(remaining)
-				current.WriteString(match)
-				i += len(match)
-				inDoBlock = false
-				doBlockDelimiter = ""
-				// Continue to check if this ends a statement
-				continue
+// Status lists migrations from disk and schema_migrations ordered by version.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.locked(ctx, func(conn *sql.Conn, migrations []Migration, applied map[int64]appliedMigration) error {
+		for _, migration := range migrations {
+			status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+			if a, ok := applied[migration.Version]; ok {
+				appliedAt := a.AppliedAt
+				status.Applied = true
+				status.AppliedAt = &appliedAt
+				status.Modified = a.Checksum != migration.Checksum
+				delete(applied, migration.Version)
 			}
+			statuses = append(statuses, status)
+		}
+		for _, a := range applied {
+			appliedAt := a.AppliedAt
+			statuses = append(statuses, MigrationStatus{
+				Version:   a.Version,
+				Name:      a.Name,
+				Applied:   true,
+				AppliedAt: &appliedAt,
+				Missing:   true,
+			})
 		}
+		return nil
+	})
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, err
+}
 
-		// Write current character
-		current.WriteRune(char)
+// locked loads the migration files, takes the advisory lock on a dedicated
+// connection, makes sure schema_migrations exists and runs fn with the applied
+// migrations.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn, migrations []Migration, applied map[int64]appliedMigration) error) error {
+	migrations, err := LoadMigrations(m.dir)
+	if err != nil {
+		return err
+	}
 
-		// Check for statement end (semicolon) only if not in DO block
-		if !inDoBlock && char == ';' {
-			// Check if this semicolon is not inside a string
-			stmt := strings.TrimSpace(current.String())
-			if stmt != "" && stmt != ";" {
-				statements = append(statements, stmt)
-			}
-			current.Reset()
-		}
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get a database connection: %w", err)
+	}
+	defer conn.Close()
 
-		i++
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+		return fmt.Errorf("failed to take migration lock: %w", err)
+	}
+	defer func() {
+		// A fresh context: the lock must be released even if ctx is cancelled.
+		if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockKey); err != nil {
+			log.Printf("failed to release migration lock: %v", err)
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			checksum CHAR(64) NOT NULL,
+			applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+		)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
 	}
 
-	// Add any remaining statement
-	stmt := strings.TrimSpace(current.String())
-	if stmt != "" && stmt != ";" {
-		statements = append(statements, stmt)
+	rows, err := conn.QueryContext(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+	applied := make(map[int64]appliedMigration)
+	for rows.Next() {
+		var a appliedMigration
+		if err := rows.Scan(&a.Version, &a.Name, &a.Checksum, &a.AppliedAt); err != nil {
+			return fmt.Errorf("failed to read schema_migrations: %w", err)
+		}
+		applied[a.Version] = a
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read schema_migrations: %w", err)
 	}
+	rows.Close()
 
-	return statements
+	return fn(conn, migrations, applied)
+}
+
+// checkApplied fails when an applied migration was modified or its file removed.
+func checkApplied(migrations []Migration, applied map[int64]appliedMigration) error {
+	files := make(map[int64]Migration, len(migrations))
+	for _, migration := range migrations {
+		files[migration.Version] = migration
+	}
+	for version, a := range applied {
+		migration, ok := files[version]
+		if !ok {
+			return fmt.Errorf("migration %d_%s is applied but its files are missing", version, a.Name)
+		}
+		if migration.Checksum != a.Checksum {
+			return fmt.Errorf("migration %d_%s was modified after it was applied (checksum %s, file %s); add a new migration instead",
+				version, migration.Name, a.Checksum, migration.Checksum)
+		}
+	}
+	return nil
 }
 
+// inTx runs fn in a transaction on conn.
+func inTx(ctx context.Context, conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			log.Printf("rollback failed: %v", rbErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,315 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+	"web_backend_v2/forms"
+)
+
+// memoryRequeueDelay keeps a failing handler (e.g. database down) from spinning.
+const memoryRequeueDelay = 100 * time.Millisecond
+
+// ErrBrokerClosed is returned by MemoryBroker.Publish after Close.
+var ErrBrokerClosed = errors.New("memory broker is closed")
+
+// memoryMessage is an event queued in a MemoryBroker.
+type memoryMessage struct {
+	id   string
+	body []byte
+}
+
+// MemoryBroker is an in-process EventSource backed by a buffered channel, for
+// local development and tests without RabbitMQ. It settles deliveries exactly like
+// the AMQP consumer but keeps nothing across restarts, dead letters included.
+type MemoryBroker struct {
+	queue       chan memoryMessage
+	workers     int
+	batchSize   int
+	batchWindow time.Duration
+
+	mu     sync.Mutex
+	closed bool
+	dead   []forms.DeadLetter
+	status Status
+	stats  *Counters
+}
+
+var _ EventSource = (*MemoryBroker)(nil)
+
+// NewMemoryBroker creates a broker holding up to capacity queued events.
+func NewMemoryBroker(capacity, workers, batchSize int, batchWindow time.Duration) *MemoryBroker {
+	if workers < 1 {
+		workers = 1
+	}
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	return &MemoryBroker{
+		queue:       make(chan memoryMessage, capacity),
+		workers:     workers,
+		batchSize:   batchSize,
+		batchWindow: batchWindow,
+		status:      Status{Kind: KindMemory, State: StateConnecting, Since: time.Now().UTC()},
+		stats:       NewCounters(workers),
+	}
+}
+
+// Publish enqueues an event body; it blocks while the queue is full.
+func (b *MemoryBroker) Publish(ctx context.Context, body []byte) error {
+	b.mu.Lock()
+	closed := b.closed
+	b.mu.Unlock()
+	if closed {
+		return ErrBrokerClosed
+	}
+
+	select {
+	case b.queue <- memoryMessage{id: NewMessageID(), body: body}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Run hands queued events to handler one at a time per worker.
+func (b *MemoryBroker) Run(ctx context.Context, handler Handler) error {
+	return b.run(ctx, func(ctx context.Context, worker int, in <-chan memoryMessage) {
+		for msg := range in {
+			start := time.Now()
+			err := handler(msg.body)
+			b.stats.Observe(worker, time.Since(start), err != nil)
+			b.settle(ctx, msg, err)
+			b.stats.InFlight.Add(-1)
+		}
+	})
+}
+
+// RunBatch hands queued events to handler in batches per worker.
+func (b *MemoryBroker) RunBatch(ctx context.Context, handler BatchHandler) error {
+	return b.run(ctx, func(ctx context.Context, worker int, in <-chan memoryMessage) {
+		CollectBatches(in, b.batchSize, b.batchWindow, func(batch []memoryMessage) {
+			defer b.stats.InFlight.Add(-int64(len(batch)))
+
+			bodies := make([][]byte, len(batch))
+			for i, msg := range batch {
+				bodies[i] = msg.body
+			}
+			start := time.Now()
+			errs, err := handler(bodies)
+			took := time.Since(start) / time.Duration(len(batch))
+			b.stats.Batches.Add(1)
+
+			if err == nil && len(errs) != len(batch) {
+				err = fmt.Errorf("batch handler returned %d results for %d messages", len(errs), len(batch))
+			}
+			for i, msg := range batch {
+				msgErr := err
+				if err == nil {
+					msgErr = errs[i]
+				} else if i == 0 {
+					log.Printf("batch of %d messages failed, requeueing it: %v", len(batch), err)
+				}
+				b.stats.Observe(worker, took, msgErr != nil)
+				if err != nil {
+					b.requeue(ctx, msg)
+					continue
+				}
+				b.settle(ctx, msg, msgErr)
+			}
+		})
+	})
+}
+
+// run dispatches queued events to workers by camera until ctx is canceled.
+func (b *MemoryBroker) run(ctx context.Context, work func(ctx context.Context, worker int, in <-chan memoryMessage)) error {
+	b.setState(StateConnected)
+	log.Printf("Consuming from in-memory event queue with %d workers (capacity %d)", b.workers, cap(b.queue))
+
+	queues := make([]chan memoryMessage, b.workers)
+	var wg sync.WaitGroup
+	for i := range queues {
+		queues[i] = make(chan memoryMessage, cap(b.queue))
+		wg.Add(1)
+		go func(worker int, in <-chan memoryMessage) {
+			defer wg.Done()
+			work(ctx, worker, in)
+		}(i, queues[i])
+	}
+
+	defer func() {
+		for _, q := range queues {
+			close(q)
+		}
+		wg.Wait()
+		b.setState(StateStopped)
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg := <-b.queue:
+			b.stats.InFlight.Add(1)
+			queues[WorkerFor(msg.body, b.workers)] <- msg
+		}
+	}
+}
+
+// settle drops a handled event, or dead-letters / requeues it when handling failed.
+func (b *MemoryBroker) settle(ctx context.Context, msg memoryMessage, err error) {
+	if err == nil {
+		return
+	}
+	log.Printf("handler error, delivery will be %s: %v", AckAction(err), err)
+	if IsNonRetryable(err) {
+		b.stats.DeadLettered.Add(1)
+		b.deadLetter(msg, err, 0)
+		return
+	}
+	b.requeue(ctx, msg)
+}
+
+// requeue puts the event back at the end of the queue after a short delay.
+// Events still waiting when the broker stops are lost, like the rest of the queue.
+func (b *MemoryBroker) requeue(ctx context.Context, msg memoryMessage) {
+	b.stats.Requeued.Add(1)
+	go func() {
+		select {
+		case <-time.After(memoryRequeueDelay):
+		case <-ctx.Done():
+			return
+		}
+		select {
+		case b.queue <- msg:
+		case <-ctx.Done():
+		}
+	}()
+}
+
+func (b *MemoryBroker) deadLetter(msg memoryMessage, cause error, replayCount int) {
+	now := time.Now().UTC()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.dead = append(b.dead, forms.DeadLetter{
+		MessageID:     msg.id,
+		Reason:        FailureReason(cause),
+		Error:         cause.Error(),
+		OriginalQueue: KindMemory,
+		FailedAt:      &now,
+		ReplayCount:   replayCount,
+		Body:          string(msg.body),
+		Event:         ParseEvent(msg.body),
+	})
+}
+
+// ListDeadLetters returns up to limit dead-lettered events, oldest first.
+func (b *MemoryBroker) ListDeadLetters(limit int) ([]forms.DeadLetter, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if limit > len(b.dead) {
+		limit = len(b.dead)
+	}
+	return append([]forms.DeadLetter(nil), b.dead[:limit]...), nil
+}
+
+// GetDeadLetter returns a single dead-lettered event by message ID.
+func (b *MemoryBroker) GetDeadLetter(messageID string) (*forms.DeadLetter, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := range b.dead {
+		if b.dead[i].MessageID == messageID {
+			dl := b.dead[i]
+			return &dl, nil
+		}
+	}
+	return nil, ErrDeadLetterNotFound
+}
+
+// ReplayDeadLetters feeds dead-lettered events accepted by match back into handler.
+func (b *MemoryBroker) ReplayDeadLetters(handler Handler, match func(forms.DeadLetter) bool) ([]forms.ReplayResult, error) {
+	b.mu.Lock()
+	pending := append([]forms.DeadLetter(nil), b.dead...)
+	b.mu.Unlock()
+
+	var results []forms.ReplayResult
+	for _, dl := range pending {
+		if !match(dl) {
+			continue
+		}
+		res := forms.ReplayResult{MessageID: dl.MessageID}
+		err := handler([]byte(dl.Body))
+		if err != nil {
+			res.Error = err.Error()
+			results = append(results, res)
+			if !IsNonRetryable(err) {
+				// Infrastructure error (e.g. DB down); stop instead of failing every message.
+				break
+			}
+			// Still rejected: park it again with the new reason and a bumped replay count.
+			b.remove(dl.MessageID)
+			b.deadLetter(memoryMessage{id: dl.MessageID, body: []byte(dl.Body)}, err, dl.ReplayCount+1)
+			continue
+		}
+		b.remove(dl.MessageID)
+		res.Replayed = true
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// DiscardDeadLetter permanently removes a dead-lettered event.
+func (b *MemoryBroker) DiscardDeadLetter(messageID string) error {
+	if !b.remove(messageID) {
+		return ErrDeadLetterNotFound
+	}
+	return nil
+}
+
+func (b *MemoryBroker) remove(messageID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := range b.dead {
+		if b.dead[i].MessageID == messageID {
+			b.dead = append(b.dead[:i], b.dead[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Status returns the broker state: connected while Run is active.
+func (b *MemoryBroker) Status() Status {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.status
+}
+
+func (b *MemoryBroker) setState(state string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.status.State != state {
+		b.status.State = state
+		b.status.Since = time.Now().UTC()
+	}
+}
+
+// Stats returns throughput counters of the worker pool.
+func (b *MemoryBroker) Stats() Stats {
+	st := b.stats.Snapshot(b.workers, cap(b.queue))
+	if st.Batches > 0 {
+		st.BatchSize = b.batchSize
+	}
+	return st
+}
+
+// Close rejects further Publish calls; Run should be stopped via its context.
+func (b *MemoryBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	return nil
+}
@@ -0,0 +1,184 @@
+// Package events defines where camera events come from: the EventSource contract
+// shared by the RabbitMQ consumer and the in-process MemoryBroker, and the rules
+// both use to settle a handled event.
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
+	"web_backend_v2/forms"
+	"web_backend_v2/models"
+)
+
+// Event source kinds, selected with EVENT_SOURCE.
+const (
+	KindRabbitMQ = "rabbitmq"
+	KindMemory   = "memory"
+)
+
+// Connection states reported by EventSource.Status.
+const (
+	StateConnecting   = "connecting"
+	StateConnected    = "connected"
+	StateReconnecting = "reconnecting"
+	StateStopped      = "stopped"
+)
+
+// Status describes the event source for health checks.
+type Status struct {
+	Kind       string    `json:"kind"`
+	State      string    `json:"state"`
+	Since      time.Time `json:"since"`
+	LastError  string    `json:"last_error,omitempty"`
+	Reconnects int       `json:"reconnects"`
+}
+
+// ErrDeadLetterNotFound is returned when no dead-lettered message has the requested ID.
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// Handler stores one event body.
+type Handler func(body []byte) error
+
+// BatchHandler processes a batch of message bodies and returns one error per body.
+// A non-nil second result means the batch was not stored and all of it is requeued.
+type BatchHandler func(bodies [][]byte) ([]error, error)
+
+// EventSource delivers camera events to a handler and settles every delivery the
+// same way: acked when handled, dead-lettered when IsNonRetryable(err), requeued
+// otherwise. Events of one camera are handled in the order they were published.
+type EventSource interface {
+	// Run hands events to handler one at a time until ctx is canceled; returns ctx.Err().
+	Run(ctx context.Context, handler Handler) error
+	// RunBatch hands events to handler in batches until ctx is canceled; returns ctx.Err().
+	RunBatch(ctx context.Context, handler BatchHandler) error
+	// Publish enqueues an event body.
+	Publish(ctx context.Context, body []byte) error
+	Status() Status
+	Stats() Stats
+	Close() error
+	DeadLetterStore
+}
+
+// DeadLetterStore gives access to events rejected with a non-retryable error.
+type DeadLetterStore interface {
+	// ListDeadLetters returns up to limit dead-lettered events without removing them.
+	ListDeadLetters(limit int) ([]forms.DeadLetter, error)
+	// GetDeadLetter returns a single dead-lettered event or ErrDeadLetterNotFound.
+	GetDeadLetter(messageID string) (*forms.DeadLetter, error)
+	// ReplayDeadLetters feeds events accepted by match back into handler. Handled ones
+	// are removed, still rejected ones stay with a bumped replay count, and the first
+	// retryable error stops the replay.
+	ReplayDeadLetters(handler Handler, match func(forms.DeadLetter) bool) ([]forms.ReplayResult, error)
+	// DiscardDeadLetter permanently removes a dead-lettered event.
+	DiscardDeadLetter(messageID string) error
+}
+
+// IsNonRetryable reports whether err means the event can never be stored as is,
+// so redelivering it is pointless.
+func IsNonRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(err, forms.ErrInvalidCameraEvent) ||
+		errors.Is(err, models.ErrCameraNotFound) ||
+		errors.Is(err, models.ErrCameraNotAttached)
+}
+
+// AckAction names what happens to a delivery that failed with err, for logs.
+func AckAction(err error) string {
+	if IsNonRetryable(err) {
+		return "dead-lettered"
+	}
+	return "requeued"
+}
+
+// FailureReason classifies a non-retryable error for the dead-letter record.
+func FailureReason(err error) string {
+	switch {
+	case errors.Is(err, forms.ErrInvalidCameraEvent):
+		return forms.DeadLetterInvalidEvent
+	case errors.Is(err, models.ErrCameraNotFound):
+		return forms.DeadLetterCameraNotFound
+	case errors.Is(err, models.ErrCameraNotAttached):
+		return forms.DeadLetterCameraNotAttached
+	default:
+		return forms.DeadLetterOther
+	}
+}
+
+// NewMessageID returns a random ID for messages published without one.
+func NewMessageID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// ParseEvent decodes body for display in a dead letter; nil when it is not a camera event.
+func ParseEvent(body []byte) *forms.CameraEvent {
+	var event forms.CameraEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil
+	}
+	return &event
+}
+
+// WorkerFor picks the worker for an event by hashing the camera MAC, so events of
+// one camera are handled sequentially. Unparseable bodies go to worker 0.
+func WorkerFor(body []byte, workers int) int {
+	if workers == 1 {
+		return 0
+	}
+	var key struct {
+		IDCamera string `json:"id_camera"`
+	}
+	if err := json.Unmarshal(body, &key); err != nil || key.IDCamera == "" {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(strings.ToUpper(key.IDCamera)))
+	return int(h.Sum32() % uint32(workers))
+}
+
+// CollectBatches reads in until it is closed and calls flush with up to size items,
+// or with whatever arrived within window after the first item of a batch.
+func CollectBatches[T any](in <-chan T, size int, window time.Duration, flush func([]T)) {
+	batch := make([]T, 0, size)
+	emit := func() {
+		if len(batch) > 0 {
+			flush(batch)
+			batch = batch[:0]
+		}
+	}
+
+	timer := time.NewTimer(window)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		select {
+		case item, ok := <-in:
+			if !ok {
+				emit()
+				return
+			}
+			batch = append(batch, item)
+			if len(batch) == 1 {
+				timer.Reset(window)
+			}
+			if len(batch) >= size {
+				timer.Stop()
+				emit()
+			}
+		case <-timer.C:
+			emit()
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// rateWindow is the period over which EventsPerSecond is averaged.
+const rateWindow = 60
+
+// Stats is a snapshot of event source throughput.
+type Stats struct {
+	Workers         int       `json:"workers"`
+	Prefetch        int       `json:"prefetch"`
+	Since           time.Time `json:"since"`
+	Processed       uint64    `json:"processed"`
+	Failed          uint64    `json:"failed"`
+	DeadLettered    uint64    `json:"dead_lettered"`
+	Requeued        uint64    `json:"requeued"`
+	InFlight        int64     `json:"in_flight"`
+	Batches         uint64    `json:"batches,omitempty"`
+	BatchSize       int       `json:"batch_size,omitempty"`
+	EventsPerSecond float64   `json:"events_per_second_1m"`
+	AvgHandleMillis float64   `json:"avg_handle_ms"`
+	PerWorker       []uint64  `json:"per_worker_processed"`
+}
+
+// Counters collects statistics updated concurrently by workers. The exported
+// counters are updated by the event source directly; handled deliveries go
+// through Observe.
+type Counters struct {
+	DeadLettered atomic.Uint64
+	Requeued     atomic.Uint64
+	InFlight     atomic.Int64
+	Batches      atomic.Uint64
+
+	since       time.Time
+	processed   atomic.Uint64
+	failed      atomic.Uint64
+	handleNanos atomic.Uint64
+	perWorker   []atomic.Uint64
+
+	mu      sync.Mutex
+	buckets [rateWindow]uint64 // events handled per second, ring buffer
+	stamps  [rateWindow]int64  // unix second each bucket belongs to
+}
+
+// NewCounters creates counters for the given number of workers.
+func NewCounters(workers int) *Counters {
+	return &Counters{
+		since:     time.Now().UTC(),
+		perWorker: make([]atomic.Uint64, workers),
+	}
+}
+
+// Observe records one handled delivery.
+func (s *Counters) Observe(worker int, took time.Duration, failed bool) {
+	s.processed.Add(1)
+	s.perWorker[worker].Add(1)
+	s.handleNanos.Add(uint64(took))
+	if failed {
+		s.failed.Add(1)
+	}
+
+	now := time.Now().Unix()
+	i := now % rateWindow
+	s.mu.Lock()
+	if s.stamps[i] != now {
+		s.stamps[i] = now
+		s.buckets[i] = 0
+	}
+	s.buckets[i]++
+	s.mu.Unlock()
+}
+
+// Snapshot returns the current statistics.
+func (s *Counters) Snapshot(workers, prefetch int) Stats {
+	st := Stats{
+		Workers:      workers,
+		Prefetch:     prefetch,
+		Since:        s.since,
+		Processed:    s.processed.Load(),
+		Failed:       s.failed.Load(),
+		DeadLettered: s.DeadLettered.Load(),
+		Requeued:     s.Requeued.Load(),
+		InFlight:     s.InFlight.Load(),
+		Batches:      s.Batches.Load(),
+		PerWorker:    make([]uint64, len(s.perWorker)),
+	}
+	for i := range s.perWorker {
+		st.PerWorker[i] = s.perWorker[i].Load()
+	}
+	if st.Processed > 0 {
+		st.AvgHandleMillis = float64(s.handleNanos.Load()) / float64(st.Processed) / float64(time.Millisecond)
+	}
+
+	now := time.Now().Unix()
+	var total uint64
+	s.mu.Lock()
+	for i := range s.buckets {
+		if now-s.stamps[i] < rateWindow {
+			total += s.buckets[i]
+		}
+	}
+	s.mu.Unlock()
+	st.EventsPerSecond = float64(total) / rateWindow
+	return st
+}
@@ -0,0 +1,136 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXLSXColumn(t *testing.T) {
+	tests := []struct {
+		index int
+		want  string
+	}{
+		{0, "A"},
+		{1, "B"},
+		{25, "Z"},
+		{26, "AA"},
+		{27, "AB"},
+		{51, "AZ"},
+		{52, "BA"},
+		{701, "ZZ"},
+		{702, "AAA"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, xlsxColumn(tt.index), "column %d", tt.index)
+	}
+}
+
+// xlsxSheet is the part of a worksheet the writer produces.
+type xlsxSheet struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			R      string `xml:"r,attr"`
+			T      string `xml:"t,attr"`
+			S      string `xml:"s,attr"`
+			V      string `xml:"v"`
+			Inline string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func TestXLSXWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(FormatXLSX, &buf)
+	require.NoError(t, err)
+	require.NoError(t, w.WriteRow("Аудитория", "Час", "Среднее", "Время"))
+	require.NoError(t, w.WriteRow(`<b>"R&D" 'lab'</b>`, 10, 12.5, time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)))
+	require.NoError(t, w.WriteRow(uint(7), nil, "  padded  "))
+	wide := make([]interface{}, 28)
+	wide[27] = "last"
+	require.NoError(t, w.WriteRow(wide...))
+	require.NoError(t, w.Close())
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	parts := make(map[string][]byte)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		data, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+		parts[f.Name] = data
+
+		// Every part is well-formed XML.
+		dec := xml.NewDecoder(bytes.NewReader(data))
+		for {
+			_, err := dec.Token()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err, f.Name)
+		}
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet1.xml"} {
+		assert.Contains(t, parts, name)
+	}
+
+	sheetXML := string(parts["xl/worksheets/sheet1.xml"])
+	assert.Contains(t, sheetXML, "&lt;b&gt;&#34;R&amp;D&#34; &#39;lab&#39;&lt;/b&gt;")
+	var sheet xlsxSheet
+	require.NoError(t, xml.Unmarshal(parts["xl/worksheets/sheet1.xml"], &sheet))
+	require.Len(t, sheet.Rows, 4)
+
+	header := sheet.Rows[0]
+	assert.Equal(t, 1, header.R)
+	require.Len(t, header.Cells, 4)
+	assert.Equal(t, "A1", header.Cells[0].R)
+	assert.Equal(t, "inlineStr", header.Cells[0].T)
+	assert.Equal(t, "Аудитория", header.Cells[0].Inline)
+
+	values := sheet.Rows[1].Cells
+	require.Len(t, values, 4)
+	assert.Equal(t, `<b>"R&D" 'lab'</b>`, values[0].Inline)
+	assert.Equal(t, "B2", values[1].R)
+	assert.Equal(t, "10", values[1].V)
+	assert.Equal(t, "12.5", values[2].V)
+	assert.Equal(t, "1", values[3].S, "date-time style")
+	assert.Equal(t, "46083.5", values[3].V, "days since 1899-12-30")
+
+	sparse := sheet.Rows[2].Cells
+	require.Len(t, sparse, 2, "nil cells are left out")
+	assert.Equal(t, "A3", sparse[0].R)
+	assert.Equal(t, "7", sparse[0].V)
+	assert.Equal(t, "C3", sparse[1].R)
+	assert.Equal(t, "  padded  ", sparse[1].Inline)
+
+	require.Len(t, sheet.Rows[3].Cells, 1)
+	assert.Equal(t, "AB4", sheet.Rows[3].Cells[0].R)
+}
+
+func TestCSVWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(FormatCSV, &buf)
+	require.NoError(t, err)
+	require.NoError(t, w.WriteRow("Аудитория", "Время", "Среднее"))
+	require.NoError(t, w.WriteRow(`101, "big"`, time.Date(2026, 3, 2, 15, 0, 0, 0, time.FixedZone("MSK", 3*3600)), 12.5))
+	require.NoError(t, w.WriteRow(uint(7), nil, 3))
+	require.NoError(t, w.Close())
+
+	assert.Equal(t, "\ufeffАудитория,Время,Среднее\n"+
+		"\"101, \"\"big\"\"\",2026-03-02T12:00:00Z,12.5\n"+
+		"7,,3\n", buf.String())
+}
+
+func TestNewWriterUnknownFormat(t *testing.T) {
+	_, err := NewWriter("ods", io.Discard)
+	assert.EqualError(t, err, `unknown export format "ods"`)
+}
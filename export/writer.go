@@ -0,0 +1,95 @@
+// Package export writes tabular reports as CSV or XLSX row by row, so large
+// exports are streamed to the client instead of being built in memory.
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Supported formats.
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// Writer writes one table. Cells may be string, int, uint, float64, time.Time
+// or nil (an empty cell). Close must be called to finish the file.
+type Writer interface {
+	WriteRow(cells ...interface{}) error
+	Close() error
+}
+
+// NewWriter returns a writer of the given format (FormatCSV or FormatXLSX).
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w)
+	case FormatXLSX:
+		return newXLSXWriter(w)
+	default:
+		return nil, fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+// ContentType returns the MIME type of a format.
+func ContentType(format string) string {
+	if format == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+type csvWriter struct {
+	w      *csv.Writer
+	record []string
+}
+
+// newCSVWriter starts the file with a UTF-8 BOM so that Excel shows Cyrillic
+// headers correctly.
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return nil, err
+	}
+	return &csvWriter{w: csv.NewWriter(w)}, nil
+}
+
+func (c *csvWriter) WriteRow(cells ...interface{}) error {
+	c.record = c.record[:0]
+	for _, cell := range cells {
+		c.record = append(c.record, csvCell(cell))
+	}
+	if err := c.w.Write(c.record); err != nil {
+		return err
+	}
+	// Flush every row: the point of the export is to stream.
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func csvCell(cell interface{}) string {
+	switch v := cell.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case uint:
+		return strconv.FormatUint(uint64(v), 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,97 @@
+package forms
+
+import "time"
+
+// Alert kinds.
+const (
+	// AlertStale: an attached camera has sent no event for too long.
+	AlertStale = "stale"
+	// AlertOverCapacity: the latest fresh reading of an auditorium exceeds its capacity.
+	AlertOverCapacity = "over_capacity"
+	// AlertSpike: the person count of an auditorium jumped between two
+	// consecutive readings within the spike window.
+	AlertSpike = "spike"
+)
+
+// Alert statuses.
+const (
+	AlertFiring   = "firing"
+	AlertResolved = "resolved"
+)
+
+// Alert is a condition found by the alert evaluator. CameraID is set for stale
+// alerts only. Message describes the condition as last evaluated.
+type Alert struct {
+	ID             uint64     `gorm:"primaryKey;column:id"`
+	Kind           string     `gorm:"column:kind;not null"`
+	AuditoriumID   uint       `gorm:"column:auditorium_id;not null;index"`
+	CameraID       *uint      `gorm:"column:camera_id"`
+	Status         string     `gorm:"column:status;not null"`
+	Message        string     `gorm:"column:message;not null"`
+	StartedAt      time.Time  `gorm:"column:started_at;not null;type:timestamptz"`
+	UpdatedAt      time.Time  `gorm:"column:updated_at;not null;type:timestamptz"`
+	ResolvedAt     *time.Time `gorm:"column:resolved_at;type:timestamptz"`
+	AcknowledgedAt *time.Time `gorm:"column:acknowledged_at;type:timestamptz"`
+	AckNote        *string    `gorm:"column:ack_note"`
+}
+
+func (Alert) TableName() string { return "alert" }
+
+// AlertQuery binds GET /v1/alerts filters; the latest alerts come first.
+type AlertQuery struct {
+	Status       string `form:"status" binding:"omitempty,oneof=firing resolved"`
+	Kind         string `form:"kind" binding:"omitempty,oneof=stale over_capacity spike"`
+	CityID       uint   `form:"city_id"`
+	BuildingID   uint   `form:"building_id"`
+	AuditoriumID uint   `form:"auditorium_id"`
+	CameraID     uint   `form:"camera_id"`
+	Acknowledged *bool  `form:"acknowledged"`
+	Limit        int    `form:"limit" binding:"omitempty,gte=1,lte=1000"`
+	// ScopeCityIDs and ScopeBuildingIDs, when either is set, limit the alerts
+	// to those cities and buildings. They come from the principal, not the query.
+	ScopeCityIDs     []uint `form:"-"`
+	ScopeBuildingIDs []uint `form:"-"`
+}
+
+// AlertAckRequest is the optional body of POST /v1/alerts/:alert_id/ack.
+type AlertAckRequest struct {
+	Note string `json:"note" binding:"max=1000"`
+}
+
+// AlertResponse is an alert with the building and city of its auditorium.
+type AlertResponse struct {
+	ID             uint64     `json:"id"`
+	Kind           string     `json:"kind"`
+	Status         string     `json:"status"`
+	CityID         uint       `json:"city_id"`
+	BuildingID     uint       `json:"building_id"`
+	AuditoriumID   uint       `json:"auditorium_id"`
+	CameraID       *uint      `json:"camera_id,omitempty"`
+	Message        string     `json:"message"`
+	StartedAt      time.Time  `json:"started_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty"`
+	Acknowledged   bool       `json:"acknowledged"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+	AckNote        *string    `json:"ack_note,omitempty"`
+}
+
+// ToAlertResponse converts an Alert model to AlertResponse
+func (a *Alert) ToAlertResponse(cityID, buildingID uint) AlertResponse {
+	return AlertResponse{
+		ID:             a.ID,
+		Kind:           a.Kind,
+		Status:         a.Status,
+		CityID:         cityID,
+		BuildingID:     buildingID,
+		AuditoriumID:   a.AuditoriumID,
+		CameraID:       a.CameraID,
+		Message:        a.Message,
+		StartedAt:      a.StartedAt,
+		UpdatedAt:      a.UpdatedAt,
+		ResolvedAt:     a.ResolvedAt,
+		Acknowledged:   a.AcknowledgedAt != nil,
+		AcknowledgedAt: a.AcknowledgedAt,
+		AckNote:        a.AckNote,
+	}
+}
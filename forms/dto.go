@@ -34,6 +34,7 @@ type AuditoriumResponse struct {
 	AuditoriumNumber string          `json:"auditorium_number"`
 	Type             LocalizedString `json:"type"`
 	ImageURL         string          `json:"image_url"`
+	FusionStrategy   *string         `json:"fusion_strategy,omitempty"`
 }
 
 type CameraResponse struct {
@@ -42,6 +43,42 @@ type CameraResponse struct {
 	AuditoriumID *uint  `json:"auditorium_id,omitempty"`
 }
 
+// Camera health statuses.
+const (
+	CameraStatusOK       = "ok"
+	CameraStatusDegraded = "degraded"
+	CameraStatusOffline  = "offline"
+)
+
+// CameraHealthResponse describes liveness of a camera.
+type CameraHealthResponse struct {
+	CameraID        uint       `json:"camera_id"`
+	Mac             string     `json:"mac"`
+	AuditoriumID    *uint      `json:"auditorium_id,omitempty"`
+	BuildingID      *uint      `json:"building_id,omitempty"`
+	CityID          *uint      `json:"city_id,omitempty"`
+	Status          string     `json:"status"`
+	Reasons         []string   `json:"reasons,omitempty"`
+	LastSeenAt      *time.Time `json:"last_seen_at"`
+	LastEventAt     *time.Time `json:"last_event_at,omitempty"`
+	LastPersonCount *int       `json:"last_person_count,omitempty"`
+	SilentSeconds   *float64   `json:"silent_seconds,omitempty"`
+	EventsTotal     int64      `json:"events_total"`
+	EventsPerMinute *float64   `json:"events_per_minute,omitempty"`
+}
+
+// CameraListQuery binds GET /v1/cameras filters.
+type CameraListQuery struct {
+	Status string `form:"status" binding:"omitempty,oneof=ok degraded offline"`
+}
+
+// CameraTokenResponse returns a newly issued ingestion token; it is shown only once.
+type CameraTokenResponse struct {
+	CameraID uint   `json:"camera_id"`
+	Mac      string `json:"mac"`
+	Token    string `json:"token"`
+}
+
 type CreateCameraRequest struct {
 	Mac string `json:"mac" binding:"required,len=17"`
 }
@@ -50,6 +87,43 @@ type AttachCameraRequest struct {
 	CameraID uint `json:"camera_id" binding:"required"`
 }
 
+// AuditoriumTypeRU maps values of auditorium_type_enum to their Russian names.
+var AuditoriumTypeRU = map[string]string{
+	"coworking":    "коворкинг",
+	"classroom":    "учебная",
+	"lecture_hall": "лекционная",
+}
+
+// LocalizedStringRequest is the writable counterpart of LocalizedString.
+type LocalizedStringRequest struct {
+	RU string `json:"ru" binding:"required,max=255"`
+	EN string `json:"en" binding:"required,max=255"`
+}
+
+// CityRequest is the body of POST/PUT/PATCH /v1/cities.
+type CityRequest struct {
+	Name LocalizedStringRequest `json:"name"`
+}
+
+// BuildingRequest is the body of POST/PUT/PATCH /v1/cities/:city_id/buildings.
+type BuildingRequest struct {
+	Address     LocalizedStringRequest `json:"address"`
+	FloorsCount int                    `json:"floors_count" binding:"required,gte=1"`
+}
+
+// AuditoriumRequest is the body of POST/PUT/PATCH .../auditories.
+// type_ru is derived from Type; floor_number is 1..floors_count of the building
+// or negative for a basement level, never 0.
+type AuditoriumRequest struct {
+	FloorNumber      int    `json:"floor_number"`
+	Capacity         int    `json:"capacity" binding:"required,gte=1"`
+	AuditoriumNumber string `json:"auditorium_number" binding:"required,max=50"`
+	Type             string `json:"type" binding:"required,oneof=coworking classroom lecture_hall"`
+	ImageURL         string `json:"image_url" binding:"omitempty,url,max=500"`
+	// FusionStrategy is optional; null falls back to the service-wide FUSION_STRATEGY.
+	FusionStrategy *string `json:"fusion_strategy" binding:"omitempty,oneof=max sum median"`
+}
+
 type OccupancyResult struct {
 	PersonCount     int       `json:"person_count"`
 	ActualTimestamp time.Time `json:"actual_timestamp"`
@@ -64,6 +138,50 @@ type OccupancyQuery struct {
 	Timestamp time.Time `form:"timestamp" binding:"required" time_format:"2006-01-02T15:04:05Z07:00"`
 }
 
+// Statistics types accepted in StatisticsQuery.Type.
+const (
+	StatsTypeAbsolute      = 1
+	StatsTypeOccupancyRate = 2
+)
+
+// OccupancySeriesQuery is used for binding time-series occupancy requests.
+// Expects from/to as RFC3339 and bucket as one of SeriesBuckets (default 1h).
+type OccupancySeriesQuery struct {
+	From   time.Time `form:"from" binding:"required" time_format:"2006-01-02T15:04:05Z07:00"`
+	To     time.Time `form:"to" binding:"required" time_format:"2006-01-02T15:04:05Z07:00"`
+	Bucket string    `form:"bucket" binding:"omitempty,oneof=5m 15m 1h"`
+}
+
+// SeriesBuckets lists the supported bucket sizes for occupancy series.
+var SeriesBuckets = map[string]time.Duration{
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"1h":  time.Hour,
+}
+
+// OccupancySeriesPoint is one bucket of an occupancy time series.
+// Source is "occupancy" for raw readings and "dailyload" for days already
+// compacted by the daily aggregation; dailyload points always span one hour
+// and carry no min/max/sample_count.
+type OccupancySeriesPoint struct {
+	BucketStart time.Time `json:"bucket_start"`
+	BucketEnd   time.Time `json:"bucket_end"`
+	Avg         float64   `json:"avg"`
+	Min         *int      `json:"min,omitempty"`
+	Max         *int      `json:"max,omitempty"`
+	SampleCount int       `json:"sample_count"`
+	Source      string    `json:"source"`
+}
+
+// OccupancySeriesResponse is the payload of the occupancy series endpoint.
+type OccupancySeriesResponse struct {
+	AuditoriumID uint                   `json:"auditorium_id"`
+	From         time.Time              `json:"from"`
+	To           time.Time              `json:"to"`
+	Bucket       string                 `json:"bucket"`
+	Points       []OccupancySeriesPoint `json:"points"`
+}
+
 // StatisticsQuery is used for binding statistics requests.
 // Expects day as YYYY-MM-DD in query string (?day=...).
 // Type: 1 = Average Person Count (Absolute), 2 = Occupancy Rate (Percentage)
@@ -78,6 +196,16 @@ type HourlyStatsResponse struct {
 	AvgPersonCount float64 `json:"avg_person_count"`
 }
 
+// HourlyRateStatsResponse represents hourly utilisation against auditorium capacity.
+// OccupancyRate is a percentage and may exceed 100; OverCapacity marks such hours.
+type HourlyRateStatsResponse struct {
+	Hour           int     `json:"hour"`
+	AvgPersonCount float64 `json:"avg_person_count"`
+	Capacity       int     `json:"capacity"`
+	OccupancyRate  float64 `json:"occupancy_rate"`
+	OverCapacity   bool    `json:"over_capacity"`
+}
+
 // AuditoriumOccupancyResponse describes occupancy for a specific auditorium.
 type AuditoriumOccupancyResponse struct {
 	AuditoriumID    uint      `json:"auditorium_id"`
@@ -86,11 +214,99 @@ type AuditoriumOccupancyResponse struct {
 	IsFresh         bool      `json:"is_fresh"`
 	TimeDiffMinutes float64   `json:"time_diff_minutes"`
 	Warning         *string   `json:"warning,omitempty"`
+	// FusionStrategy and Contributions show how PersonCount was combined from
+	// the auditorium's cameras; empty for rows stored before fusion existed.
+	FusionStrategy string               `json:"fusion_strategy,omitempty"`
+	Contributions  []CameraContribution `json:"contributions,omitempty"`
 }
 
 // BuildingOccupancyResponse is a typed alias for the building-wide payload.
 type BuildingOccupancyResponse []AuditoriumOccupancyResponse
 
+// OccupancyUpdate is a newly stored occupancy reading, as pushed on the
+// building occupancy stream.
+type OccupancyUpdate struct {
+	CityID         uint                 `json:"city_id"`
+	BuildingID     uint                 `json:"building_id"`
+	AuditoriumID   uint                 `json:"auditorium_id"`
+	PersonCount    int                  `json:"person_count"`
+	Timestamp      time.Time            `json:"timestamp"`
+	FusionStrategy string               `json:"fusion_strategy,omitempty"`
+	Contributions  []CameraContribution `json:"contributions,omitempty"`
+}
+
+// OccupancyTotals sums the latest readings of a group of auditoriums. A room is
+// fresh when its latest reading is within the freshness limit, stale when it is
+// older and "no data" when it has none. TotalPeople counts fresh rooms only and
+// UtilisationRate is TotalPeople as a percentage of FreshCapacity (the capacity
+// of the fresh rooms); it is null when no fresh room has a capacity.
+type OccupancyTotals struct {
+	Auditoriums       int      `json:"auditoriums"`
+	FreshAuditoriums  int      `json:"fresh_auditoriums"`
+	StaleAuditoriums  int      `json:"stale_auditoriums"`
+	NoDataAuditoriums int      `json:"no_data_auditoriums"`
+	TotalPeople       int      `json:"total_people"`
+	TotalCapacity     int      `json:"total_capacity"`
+	FreshCapacity     int      `json:"fresh_capacity"`
+	UtilisationRate   *float64 `json:"utilisation_rate"`
+}
+
+// FloorOccupancySummary is OccupancyTotals of one floor of a building.
+type FloorOccupancySummary struct {
+	FloorNumber int `json:"floor_number"`
+	OccupancyTotals
+}
+
+// BuildingOccupancySummary is OccupancyTotals of a building with its floors in
+// ascending order (only floors that have auditoriums are listed).
+type BuildingOccupancySummary struct {
+	Building  BuildingResponse `json:"building"`
+	Timestamp time.Time        `json:"timestamp"`
+	OccupancyTotals
+	Floors []FloorOccupancySummary `json:"floors"`
+}
+
+// CityOccupancySummary rolls up all buildings of a city.
+type CityOccupancySummary struct {
+	CityID    uint      `json:"city_id"`
+	Timestamp time.Time `json:"timestamp"`
+	OccupancyTotals
+	Buildings []BuildingOccupancySummary `json:"buildings"`
+}
+
+// FreeAuditoriumsQuery binds GET /v1/cities/:city_id/free-auditoriums.
+// At (RFC3339) defaults to now. Rooms whose latest reading is older than the
+// freshness limit, or that have no readings, are left out unless IncludeStale.
+type FreeAuditoriumsQuery struct {
+	At           time.Time `form:"at" time_format:"2006-01-02T15:04:05Z07:00"`
+	BuildingID   uint      `form:"building_id"`
+	MinSeats     int       `form:"min_seats" binding:"omitempty,gte=1"`
+	Type         string    `form:"type" binding:"omitempty,oneof=coworking classroom lecture_hall"`
+	Floor        *int      `form:"floor"`
+	IncludeStale bool      `form:"include_stale"`
+}
+
+// FreeAuditoriumResponse is an auditorium ranked by free seats at the requested
+// time. PersonCount, FreeSeats, OccupancyRate and ActualTimestamp are null when
+// the auditorium has no readings, FreeSeats and OccupancyRate also when it has
+// no capacity; stale rooms are flagged with IsFresh false.
+type FreeAuditoriumResponse struct {
+	AuditoriumID     uint             `json:"auditorium_id"`
+	AuditoriumNumber string           `json:"auditorium_number"`
+	Type             LocalizedString  `json:"type"`
+	FloorNumber      int              `json:"floor_number"`
+	ImageURL         string           `json:"image_url"`
+	Building         BuildingResponse `json:"building"`
+	Capacity         int              `json:"capacity"`
+	PersonCount      *int             `json:"person_count"`
+	FreeSeats        *int             `json:"free_seats"`
+	OccupancyRate    *float64         `json:"occupancy_rate"`
+	ActualTimestamp  *time.Time       `json:"actual_timestamp"`
+	IsFresh          bool             `json:"is_fresh"`
+	TimeDiffMinutes  *float64         `json:"time_diff_minutes,omitempty"`
+	Warning          *string          `json:"warning,omitempty"`
+}
+
 func (o *Occupancy) ToOccupancyResponse(currentTime time.Time, maxTimeDiffMinutes int) *OccupancyResult {
 	// Вычисляем разницу во времени в минутах
 	timeDiff := currentTime.Sub(o.Timestamp).Minutes()
@@ -151,6 +367,63 @@ func (a *Auditorium) ToAuditoriumResponse() AuditoriumResponse {
 			RU: a.TypeRU,
 			EN: a.Type,
 		},
-		ImageURL: a.ImageURL,
+		ImageURL:       a.ImageURL,
+		FusionStrategy: a.FusionStrategy,
+	}
+}
+
+// ToCityRequest converts a City model to a CityRequest (used as PATCH base)
+func (c *City) ToCityRequest() CityRequest {
+	return CityRequest{Name: LocalizedStringRequest{RU: c.NameRU, EN: c.NameEN}}
+}
+
+// ToCity converts a CityRequest to a City model
+func (r *CityRequest) ToCity(id uint) City {
+	return City{ID: id, NameRU: r.Name.RU, NameEN: r.Name.EN}
+}
+
+// ToBuildingRequest converts a Building model to a BuildingRequest (used as PATCH base)
+func (b *Building) ToBuildingRequest() BuildingRequest {
+	return BuildingRequest{
+		Address:     LocalizedStringRequest{RU: b.AddressRU, EN: b.AddressEN},
+		FloorsCount: b.FloorCount,
+	}
+}
+
+// ToBuilding converts a BuildingRequest to a Building model
+func (r *BuildingRequest) ToBuilding(id, cityID uint) Building {
+	return Building{
+		ID:         id,
+		CityID:     cityID,
+		AddressRU:  r.Address.RU,
+		AddressEN:  r.Address.EN,
+		FloorCount: r.FloorsCount,
+	}
+}
+
+// ToAuditoriumRequest converts an Auditorium model to an AuditoriumRequest (used as PATCH base)
+func (a *Auditorium) ToAuditoriumRequest() AuditoriumRequest {
+	return AuditoriumRequest{
+		FloorNumber:      a.FloorNumber,
+		Capacity:         a.Capacity,
+		AuditoriumNumber: a.AuditoriumNumber,
+		Type:             a.Type,
+		ImageURL:         a.ImageURL,
+		FusionStrategy:   a.FusionStrategy,
+	}
+}
+
+// ToAuditorium converts an AuditoriumRequest to an Auditorium model, filling type_ru
+func (r *AuditoriumRequest) ToAuditorium(id, buildingID uint) Auditorium {
+	return Auditorium{
+		ID:               id,
+		BuildingID:       buildingID,
+		FloorNumber:      r.FloorNumber,
+		Capacity:         r.Capacity,
+		AuditoriumNumber: r.AuditoriumNumber,
+		Type:             r.Type,
+		TypeRU:           AuditoriumTypeRU[r.Type],
+		ImageURL:         r.ImageURL,
+		FusionStrategy:   r.FusionStrategy,
 	}
 }
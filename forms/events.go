@@ -3,6 +3,7 @@ package forms
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -17,6 +18,18 @@ type CameraEvent struct {
 	Timestamp   time.Time `json:"timestamp" validate:"required"`
 	// PersonCount is a pointer to distinguish "field absent" (nil) from zero.
 	PersonCount *int `json:"person_count" validate:"required,gte=0"`
+	// EventID is an optional camera-assigned id used to detect retransmissions.
+	EventID string `json:"event_id,omitempty" validate:"omitempty,max=128"`
+}
+
+// DedupKey identifies the event for deduplication: the camera's event_id when
+// present, otherwise the camera and the event timestamp.
+func (e *CameraEvent) DedupKey() string {
+	mac := strings.ToUpper(e.IDCamera)
+	if e.EventID != "" {
+		return mac + "|id:" + e.EventID
+	}
+	return mac + "|ts:" + e.Timestamp.UTC().Format(time.RFC3339Nano)
 }
 
 // Validate ensures the event has all required fields and sane values via struct tags.
@@ -31,3 +44,72 @@ func (e *CameraEvent) Validate() error {
 	}
 	return nil
 }
+
+// Outcomes of an event posted to POST /v1/events.
+const (
+	EventStored    = "stored"
+	EventDuplicate = "duplicate"
+	EventRejected  = "rejected" // invalid or not acceptable; do not resend
+	EventFailed    = "failed"   // temporary failure; may be resent
+)
+
+// EventResult is the outcome of one posted event.
+type EventResult struct {
+	Index  int    `json:"index"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// EventBatchResponse reports per-item results of a posted batch.
+type EventBatchResponse struct {
+	Results    []EventResult `json:"results"`
+	Stored     int           `json:"stored"`
+	Duplicates int           `json:"duplicates"`
+	Rejected   int           `json:"rejected"`
+	Failed     int           `json:"failed"`
+}
+
+// Dead-letter reasons recorded in the x-failure-reason header.
+const (
+	DeadLetterInvalidEvent      = "invalid_event"
+	DeadLetterCameraNotFound    = "camera_not_found"
+	DeadLetterCameraNotAttached = "camera_not_attached"
+	DeadLetterOther             = "other"
+)
+
+// DeadLetter is a rejected camera event parked in the dead-letter queue.
+type DeadLetter struct {
+	MessageID     string       `json:"message_id"`
+	Reason        string       `json:"reason"`
+	Error         string       `json:"error"`
+	OriginalQueue string       `json:"original_queue"`
+	FailedAt      *time.Time   `json:"failed_at,omitempty"`
+	ReplayCount   int          `json:"replay_count"`
+	Body          string       `json:"body"`
+	Event         *CameraEvent `json:"event,omitempty"`
+}
+
+// ReplayResult reports the outcome of replaying one dead-lettered event.
+type ReplayResult struct {
+	MessageID string `json:"message_id"`
+	Replayed  bool   `json:"replayed"`
+	Error     string `json:"error,omitempty"`
+}
+
+// DeadLetterQuery filters dead-lettered events (?limit=&reason=&camera=).
+type DeadLetterQuery struct {
+	Limit  int    `form:"limit" binding:"omitempty,gte=1,lte=1000"`
+	Reason string `form:"reason"`
+	Camera string `form:"camera"`
+}
+
+// Match reports whether a dead letter passes the reason and camera filters.
+func (q *DeadLetterQuery) Match(dl DeadLetter) bool {
+	if q.Reason != "" && dl.Reason != q.Reason {
+		return false
+	}
+	if q.Camera != "" && (dl.Event == nil || dl.Event.IDCamera != q.Camera) {
+		return false
+	}
+	return true
+}
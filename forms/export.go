@@ -0,0 +1,104 @@
+package forms
+
+import "time"
+
+// ExportQuery selects the file format and the language of column headers and
+// localized values of an export. Both default to csv and ru.
+type ExportQuery struct {
+	Format string `form:"format" binding:"omitempty,oneof=csv xlsx"`
+	Lang   string `form:"lang" binding:"omitempty,oneof=ru en"`
+}
+
+// OccupancyExportQuery binds the building occupancy export.
+type OccupancyExportQuery struct {
+	OccupancyQuery
+	ExportQuery
+}
+
+// StatisticsExportQuery binds the auditorium statistics export. From and To are
+// days (YYYY-MM-DD), both included.
+type StatisticsExportQuery struct {
+	From string `form:"from" binding:"required"`
+	To   string `form:"to" binding:"required"`
+	ExportQuery
+}
+
+// RawOccupancyExportQuery binds the raw occupancy dump of a building: every
+// stored reading with from <= timestamp < to (RFC3339).
+type RawOccupancyExportQuery struct {
+	From time.Time `form:"from" binding:"required" time_format:"2006-01-02T15:04:05Z07:00"`
+	To   time.Time `form:"to" binding:"required" time_format:"2006-01-02T15:04:05Z07:00"`
+	ExportQuery
+}
+
+// In returns the value for lang ("en" or anything else for Russian).
+func (s LocalizedString) In(lang string) string {
+	if lang == "en" {
+		return s.EN
+	}
+	return s.RU
+}
+
+// AuditoriumOccupancyExportRow is an auditorium of a building with its latest
+// reading at the requested time; PersonCount and Timestamp are nil when there
+// is none.
+type AuditoriumOccupancyExportRow struct {
+	AuditoriumID     uint
+	AuditoriumNumber string
+	FloorNumber      int
+	Type             LocalizedString
+	Capacity         int
+	PersonCount      *int
+	Timestamp        *time.Time
+}
+
+// HourlyStatsExportRow is one hour (9 to 21) of a day of auditorium statistics,
+// 0 where there is no data like in HourlyStatsResponse.
+type HourlyStatsExportRow struct {
+	Day            time.Time
+	Hour           int
+	AvgPersonCount float64
+}
+
+// OccupancyExportRow is one stored occupancy reading.
+type OccupancyExportRow struct {
+	Timestamp        time.Time
+	AuditoriumID     uint
+	AuditoriumNumber string
+	PersonCount      int
+	FusionStrategy   string
+}
+
+// Column headers of the exports.
+var (
+	OccupancyExportColumns = []LocalizedString{
+		{RU: "ID аудитории", EN: "Auditorium ID"},
+		{RU: "Аудитория", EN: "Auditorium"},
+		{RU: "Этаж", EN: "Floor"},
+		{RU: "Тип", EN: "Type"},
+		{RU: "Вместимость", EN: "Capacity"},
+		{RU: "Человек", EN: "Persons"},
+		{RU: "Время данных (UTC)", EN: "Data time (UTC)"},
+		{RU: "Данные актуальны", EN: "Data is fresh"},
+	}
+	StatisticsExportColumns = []LocalizedString{
+		{RU: "День", EN: "Day"},
+		{RU: "Час", EN: "Hour"},
+		{RU: "Среднее число человек", EN: "Average persons"},
+		{RU: "Вместимость", EN: "Capacity"},
+		{RU: "Загруженность, %", EN: "Occupancy rate, %"},
+	}
+	RawOccupancyExportColumns = []LocalizedString{
+		{RU: "Время (UTC)", EN: "Time (UTC)"},
+		{RU: "ID аудитории", EN: "Auditorium ID"},
+		{RU: "Аудитория", EN: "Auditorium"},
+		{RU: "Человек", EN: "Persons"},
+		{RU: "Стратегия объединения камер", EN: "Camera fusion strategy"},
+	}
+)
+
+// Localized yes/no for boolean export cells.
+var (
+	ExportYes = LocalizedString{RU: "да", EN: "yes"}
+	ExportNo  = LocalizedString{RU: "нет", EN: "no"}
+)
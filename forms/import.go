@@ -0,0 +1,291 @@
+package forms
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// MaxImportRows limits the number of data rows in one imported CSV.
+const MaxImportRows = 5000
+
+// Columns of the buildings CSV; all are required.
+var BuildingImportColumns = []string{"address_ru", "address_en", "floors_count"}
+
+// Columns of the auditoriums CSV. building_address_en selects a building of the
+// city. At least one of type_en/type_ru is required; when both are given they
+// must name the same type. image_url and fusion_strategy may be empty.
+var AuditoriumImportColumns = []string{
+	"building_address_en", "auditorium_number", "floor_number", "capacity",
+	"type_en", "type_ru", "image_url", "fusion_strategy",
+}
+
+// ImportRowError is a problem with one CSV row. Row is the line number in the
+// file (the header is line 1).
+type ImportRowError struct {
+	Row    int    `json:"row"`
+	Column string `json:"column,omitempty"`
+	Error  string `json:"error"`
+}
+
+// ImportResult is the outcome of a CSV import. Nothing is written unless
+// Applied is true: any row error, or a dry run, rolls the whole file back.
+// The counters describe what was (or, on a dry run, would be) done.
+type ImportResult struct {
+	DryRun    bool             `json:"dry_run"`
+	Applied   bool             `json:"applied"`
+	Rows      int              `json:"rows"`
+	Created   int              `json:"created"`
+	Updated   int              `json:"updated"`
+	Unchanged int              `json:"unchanged"`
+	Errors    []ImportRowError `json:"errors"`
+}
+
+// SortErrors orders Errors by row.
+func (r *ImportResult) SortErrors() {
+	sort.SliceStable(r.Errors, func(i, j int) bool { return r.Errors[i].Row < r.Errors[j].Row })
+}
+
+// BuildingImportRow is a valid row of the buildings CSV.
+type BuildingImportRow struct {
+	Row      int
+	Building BuildingRequest
+}
+
+// BuildingImport is a parsed buildings CSV: the valid rows and the errors of the others.
+type BuildingImport struct {
+	Total  int // data rows in the file
+	Rows   []BuildingImportRow
+	Errors []ImportRowError
+}
+
+// AuditoriumImportRow is a valid row of the auditoriums CSV.
+type AuditoriumImportRow struct {
+	Row               int
+	BuildingAddressEN string
+	Auditorium        AuditoriumRequest
+}
+
+// AuditoriumImport is a parsed auditoriums CSV: the valid rows and the errors of the others.
+type AuditoriumImport struct {
+	Total  int // data rows in the file
+	Rows   []AuditoriumImportRow
+	Errors []ImportRowError
+}
+
+// ErrImportFile is returned when a CSV cannot be imported at all (bad header, too many rows, broken quoting).
+var ErrImportFile = errors.New("invalid import file")
+
+// ParseBuildingsCSV reads a buildings CSV and validates every row with the
+// rules of BuildingRequest.
+func ParseBuildingsCSV(r io.Reader) (*BuildingImport, error) {
+	var result BuildingImport
+	total, err := readImportCSV(r, BuildingImportColumns, BuildingImportColumns, func(row int, get func(string) string) {
+		floors, err := strconv.Atoi(get("floors_count"))
+		if err != nil {
+			result.Errors = append(result.Errors, ImportRowError{Row: row, Column: "floors_count", Error: "must be an integer"})
+			return
+		}
+		req := BuildingRequest{
+			Address:     LocalizedStringRequest{RU: get("address_ru"), EN: get("address_en")},
+			FloorsCount: floors,
+		}
+		if errs := importValidationErrors(row, &req, buildingImportFields); len(errs) > 0 {
+			result.Errors = append(result.Errors, errs...)
+			return
+		}
+		result.Rows = append(result.Rows, BuildingImportRow{Row: row, Building: req})
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Total = total
+	return &result, nil
+}
+
+// ParseAuditoriumsCSV reads an auditoriums CSV and validates every row with the
+// rules of AuditoriumRequest. Floors are checked against the building on import.
+func ParseAuditoriumsCSV(r io.Reader) (*AuditoriumImport, error) {
+	required := []string{"building_address_en", "auditorium_number", "floor_number", "capacity"}
+	var result AuditoriumImport
+	total, err := readImportCSV(r, AuditoriumImportColumns, required, func(row int, get func(string) string) {
+		var errs []ImportRowError
+		floor, err := strconv.Atoi(get("floor_number"))
+		if err != nil {
+			errs = append(errs, ImportRowError{Row: row, Column: "floor_number", Error: "must be an integer"})
+		}
+		capacity, err := strconv.Atoi(get("capacity"))
+		if err != nil {
+			errs = append(errs, ImportRowError{Row: row, Column: "capacity", Error: "must be an integer"})
+		}
+		auditoriumType, err := importAuditoriumType(get("type_en"), get("type_ru"))
+		if err != nil {
+			column := "type_en"
+			if get("type_en") == "" {
+				column = "type_ru"
+			}
+			errs = append(errs, ImportRowError{Row: row, Column: column, Error: err.Error()})
+		}
+		buildingAddress := get("building_address_en")
+		if buildingAddress == "" {
+			errs = append(errs, ImportRowError{Row: row, Column: "building_address_en", Error: "is required"})
+		}
+		if len(errs) > 0 {
+			result.Errors = append(result.Errors, errs...)
+			return
+		}
+
+		req := AuditoriumRequest{
+			FloorNumber:      floor,
+			Capacity:         capacity,
+			AuditoriumNumber: get("auditorium_number"),
+			Type:             auditoriumType,
+			ImageURL:         get("image_url"),
+		}
+		if s := get("fusion_strategy"); s != "" {
+			req.FusionStrategy = &s
+		}
+		if errs := importValidationErrors(row, &req, auditoriumImportFields); len(errs) > 0 {
+			result.Errors = append(result.Errors, errs...)
+			return
+		}
+		result.Rows = append(result.Rows, AuditoriumImportRow{Row: row, BuildingAddressEN: buildingAddress, Auditorium: req})
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Total = total
+	return &result, nil
+}
+
+// importAuditoriumType resolves the type from its English and/or Russian name.
+func importAuditoriumType(en, ru string) (string, error) {
+	if en == "" && ru == "" {
+		return "", errors.New("type_en or type_ru is required")
+	}
+	if en != "" {
+		if _, ok := AuditoriumTypeRU[en]; !ok {
+			return "", fmt.Errorf("unknown type %q (want coworking, classroom or lecture_hall)", en)
+		}
+		if ru != "" && AuditoriumTypeRU[en] != ru {
+			return "", fmt.Errorf("type_ru %q does not match type_en %q (%s)", ru, en, AuditoriumTypeRU[en])
+		}
+		return en, nil
+	}
+	for t, name := range AuditoriumTypeRU {
+		if strings.EqualFold(name, ru) {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("unknown type_ru %q", ru)
+}
+
+// readImportCSV checks the header against the allowed and required columns and
+// calls fn for every data row with a getter of trimmed values by column name.
+// Returns the number of data rows.
+func readImportCSV(r io.Reader, allowed, required []string, fn func(row int, get func(string) string)) (int, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return 0, fmt.Errorf("%w: file is empty", ErrImportFile)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrImportFile, err)
+	}
+
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !slices.Contains(allowed, name) {
+			return 0, fmt.Errorf("%w: unknown column %q (allowed: %s)", ErrImportFile, name, strings.Join(allowed, ", "))
+		}
+		if _, dup := index[name]; dup {
+			return 0, fmt.Errorf("%w: column %q appears twice", ErrImportFile, name)
+		}
+		index[name] = i
+	}
+	for _, name := range required {
+		if _, ok := index[name]; !ok {
+			return 0, fmt.Errorf("%w: missing column %q", ErrImportFile, name)
+		}
+	}
+
+	for total := 0; ; total++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%w: %w", ErrImportFile, err)
+		}
+		if total == MaxImportRows {
+			return 0, fmt.Errorf("%w: more than %d rows", ErrImportFile, MaxImportRows)
+		}
+		// Quoted values may span lines, so report the line the record starts on.
+		row, _ := reader.FieldPos(0)
+		fn(row, func(name string) string {
+			if i, ok := index[name]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		})
+	}
+}
+
+// CSV columns of the request fields, by validator namespace.
+var (
+	buildingImportFields = map[string]string{
+		"BuildingRequest.Address.RU":  "address_ru",
+		"BuildingRequest.Address.EN":  "address_en",
+		"BuildingRequest.FloorsCount": "floors_count",
+	}
+	auditoriumImportFields = map[string]string{
+		"AuditoriumRequest.Capacity":         "capacity",
+		"AuditoriumRequest.AuditoriumNumber": "auditorium_number",
+		"AuditoriumRequest.Type":             "type_en",
+		"AuditoriumRequest.ImageURL":         "image_url",
+		"AuditoriumRequest.FusionStrategy":   "fusion_strategy",
+	}
+)
+
+// importValidationErrors validates req and reports each failed rule against its CSV column.
+func importValidationErrors(row int, req interface{}, columns map[string]string) []ImportRowError {
+	err := validateRequest(req)
+	if err == nil {
+		return nil
+	}
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return []ImportRowError{{Row: row, Error: err.Error()}}
+	}
+	result := make([]ImportRowError, 0, len(verrs))
+	for _, fe := range verrs {
+		rule := fe.Tag()
+		if fe.Param() != "" {
+			rule += "=" + fe.Param()
+		}
+		result = append(result, ImportRowError{
+			Row:    row,
+			Column: columns[fe.Namespace()],
+			Error:  fmt.Sprintf("failed on the '%s' rule", rule),
+		})
+	}
+	return result
+}
+
+// validateRequest checks a request DTO with its `binding` tags, the same rules
+// gin applies to request bodies.
+func validateRequest(req interface{}) error {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.SetTagName("binding")
+	return v.Struct(req)
+}
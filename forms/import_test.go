@@ -0,0 +1,169 @@
+package forms
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBuildingsCSV(t *testing.T) {
+	tests := []struct {
+		name       string
+		csv        string
+		wantErr    string
+		wantTotal  int
+		wantRows   []BuildingImportRow
+		wantErrors []ImportRowError
+	}{
+		{
+			name: "valid rows in any column order",
+			csv: "floors_count,address_en,address_ru\n" +
+				"5,1 Lenina St,\"ул. Ленина, 1\"\n" +
+				" 3 , 2 Mira Ave ,пр. Мира 2\n",
+			wantTotal: 2,
+			wantRows: []BuildingImportRow{
+				{Row: 2, Building: BuildingRequest{Address: LocalizedStringRequest{RU: "ул. Ленина, 1", EN: "1 Lenina St"}, FloorsCount: 5}},
+				{Row: 3, Building: BuildingRequest{Address: LocalizedStringRequest{RU: "пр. Мира 2", EN: "2 Mira Ave"}, FloorsCount: 3}},
+			},
+		},
+		{
+			name:      "byte order mark and upper-case header",
+			csv:       "\ufeffADDRESS_RU,Address_EN,floors_count\nа,a,1\n",
+			wantTotal: 1,
+			wantRows: []BuildingImportRow{
+				{Row: 2, Building: BuildingRequest{Address: LocalizedStringRequest{RU: "а", EN: "a"}, FloorsCount: 1}},
+			},
+		},
+		{
+			name: "row errors name the line and column",
+			csv: "address_ru,address_en,floors_count\n" +
+				"а,a,many\n" +
+				"б,,-2\n" +
+				"\"в\nг\",c,2\n",
+			wantTotal: 3,
+			wantRows: []BuildingImportRow{
+				{Row: 4, Building: BuildingRequest{Address: LocalizedStringRequest{RU: "в\nг", EN: "c"}, FloorsCount: 2}},
+			},
+			wantErrors: []ImportRowError{
+				{Row: 2, Column: "floors_count", Error: "must be an integer"},
+				{Row: 3, Column: "address_en", Error: "failed on the 'required' rule"},
+				{Row: 3, Column: "floors_count", Error: "failed on the 'gte=1' rule"},
+			},
+		},
+		{name: "empty file", csv: "", wantErr: "invalid import file: file is empty"},
+		{name: "header only", csv: "address_ru,address_en,floors_count\n"},
+		{name: "unknown column", csv: "address_ru,address_en,floors_count,notes\n", wantErr: `unknown column "notes"`},
+		{name: "missing column", csv: "address_ru,address_en\n", wantErr: `missing column "floors_count"`},
+		{name: "duplicate column", csv: "address_ru,address_en,floors_count,address_en\n", wantErr: `column "address_en" appears twice`},
+		{name: "wrong number of fields", csv: "address_ru,address_en,floors_count\nа,a\n", wantErr: "wrong number of fields"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imp, err := ParseBuildingsCSV(strings.NewReader(tt.csv))
+			if tt.wantErr != "" {
+				assert.ErrorIs(t, err, ErrImportFile)
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantTotal, imp.Total)
+			assert.Equal(t, tt.wantRows, imp.Rows)
+			assert.Equal(t, tt.wantErrors, imp.Errors)
+		})
+	}
+}
+
+func TestParseBuildingsCSVRowLimit(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("address_ru,address_en,floors_count\n")
+	for i := 0; i < MaxImportRows; i++ {
+		fmt.Fprintf(&b, "д%d,b%d,1\n", i, i)
+	}
+	imp, err := ParseBuildingsCSV(strings.NewReader(b.String()))
+	require.NoError(t, err)
+	assert.Equal(t, MaxImportRows, imp.Total)
+
+	b.WriteString("one,more,1\n")
+	_, err = ParseBuildingsCSV(strings.NewReader(b.String()))
+	assert.ErrorIs(t, err, ErrImportFile)
+	assert.ErrorContains(t, err, "more than 5000 rows")
+}
+
+func TestParseAuditoriumsCSV(t *testing.T) {
+	const header = "building_address_en,auditorium_number,floor_number,capacity,type_en,type_ru,image_url,fusion_strategy\n"
+	median := "median"
+
+	tests := []struct {
+		name       string
+		csv        string
+		wantErr    string
+		wantRows   []AuditoriumImportRow
+		wantErrors []ImportRowError
+	}{
+		{
+			name: "valid rows",
+			csv: header +
+				"1 Lenina St,101,1,30,classroom,,,\n" +
+				"1 Lenina St,Актовый зал,-1,200,,Коворкинг,https://example.org/hall.jpg,median\n" +
+				"1 Lenina St,201,2,80,lecture_hall,лекционная,,\n",
+			wantRows: []AuditoriumImportRow{
+				{Row: 2, BuildingAddressEN: "1 Lenina St", Auditorium: AuditoriumRequest{
+					FloorNumber: 1, Capacity: 30, AuditoriumNumber: "101", Type: "classroom"}},
+				{Row: 3, BuildingAddressEN: "1 Lenina St", Auditorium: AuditoriumRequest{
+					FloorNumber: -1, Capacity: 200, AuditoriumNumber: "Актовый зал", Type: "coworking",
+					ImageURL: "https://example.org/hall.jpg", FusionStrategy: &median}},
+				{Row: 4, BuildingAddressEN: "1 Lenina St", Auditorium: AuditoriumRequest{
+					FloorNumber: 2, Capacity: 80, AuditoriumNumber: "201", Type: "lecture_hall"}},
+			},
+		},
+		{
+			name: "optional columns may be left out",
+			csv:  "building_address_en,auditorium_number,floor_number,capacity,type_ru\n1 Lenina St,101,1,30,учебная\n",
+			wantRows: []AuditoriumImportRow{
+				{Row: 2, BuildingAddressEN: "1 Lenina St", Auditorium: AuditoriumRequest{
+					FloorNumber: 1, Capacity: 30, AuditoriumNumber: "101", Type: "classroom"}},
+			},
+		},
+		{
+			name: "row errors",
+			csv: header +
+				"1 Lenina St,101,first,lots,classroom,,,\n" +
+				",102,1,30,,,,\n" +
+				"1 Lenina St,103,1,30,gym,,,\n" +
+				"1 Lenina St,104,1,30,classroom,лекционная,,\n" +
+				"1 Lenina St,105,1,30,,спортзал,,\n" +
+				"1 Lenina St,,1,-5,classroom,,not a url,avg\n",
+			wantErrors: []ImportRowError{
+				{Row: 2, Column: "floor_number", Error: "must be an integer"},
+				{Row: 2, Column: "capacity", Error: "must be an integer"},
+				{Row: 3, Column: "type_ru", Error: "type_en or type_ru is required"},
+				{Row: 3, Column: "building_address_en", Error: "is required"},
+				{Row: 4, Column: "type_en", Error: `unknown type "gym" (want coworking, classroom or lecture_hall)`},
+				{Row: 5, Column: "type_en", Error: `type_ru "лекционная" does not match type_en "classroom" (учебная)`},
+				{Row: 6, Column: "type_ru", Error: `unknown type_ru "спортзал"`},
+				{Row: 7, Column: "capacity", Error: "failed on the 'gte=1' rule"},
+				{Row: 7, Column: "auditorium_number", Error: "failed on the 'required' rule"},
+				{Row: 7, Column: "image_url", Error: "failed on the 'url' rule"},
+				{Row: 7, Column: "fusion_strategy", Error: "failed on the 'oneof=max sum median' rule"},
+			},
+		},
+		{name: "missing type is not a missing column", csv: "building_address_en,auditorium_number,floor_number\n", wantErr: `missing column "capacity"`},
+		{name: "building column of the buildings CSV", csv: "address_en,auditorium_number,floor_number,capacity\n", wantErr: `unknown column "address_en"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imp, err := ParseAuditoriumsCSV(strings.NewReader(tt.csv))
+			if tt.wantErr != "" {
+				assert.ErrorIs(t, err, ErrImportFile)
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantRows, imp.Rows)
+			assert.Equal(t, tt.wantErrors, imp.Errors)
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package forms
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"time"
 )
 
@@ -31,6 +34,8 @@ type Auditorium struct {
 	Type             string `gorm:"column:type;not null"`
 	TypeRU           string `gorm:"column:type_ru;not null"`
 	ImageURL         string `gorm:"column:image_url"`
+	// FusionStrategy overrides the service-wide multi-camera fusion strategy when set.
+	FusionStrategy *string `gorm:"column:fusion_strategy"`
 }
 
 func (Auditorium) TableName() string { return "auditorium" }
@@ -40,10 +45,62 @@ type Occupancy struct {
 	AuditoriumID uint      `gorm:"column:auditorium_id;not null;index"`
 	PersonCount  int       `gorm:"column:person_count;not null;check:person_count >= 0"`
 	Timestamp    time.Time `gorm:"column:timestamp;not null;type:timestamptz;default:now()"`
+	// FusionStrategy and Contributions describe how PersonCount was fused from cameras.
+	FusionStrategy *string             `gorm:"column:fusion_strategy"`
+	Contributions  CameraContributions `gorm:"column:contributions;type:jsonb"`
+	// EventKey identifies the camera event the row was stored from (see CameraEvent.DedupKey).
+	EventKey *string `gorm:"column:event_key"`
 }
 
 func (Occupancy) TableName() string { return "occupancy" }
 
+// CameraContribution is a single camera reading that took part in a fused occupancy count.
+type CameraContribution struct {
+	CameraID    uint      `json:"camera_id"`
+	Mac         string    `json:"mac"`
+	PersonCount int       `json:"person_count"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// CameraContributions is stored as a JSONB array in occupancy.contributions.
+type CameraContributions []CameraContribution
+
+// Value implements driver.Valuer.
+func (c CameraContributions) Value() (driver.Value, error) {
+	if c == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner.
+func (c *CameraContributions) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*c = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, c)
+	case string:
+		return json.Unmarshal([]byte(v), c)
+	default:
+		return fmt.Errorf("unsupported type %T for camera contributions", src)
+	}
+}
+
+// CameraReading holds the latest reading reported by a camera.
+type CameraReading struct {
+	CameraID    uint      `gorm:"column:camera_id;primaryKey"`
+	PersonCount int       `gorm:"column:person_count;not null"`
+	Timestamp   time.Time `gorm:"column:timestamp;not null;type:timestamptz"`
+}
+
+func (CameraReading) TableName() string { return "camerareading" }
+
 // DailyLoad holds aggregated per-day, per-hour occupancy averages.
 type DailyLoad struct {
 	ID             uint      `gorm:"primaryKey;column:id"`
@@ -58,6 +115,8 @@ func (DailyLoad) TableName() string { return "dailyload" }
 type Camera struct {
 	ID  uint   `gorm:"primaryKey;column:id"`
 	Mac string `gorm:"column:mac;size:17;not null;unique"`
+	// TokenHash is the SHA-256 (hex) of the token the camera uses for HTTP ingestion.
+	TokenHash *string `gorm:"column:token_hash"`
 }
 
 func (Camera) TableName() string { return "camera" }
@@ -76,3 +135,15 @@ func (CamerasInAuditorium) TableName() string { return "camerasinauditorium" }
 // 	Timestamp        time.Time `json:"timestamp"`
 // 	PersonCount      int       `json:"person_count"`
 // }
+
+// CameraHealth tracks when a camera was last heard from and how often it reports.
+type CameraHealth struct {
+	CameraID           uint      `gorm:"column:camera_id;primaryKey"`
+	LastSeenAt         time.Time `gorm:"column:last_seen_at;not null;type:timestamptz"`
+	LastEventAt        time.Time `gorm:"column:last_event_at;not null;type:timestamptz"`
+	LastPersonCount    int       `gorm:"column:last_person_count;not null"`
+	EventsTotal        int64     `gorm:"column:events_total;not null"`
+	AvgIntervalSeconds *float64  `gorm:"column:avg_interval_seconds"`
+}
+
+func (CameraHealth) TableName() string { return "camerahealth" }
@@ -0,0 +1,68 @@
+package forms
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// SeedFixture is an inventory of cities with their buildings, auditoriums and
+// cameras, loaded by cmd/seed. Entities are matched by natural keys so that a
+// fixture can be applied repeatedly: cities by name.en, buildings by address.en
+// within the city, auditoriums by auditorium_number within the building and
+// cameras by MAC.
+type SeedFixture struct {
+	Cities []SeedCity `json:"cities" binding:"dive"`
+}
+
+type SeedCity struct {
+	CityRequest
+	Buildings []SeedBuilding `json:"buildings" binding:"dive"`
+}
+
+type SeedBuilding struct {
+	BuildingRequest
+	Auditoriums []SeedAuditorium `json:"auditoriums" binding:"dive"`
+}
+
+type SeedAuditorium struct {
+	AuditoriumRequest
+	// Cameras are MACs attached to the auditorium (moved from another one if needed).
+	Cameras []string `json:"cameras" binding:"dive,len=17,mac"`
+}
+
+// LoadSeedFixture reads a fixture from a .json, .yaml or .yml file and validates
+// it with the same rules as the admin API requests. Unknown fields are rejected.
+func LoadSeedFixture(path string) (*SeedFixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture %s: %w", path, err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+	case ".yaml", ".yml":
+		if data, err = yaml.YAMLToJSON(data); err != nil {
+			return nil, fmt.Errorf("failed to parse fixture %s: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported fixture format %q (want .json, .yaml or .yml)", filepath.Ext(path))
+	}
+
+	var fixture SeedFixture
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&fixture); err != nil {
+		return nil, fmt.Errorf("failed to parse fixture %s: %w", path, err)
+	}
+
+	if err := validateRequest(&fixture); err != nil {
+		return nil, fmt.Errorf("invalid fixture %s: %w", path, err)
+	}
+	return &fixture, nil
+}
@@ -0,0 +1,61 @@
+package forms
+
+import "errors"
+
+// Scopes a client of the occupancy socket can subscribe to.
+const (
+	ScopeAuditorium = "auditorium"
+	ScopeBuilding   = "building"
+	ScopeCity       = "city"
+)
+
+// Actions of a SubscriptionRequest.
+const (
+	ActionSubscribe   = "subscribe"
+	ActionUnsubscribe = "unsubscribe"
+)
+
+// Types of the messages sent on the occupancy socket.
+const (
+	MessageSubscribed   = "subscribed"
+	MessageUnsubscribed = "unsubscribed"
+	MessageError        = "error"
+	MessageOccupancy    = "occupancy"
+)
+
+// SubscriptionRequest is a control message sent by a client of the occupancy
+// socket, e.g. {"action":"subscribe","scope":"building","id":3}.
+type SubscriptionRequest struct {
+	Action string `json:"action" binding:"required,oneof=subscribe unsubscribe"`
+	Scope  string `json:"scope" binding:"required,oneof=auditorium building city"`
+	ID     uint   `json:"id" binding:"required"`
+}
+
+// Validate checks the request with its binding tags.
+func (r *SubscriptionRequest) Validate() error {
+	if err := validateRequest(r); err != nil {
+		return errors.New("action must be subscribe or unsubscribe, scope must be auditorium, building or city, id is required")
+	}
+	return nil
+}
+
+// SubscriptionReply answers a control message. Subscriptions is the number of
+// subscriptions of the connection after the message was applied.
+type SubscriptionReply struct {
+	Type          string `json:"type"`
+	Action        string `json:"action,omitempty"`
+	Scope         string `json:"scope,omitempty"`
+	ID            uint   `json:"id,omitempty"`
+	Subscriptions int    `json:"subscriptions"`
+	Error         string `json:"error,omitempty"`
+}
+
+// OccupancyMessage is a stored reading pushed on the occupancy socket: the
+// fields of AuditoriumOccupancyResponse plus the building and city of the
+// auditorium.
+type OccupancyMessage struct {
+	Type       string `json:"type"`
+	CityID     uint   `json:"city_id"`
+	BuildingID uint   `json:"building_id"`
+	AuditoriumOccupancyResponse
+}
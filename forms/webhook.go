@@ -0,0 +1,191 @@
+package forms
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook rules.
+const (
+	// RuleAbove triggers when occupancy reaches ThresholdPercent of capacity and
+	// clears when it falls below ThresholdPercent - HysteresisPercent.
+	RuleAbove = "above"
+	// RuleEmpty triggers when nobody is in the room and clears when occupancy
+	// rises above HysteresisPercent of capacity (and at least one person).
+	RuleEmpty = "empty"
+)
+
+// Webhook delivery statuses.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook calls URL when its rule is triggered or cleared for an auditorium of
+// the building, or only for AuditoriumID when set.
+type Webhook struct {
+	ID                uint      `gorm:"primaryKey;column:id"`
+	BuildingID        uint      `gorm:"column:building_id;not null;index"`
+	AuditoriumID      *uint     `gorm:"column:auditorium_id"`
+	URL               string    `gorm:"column:url;not null"`
+	Secret            string    `gorm:"column:secret;not null"`
+	Rule              string    `gorm:"column:rule;not null"`
+	ThresholdPercent  float64   `gorm:"column:threshold_percent;not null"`
+	HysteresisPercent float64   `gorm:"column:hysteresis_percent;not null"`
+	CreatedAt         time.Time `gorm:"column:created_at;not null;type:timestamptz"`
+}
+
+func (Webhook) TableName() string { return "webhook" }
+
+// Triggered applies the rule to a reading of an auditorium, given whether the
+// rule is triggered for it now, and returns whether it is triggered after the
+// reading. Between the trigger and clear levels the state does not change, so a
+// count flapping around one level sends nothing.
+func (w *Webhook) Triggered(active bool, personCount, capacity int) bool {
+	var rate float64
+	if capacity > 0 {
+		rate = float64(personCount) / float64(capacity) * 100
+	}
+	switch w.Rule {
+	case RuleAbove:
+		if capacity <= 0 {
+			return active
+		}
+		if active {
+			return rate >= w.ThresholdPercent-w.HysteresisPercent
+		}
+		return rate >= w.ThresholdPercent
+	case RuleEmpty:
+		if active {
+			return personCount == 0 || (capacity > 0 && rate <= w.HysteresisPercent)
+		}
+		return personCount == 0
+	}
+	return active
+}
+
+// WebhookState records whether a webhook's rule is triggered for an auditorium
+// as of the reading at ReadingAt.
+type WebhookState struct {
+	WebhookID    uint      `gorm:"column:webhook_id;primaryKey"`
+	AuditoriumID uint      `gorm:"column:auditorium_id;primaryKey"`
+	Active       bool      `gorm:"column:active;not null"`
+	ReadingAt    time.Time `gorm:"column:reading_at;not null;type:timestamptz"`
+}
+
+func (WebhookState) TableName() string { return "webhookstate" }
+
+// WebhookDelivery is one call of a webhook, pending until it is delivered or
+// runs out of attempts.
+type WebhookDelivery struct {
+	ID             uint64     `gorm:"primaryKey;column:id"`
+	WebhookID      uint       `gorm:"column:webhook_id;not null;index"`
+	Event          string     `gorm:"column:event;not null"`
+	Payload        string     `gorm:"column:payload;type:jsonb;not null"`
+	Status         string     `gorm:"column:status;not null"`
+	Attempts       int        `gorm:"column:attempts;not null"`
+	NextAttemptAt  time.Time  `gorm:"column:next_attempt_at;not null;type:timestamptz"`
+	LastStatusCode *int       `gorm:"column:last_status_code"`
+	LastError      *string    `gorm:"column:last_error"`
+	CreatedAt      time.Time  `gorm:"column:created_at;not null;type:timestamptz"`
+	DeliveredAt    *time.Time `gorm:"column:delivered_at;type:timestamptz"`
+}
+
+func (WebhookDelivery) TableName() string { return "webhookdelivery" }
+
+// WebhookPayload is the JSON body of a webhook call. Event is the rule followed
+// by ".triggered" or ".cleared", e.g. "above.triggered".
+type WebhookPayload struct {
+	Event            string    `json:"event"`
+	WebhookID        uint      `json:"webhook_id"`
+	Rule             string    `json:"rule"`
+	ThresholdPercent float64   `json:"threshold_percent,omitempty"`
+	CityID           uint      `json:"city_id"`
+	BuildingID       uint      `json:"building_id"`
+	AuditoriumID     uint      `json:"auditorium_id"`
+	PersonCount      int       `json:"person_count"`
+	Capacity         int       `json:"capacity"`
+	OccupancyRate    *float64  `json:"occupancy_rate"`
+	Timestamp        time.Time `json:"timestamp"`
+}
+
+// WebhookRequest is the body of POST /v1/cities/:city_id/buildings/:building_id/webhooks.
+// A secret is generated when none is given; either way it is returned only in
+// the response to this request.
+type WebhookRequest struct {
+	URL               string   `json:"url" binding:"required,http_url,max=2000"`
+	Secret            string   `json:"secret" binding:"omitempty,min=16,max=255"`
+	AuditoriumID      *uint    `json:"auditorium_id" binding:"omitempty,gte=1"`
+	Rule              string   `json:"rule" binding:"required,oneof=above empty"`
+	ThresholdPercent  *float64 `json:"threshold_percent" binding:"omitempty,gt=0,lte=1000"`
+	HysteresisPercent *float64 `json:"hysteresis_percent" binding:"omitempty,gte=0,lte=100"`
+}
+
+// WebhookResponse is a webhook as returned by the API.
+type WebhookResponse struct {
+	ID                uint      `json:"id"`
+	BuildingID        uint      `json:"building_id"`
+	AuditoriumID      *uint     `json:"auditorium_id"`
+	URL               string    `json:"url"`
+	Rule              string    `json:"rule"`
+	ThresholdPercent  float64   `json:"threshold_percent"`
+	HysteresisPercent float64   `json:"hysteresis_percent"`
+	CreatedAt         time.Time `json:"created_at"`
+	// Secret is only set in the response to the creating request.
+	Secret string `json:"secret,omitempty"`
+}
+
+// ToWebhookResponse converts a Webhook model to WebhookResponse without the secret
+func (w *Webhook) ToWebhookResponse() WebhookResponse {
+	return WebhookResponse{
+		ID:                w.ID,
+		BuildingID:        w.BuildingID,
+		AuditoriumID:      w.AuditoriumID,
+		URL:               w.URL,
+		Rule:              w.Rule,
+		ThresholdPercent:  w.ThresholdPercent,
+		HysteresisPercent: w.HysteresisPercent,
+		CreatedAt:         w.CreatedAt,
+	}
+}
+
+// WebhookDeliveryQuery filters the delivery log (?status=&limit=).
+type WebhookDeliveryQuery struct {
+	Status string `form:"status" binding:"omitempty,oneof=pending delivered failed"`
+	Limit  int    `form:"limit" binding:"omitempty,gte=1,lte=1000"`
+}
+
+// WebhookDeliveryResponse is an entry of the delivery log.
+type WebhookDeliveryResponse struct {
+	ID             uint64          `json:"id"`
+	Event          string          `json:"event"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	LastStatusCode *int            `json:"last_status_code,omitempty"`
+	LastError      *string         `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	Payload        json.RawMessage `json:"payload"`
+}
+
+// ToWebhookDeliveryResponse converts a WebhookDelivery model to WebhookDeliveryResponse
+func (d *WebhookDelivery) ToWebhookDeliveryResponse() WebhookDeliveryResponse {
+	resp := WebhookDeliveryResponse{
+		ID:             d.ID,
+		Event:          d.Event,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		LastStatusCode: d.LastStatusCode,
+		LastError:      d.LastError,
+		CreatedAt:      d.CreatedAt,
+		DeliveredAt:    d.DeliveredAt,
+		Payload:        json.RawMessage(d.Payload),
+	}
+	if d.Status == DeliveryPending {
+		next := d.NextAttemptAt
+		resp.NextAttemptAt = &next
+	}
+	return resp
+}
@@ -0,0 +1,62 @@
+package forms
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookTriggered(t *testing.T) {
+	above := &Webhook{Rule: RuleAbove, ThresholdPercent: 80, HysteresisPercent: 10}
+	empty := &Webhook{Rule: RuleEmpty, HysteresisPercent: 10}
+
+	tests := []struct {
+		name        string
+		hook        *Webhook
+		active      bool
+		personCount int
+		capacity    int
+		want        bool
+	}{
+		{name: "above: below threshold", hook: above, personCount: 79, capacity: 100, want: false},
+		{name: "above: reaches threshold", hook: above, personCount: 80, capacity: 100, want: true},
+		{name: "above: over capacity", hook: above, personCount: 130, capacity: 100, want: true},
+		{name: "above: stays inside hysteresis", hook: above, active: true, personCount: 75, capacity: 100, want: true},
+		{name: "above: at clear level", hook: above, active: true, personCount: 70, capacity: 100, want: true},
+		{name: "above: below clear level", hook: above, active: true, personCount: 69, capacity: 100, want: false},
+		{name: "above: inside hysteresis does not trigger", hook: above, personCount: 75, capacity: 100, want: false},
+		{name: "above: rounding of small rooms", hook: above, personCount: 4, capacity: 5, want: true},
+		{name: "above: no capacity keeps inactive", hook: above, personCount: 500, capacity: 0, want: false},
+		{name: "above: no capacity keeps active", hook: above, active: true, personCount: 0, capacity: 0, want: true},
+		{name: "above: no hysteresis clears below threshold", hook: &Webhook{Rule: RuleAbove, ThresholdPercent: 50}, active: true, personCount: 49, capacity: 100, want: false},
+
+		{name: "empty: nobody", hook: empty, personCount: 0, capacity: 100, want: true},
+		{name: "empty: somebody", hook: empty, personCount: 1, capacity: 100, want: false},
+		{name: "empty: stays inside hysteresis", hook: empty, active: true, personCount: 10, capacity: 100, want: true},
+		{name: "empty: clears above hysteresis", hook: empty, active: true, personCount: 11, capacity: 100, want: false},
+		{name: "empty: no capacity clears on anybody", hook: empty, active: true, personCount: 1, capacity: 0, want: false},
+		{name: "empty: no capacity, nobody", hook: empty, personCount: 0, capacity: 0, want: true},
+
+		{name: "unknown rule keeps state", hook: &Webhook{Rule: "full"}, active: true, personCount: 0, capacity: 10, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.hook.Triggered(tt.active, tt.personCount, tt.capacity))
+		})
+	}
+}
+
+// A count flapping around the threshold triggers once and clears once.
+func TestWebhookTriggeredFlapping(t *testing.T) {
+	hook := &Webhook{Rule: RuleAbove, ThresholdPercent: 80, HysteresisPercent: 10}
+	active, changes := false, 0
+	for _, count := range []int{79, 80, 79, 81, 72, 80, 71, 69, 79, 70} {
+		next := hook.Triggered(active, count, 100)
+		if next != active {
+			changes++
+		}
+		active = next
+	}
+	assert.Equal(t, 2, changes)
+	assert.False(t, active)
+}
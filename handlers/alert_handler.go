@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	"web_backend_v2/forms"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// AlertController lists the alerts raised by alerting.Evaluator and
+// acknowledges them.
+type AlertController struct {
+	*Stores
+}
+
+// GetAlerts handles GET /v1/alerts
+// Filters: ?status=firing|resolved, ?kind=stale|over_capacity|spike,
+// ?city_id, ?building_id, ?auditorium_id, ?camera_id, ?acknowledged=true|false;
+// the latest alerts first (?limit=, default 100). A scoped principal only gets
+// the alerts of its cities and buildings.
+func (h *AlertController) GetAlerts(c *gin.Context) {
+	var q forms.AlertQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be firing or resolved, kind must be stale, over_capacity or spike, limit must be between 1 and 1000"})
+		return
+	}
+	if q.Limit == 0 {
+		q.Limit = 100
+	}
+	scope := scopeOf(c)
+	q.ScopeCityIDs, q.ScopeBuildingIDs = scope.CityIDs, scope.BuildingIDs
+
+	alerts, err := h.Alerts.GetAlerts(q)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, alerts)
+}
+
+// GetAlert handles GET /v1/alerts/:alert_id
+func (h *AlertController) GetAlert(c *gin.Context) {
+	alertID, ok := parseAlertID(c)
+	if !ok {
+		return
+	}
+	alert, err := h.Alerts.GetAlert(alertID)
+	if err != nil {
+		writeAlertError(c, err)
+		return
+	}
+	if !scopeOf(c).AllowsBuilding(alert.CityID, alert.BuildingID) {
+		forbidOutOfScope(c)
+		return
+	}
+	c.JSON(http.StatusOK, alert)
+}
+
+// AcknowledgeAlert handles POST /v1/alerts/:alert_id/ack
+// The body {"note": "..."} is optional. Acknowledging again keeps the first
+// acknowledgement.
+func (h *AlertController) AcknowledgeAlert(c *gin.Context) {
+	alertID, ok := parseAlertID(c)
+	if !ok {
+		return
+	}
+	var req forms.AlertAckRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if scope := scopeOf(c); !scope.Unrestricted() {
+		alert, err := h.Alerts.GetAlert(alertID)
+		if err != nil {
+			writeAlertError(c, err)
+			return
+		}
+		if !scope.AllowsBuilding(alert.CityID, alert.BuildingID) {
+			forbidOutOfScope(c)
+			return
+		}
+	}
+
+	alert, err := h.Alerts.AcknowledgeAlert(alertID, req.Note, time.Now().UTC())
+	if err != nil {
+		writeAlertError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, alert)
+}
+
+// parseAlertID reads :alert_id, writing a 400 itself.
+func parseAlertID(c *gin.Context) (uint64, bool) {
+	alertID, err := strconv.ParseUint(c.Param("alert_id"), 10, 64)
+	if err != nil || alertID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "alert_id must be a positive integer"})
+		return 0, false
+	}
+	return alertID, true
+}
+
+func writeAlertError(c *gin.Context, err error) {
+	if err == gorm.ErrRecordNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "alert not found"})
+		return
+	}
+	log.Println(err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
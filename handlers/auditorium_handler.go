@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -15,12 +16,15 @@ import (
 
 // GetAuditoriumsByBuilding handles GET /v1/buildings/:building_id/auditoriums
 // Returns a list of auditoriums in a specific building
-var AuditoriumModel = new(models.AuditoryModel)
-
-type AuditoriumController struct{}
+type AuditoriumController struct {
+	*Stores
+}
 
 const maxFreshMinutes = 5
 
+// maxSeriesRange limits how much history a single series request may span.
+const maxSeriesRange = 31 * 24 * time.Hour
+
 func (b *AuditoriumController) GetAuditoriumsByBuilding(c *gin.Context) {
 	BuildingIDStr := c.Param("building_id")
 
@@ -47,7 +51,7 @@ func (b *AuditoriumController) GetAuditoriumsByBuilding(c *gin.Context) {
 		return
 	}
 
-	auditoriums, err := AuditoriumModel.GetAuditoriumsByBuilding(BuildingID)
+	auditoriums, err := b.Auditoriums.GetAuditoriumsByBuilding(BuildingID)
 	if err != nil {
 		log.Println(err)
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -85,7 +89,7 @@ func (b *AuditoriumController) GetOccupancyByBuilding(c *gin.Context) {
 		return
 	}
 
-	occupancies, err := AuditoriumModel.GetLatestOccupancyByBuilding(buildingID, q.Timestamp, maxFreshMinutes)
+	occupancies, err := b.Auditoriums.GetLatestOccupancyByBuilding(buildingID, q.Timestamp, maxFreshMinutes)
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusOK, gin.H{
@@ -102,6 +106,62 @@ func (b *AuditoriumController) GetOccupancyByBuilding(c *gin.Context) {
 	c.JSON(http.StatusOK, forms.BuildingOccupancyResponse(occupancies))
 }
 
+// GetBuildingOccupancySummary handles GET /v1/cities/:city_id/buildings/:building_id/occupancy/summary
+// Totals of the latest readings at ?timestamp= for the building and each of its floors.
+func (b *AuditoriumController) GetBuildingOccupancySummary(c *gin.Context) {
+	building, ok := b.scopedBuilding(c)
+	if !ok {
+		return
+	}
+
+	var q forms.OccupancyQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "timestamp is required in RFC3339"})
+		return
+	}
+
+	summary, err := b.Auditoriums.GetBuildingOccupancySummary(building, q.Timestamp, maxFreshMinutes)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, summary)
+}
+
+// GetCityOccupancySummary handles GET /v1/cities/:city_id/occupancy/summary
+// Totals of the latest readings at ?timestamp= for the city and each of its buildings.
+func (b *AuditoriumController) GetCityOccupancySummary(c *gin.Context) {
+	cityID, err := parseUintParam(c, "city_id")
+	if err != nil {
+		return
+	}
+
+	var q forms.OccupancyQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "timestamp is required in RFC3339"})
+		return
+	}
+
+	if _, err := b.Cities.GetCity(cityID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "city not found"})
+			return
+		}
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	summary, err := b.Auditoriums.GetCityOccupancySummary(cityID, q.Timestamp, maxFreshMinutes)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, summary)
+}
+
 // GetOccupancyByAuditorium handles GET /v1/cities/:city_id/buildings/:building_id/auditories/:auditorium_id/occupancy
 func (b *AuditoriumController) GetOccupancyByAuditorium(c *gin.Context) {
 	auditoriumID, err := parseUintParam(c, "auditorium_id")
@@ -115,7 +175,7 @@ func (b *AuditoriumController) GetOccupancyByAuditorium(c *gin.Context) {
 		return
 	}
 
-	occupancy, err := AuditoriumModel.GetLatestOccupancyForAuditorium(auditoriumID, q.Timestamp, maxFreshMinutes)
+	occupancy, err := b.Auditoriums.GetLatestOccupancyForAuditorium(auditoriumID, q.Timestamp, maxFreshMinutes)
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "no occupancy data found"})
@@ -129,6 +189,110 @@ func (b *AuditoriumController) GetOccupancyByAuditorium(c *gin.Context) {
 	c.JSON(http.StatusOK, occupancy)
 }
 
+// GetOccupancySeries handles GET /v1/cities/:city_id/buildings/:building_id/auditories/:auditorium_id/occupancy/series
+func (b *AuditoriumController) GetOccupancySeries(c *gin.Context) {
+	auditoriumID, err := parseUintParam(c, "auditorium_id")
+	if err != nil {
+		return
+	}
+
+	var q forms.OccupancySeriesQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from and to are required in RFC3339, bucket must be one of 5m, 15m, 1h"})
+		return
+	}
+	if q.Bucket == "" {
+		q.Bucket = "1h"
+	}
+	if !q.From.Before(q.To) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+	if q.To.Sub(q.From) > maxSeriesRange {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("range must not exceed %d days", int(maxSeriesRange.Hours()/24))})
+		return
+	}
+
+	exists, err := b.Auditoriums.Exists(auditoriumID)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify auditorium"})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "auditorium not found"})
+		return
+	}
+
+	points, err := b.Auditoriums.GetOccupancySeries(auditoriumID, q.From, q.To, forms.SeriesBuckets[q.Bucket])
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if points == nil {
+		points = []forms.OccupancySeriesPoint{}
+	}
+
+	c.JSON(http.StatusOK, forms.OccupancySeriesResponse{
+		AuditoriumID: auditoriumID,
+		From:         q.From.UTC(),
+		To:           q.To.UTC(),
+		Bucket:       q.Bucket,
+		Points:       points,
+	})
+}
+
+// GetFreeAuditoriums handles GET /v1/cities/:city_id/free-auditoriums
+// Ranks the city's auditoriums (optionally of one building_id, type and floor)
+// by free seats at ?at= (RFC3339, default now). Rooms without a reading in the
+// last maxFreshMinutes are left out unless include_stale=true, in which case
+// they are listed after the fresh ones with is_fresh false.
+func (b *AuditoriumController) GetFreeAuditoriums(c *gin.Context) {
+	cityID, err := parseUintParam(c, "city_id")
+	if err != nil {
+		return
+	}
+
+	var q forms.FreeAuditoriumsQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if q.At.IsZero() {
+		q.At = time.Now()
+	}
+
+	if _, err := b.Cities.GetCity(cityID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "city not found"})
+			return
+		}
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if q.BuildingID != 0 {
+		if _, err := b.Buildings.GetBuilding(cityID, q.BuildingID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "building not found"})
+				return
+			}
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	rooms, err := b.Auditoriums.FindFreeAuditoriums(cityID, q, maxFreshMinutes)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rooms)
+}
+
 // GetStatisticsByAuditorium handles GET /v1/cities/:city_id/buildings/:building_id/auditories/:auditorium_id/statistics
 func (b *AuditoriumController) GetStatisticsByAuditorium(c *gin.Context) {
 	auditoriumID, err := parseUintParam(c, "auditorium_id")
@@ -151,11 +315,17 @@ func (b *AuditoriumController) GetStatisticsByAuditorium(c *gin.Context) {
 	// Default to type 1 (Absolute) if not provided or zero
 	statsType := q.Type
 	if statsType == 0 {
-		statsType = 1
+		statsType = forms.StatsTypeAbsolute
+	}
+	if statsType != forms.StatsTypeAbsolute && statsType != forms.StatsTypeOccupancyRate {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("unknown statistics type %d, expected 1 (absolute) or 2 (occupancy rate)", statsType),
+		})
+		return
 	}
 
 	// Ensure auditorium exists
-	exists, err := AuditoriumModel.Exists(auditoriumID)
+	exists, err := b.Auditoriums.Exists(auditoriumID)
 	if err != nil {
 		log.Println(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify auditorium"})
@@ -166,8 +336,20 @@ func (b *AuditoriumController) GetStatisticsByAuditorium(c *gin.Context) {
 		return
 	}
 
-	stats, noData, err := AuditoriumModel.GetAuditoriumStats(auditoriumID, day, statsType)
+	var (
+		stats  interface{}
+		noData bool
+	)
+	if statsType == forms.StatsTypeOccupancyRate {
+		stats, noData, err = b.Auditoriums.GetAuditoriumOccupancyRate(auditoriumID, day)
+	} else {
+		stats, noData, err = b.Auditoriums.GetAuditoriumStats(auditoriumID, day)
+	}
 	if err != nil {
+		if errors.Is(err, models.ErrCapacityNotSet) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
 		log.Println(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -183,3 +365,153 @@ func (b *AuditoriumController) GetStatisticsByAuditorium(c *gin.Context) {
 
 	c.JSON(http.StatusOK, stats)
 }
+
+// CreateAuditorium handles POST /v1/cities/:city_id/buildings/:building_id/auditories
+func (b *AuditoriumController) CreateAuditorium(c *gin.Context) {
+	building, ok := b.scopedBuilding(c)
+	if !ok {
+		return
+	}
+
+	var req forms.AuditoriumRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	created := req.ToAuditorium(0, building.ID)
+	if err := b.Auditoriums.CreateAuditorium(&created); err != nil {
+		respondAuditoriumSaveError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, created.ToAuditoriumResponse())
+}
+
+// UpdateAuditorium handles PUT /v1/cities/:city_id/buildings/:building_id/auditories/:auditorium_id
+func (b *AuditoriumController) UpdateAuditorium(c *gin.Context) {
+	b.saveAuditorium(c, false)
+}
+
+// PatchAuditorium handles PATCH /v1/cities/:city_id/buildings/:building_id/auditories/:auditorium_id
+func (b *AuditoriumController) PatchAuditorium(c *gin.Context) {
+	b.saveAuditorium(c, true)
+}
+
+func (b *AuditoriumController) saveAuditorium(c *gin.Context, patch bool) {
+	building, ok := b.scopedBuilding(c)
+	if !ok {
+		return
+	}
+	auditoriumID, err := parseUintParam(c, "auditorium_id")
+	if err != nil {
+		return
+	}
+
+	existing, err := b.Auditoriums.GetAuditorium(building.ID, auditoriumID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "auditorium not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	var req forms.AuditoriumRequest
+	if patch {
+		req = existing.ToAuditoriumRequest()
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated := req.ToAuditorium(auditoriumID, building.ID)
+	if err := b.Auditoriums.UpdateAuditorium(&updated); err != nil {
+		respondAuditoriumSaveError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, updated.ToAuditoriumResponse())
+}
+
+// DeleteAuditorium handles DELETE /v1/cities/:city_id/buildings/:building_id/auditories/:auditorium_id
+// An auditorium with attached cameras is only deleted with ?confirm=true.
+func (b *AuditoriumController) DeleteAuditorium(c *gin.Context) {
+	building, ok := b.scopedBuilding(c)
+	if !ok {
+		return
+	}
+	auditoriumID, err := parseUintParam(c, "auditorium_id")
+	if err != nil {
+		return
+	}
+
+	if _, err := b.Auditoriums.GetAuditorium(building.ID, auditoriumID); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "auditorium not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	cameras, err := b.Cameras.GetCamerasByAuditorium(auditoriumID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(cameras) > 0 && c.Query("confirm") != "true" {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":           "auditorium has attached cameras",
+			"cameras_count":   len(cameras),
+			"require_confirm": true,
+			"confirm_hint":    "repeat request with ?confirm=true to delete the auditorium and detach its cameras",
+		})
+		return
+	}
+
+	if err := b.Auditoriums.DeleteAuditorium(building.ID, auditoriumID); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "auditorium not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// scopedBuilding resolves :building_id within :city_id, writing the error response itself.
+func (s *Stores) scopedBuilding(c *gin.Context) (*forms.Building, bool) {
+	cityID, err := parseUintParam(c, "city_id")
+	if err != nil {
+		return nil, false
+	}
+	buildingID, err := parseUintParam(c, "building_id")
+	if err != nil {
+		return nil, false
+	}
+
+	building, err := s.Buildings.GetBuilding(cityID, buildingID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "building not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return nil, false
+	}
+	return building, true
+}
+
+func respondAuditoriumSaveError(c *gin.Context, err error) {
+	switch {
+	case err == gorm.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "auditorium not found"})
+	case errors.Is(err, models.ErrFloorOutOfRange):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
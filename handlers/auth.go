@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"web_backend_v2/auth"
+	"web_backend_v2/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// principalContextKey holds the *auth.Principal set by Authenticate.
+const principalContextKey = "principal"
+
+// Role sets used by setupRouter.
+var (
+	ReadRoles  = []string{auth.RoleViewer, auth.RoleFacilityAdmin}
+	AdminRoles = []string{auth.RoleFacilityAdmin}
+)
+
+// Authenticate rejects requests without valid credentials with 401. With a nil
+// authenticator (authentication disabled) every request is auth.Anonymous.
+func Authenticate(a *auth.Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !authenticate(c, a) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireRole rejects authenticated requests whose principal has none of the
+// roles with 403.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !authorize(c, roles) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// Authorize is RequireRole(read...) for GET and HEAD requests and
+// RequireRole(write...) for the other methods.
+func Authorize(read, write []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		roles := write
+		if isRead(c) {
+			roles = read
+		}
+		if !authorize(c, roles) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// DeviceAuth guards POST /v1/events. A camera token (see CameraTokenAuth) lets
+// a camera post its own events; an API key or JWT with the device role lets a
+// gateway post events of any camera. Without an authenticator only camera
+// tokens are accepted, as before authentication was added.
+func DeviceAuth(a *auth.Authenticator, cameras models.CameraStore) gin.HandlerFunc {
+	cameraAuth := CameraTokenAuth(cameras)
+	return func(c *gin.Context) {
+		if a == nil || c.GetHeader("X-Camera-Token") != "" || !auth.Carries(c.Request) {
+			cameraAuth(c)
+			return
+		}
+		if !authenticate(c, a) || !authorize(c, []string{auth.RoleDevice}) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// PrincipalFrom returns the principal set by Authenticate, or nil.
+func PrincipalFrom(c *gin.Context) *auth.Principal {
+	if v, ok := c.Get(principalContextKey); ok {
+		return v.(*auth.Principal)
+	}
+	return nil
+}
+
+// authenticate sets the principal of the request or writes the 401 itself.
+func authenticate(c *gin.Context, a *auth.Authenticator) bool {
+	if a == nil {
+		c.Set(principalContextKey, auth.Anonymous)
+		return true
+	}
+	principal, err := a.Authenticate(c.Request)
+	if err != nil {
+		c.Header("WWW-Authenticate", `Bearer realm="web_backend_v2"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return false
+	}
+	c.Set(principalContextKey, principal)
+	return true
+}
+
+// authorize checks the role of the principal set by authenticate or writes the
+// 403 itself.
+func authorize(c *gin.Context, roles []string) bool {
+	principal := PrincipalFrom(c)
+	if principal == nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": auth.ErrNoCredentials.Error()})
+		return false
+	}
+	if !principal.HasRole(roles...) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden: requires role " + strings.Join(roles, " or ")})
+		return false
+	}
+	return true
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -9,12 +10,14 @@ import (
 	"web_backend_v2/models"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-var BuildingModel = new(models.BuildingModel)
-
-type BuildingController struct{}
+type BuildingController struct {
+	*Stores
+}
 
+// GetBuildingsByCity handles GET /v1/cities/:city_id/buildings
 func (b *BuildingController) GetBuildingsByCity(c *gin.Context) {
 	// Parse city_id from path parameter
 	cityIDStr := c.Param("city_id")
@@ -39,13 +42,23 @@ func (b *BuildingController) GetBuildingsByCity(c *gin.Context) {
 		return
 	}
 
-	buildings, err := BuildingModel.GetBuildingsByCity(cityID)
+	buildings, err := b.Buildings.GetBuildingsByCity(cityID)
 	if err != nil {
 		log.Println(err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": fmt.Sprintln(err),
 		})
 	}
+	// A principal scoped to single buildings of the city only sees those
+	if scope := scopeOf(c); !scope.AllowsCity(cityID) {
+		kept := buildings[:0]
+		for _, building := range buildings {
+			if scope.AllowsBuilding(cityID, building.ID) {
+				kept = append(kept, building)
+			}
+		}
+		buildings = kept
+	}
 	// Convert to response format
 	response := make([]forms.BuildingResponse, len(buildings))
 	for i := range buildings {
@@ -54,3 +67,131 @@ func (b *BuildingController) GetBuildingsByCity(c *gin.Context) {
 
 	c.JSON(http.StatusOK, response)
 }
+
+// CreateBuilding handles POST /v1/cities/:city_id/buildings
+func (b *BuildingController) CreateBuilding(c *gin.Context) {
+	cityID, err := parseUintParam(c, "city_id")
+	if err != nil {
+		return
+	}
+
+	var req forms.BuildingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	created := req.ToBuilding(0, cityID)
+	if err := b.Buildings.CreateBuilding(&created); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "city not found"})
+		} else {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, created.ToBuildingResponse())
+}
+
+// UpdateBuilding handles PUT /v1/cities/:city_id/buildings/:building_id (full replace)
+func (b *BuildingController) UpdateBuilding(c *gin.Context) {
+	b.saveBuilding(c, false)
+}
+
+// PatchBuilding handles PATCH /v1/cities/:city_id/buildings/:building_id
+func (b *BuildingController) PatchBuilding(c *gin.Context) {
+	b.saveBuilding(c, true)
+}
+
+func (b *BuildingController) saveBuilding(c *gin.Context, patch bool) {
+	cityID, err := parseUintParam(c, "city_id")
+	if err != nil {
+		return
+	}
+	buildingID, err := parseUintParam(c, "building_id")
+	if err != nil {
+		return
+	}
+
+	existing, err := b.Buildings.GetBuilding(cityID, buildingID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "building not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	var req forms.BuildingRequest
+	if patch {
+		req = existing.ToBuildingRequest()
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated := req.ToBuilding(buildingID, cityID)
+	if err := b.Buildings.UpdateBuilding(&updated); err != nil {
+		switch {
+		case err == gorm.ErrRecordNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "building not found"})
+		case errors.Is(err, models.ErrFloorOutOfRange):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, updated.ToBuildingResponse())
+}
+
+// DeleteBuilding handles DELETE /v1/cities/:city_id/buildings/:building_id
+// A building that still has auditoriums is only deleted with ?confirm=true.
+func (b *BuildingController) DeleteBuilding(c *gin.Context) {
+	cityID, err := parseUintParam(c, "city_id")
+	if err != nil {
+		return
+	}
+	buildingID, err := parseUintParam(c, "building_id")
+	if err != nil {
+		return
+	}
+
+	if _, err := b.Buildings.GetBuilding(cityID, buildingID); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "building not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	auditoriums, err := b.Buildings.CountAuditoriums(buildingID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if auditoriums > 0 && c.Query("confirm") != "true" {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":             "building still has auditoriums",
+			"auditoriums_count": auditoriums,
+			"require_confirm":   true,
+			"confirm_hint":      "repeat request with ?confirm=true to delete the building with all its auditoriums",
+		})
+		return
+	}
+
+	if err := b.Buildings.DeleteBuilding(cityID, buildingID); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "building not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 	"strconv"
+	"time"
 	"web_backend_v2/forms"
 	"web_backend_v2/models"
 
@@ -10,7 +11,9 @@ import (
 	"gorm.io/gorm"
 )
 
-var CameraModel = new(models.CameraModel)
+type CameraController struct {
+	*Stores
+}
 
 // CreateCamera handles POST /v1/cameras
 func (h *CameraController) CreateCamera(c *gin.Context) {
@@ -20,7 +23,7 @@ func (h *CameraController) CreateCamera(c *gin.Context) {
 		return
 	}
 
-	camera, err := CameraModel.CreateCamera(req.Mac)
+	camera, err := h.Cameras.CreateCamera(req.Mac)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -40,7 +43,7 @@ func (h *CameraController) GetCamera(c *gin.Context) {
 		return
 	}
 
-	camera, err := CameraModel.GetCameraWithAssignment(cameraID)
+	camera, err := h.Cameras.GetCameraWithAssignment(cameraID)
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "camera not found"})
@@ -58,9 +61,66 @@ func (h *CameraController) GetCamera(c *gin.Context) {
 	c.JSON(http.StatusOK, resp)
 }
 
+// Camera health thresholds; offline matches the occupancy freshness limit.
+var cameraHealthThresholds = models.HealthThresholds{
+	DegradedAfter: time.Minute,
+	OfflineAfter:  maxFreshMinutes * time.Minute,
+}
+
+// GetCameras handles GET /v1/cameras
+// Without ?status it lists free cameras (see GetFreeCameras); with
+// ?status=ok|degraded|offline it lists the health of all cameras in that state
+// that are unattached or attached within the principal's scope.
+func (h *CameraController) GetCameras(c *gin.Context) {
+	var q forms.CameraListQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be one of ok, degraded, offline"})
+		return
+	}
+	if q.Status == "" {
+		h.GetFreeCameras(c)
+		return
+	}
+
+	cameras, err := h.Cameras.ListCameraHealth(q.Status, time.Now().UTC(), cameraHealthThresholds)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if scope := scopeOf(c); !scope.Unrestricted() {
+		kept := cameras[:0]
+		for _, camera := range cameras {
+			if camera.CityID == nil || camera.BuildingID == nil || scope.AllowsBuilding(*camera.CityID, *camera.BuildingID) {
+				kept = append(kept, camera)
+			}
+		}
+		cameras = kept
+	}
+	c.JSON(http.StatusOK, cameras)
+}
+
+// GetCameraHealth handles GET /v1/cameras/:camera_id/health
+func (h *CameraController) GetCameraHealth(c *gin.Context) {
+	cameraID, err := parseUintParam(c, "camera_id")
+	if err != nil {
+		return
+	}
+
+	health, err := h.Cameras.GetCameraHealth(cameraID, time.Now().UTC(), cameraHealthThresholds)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "camera not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, health)
+}
+
 // GetFreeCameras handles GET /v1/cameras
 func (h *CameraController) GetFreeCameras(c *gin.Context) {
-	cameras, err := CameraModel.GetFreeCameras()
+	cameras, err := h.Cameras.GetFreeCameras()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -83,7 +143,7 @@ func (h *CameraController) GetCamerasByAuditorium(c *gin.Context) {
 		return
 	}
 
-	cameras, err := CameraModel.GetCamerasByAuditorium(auditoriumID)
+	cameras, err := h.Cameras.GetCamerasByAuditorium(auditoriumID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -113,7 +173,7 @@ func (h *CameraController) AttachCamera(c *gin.Context) {
 		return
 	}
 
-	if err := CameraModel.AttachCameraToAuditorium(req.CameraID, auditoriumID); err != nil {
+	if err := h.Cameras.AttachCameraToAuditorium(req.CameraID, auditoriumID); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -121,15 +181,32 @@ func (h *CameraController) AttachCamera(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
-type CameraController struct{}
-
 // GetAttachedCameras handles GET /v1/cameras/attached
+// A scoped principal only gets the cameras attached within its scope.
 func (h *CameraController) GetAttachedCameras(c *gin.Context) {
-	cameras, err := CameraModel.GetAttachedCameras()
+	cameras, err := h.Cameras.GetAttachedCameras()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if scope := scopeOf(c); !scope.Unrestricted() {
+		auditoriumIDs := make([]uint, 0, len(cameras))
+		for _, camera := range cameras {
+			auditoriumIDs = append(auditoriumIDs, *camera.AuditoriumID)
+		}
+		locations, err := h.Auditoriums.GetAuditoriumLocations(auditoriumIDs)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		kept := cameras[:0]
+		for _, camera := range cameras {
+			if location, ok := locations[*camera.AuditoriumID]; ok && scope.AllowsBuilding(location.CityID, location.BuildingID) {
+				kept = append(kept, camera)
+			}
+		}
+		cameras = kept
+	}
 
 	resp := make([]forms.CameraResponse, len(cameras))
 	for i := range cameras {
@@ -150,7 +227,7 @@ func (h *CameraController) DetachCamera(c *gin.Context) {
 	}
 
 	// Check camera existence
-	_, err = CameraModel.GetCameraWithAssignment(cameraID)
+	_, err = h.Cameras.GetCameraWithAssignment(cameraID)
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "camera not found"})
@@ -160,7 +237,7 @@ func (h *CameraController) DetachCamera(c *gin.Context) {
 		return
 	}
 
-	if err := CameraModel.DetachCameraFromAuditorium(cameraID); err != nil {
+	if err := h.Cameras.DetachCameraFromAuditorium(cameraID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -176,7 +253,7 @@ func (h *CameraController) DeleteCamera(c *gin.Context) {
 
 	confirm := c.Query("confirm") == "true"
 
-	cam, err := CameraModel.GetCameraWithAssignment(cameraID)
+	cam, err := h.Cameras.GetCameraWithAssignment(cameraID)
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "camera not found"})
@@ -196,7 +273,7 @@ func (h *CameraController) DeleteCamera(c *gin.Context) {
 		return
 	}
 
-	if err := CameraModel.DeleteCamera(cameraID); err != nil {
+	if err := h.Cameras.DeleteCamera(cameraID); err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "camera not found"})
 		} else {
@@ -214,11 +291,56 @@ func parseUintParam(c *gin.Context, name string) (uint, error) {
 		return 0, strconv.ErrSyntax
 	}
 	valUint64, err := strconv.ParseUint(valStr, 10, 32)
-	if err != nil || valUint64 == 0 {
+	if err == nil && valUint64 == 0 {
+		err = strconv.ErrRange
+	}
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be a positive integer"})
 		return 0, err
 	}
 	return uint(valUint64), nil
 }
 
+// IssueCameraToken handles POST /v1/cameras/:camera_id/token
+// Issues a new token for POST /v1/events, invalidating the previous one.
+// The token is returned only in this response.
+func (h *CameraController) IssueCameraToken(c *gin.Context) {
+	cameraID, err := parseUintParam(c, "camera_id")
+	if err != nil {
+		return
+	}
+
+	camera, token, err := h.Cameras.IssueToken(cameraID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "camera not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, forms.CameraTokenResponse{
+		CameraID: camera.ID,
+		Mac:      camera.Mac,
+		Token:    token,
+	})
+}
+
+// RevokeCameraToken handles DELETE /v1/cameras/:camera_id/token
+func (h *CameraController) RevokeCameraToken(c *gin.Context) {
+	cameraID, err := parseUintParam(c, "camera_id")
+	if err != nil {
+		return
+	}
 
+	if err := h.Cameras.RevokeToken(cameraID); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "camera not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -5,19 +5,23 @@ import (
 	"log"
 	"net/http"
 	"web_backend_v2/forms"
-	"web_backend_v2/models"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-var CityModel = new(models.CityModel)
-
-type CityController struct{}
+type CityController struct {
+	*Stores
+}
 
 // GetCities handles GET /v1/cities
-// Returns a list of sall cities with localized names
+// Returns a list of sall cities with localized names; a scoped principal
+// only gets the cities of its scope and of the buildings in it.
 func (city *CityController) GetCities(c *gin.Context) {
-	cities, err := CityModel.GetCities()
+	cities, err := city.Cities.GetCities()
+	if err == nil {
+		cities, err = city.citiesInScope(scopeOf(c), cities)
+	}
 	if err != nil {
 		log.Println(err)
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -33,6 +37,105 @@ func (city *CityController) GetCities(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// CreateCity handles POST /v1/cities
+func (city *CityController) CreateCity(c *gin.Context) {
+	var req forms.CityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	created := req.ToCity(0)
+	if err := city.Cities.CreateCity(&created); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, created.ToCityResponse())
+}
+
+// UpdateCity handles PUT /v1/cities/:city_id (full replace)
+func (city *CityController) UpdateCity(c *gin.Context) {
+	city.saveCity(c, false)
+}
+
+// PatchCity handles PATCH /v1/cities/:city_id (only the fields present in the body are changed)
+func (city *CityController) PatchCity(c *gin.Context) {
+	city.saveCity(c, true)
+}
+
+func (city *CityController) saveCity(c *gin.Context, patch bool) {
+	cityID, err := parseUintParam(c, "city_id")
+	if err != nil {
+		return
+	}
+
+	existing, err := city.Cities.GetCity(cityID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "city not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	var req forms.CityRequest
+	if patch {
+		// Decoding on top of the current values keeps fields absent from the body.
+		req = existing.ToCityRequest()
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated := req.ToCity(cityID)
+	if err := city.Cities.UpdateCity(&updated); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "city not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, updated.ToCityResponse())
+}
+
+// DeleteCity handles DELETE /v1/cities/:city_id
+// A city that still has buildings is only deleted with ?confirm=true.
+func (city *CityController) DeleteCity(c *gin.Context) {
+	cityID, err := parseUintParam(c, "city_id")
+	if err != nil {
+		return
+	}
+
+	buildings, err := city.Cities.CountBuildings(cityID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if buildings > 0 && c.Query("confirm") != "true" {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":           "city still has buildings",
+			"buildings_count": buildings,
+			"require_confirm": true,
+			"confirm_hint":    "repeat request with ?confirm=true to delete the city with all its buildings and auditoriums",
+		})
+		return
+	}
+
+	if err := city.Cities.DeleteCity(cityID); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "city not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
 // // GetCityByID fetches a city by ID (helper function for validation)
 // func GetCityByID(cityID uint) (*models.City, error) {
 // 	sqlDB, err := db.GetDB().DB()
@@ -2,13 +2,28 @@ package handlers
 
 import "github.com/gin-gonic/gin"
 
-// CORSMiddleware allows cross-origin requests for testing/frontends hosted elsewhere.
-func CORSMiddleware() gin.HandlerFunc {
+// CORSMiddleware allows cross-origin requests from the given origins ("*" for
+// any) for frontends hosted elsewhere. Without origins no cross-origin
+// request is allowed.
+func CORSMiddleware(origins []string) gin.HandlerFunc {
+	allowAny := false
+	allowed := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		allowAny = allowAny || origin == "*"
+		allowed[origin] = true
+	}
 	return func(c *gin.Context) {
 		h := c.Writer.Header()
-		h.Set("Access-Control-Allow-Origin", "*")
+		if allowAny {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Add("Vary", "Origin")
+			if origin := c.GetHeader("Origin"); allowed[origin] {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+		}
 		h.Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-		h.Set("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization")
+		h.Set("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, X-API-Key, X-Camera-Token, Last-Event-ID")
 		h.Set("Access-Control-Expose-Headers", "Content-Length")
 
 		// Handle preflight
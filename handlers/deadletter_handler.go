@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"web_backend_v2/events"
+	"web_backend_v2/forms"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DeadLetterController struct {
+	Source events.DeadLetterStore
+	*Stores
+}
+
+// ListDeadLetters handles GET /v1/admin/dead-letters
+func (h *DeadLetterController) ListDeadLetters(c *gin.Context) {
+	var q forms.DeadLetterQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+		return
+	}
+	if q.Limit == 0 {
+		q.Limit = 100
+	}
+
+	// Filters are applied after fetching, so scan the whole queue when filtering.
+	fetch := q.Limit
+	if q.Reason != "" || q.Camera != "" {
+		fetch = int(^uint(0) >> 1)
+	}
+	letters, err := h.Source.ListDeadLetters(fetch)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp := make([]forms.DeadLetter, 0, len(letters))
+	for _, dl := range letters {
+		if q.Match(dl) && len(resp) < q.Limit {
+			resp = append(resp, dl)
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetDeadLetter handles GET /v1/admin/dead-letters/:message_id
+func (h *DeadLetterController) GetDeadLetter(c *gin.Context) {
+	dl, err := h.Source.GetDeadLetter(c.Param("message_id"))
+	if err != nil {
+		if errors.Is(err, events.ErrDeadLetterNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "dead letter not found"})
+			return
+		}
+		log.Println(err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, dl)
+}
+
+// ReplayDeadLetter handles POST /v1/admin/dead-letters/:message_id/replay
+func (h *DeadLetterController) ReplayDeadLetter(c *gin.Context) {
+	messageID := c.Param("message_id")
+	results, err := h.Source.ReplayDeadLetters(h.ProcessCameraEvent, func(dl forms.DeadLetter) bool {
+		return dl.MessageID == messageID
+	})
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	if len(results) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "dead letter not found"})
+		return
+	}
+	if !results[0].Replayed {
+		c.JSON(http.StatusUnprocessableEntity, results[0])
+		return
+	}
+	c.JSON(http.StatusOK, results[0])
+}
+
+// ReplayDeadLetters handles POST /v1/admin/dead-letters/replay
+// Replays every dead-lettered event matching ?reason= and ?camera= (all when omitted).
+func (h *DeadLetterController) ReplayDeadLetters(c *gin.Context) {
+	var q forms.DeadLetterQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	results, err := h.Source.ReplayDeadLetters(h.ProcessCameraEvent, q.Match)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+
+	replayed := 0
+	for _, r := range results {
+		if r.Replayed {
+			replayed++
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"replayed": replayed,
+		"failed":   len(results) - replayed,
+		"results":  results,
+	})
+}
+
+// DiscardDeadLetter handles DELETE /v1/admin/dead-letters/:message_id
+func (h *DeadLetterController) DiscardDeadLetter(c *gin.Context) {
+	if err := h.Source.DiscardDeadLetter(c.Param("message_id")); err != nil {
+		if errors.Is(err, events.ErrDeadLetterNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "dead letter not found"})
+			return
+		}
+		log.Println(err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"web_backend_v2/auth"
+	"web_backend_v2/forms"
+	"web_backend_v2/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxEventBodyBytes limits the size of a posted event or batch.
+	maxEventBodyBytes = 1 << 20
+	// maxEventBatch limits the number of events in one posted batch.
+	maxEventBatch = 500
+	// cameraContextKey holds the camera authenticated by CameraTokenAuth.
+	cameraContextKey = "camera"
+)
+
+// EventController accepts camera events over HTTP for devices that cannot use RabbitMQ.
+type EventController struct {
+	*Stores
+}
+
+// CameraTokenAuth authenticates a camera by the token issued via
+// POST /v1/cameras/:camera_id/token, sent as "Authorization: Bearer <token>"
+// or "X-Camera-Token: <token>".
+func CameraTokenAuth(cameras models.CameraStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.GetHeader("X-Camera-Token")
+		if auth := c.GetHeader("Authorization"); token == "" && strings.HasPrefix(auth, "Bearer ") {
+			token = strings.TrimPrefix(auth, "Bearer ")
+		}
+
+		camera, err := cameras.AuthenticateToken(token)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidCameraToken) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing or invalid camera token"})
+			} else {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			return
+		}
+
+		c.Set(cameraContextKey, camera)
+		c.Next()
+	}
+}
+
+// PostEvents handles POST /v1/events
+// Accepts a single camera event object or a JSON array of them. Every event goes
+// through the same validation and storage as events from RabbitMQ and must come
+// from the authenticated camera, unless a device principal (see DeviceAuth)
+// posts them; a scoped device principal may only post events of cameras
+// attached within its scope. A single event gets a plain status code; a batch gets 200 with
+// per-item results.
+func (h *EventController) PostEvents(c *gin.Context) {
+	var camera *forms.Camera
+	if v, ok := c.Get(cameraContextKey); ok {
+		camera = v.(*forms.Camera)
+	}
+	scope := scopeOf(c)
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxEventBodyBytes)
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("request body exceeds %d bytes", maxEventBodyBytes)})
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var events []forms.CameraEvent
+		if err := json.Unmarshal(body, &events); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON array of events: " + err.Error()})
+			return
+		}
+		if len(events) == 0 || len(events) > maxEventBatch {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("batch must contain 1 to %d events", maxEventBatch)})
+			return
+		}
+
+		resp := forms.EventBatchResponse{Results: make([]forms.EventResult, len(events))}
+		for i := range events {
+			result, _ := h.ingestHTTPEvent(camera, scope, &events[i])
+			result.Index = i
+			resp.Results[i] = result
+			switch result.Status {
+			case forms.EventStored:
+				resp.Stored++
+			case forms.EventDuplicate:
+				resp.Duplicates++
+			case forms.EventRejected:
+				resp.Rejected++
+			case forms.EventFailed:
+				resp.Failed++
+			}
+		}
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	var event forms.CameraEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid event JSON: " + err.Error()})
+		return
+	}
+	result, status := h.ingestHTTPEvent(camera, scope, &event)
+	if result.Error != "" {
+		c.JSON(status, gin.H{"status": result.Status, "error": result.Error})
+		return
+	}
+	c.JSON(status, gin.H{"status": result.Status})
+}
+
+// ingestHTTPEvent stores an event posted by camera (nil for a device principal
+// with the given scope) and returns its result with the HTTP status that fits
+// it when the event was posted alone.
+func (h *EventController) ingestHTTPEvent(camera *forms.Camera, scope auth.Scope, event *forms.CameraEvent) (forms.EventResult, int) {
+	if camera != nil && event.IDCamera != "" && !strings.EqualFold(event.IDCamera, camera.Mac) {
+		return forms.EventResult{
+			Status: forms.EventRejected,
+			Error:  fmt.Sprintf("event of camera %s cannot be posted with the token of camera %s", event.IDCamera, camera.Mac),
+		}, http.StatusForbidden
+	}
+	if camera == nil && !scope.Unrestricted() && event.IDCamera != "" {
+		// Unknown and unattached cameras are left to storeCameraEvent to reject.
+		cam, err := h.Cameras.GetCameraByMac(event.IDCamera)
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return forms.EventResult{Status: forms.EventFailed, Error: err.Error()}, http.StatusInternalServerError
+		}
+		if err == nil {
+			inScope, err := h.cameraInScope(scope, cam)
+			if err != nil {
+				return forms.EventResult{Status: forms.EventFailed, Error: err.Error()}, http.StatusInternalServerError
+			}
+			if !inScope {
+				return forms.EventResult{
+					Status: forms.EventRejected,
+					Error:  fmt.Sprintf("camera %s is outside the cities and buildings of the principal", event.IDCamera),
+				}, http.StatusForbidden
+			}
+		}
+	}
+
+	err := h.storeCameraEvent(event)
+	switch {
+	case err == nil:
+		return forms.EventResult{Status: forms.EventStored}, http.StatusCreated
+	case errors.Is(err, models.ErrDuplicateEvent):
+		return forms.EventResult{Status: forms.EventDuplicate}, http.StatusOK
+	case errors.Is(err, forms.ErrInvalidCameraEvent):
+		return forms.EventResult{Status: forms.EventRejected, Error: err.Error()}, http.StatusBadRequest
+	case errors.Is(err, models.ErrCameraNotFound), errors.Is(err, models.ErrCameraNotAttached):
+		return forms.EventResult{Status: forms.EventRejected, Error: err.Error()}, http.StatusUnprocessableEntity
+	default:
+		return forms.EventResult{Status: forms.EventFailed, Error: err.Error()}, http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+	"web_backend_v2/export"
+	"web_backend_v2/forms"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxStatsExportDays limits the day range of a statistics export.
+const maxStatsExportDays = 366
+
+// ExportController serves occupancy and statistics as CSV or XLSX files
+// (?format=csv|xlsx, default csv) with column headers in ?lang=ru|en (default ru).
+// Rows are streamed from the store to the client as they are read.
+type ExportController struct {
+	*Stores
+}
+
+// ExportBuildingOccupancy handles GET /v1/cities/:city_id/buildings/:building_id/auditories/occupancy/export
+// One row per auditorium of the building with its latest reading at ?timestamp=
+// (RFC3339); auditoriums without readings have empty person count and time.
+func (h *ExportController) ExportBuildingOccupancy(c *gin.Context) {
+	building, ok := h.scopedBuilding(c)
+	if !ok {
+		return
+	}
+	var q forms.OccupancyExportQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "timestamp is required in RFC3339, format must be csv or xlsx, lang must be ru or en"})
+		return
+	}
+
+	stream := newExportStream(c, q.ExportQuery, fmt.Sprintf("building_%d_occupancy", building.ID), forms.OccupancyExportColumns)
+	err := h.Exports.ExportBuildingOccupancy(building.ID, q.Timestamp, func(row forms.AuditoriumOccupancyExportRow) error {
+		var personCount, timestamp, fresh interface{}
+		if row.PersonCount != nil {
+			personCount, timestamp = *row.PersonCount, *row.Timestamp
+			fresh = forms.ExportNo.In(stream.lang)
+			if q.Timestamp.Sub(*row.Timestamp) <= maxFreshMinutes*time.Minute {
+				fresh = forms.ExportYes.In(stream.lang)
+			}
+		}
+		return stream.row(row.AuditoriumID, row.AuditoriumNumber, row.FloorNumber, row.Type.In(stream.lang),
+			row.Capacity, personCount, timestamp, fresh)
+	})
+	stream.finish(err)
+}
+
+// ExportAuditoriumStatistics handles GET /v1/cities/:city_id/buildings/:building_id/auditories/:auditorium_id/statistics/export
+// Hourly statistics (hours 9 to 21) of every day from ?from= to ?to= (YYYY-MM-DD,
+// inclusive, at most maxStatsExportDays days) with both the average person count
+// and the occupancy rate; the rate is empty when the auditorium has no capacity.
+func (h *ExportController) ExportAuditoriumStatistics(c *gin.Context) {
+	building, ok := h.scopedBuilding(c)
+	if !ok {
+		return
+	}
+	auditoriumID, err := parseUintParam(c, "auditorium_id")
+	if err != nil {
+		return
+	}
+	var q forms.StatisticsExportQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from and to are required in format YYYY-MM-DD, format must be csv or xlsx, lang must be ru or en"})
+		return
+	}
+	from, errFrom := time.Parse("2006-01-02", q.From)
+	to, errTo := time.Parse("2006-01-02", q.To)
+	if errFrom != nil || errTo != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid day format, expected YYYY-MM-DD"})
+		return
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+		return
+	}
+	if to.Sub(from) >= maxStatsExportDays*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("range must not exceed %d days", maxStatsExportDays)})
+		return
+	}
+
+	auditorium, err := h.Auditoriums.GetAuditorium(building.ID, auditoriumID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "auditorium not found"})
+			return
+		}
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify auditorium"})
+		return
+	}
+
+	name := fmt.Sprintf("auditorium_%d_statistics_%s_%s", auditorium.ID, q.From, q.To)
+	stream := newExportStream(c, q.ExportQuery, name, forms.StatisticsExportColumns)
+	err = h.Exports.ExportAuditoriumStats(auditorium.ID, from, to, func(row forms.HourlyStatsExportRow) error {
+		var capacity, rate interface{}
+		if auditorium.Capacity > 0 {
+			capacity = auditorium.Capacity
+			rate = row.AvgPersonCount / float64(auditorium.Capacity) * 100
+		}
+		return stream.row(row.Day.Format("2006-01-02"), row.Hour, row.AvgPersonCount, capacity, rate)
+	})
+	stream.finish(err)
+}
+
+// ExportOccupancy handles GET /v1/cities/:city_id/buildings/:building_id/occupancy/export
+// Raw dump of the occupancy readings of all auditoriums of the building with
+// ?from= <= timestamp < ?to= (RFC3339, at most maxSeriesRange). Days already
+// compacted by the daily aggregation have no raw readings left.
+func (h *ExportController) ExportOccupancy(c *gin.Context) {
+	building, ok := h.scopedBuilding(c)
+	if !ok {
+		return
+	}
+	var q forms.RawOccupancyExportQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from and to are required in RFC3339, format must be csv or xlsx, lang must be ru or en"})
+		return
+	}
+	if !q.From.Before(q.To) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+	if q.To.Sub(q.From) > maxSeriesRange {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("range must not exceed %d days", int(maxSeriesRange.Hours()/24))})
+		return
+	}
+
+	stream := newExportStream(c, q.ExportQuery, fmt.Sprintf("building_%d_occupancy_raw", building.ID), forms.RawOccupancyExportColumns)
+	err := h.Exports.ExportOccupancy(building.ID, q.From, q.To, func(row forms.OccupancyExportRow) error {
+		return stream.row(row.Timestamp, row.AuditoriumID, row.AuditoriumNumber, row.PersonCount, row.FusionStrategy)
+	})
+	stream.finish(err)
+}
+
+// exportStream writes an export file to the response. Nothing is sent before
+// the first row (or finish for an empty export), so a store error that happens
+// before any row still gets a JSON error response.
+type exportStream struct {
+	c        *gin.Context
+	format   string
+	lang     string
+	filename string
+	columns  []forms.LocalizedString
+	w        export.Writer
+}
+
+func newExportStream(c *gin.Context, q forms.ExportQuery, filename string, columns []forms.LocalizedString) *exportStream {
+	s := &exportStream{c: c, format: q.Format, lang: q.Lang, filename: filename, columns: columns}
+	if s.format == "" {
+		s.format = export.FormatCSV
+	}
+	if s.lang == "" {
+		s.lang = "ru"
+	}
+	return s
+}
+
+// row writes one data row, starting the file first if needed.
+func (s *exportStream) row(cells ...interface{}) error {
+	if s.w == nil {
+		if err := s.start(); err != nil {
+			return err
+		}
+	}
+	return s.w.WriteRow(cells...)
+}
+
+// start sends the response headers and the header row.
+func (s *exportStream) start() error {
+	s.c.Header("Content-Type", export.ContentType(s.format))
+	s.c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, s.filename, s.format))
+	s.c.Status(http.StatusOK)
+
+	w, err := export.NewWriter(s.format, s.c.Writer)
+	if err != nil {
+		return err
+	}
+	s.w = w
+	header := make([]interface{}, len(s.columns))
+	for i, column := range s.columns {
+		header[i] = column.In(s.lang)
+	}
+	return s.w.WriteRow(header...)
+}
+
+// finish completes the file. When err happens after the file has been started
+// the status can no longer change: the error is logged and the file is left
+// unfinished (an XLSX will not open, a CSV is cut short).
+func (s *exportStream) finish(err error) {
+	if err == nil && s.w == nil {
+		err = s.start()
+	}
+	if err != nil {
+		log.Println(err)
+		if s.w == nil {
+			s.c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	if err := s.w.Close(); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,661 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	"web_backend_v2/auth"
+	"web_backend_v2/events"
+	"web_backend_v2/forms"
+	"web_backend_v2/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readingTime is when the test cameras report; queries look one minute later,
+// well within maxFreshMinutes.
+var readingTime = time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// testAPI is the /v1 router of main.go on top of an empty memory store.
+type testAPI struct {
+	t      *testing.T
+	router *gin.Engine
+	// store backs the router, for state the API does not let clients set up.
+	store *models.MemoryStore
+	// header pairs are sent with every request, before the request's own.
+	header []string
+}
+
+// newTestAPI is newAuthTestAPI with authentication disabled: every request is
+// auth.Anonymous.
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	return newAuthTestAPI(t, nil)
+}
+
+func newAuthTestAPI(t *testing.T, authenticator *auth.Authenticator) *testAPI {
+	t.Helper()
+	store := models.NewMemoryStore(models.FusionMax, models.DefaultFusionWindow)
+	stores := NewMemoryStores(store)
+
+	router := gin.New()
+	authenticate := Authenticate(authenticator)
+	readOrAdmin := Authorize(ReadRoles, AdminRoles)
+	cities := router.Group("/v1/cities", authenticate, readOrAdmin, RequireScope(stores))
+	city := &CityController{Stores: stores}
+	cities.GET("/", city.GetCities)
+	cities.POST("/", city.CreateCity)
+	cities.PUT("/:city_id", city.UpdateCity)
+	cities.PATCH("/:city_id", city.PatchCity)
+	cities.DELETE("/:city_id", city.DeleteCity)
+	building := &BuildingController{Stores: stores}
+	cities.GET("/:city_id/buildings", building.GetBuildingsByCity)
+	cities.POST("/:city_id/buildings", building.CreateBuilding)
+	cities.PUT("/:city_id/buildings/:building_id", building.UpdateBuilding)
+	cities.PATCH("/:city_id/buildings/:building_id", building.PatchBuilding)
+	cities.DELETE("/:city_id/buildings/:building_id", building.DeleteBuilding)
+	auditorium := &AuditoriumController{Stores: stores}
+	cities.GET("/:city_id/buildings/:building_id/auditories", auditorium.GetAuditoriumsByBuilding)
+	cities.POST("/:city_id/buildings/:building_id/auditories", auditorium.CreateAuditorium)
+	cities.PUT("/:city_id/buildings/:building_id/auditories/:auditorium_id", auditorium.UpdateAuditorium)
+	cities.PATCH("/:city_id/buildings/:building_id/auditories/:auditorium_id", auditorium.PatchAuditorium)
+	cities.DELETE("/:city_id/buildings/:building_id/auditories/:auditorium_id", auditorium.DeleteAuditorium)
+	cities.GET("/:city_id/buildings/:building_id/auditories/:auditorium_id/occupancy", auditorium.GetOccupancyByAuditorium)
+	cities.GET("/:city_id/buildings/:building_id/auditories/:auditorium_id/occupancy/series", auditorium.GetOccupancySeries)
+	cities.GET("/:city_id/buildings/:building_id/auditories/:auditorium_id/statistics", auditorium.GetStatisticsByAuditorium)
+	cities.GET("/:city_id/free-auditoriums", auditorium.GetFreeAuditoriums)
+	cities.GET("/:city_id/occupancy/summary", auditorium.GetCityOccupancySummary)
+	cities.GET("/:city_id/buildings/:building_id/occupancy/summary", auditorium.GetBuildingOccupancySummary)
+	imports := &ImportController{Stores: stores}
+	cities.POST("/:city_id/import/buildings", imports.ImportBuildings)
+	cities.POST("/:city_id/import/auditoriums", imports.ImportAuditoriums)
+	camera := &CameraController{Stores: stores}
+	cities.POST("/:city_id/buildings/:building_id/auditories/:auditorium_id/cameras", camera.AttachCamera)
+	alerts := router.Group("/v1/alerts", authenticate, readOrAdmin)
+	alert := &AlertController{Stores: stores}
+	alerts.GET("", alert.GetAlerts)
+	alerts.GET("/:alert_id", alert.GetAlert)
+	alerts.POST("/:alert_id/ack", alert.AcknowledgeAlert)
+	cameras := router.Group("/v1/cameras", authenticate, readOrAdmin, RequireCameraScope(stores))
+	cameras.GET("/attached", camera.GetAttachedCameras)
+	cameras.GET("/:camera_id", camera.GetCamera)
+	cameras.GET("/:camera_id/health", camera.GetCameraHealth)
+	cameras.POST("/", camera.CreateCamera)
+	cameras.DELETE("/:camera_id", camera.DeleteCamera)
+	cameras.DELETE("/:camera_id/attachment", camera.DetachCamera)
+	cameras.POST("/:camera_id/token", camera.IssueCameraToken)
+	cameras.DELETE("/:camera_id/token", camera.RevokeCameraToken)
+	event := &EventController{Stores: stores}
+	router.POST("/v1/events", DeviceAuth(authenticator, stores.Cameras), event.PostEvents)
+	admin := router.Group("/v1/admin", authenticate, RequireRole(AdminRoles...), RequireUnscoped())
+	ingestion := &IngestionController{Source: events.NewMemoryBroker(1, 1, 1, 0)}
+	admin.GET("/ingestion/stats", ingestion.GetIngestionStats)
+
+	return &testAPI{t: t, router: router, store: store}
+}
+
+// do sends body (marshalled to JSON unless nil) with the given header pairs and
+// checks the status; out, when not nil, receives the decoded response.
+func (api *testAPI) do(method, path string, body any, wantStatus int, out any, header ...string) {
+	api.t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		require.NoError(api.t, err)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	header = append(append([]string(nil), api.header...), header...)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	api.router.ServeHTTP(w, req)
+	require.Equal(api.t, wantStatus, w.Code, "%s %s: %s", method, path, w.Body.String())
+	if out != nil {
+		require.NoError(api.t, json.Unmarshal(w.Body.Bytes(), out), w.Body.String())
+	}
+}
+
+func (api *testAPI) createCity(ru, en string) forms.CityResponse {
+	api.t.Helper()
+	var city forms.CityResponse
+	api.do(http.MethodPost, "/v1/cities/", gin.H{"name": gin.H{"ru": ru, "en": en}}, http.StatusCreated, &city)
+	return city
+}
+
+func (api *testAPI) createBuilding(cityID uint, floors int) forms.BuildingResponse {
+	api.t.Helper()
+	var building forms.BuildingResponse
+	api.do(http.MethodPost, fmt.Sprintf("/v1/cities/%d/buildings", cityID),
+		gin.H{"address": gin.H{"ru": "ул. Ленина, 1", "en": "1 Lenina St"}, "floors_count": floors},
+		http.StatusCreated, &building)
+	return building
+}
+
+func (api *testAPI) createAuditorium(b forms.BuildingResponse, number, kind string, floor, capacity int) forms.AuditoriumResponse {
+	api.t.Helper()
+	var auditorium forms.AuditoriumResponse
+	api.do(http.MethodPost, fmt.Sprintf("/v1/cities/%d/buildings/%d/auditories", b.CityID, b.ID),
+		gin.H{"floor_number": floor, "capacity": capacity, "auditorium_number": number, "type": kind},
+		http.StatusCreated, &auditorium)
+	return auditorium
+}
+
+// createCamera creates a camera, attaches it to the auditorium unless a is nil
+// and returns it with its device token.
+func (api *testAPI) createCamera(mac string, b forms.BuildingResponse, a *forms.AuditoriumResponse) (forms.CameraResponse, string) {
+	api.t.Helper()
+	var camera forms.CameraResponse
+	api.do(http.MethodPost, "/v1/cameras/", gin.H{"mac": mac}, http.StatusCreated, &camera)
+	if a != nil {
+		api.do(http.MethodPost, fmt.Sprintf("/v1/cities/%d/buildings/%d/auditories/%d/cameras", b.CityID, b.ID, a.ID),
+			gin.H{"camera_id": camera.ID}, http.StatusNoContent, nil)
+	}
+	var token forms.CameraTokenResponse
+	api.do(http.MethodPost, fmt.Sprintf("/v1/cameras/%d/token", camera.ID), nil, http.StatusCreated, &token)
+	return camera, token.Token
+}
+
+func (api *testAPI) postEvent(token, mac string, at time.Time, count, wantStatus int) string {
+	api.t.Helper()
+	var resp struct {
+		Status string `json:"status"`
+	}
+	api.do(http.MethodPost, "/v1/events", gin.H{"id_camera": mac, "timestamp": at, "person_count": count},
+		wantStatus, &resp, "X-Camera-Token", token)
+	return resp.Status
+}
+
+func TestCityCRUD(t *testing.T) {
+	api := newTestAPI(t)
+
+	city := api.createCity("Москва", "Moscow")
+	assert.NotZero(t, city.ID)
+	assert.Equal(t, forms.LocalizedString{RU: "Москва", EN: "Moscow"}, city.Name)
+
+	var cities []forms.CityResponse
+	api.do(http.MethodGet, "/v1/cities/", nil, http.StatusOK, &cities)
+	assert.Equal(t, []forms.CityResponse{city}, cities)
+
+	path := fmt.Sprintf("/v1/cities/%d", city.ID)
+	var updated forms.CityResponse
+	api.do(http.MethodPut, path, gin.H{"name": gin.H{"ru": "Казань", "en": "Kazan"}}, http.StatusOK, &updated)
+	assert.Equal(t, forms.LocalizedString{RU: "Казань", EN: "Kazan"}, updated.Name)
+
+	var patched forms.CityResponse
+	api.do(http.MethodPatch, path, gin.H{"name": gin.H{"en": "Kazan'"}}, http.StatusOK, &patched)
+	assert.Equal(t, forms.LocalizedString{RU: "Казань", EN: "Kazan'"}, patched.Name)
+
+	api.do(http.MethodDelete, path, nil, http.StatusNoContent, nil)
+	api.do(http.MethodDelete, path, nil, http.StatusNotFound, nil)
+	api.do(http.MethodGet, "/v1/cities/", nil, http.StatusOK, &cities)
+	assert.Empty(t, cities)
+}
+
+func TestBuildingCRUD(t *testing.T) {
+	api := newTestAPI(t)
+	city := api.createCity("Москва", "Moscow")
+
+	building := api.createBuilding(city.ID, 3)
+	assert.Equal(t, city.ID, building.CityID)
+	assert.Equal(t, 3, building.FloorsCount)
+
+	var buildings []forms.BuildingResponse
+	api.do(http.MethodGet, fmt.Sprintf("/v1/cities/%d/buildings", city.ID), nil, http.StatusOK, &buildings)
+	assert.Equal(t, []forms.BuildingResponse{building}, buildings)
+
+	path := fmt.Sprintf("/v1/cities/%d/buildings/%d", city.ID, building.ID)
+	var updated forms.BuildingResponse
+	api.do(http.MethodPut, path, gin.H{"address": gin.H{"ru": "пр. Мира, 2", "en": "2 Mira Ave"}, "floors_count": 5}, http.StatusOK, &updated)
+	assert.Equal(t, forms.LocalizedString{RU: "пр. Мира, 2", EN: "2 Mira Ave"}, updated.Address)
+
+	var patched forms.BuildingResponse
+	api.do(http.MethodPatch, path, gin.H{"floors_count": 4}, http.StatusOK, &patched)
+	assert.Equal(t, 4, patched.FloorsCount)
+	assert.Equal(t, updated.Address, patched.Address)
+
+	api.do(http.MethodDelete, path, nil, http.StatusNoContent, nil)
+	api.do(http.MethodDelete, path, nil, http.StatusNotFound, nil)
+}
+
+func TestAuditoriumCRUD(t *testing.T) {
+	api := newTestAPI(t)
+	building := api.createBuilding(api.createCity("Москва", "Moscow").ID, 3)
+
+	auditorium := api.createAuditorium(building, "101", "classroom", 1, 30)
+	assert.Equal(t, building.ID, auditorium.BuildingID)
+	assert.Equal(t, forms.LocalizedString{RU: "учебная", EN: "classroom"}, auditorium.Type)
+
+	// Basements are not limited in depth.
+	basement := api.createAuditorium(building, "B-501", "coworking", -5, 10)
+	assert.Equal(t, -5, basement.FloorNumber)
+
+	list := fmt.Sprintf("/v1/cities/%d/buildings/%d/auditories", building.CityID, building.ID)
+	var auditoriums []forms.AuditoriumResponse
+	api.do(http.MethodGet, list, nil, http.StatusOK, &auditoriums)
+	require.Len(t, auditoriums, 2)
+	assert.Equal(t, "101", auditoriums[0].AuditoriumNumber)
+
+	path := fmt.Sprintf("%s/%d", list, auditorium.ID)
+	var updated forms.AuditoriumResponse
+	api.do(http.MethodPut, path, gin.H{"floor_number": 2, "capacity": 120, "auditorium_number": "201", "type": "lecture_hall"}, http.StatusOK, &updated)
+	assert.Equal(t, 2, updated.FloorNumber)
+	assert.Equal(t, 120, updated.Capacity)
+
+	var patched forms.AuditoriumResponse
+	api.do(http.MethodPatch, path, gin.H{"capacity": 100, "fusion_strategy": "median"}, http.StatusOK, &patched)
+	assert.Equal(t, 100, patched.Capacity)
+	assert.Equal(t, "201", patched.AuditoriumNumber)
+	require.NotNil(t, patched.FusionStrategy)
+	assert.Equal(t, "median", *patched.FusionStrategy)
+
+	api.do(http.MethodDelete, path, nil, http.StatusNoContent, nil)
+	api.do(http.MethodDelete, path, nil, http.StatusNotFound, nil)
+}
+
+func TestBadRequests(t *testing.T) {
+	api := newTestAPI(t)
+	building := api.createBuilding(api.createCity("Москва", "Moscow").ID, 2)
+	auditoriums := fmt.Sprintf("/v1/cities/%d/buildings/%d/auditories", building.CityID, building.ID)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   any
+	}{
+		{"non-numeric city id", http.MethodPut, "/v1/cities/abc", gin.H{"name": gin.H{"ru": "a", "en": "a"}}},
+		{"zero city id", http.MethodDelete, "/v1/cities/0", nil},
+		{"city without english name", http.MethodPost, "/v1/cities/", gin.H{"name": gin.H{"ru": "Москва"}}},
+		{"building without floors", http.MethodPost, fmt.Sprintf("/v1/cities/%d/buildings", building.CityID), gin.H{"address": gin.H{"ru": "a", "en": "a"}}},
+		{"unknown auditorium type", http.MethodPost, auditoriums, gin.H{"floor_number": 1, "capacity": 10, "auditorium_number": "1", "type": "gym"}},
+		{"floor 0", http.MethodPost, auditoriums, gin.H{"floor_number": 0, "capacity": 10, "auditorium_number": "1", "type": "classroom"}},
+		{"floor above the building", http.MethodPost, auditoriums, gin.H{"floor_number": 3, "capacity": 10, "auditorium_number": "1", "type": "classroom"}},
+		{"unknown fusion strategy", http.MethodPost, auditoriums, gin.H{"floor_number": 1, "capacity": 10, "auditorium_number": "1", "type": "classroom", "fusion_strategy": "mean"}},
+		{"short camera mac", http.MethodPost, "/v1/cameras/", gin.H{"mac": "AA:BB"}},
+		{"summary without timestamp", http.MethodGet, fmt.Sprintf("/v1/cities/%d/occupancy/summary", building.CityID), nil},
+		{"free rooms with negative seats", http.MethodGet, fmt.Sprintf("/v1/cities/%d/free-auditoriums?min_seats=-1", building.CityID), nil},
+		{"free rooms of unknown type", http.MethodGet, fmt.Sprintf("/v1/cities/%d/free-auditoriums?type=gym", building.CityID), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := *api
+			api.t = t
+			var resp struct {
+				Error string `json:"error"`
+			}
+			api.do(tt.method, tt.path, tt.body, http.StatusBadRequest, &resp)
+			assert.NotEmpty(t, resp.Error)
+		})
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	api := newTestAPI(t)
+	building := api.createBuilding(api.createCity("Москва", "Moscow").ID, 2)
+	other := api.createCity("Казань", "Kazan")
+	at := readingTime.Format(time.RFC3339)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   any
+	}{
+		{"update unknown city", http.MethodPut, "/v1/cities/999", gin.H{"name": gin.H{"ru": "a", "en": "a"}}},
+		{"building in another city", http.MethodPatch, fmt.Sprintf("/v1/cities/%d/buildings/%d", other.ID, building.ID), gin.H{"floors_count": 1}},
+		{"auditorium in unknown building", http.MethodPost, fmt.Sprintf("/v1/cities/%d/buildings/999/auditories", building.CityID), gin.H{"floor_number": 1, "capacity": 10, "auditorium_number": "1", "type": "classroom"}},
+		{"unknown auditorium", http.MethodDelete, fmt.Sprintf("/v1/cities/%d/buildings/%d/auditories/999", building.CityID, building.ID), nil},
+		{"unknown camera", http.MethodGet, "/v1/cameras/999", nil},
+		{"token of unknown camera", http.MethodPost, "/v1/cameras/999/token", nil},
+		{"summary of unknown city", http.MethodGet, "/v1/cities/999/occupancy/summary?timestamp=" + at, nil},
+		{"summary of unknown building", http.MethodGet, fmt.Sprintf("/v1/cities/%d/buildings/999/occupancy/summary?timestamp=%s", building.CityID, at), nil},
+		{"free rooms of unknown city", http.MethodGet, "/v1/cities/999/free-auditoriums", nil},
+		{"free rooms of building in another city", http.MethodGet, fmt.Sprintf("/v1/cities/%d/free-auditoriums?building_id=%d", other.ID, building.ID), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := *api
+			api.t = t
+			api.do(tt.method, tt.path, tt.body, http.StatusNotFound, nil)
+		})
+	}
+}
+
+func TestDeleteConflicts(t *testing.T) {
+	api := newTestAPI(t)
+	building := api.createBuilding(api.createCity("Москва", "Moscow").ID, 2)
+	auditorium := api.createAuditorium(building, "101", "classroom", 1, 30)
+	camera, _ := api.createCamera("AA:BB:CC:DD:EE:01", building, &auditorium)
+
+	cityPath := fmt.Sprintf("/v1/cities/%d", building.CityID)
+	buildingPath := fmt.Sprintf("%s/buildings/%d", cityPath, building.ID)
+	auditoriumPath := fmt.Sprintf("%s/auditories/%d", buildingPath, auditorium.ID)
+	cameraPath := fmt.Sprintf("/v1/cameras/%d", camera.ID)
+
+	var conflict struct {
+		Error          string `json:"error"`
+		RequireConfirm bool   `json:"require_confirm"`
+	}
+	for _, path := range []string{cityPath, buildingPath, auditoriumPath, cameraPath} {
+		api.do(http.MethodDelete, path, nil, http.StatusConflict, &conflict)
+		assert.True(t, conflict.RequireConfirm, path)
+	}
+
+	api.do(http.MethodDelete, cameraPath+"?confirm=true", nil, http.StatusNoContent, nil)
+	// With its only camera gone the auditorium no longer needs confirmation.
+	api.do(http.MethodDelete, auditoriumPath, nil, http.StatusNoContent, nil)
+	api.createAuditorium(building, "102", "classroom", 1, 30)
+	api.do(http.MethodDelete, cityPath+"?confirm=true", nil, http.StatusNoContent, nil)
+	api.do(http.MethodDelete, buildingPath, nil, http.StatusNotFound, nil)
+}
+
+func TestPostEventsDeduplicates(t *testing.T) {
+	api := newTestAPI(t)
+	building := api.createBuilding(api.createCity("Москва", "Moscow").ID, 2)
+	auditorium := api.createAuditorium(building, "101", "classroom", 1, 30)
+	camera, token := api.createCamera("AA:BB:CC:DD:EE:01", building, &auditorium)
+
+	assert.Equal(t, forms.EventStored, api.postEvent(token, camera.Mac, readingTime, 12, http.StatusCreated))
+	assert.Equal(t, forms.EventDuplicate, api.postEvent(token, camera.Mac, readingTime, 12, http.StatusOK))
+
+	var batch forms.EventBatchResponse
+	api.do(http.MethodPost, "/v1/events", []gin.H{
+		{"id_camera": camera.Mac, "timestamp": readingTime, "person_count": 12},
+		{"id_camera": camera.Mac, "timestamp": readingTime.Add(time.Minute), "person_count": 15, "event_id": "e-2"},
+		{"id_camera": camera.Mac, "timestamp": readingTime.Add(2 * time.Minute), "person_count": 15, "event_id": "e-2"},
+		{"id_camera": camera.Mac, "timestamp": readingTime.Add(3 * time.Minute)},
+	}, http.StatusOK, &batch, "Authorization", "Bearer "+token)
+	assert.Equal(t, 1, batch.Stored)
+	assert.Equal(t, 2, batch.Duplicates)
+	assert.Equal(t, 1, batch.Rejected)
+	require.Len(t, batch.Results, 4)
+	assert.Equal(t, forms.EventRejected, batch.Results[3].Status)
+
+	var occupancy forms.OccupancyResult
+	api.do(http.MethodGet, fmt.Sprintf("/v1/cities/%d/buildings/%d/auditories/%d/occupancy?timestamp=%s",
+		building.CityID, building.ID, auditorium.ID, readingTime.Add(2*time.Minute).Format(time.RFC3339)),
+		nil, http.StatusOK, &occupancy)
+	assert.Equal(t, 15, occupancy.PersonCount)
+
+	var health forms.CameraHealthResponse
+	api.do(http.MethodGet, fmt.Sprintf("/v1/cameras/%d/health", camera.ID), nil, http.StatusOK, &health)
+	assert.EqualValues(t, 2, health.EventsTotal, "duplicates must not count as heartbeats")
+}
+
+func TestPostEventsCameraToken(t *testing.T) {
+	api := newTestAPI(t)
+	building := api.createBuilding(api.createCity("Москва", "Moscow").ID, 2)
+	auditorium := api.createAuditorium(building, "101", "classroom", 1, 30)
+	camera, token := api.createCamera("AA:BB:CC:DD:EE:01", building, &auditorium)
+	other, _ := api.createCamera("AA:BB:CC:DD:EE:02", building, &auditorium)
+	unattached, unattachedToken := api.createCamera("AA:BB:CC:DD:EE:03", building, nil)
+
+	event := gin.H{"id_camera": camera.Mac, "timestamp": readingTime, "person_count": 3}
+	api.do(http.MethodPost, "/v1/events", event, http.StatusUnauthorized, nil)
+	api.do(http.MethodPost, "/v1/events", event, http.StatusUnauthorized, nil, "X-Camera-Token", "not-a-token")
+	assert.Equal(t, forms.EventRejected, api.postEvent(token, other.Mac, readingTime, 3, http.StatusForbidden))
+	assert.Equal(t, forms.EventRejected, api.postEvent(unattachedToken, unattached.Mac, readingTime, 3, http.StatusUnprocessableEntity))
+
+	assert.Equal(t, forms.EventRejected, api.postEvent(token, camera.Mac, readingTime, -1, http.StatusBadRequest))
+	assert.Equal(t, forms.EventStored, api.postEvent(token, camera.Mac, readingTime, 3, http.StatusCreated))
+
+	api.do(http.MethodDelete, fmt.Sprintf("/v1/cameras/%d/token", camera.ID), nil, http.StatusNoContent, nil)
+	api.do(http.MethodPost, "/v1/events", event, http.StatusUnauthorized, nil, "X-Camera-Token", token)
+}
+
+func TestFreeAuditoriums(t *testing.T) {
+	api := newTestAPI(t)
+	building := api.createBuilding(api.createCity("Москва", "Moscow").ID, 3)
+	small := api.createAuditorium(building, "101", "classroom", 1, 20)
+	large := api.createAuditorium(building, "201", "lecture_hall", 2, 100)
+	api.createAuditorium(building, "301", "coworking", 3, 40) // never reports
+	unknown := api.createAuditorium(building, "102", "classroom", 1, 30)
+	a, err := api.store.GetAuditorium(building.ID, unknown.ID)
+	require.NoError(t, err)
+	a.Capacity = 0 // created before capacity was required
+	require.NoError(t, api.store.UpdateAuditorium(a))
+	for i, room := range []forms.AuditoriumResponse{small, large, unknown} {
+		camera, token := api.createCamera(fmt.Sprintf("AA:BB:CC:DD:EE:%02d", i), building, &room)
+		api.postEvent(token, camera.Mac, readingTime, 15, http.StatusCreated)
+	}
+
+	search := func(query string) []forms.FreeAuditoriumResponse {
+		t.Helper()
+		var rooms []forms.FreeAuditoriumResponse
+		api.do(http.MethodGet, fmt.Sprintf("/v1/cities/%d/free-auditoriums?at=%s%s",
+			building.CityID, readingTime.Add(time.Minute).Format(time.RFC3339), query), nil, http.StatusOK, &rooms)
+		return rooms
+	}
+	numbers := func(rooms []forms.FreeAuditoriumResponse) []string {
+		var result []string
+		for _, room := range rooms {
+			result = append(result, room.AuditoriumNumber)
+		}
+		return result
+	}
+
+	rooms := search("")
+	assert.Equal(t, []string{"201", "101", "102"}, numbers(rooms), "fresh rooms, most free seats first")
+	require.NotNil(t, rooms[0].FreeSeats)
+	assert.Equal(t, 85, *rooms[0].FreeSeats)
+	assert.True(t, rooms[0].IsFresh)
+	require.NotNil(t, rooms[2].PersonCount)
+	assert.Nil(t, rooms[2].FreeSeats, "no capacity, no free seats")
+	assert.Nil(t, rooms[2].OccupancyRate)
+	assert.True(t, rooms[2].IsFresh)
+
+	assert.Equal(t, []string{"201"}, numbers(search("&min_seats=10")))
+	assert.Equal(t, []string{"101", "102"}, numbers(search("&type=classroom")))
+	assert.Equal(t, []string{"101", "102"}, numbers(search("&floor=1")))
+	assert.Equal(t, []string{"201", "101", "102", "301"}, numbers(search("&include_stale=true")))
+	assert.Equal(t, []string{"201", "101", "102"}, numbers(search(fmt.Sprintf("&building_id=%d", building.ID))))
+}
+
+func TestOccupancySummaries(t *testing.T) {
+	api := newTestAPI(t)
+	cityID := api.createCity("Москва", "Moscow").ID
+	central := api.createBuilding(cityID, 2)
+	annex := api.createBuilding(cityID, 1)
+	first := api.createAuditorium(central, "101", "classroom", 1, 30)
+	second := api.createAuditorium(central, "201", "classroom", 2, 50)
+	api.createAuditorium(annex, "1", "coworking", 1, 20)
+
+	camera, token := api.createCamera("AA:BB:CC:DD:EE:01", central, &first)
+	api.postEvent(token, camera.Mac, readingTime, 15, http.StatusCreated)
+	camera, token = api.createCamera("AA:BB:CC:DD:EE:02", central, &second)
+	api.postEvent(token, camera.Mac, readingTime.Add(-time.Hour), 40, http.StatusCreated)
+
+	at := readingTime.Add(time.Minute).Format(time.RFC3339)
+	var building forms.BuildingOccupancySummary
+	api.do(http.MethodGet, fmt.Sprintf("/v1/cities/%d/buildings/%d/occupancy/summary?timestamp=%s", cityID, central.ID, at), nil, http.StatusOK, &building)
+	assert.Equal(t, central.ID, building.Building.ID)
+	assert.Equal(t, 2, building.Auditoriums)
+	assert.Equal(t, 1, building.FreshAuditoriums)
+	assert.Equal(t, 1, building.StaleAuditoriums)
+	assert.Equal(t, 15, building.TotalPeople)
+	assert.Equal(t, 80, building.TotalCapacity)
+	assert.Equal(t, 30, building.FreshCapacity)
+	require.NotNil(t, building.UtilisationRate)
+	assert.InDelta(t, 50, *building.UtilisationRate, 0.001)
+	require.Len(t, building.Floors, 2)
+	assert.Equal(t, 1, building.Floors[0].FloorNumber)
+	assert.Equal(t, 15, building.Floors[0].TotalPeople)
+
+	var city forms.CityOccupancySummary
+	api.do(http.MethodGet, fmt.Sprintf("/v1/cities/%d/occupancy/summary?timestamp=%s", cityID, at), nil, http.StatusOK, &city)
+	assert.Equal(t, cityID, city.CityID)
+	assert.Equal(t, 3, city.Auditoriums)
+	assert.Equal(t, 1, city.NoDataAuditoriums)
+	assert.Equal(t, 15, city.TotalPeople)
+	require.Len(t, city.Buildings, 2)
+	assert.Equal(t, annex.ID, city.Buildings[1].Building.ID)
+	assert.Nil(t, city.Buildings[1].UtilisationRate)
+}
+
+func TestAuditoriumOccupancyRate(t *testing.T) {
+	api := newTestAPI(t)
+	building := api.createBuilding(api.createCity("Москва", "Moscow").ID, 1)
+	room := api.createAuditorium(building, "101", "classroom", 1, 20)
+	camera, token := api.createCamera("AA:BB:CC:DD:EE:01", building, &room)
+	api.postEvent(token, camera.Mac, readingTime, 30, http.StatusCreated)
+	api.postEvent(token, camera.Mac, readingTime.Add(time.Hour), 10, http.StatusCreated)
+
+	path := fmt.Sprintf("/v1/cities/%d/buildings/%d/auditories/%d/statistics?day=%s&type=2",
+		building.CityID, building.ID, room.ID, readingTime.Format("2006-01-02"))
+	var stats []forms.HourlyRateStatsResponse
+	api.do(http.MethodGet, path, nil, http.StatusOK, &stats)
+	require.Len(t, stats, 13, "hours 9 to 21")
+	byHour := make(map[int]forms.HourlyRateStatsResponse)
+	for _, s := range stats {
+		byHour[s.Hour] = s
+	}
+	assert.InDelta(t, 150, byHour[10].OccupancyRate, 0.001, "not clamped to 100")
+	assert.True(t, byHour[10].OverCapacity)
+	assert.InDelta(t, 50, byHour[11].OccupancyRate, 0.001)
+	assert.False(t, byHour[11].OverCapacity)
+	assert.Equal(t, 20, byHour[11].Capacity)
+	assert.False(t, byHour[12].OverCapacity)
+
+	// Rooms created before capacity was required may have none.
+	a, err := api.store.GetAuditorium(building.ID, room.ID)
+	require.NoError(t, err)
+	a.Capacity = 0
+	require.NoError(t, api.store.UpdateAuditorium(a))
+	api.do(http.MethodGet, path, nil, http.StatusUnprocessableEntity, nil)
+	// Absolute statistics do not need the capacity.
+	api.do(http.MethodGet, strings.TrimSuffix(path, "&type=2")+"&type=1", nil, http.StatusOK, nil)
+	api.do(http.MethodGet, strings.TrimSuffix(path, "&type=2")+"&type=3", nil, http.StatusBadRequest, nil)
+}
+
+func TestOccupancySeries(t *testing.T) {
+	api := newTestAPI(t)
+	building := api.createBuilding(api.createCity("Москва", "Moscow").ID, 1)
+	room := api.createAuditorium(building, "101", "classroom", 1, 20)
+	camera, token := api.createCamera("AA:BB:CC:DD:EE:01", building, &room)
+	api.postEvent(token, camera.Mac, readingTime, 10, http.StatusCreated)
+	api.postEvent(token, camera.Mac, readingTime.Add(20*time.Minute), 20, http.StatusCreated)
+	api.postEvent(token, camera.Mac, readingTime.Add(time.Hour), 30, http.StatusCreated)
+
+	series := func(from, to time.Time, query string, wantStatus int) forms.OccupancySeriesResponse {
+		t.Helper()
+		var resp forms.OccupancySeriesResponse
+		var out any
+		if wantStatus == http.StatusOK {
+			out = &resp
+		}
+		api.do(http.MethodGet, fmt.Sprintf("/v1/cities/%d/buildings/%d/auditories/%d/occupancy/series?from=%s&to=%s%s",
+			building.CityID, building.ID, room.ID, from.Format(time.RFC3339), to.Format(time.RFC3339), query),
+			nil, wantStatus, out)
+		return resp
+	}
+	day := readingTime.Truncate(24 * time.Hour)
+
+	resp := series(day, day.AddDate(0, 0, 1), "", http.StatusOK)
+	assert.Equal(t, "1h", resp.Bucket, "default bucket")
+	require.Len(t, resp.Points, 2)
+	first := resp.Points[0]
+	assert.Equal(t, readingTime, first.BucketStart)
+	assert.Equal(t, readingTime.Add(time.Hour), first.BucketEnd)
+	assert.InDelta(t, 15, first.Avg, 0.001)
+	require.NotNil(t, first.Min)
+	require.NotNil(t, first.Max)
+	assert.Equal(t, 10, *first.Min)
+	assert.Equal(t, 20, *first.Max)
+	assert.Equal(t, 2, first.SampleCount)
+	assert.Equal(t, models.SeriesSourceOccupancy, first.Source)
+
+	resp = series(day, day.AddDate(0, 0, 1), "&bucket=15m", http.StatusOK)
+	require.Len(t, resp.Points, 3)
+	assert.Equal(t, readingTime.Add(15*time.Minute), resp.Points[1].BucketStart)
+	assert.Equal(t, readingTime.Add(30*time.Minute), resp.Points[1].BucketEnd)
+
+	resp = series(readingTime.Add(time.Minute), readingTime.Add(time.Hour), "&bucket=5m", http.StatusOK)
+	require.Len(t, resp.Points, 1, "from is inclusive, to exclusive")
+	assert.Equal(t, 20, *resp.Points[0].Max)
+
+	resp = series(day.AddDate(0, 0, -1), day, "", http.StatusOK)
+	assert.NotNil(t, resp.Points, "an empty series is [], not null")
+	assert.Empty(t, resp.Points)
+
+	series(day, day.AddDate(0, 0, 31), "", http.StatusOK)
+	series(day, day.AddDate(0, 0, 31).Add(time.Second), "", http.StatusBadRequest)
+	series(day, day, "", http.StatusBadRequest)
+	series(day, day.AddDate(0, 0, 1), "&bucket=2h", http.StatusBadRequest)
+}
+
+func TestImportCSV(t *testing.T) {
+	api := newTestAPI(t)
+	cityID := api.createCity("Москва", "Moscow").ID
+	existing := api.createBuilding(cityID, 2) // "1 Lenina St"
+	api.createAuditorium(existing, "201", "classroom", 2, 30)
+
+	upload := func(path, csv string, wantStatus int) forms.ImportResult {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(csv))
+		req.Header.Set("Content-Type", "text/csv")
+		w := httptest.NewRecorder()
+		api.router.ServeHTTP(w, req)
+		require.Equal(t, wantStatus, w.Code, w.Body.String())
+		var result forms.ImportResult
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		return result
+	}
+	buildingsPath := fmt.Sprintf("/v1/cities/%d/import/buildings", cityID)
+	listBuildings := func() []forms.BuildingResponse {
+		t.Helper()
+		var buildings []forms.BuildingResponse
+		api.do(http.MethodGet, fmt.Sprintf("/v1/cities/%d/buildings", cityID), nil, http.StatusOK, &buildings)
+		return buildings
+	}
+
+	valid := "address_ru,address_en,floors_count\n" +
+		"\"ул. Ленина, 1\",1 Lenina St,3\n" +
+		"пр. Мира 2,2 Mira Ave,4\n"
+	result := upload(buildingsPath+"?dry_run=true", valid, http.StatusOK)
+	assert.True(t, result.DryRun)
+	assert.False(t, result.Applied)
+	assert.Equal(t, forms.ImportResult{DryRun: true, Rows: 2, Created: 1, Updated: 1, Errors: []forms.ImportRowError{}}, result)
+	assert.Len(t, listBuildings(), 1, "dry run writes nothing")
+
+	bad := valid + "ул. Новая 3,3 Novaya St,0\n" + "ул. Ленина 1,1 Lenina St,1\n"
+	result = upload(buildingsPath, bad, http.StatusUnprocessableEntity)
+	assert.False(t, result.Applied)
+	assert.Equal(t, []forms.ImportRowError{
+		{Row: 4, Column: "floors_count", Error: "failed on the 'required' rule"},
+		{Row: 5, Column: "address_en", Error: "same building as row 2"},
+	}, result.Errors)
+	buildings := listBuildings()
+	require.Len(t, buildings, 1, "a bad row rolls back the whole file")
+	assert.Equal(t, 2, buildings[0].FloorsCount)
+
+	result = upload(buildingsPath, valid, http.StatusOK)
+	assert.True(t, result.Applied)
+	assert.Len(t, listBuildings(), 2)
+	result = upload(buildingsPath, valid, http.StatusOK)
+	assert.Equal(t, 2, result.Unchanged, "importing the same file again changes nothing")
+
+	// The building now has three floors.
+	upload(fmt.Sprintf("/v1/cities/%d/import/auditoriums", cityID),
+		"building_address_en,auditorium_number,floor_number,capacity,type_en\n1 Lenina St,201,3,30,classroom\n",
+		http.StatusOK)
+	api.do(http.MethodPost, buildingsPath, nil, http.StatusBadRequest, nil)
+	upload("/v1/cities/999/import/buildings", valid, http.StatusNotFound)
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"web_backend_v2/forms"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxImportBodyBytes limits the size of an uploaded CSV.
+const maxImportBodyBytes = 5 << 20
+
+// ImportController bulk-loads buildings and auditoriums of a city from CSV.
+type ImportController struct {
+	*Stores
+}
+
+// ImportBuildings handles POST /v1/cities/:city_id/import/buildings
+// The CSV (columns forms.BuildingImportColumns) is sent as the request body or
+// as the "file" field of a multipart form. ?dry_run=true only reports what
+// would change. Returns 200 when applied or on a clean dry run and 422 with
+// row errors, in which case nothing is written.
+func (h *ImportController) ImportBuildings(c *gin.Context) {
+	cityID, err := parseUintParam(c, "city_id")
+	if err != nil {
+		return
+	}
+	body, ok := importBody(c)
+	if !ok {
+		return
+	}
+	defer body.Close()
+
+	imp, err := forms.ParseBuildingsCSV(body)
+	if err != nil {
+		respondImportError(c, err)
+		return
+	}
+	result, err := h.Imports.ImportBuildings(cityID, imp, c.Query("dry_run") == "true")
+	respondImport(c, result, err)
+}
+
+// ImportAuditoriums handles POST /v1/cities/:city_id/import/auditoriums
+// Same as ImportBuildings with columns forms.AuditoriumImportColumns; rows refer
+// to buildings of the city by building_address_en.
+func (h *ImportController) ImportAuditoriums(c *gin.Context) {
+	cityID, err := parseUintParam(c, "city_id")
+	if err != nil {
+		return
+	}
+	body, ok := importBody(c)
+	if !ok {
+		return
+	}
+	defer body.Close()
+
+	imp, err := forms.ParseAuditoriumsCSV(body)
+	if err != nil {
+		respondImportError(c, err)
+		return
+	}
+	result, err := h.Imports.ImportAuditoriums(cityID, imp, c.Query("dry_run") == "true")
+	respondImport(c, result, err)
+}
+
+// importBody returns the uploaded CSV, writing the error response itself.
+func importBody(c *gin.Context) (io.ReadCloser, bool) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBodyBytes)
+	if c.ContentType() != "multipart/form-data" {
+		return c.Request.Body, true
+	}
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "multipart upload needs the CSV in the \"file\" field"})
+		return nil, false
+	}
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return f, true
+}
+
+func respondImportError(c *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file exceeds 5 MB"})
+	case errors.Is(err, forms.ErrImportFile):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func respondImport(c *gin.Context, result *forms.ImportResult, err error) {
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "city not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	if len(result.Errors) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, result)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
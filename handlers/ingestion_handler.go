@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"web_backend_v2/events"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IngestionController struct {
+	Source events.EventSource
+}
+
+// GetIngestionStats handles GET /v1/admin/ingestion/stats
+// Returns consumer throughput counters together with the event source state.
+func (h *IngestionController) GetIngestionStats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"event_source": h.Source.Status(),
+		"consumer":     h.Source.Stats(),
+	})
+}
+
+// PublishEvent handles POST /v1/admin/ingestion/publish
+// Enqueues the raw JSON body into the event source as if a camera had sent it;
+// meant for local development with EVENT_SOURCE=memory.
+func (h *IngestionController) PublishEvent(c *gin.Context) {
+	body, err := c.GetRawData()
+	if err != nil || !json.Valid(body) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "body must be a JSON camera event"})
+		return
+	}
+
+	if err := h.Source.Publish(c.Request.Context(), body); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusAccepted)
+}
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStripAccessToken(t *testing.T) {
+	tests := map[string]string{
+		"/v1/occupancy/ws":                                    "/v1/occupancy/ws",
+		"/v1/occupancy/ws?access_token=a.b.c":                 "/v1/occupancy/ws",
+		"/v1/occupancy/ws?city_id=1&access_token=a.b.c":       "/v1/occupancy/ws?city_id=1",
+		"/v1/occupancy/ws?access_token=a.b.c&city_id=1&x=%20": "/v1/occupancy/ws?city_id=1&x=%20",
+		"/v1/occupancy/ws?access%5Ftoken=a.b.c&city_id=1":     "/v1/occupancy/ws?city_id=1",
+		"/v1/occupancy/ws?access_token":                       "/v1/occupancy/ws",
+		"/v1/cities/?access_token_hint=1":                     "/v1/cities/?access_token_hint=1",
+	}
+	for path, want := range tests {
+		assert.Equal(t, want, stripAccessToken(path), path)
+	}
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"web_backend_v2/forms"
+)
+
+const (
+	// occupancyHistorySize is how many recent updates (of all buildings) are
+	// kept for Last-Event-ID resume.
+	occupancyHistorySize = 4096
+	// occupancySubscriberBuffer is how many updates a subscriber may fall
+	// behind before it is dropped.
+	occupancySubscriberBuffer = 64
+)
+
+// OccupancyEvent is a published update with its stream event id.
+type OccupancyEvent struct {
+	ID     string
+	seq    uint64
+	Update forms.OccupancyUpdate
+}
+
+// OccupancyTopic selects the updates of one auditorium, building or city
+// (Scope is one of the forms.Scope* constants).
+type OccupancyTopic struct {
+	Scope string
+	ID    uint
+}
+
+// topicsOf lists the topics an update is published on.
+func topicsOf(u forms.OccupancyUpdate) [3]OccupancyTopic {
+	return [3]OccupancyTopic{
+		{forms.ScopeAuditorium, u.AuditoriumID},
+		{forms.ScopeBuilding, u.BuildingID},
+		{forms.ScopeCity, u.CityID},
+	}
+}
+
+// OccupancyHub fans stored occupancy readings out to the subscribers of their
+// auditorium, building and city. Event ids are "<epoch>-<seq>": the epoch
+// changes on every restart, so ids from a previous process are never taken
+// for resumable ones.
+type OccupancyHub struct {
+	mu      sync.Mutex
+	epoch   string
+	seq     uint64
+	history []OccupancyEvent // ring buffer, oldest at start once full
+	start   int
+	subs    map[OccupancyTopic]map[*OccupancySubscription]struct{}
+	conns   map[*OccupancySubscription]struct{} // every live subscriber
+	closed  bool
+}
+
+// OccupancySubscription receives the updates of its topics on C, each update
+// once even when it matches several topics. C is closed when the subscriber
+// falls too far behind, is unsubscribed or the hub closes.
+type OccupancySubscription struct {
+	C <-chan OccupancyEvent
+	// StartID is the id of the last event published before the subscription,
+	// to be sent with a snapshot so that a reconnect resumes after it.
+	StartID string
+	c       chan OccupancyEvent
+	topics  map[OccupancyTopic]struct{}
+	closed  bool
+}
+
+// NewOccupancyHub creates an empty hub.
+func NewOccupancyHub() *OccupancyHub {
+	return &OccupancyHub{
+		epoch: strconv.FormatInt(time.Now().UnixNano(), 36),
+		subs:  make(map[OccupancyTopic]map[*OccupancySubscription]struct{}),
+		conns: make(map[*OccupancySubscription]struct{}),
+	}
+}
+
+// Publish records an update and passes it to the subscribers of its building.
+// It never blocks: a subscriber whose buffer is full is dropped and has to
+// reconnect with Last-Event-ID.
+func (h *OccupancyHub) Publish(update forms.OccupancyUpdate) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	h.seq++
+	event := OccupancyEvent{ID: fmt.Sprintf("%s-%d", h.epoch, h.seq), seq: h.seq, Update: update}
+	if len(h.history) < occupancyHistorySize {
+		h.history = append(h.history, event)
+	} else {
+		h.history[h.start] = event
+		h.start = (h.start + 1) % occupancyHistorySize
+	}
+
+	sent := make(map[*OccupancySubscription]bool)
+	for _, topic := range topicsOf(update) {
+		for sub := range h.subs[topic] {
+			if sent[sub] {
+				continue
+			}
+			sent[sub] = true
+			select {
+			case sub.c <- event:
+			default:
+				h.remove(sub)
+			}
+		}
+	}
+}
+
+// Subscribe registers a subscriber for a building. When lastEventID names an
+// event of this process that is still in the history, the building's updates
+// published after it are returned for replay and resumed is true; otherwise
+// the caller should start from a snapshot.
+func (h *OccupancyHub) Subscribe(buildingID uint, lastEventID string) (sub *OccupancySubscription, replay []OccupancyEvent, resumed bool) {
+	sub = h.Connect()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if sub.closed {
+		return sub, nil, false
+	}
+	h.follow(sub, OccupancyTopic{forms.ScopeBuilding, buildingID})
+
+	last, ok := h.parseID(lastEventID)
+	if !ok {
+		return sub, nil, false
+	}
+	// The events after last are all still kept only if the oldest kept one
+	// directly follows it or comes before it.
+	if len(h.history) > 0 && h.history[h.start].seq > last+1 {
+		return sub, nil, false
+	}
+	for i := range h.history {
+		event := h.history[(h.start+i)%len(h.history)]
+		if event.seq > last && event.Update.BuildingID == buildingID {
+			replay = append(replay, event)
+		}
+	}
+	return sub, replay, true
+}
+
+// Connect registers a subscriber without topics; Follow adds them. Replay is
+// not available this way.
+func (h *OccupancyHub) Connect() *OccupancySubscription {
+	c := make(chan OccupancyEvent, occupancySubscriberBuffer)
+	sub := &OccupancySubscription{C: c, c: c, topics: make(map[OccupancyTopic]struct{})}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	sub.StartID = fmt.Sprintf("%s-%d", h.epoch, h.seq)
+	if h.closed {
+		sub.closed = true
+		close(c)
+		return sub
+	}
+	h.conns[sub] = struct{}{}
+	return sub
+}
+
+// Follow adds a topic to a subscriber. It does nothing for a subscriber that
+// has been dropped.
+func (h *OccupancyHub) Follow(sub *OccupancySubscription, topic OccupancyTopic) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !sub.closed {
+		h.follow(sub, topic)
+	}
+}
+
+// Unfollow removes a topic from a subscriber.
+func (h *OccupancyHub) Unfollow(sub *OccupancySubscription, topic OccupancyTopic) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := sub.topics[topic]; ok {
+		delete(sub.topics, topic)
+		h.unindex(sub, topic)
+	}
+}
+
+// Unsubscribe removes a subscriber and closes its channel; it is safe to call
+// for a subscriber that has already been dropped.
+func (h *OccupancyHub) Unsubscribe(sub *OccupancySubscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(sub)
+}
+
+// Close closes every subscription, which ends the open streams, and makes
+// later subscriptions start closed. It is meant for server shutdown.
+func (h *OccupancyHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for sub := range h.conns {
+		h.remove(sub)
+	}
+}
+
+// follow adds a topic to a live subscriber; the caller holds mu.
+func (h *OccupancyHub) follow(sub *OccupancySubscription, topic OccupancyTopic) {
+	sub.topics[topic] = struct{}{}
+	if h.subs[topic] == nil {
+		h.subs[topic] = make(map[*OccupancySubscription]struct{})
+	}
+	h.subs[topic][sub] = struct{}{}
+}
+
+// unindex removes a subscriber from the index of a topic; the caller holds mu.
+func (h *OccupancyHub) unindex(sub *OccupancySubscription, topic OccupancyTopic) {
+	delete(h.subs[topic], sub)
+	if len(h.subs[topic]) == 0 {
+		delete(h.subs, topic)
+	}
+}
+
+// remove drops a subscriber; the caller holds mu.
+func (h *OccupancyHub) remove(sub *OccupancySubscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	delete(h.conns, sub)
+	for topic := range sub.topics {
+		h.unindex(sub, topic)
+	}
+	close(sub.c)
+}
+
+// parseID returns the sequence number of an event id of this process that is
+// not ahead of the latest one; the caller holds mu.
+func (h *OccupancyHub) parseID(id string) (uint64, bool) {
+	epoch, seq, ok := strings.Cut(id, "-")
+	if !ok || epoch != h.epoch {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil || n > h.seq {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"fmt"
+	"testing"
+	"web_backend_v2/forms"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// publishN publishes n readings of auditorium 10 of the building (city 1).
+func publishN(hub *OccupancyHub, buildingID uint, n int) {
+	for i := 0; i < n; i++ {
+		hub.Publish(forms.OccupancyUpdate{CityID: 1, BuildingID: buildingID, AuditoriumID: 10, PersonCount: i})
+	}
+}
+
+// drain returns the events buffered on sub.C and whether C has been closed.
+func drain(sub *OccupancySubscription) (events []OccupancyEvent, closed bool) {
+	for {
+		select {
+		case event, ok := <-sub.C:
+			if !ok {
+				return events, true
+			}
+			events = append(events, event)
+		default:
+			return events, false
+		}
+	}
+}
+
+func eventIDs(events []OccupancyEvent) []string {
+	var ids []string
+	for _, e := range events {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
+
+func TestOccupancyHubResume(t *testing.T) {
+	hub := NewOccupancyHub()
+	id := func(seq int) string { return fmt.Sprintf("%s-%d", hub.epoch, seq) }
+	publishN(hub, 1, 2) // 1, 2
+	publishN(hub, 2, 1) // 3
+	publishN(hub, 1, 1) // 4
+
+	sub, replay, resumed := hub.Subscribe(1, id(1))
+	assert.True(t, resumed)
+	assert.Equal(t, []string{id(2), id(4)}, eventIDs(replay), "only the building's events after the id")
+	assert.Equal(t, id(4), sub.StartID)
+
+	_, replay, resumed = hub.Subscribe(1, id(4))
+	assert.True(t, resumed, "nothing missed")
+	assert.Empty(t, replay)
+
+	_, replay, resumed = hub.Subscribe(1, id(0))
+	assert.True(t, resumed, "the whole history is still kept")
+	assert.Len(t, replay, 3)
+
+	publishN(hub, 1, 1) // 5
+	publishN(hub, 2, 1) // 6
+	events, closed := drain(sub)
+	assert.False(t, closed)
+	assert.Equal(t, []string{id(5)}, eventIDs(events), "live events follow the replay")
+}
+
+func TestOccupancyHubSnapshotFallback(t *testing.T) {
+	hub := NewOccupancyHub()
+	publishN(hub, 1, occupancyHistorySize+10) // events 1..10 are lost
+	id := func(seq int) string { return fmt.Sprintf("%s-%d", hub.epoch, seq) }
+
+	tests := []struct {
+		name        string
+		lastEventID string
+		wantResumed bool
+		wantReplay  int
+	}{
+		{name: "no id", lastEventID: ""},
+		{name: "malformed", lastEventID: "garbage"},
+		{name: "bad sequence", lastEventID: hub.epoch + "-x"},
+		{name: "previous process", lastEventID: "0-5"},
+		{name: "ahead of the hub", lastEventID: id(occupancyHistorySize + 11)},
+		{name: "fallen out of history", lastEventID: id(5)},
+		{name: "one event lost", lastEventID: id(9)},
+		{name: "last lost event", lastEventID: id(10), wantResumed: true, wantReplay: occupancyHistorySize},
+		{name: "latest", lastEventID: id(occupancyHistorySize + 10), wantResumed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, replay, resumed := hub.Subscribe(1, tt.lastEventID)
+			defer hub.Unsubscribe(sub)
+			assert.Equal(t, tt.wantResumed, resumed)
+			assert.Len(t, replay, tt.wantReplay)
+			assert.Equal(t, id(occupancyHistorySize+10), sub.StartID, "snapshot resumes after the latest event")
+		})
+	}
+}
+
+func TestOccupancyHubSlowSubscriber(t *testing.T) {
+	hub := NewOccupancyHub()
+	slow, _, _ := hub.Subscribe(1, "")
+	other, _, _ := hub.Subscribe(2, "")
+
+	// Publish never blocks on a subscriber that does not read.
+	publishN(hub, 1, occupancySubscriberBuffer+1)
+	events, closed := drain(slow)
+	assert.Len(t, events, occupancySubscriberBuffer)
+	assert.True(t, closed, "dropped once its buffer is full")
+	hub.Unsubscribe(slow) // already dropped
+
+	publishN(hub, 2, 1)
+	events, closed = drain(other)
+	assert.Len(t, events, 1)
+	assert.False(t, closed, "other subscribers are not affected")
+
+	// A dropped subscriber reconnects with the last id it got and misses nothing.
+	resumed, replay, ok := hub.Subscribe(1, slow.StartID)
+	defer hub.Unsubscribe(resumed)
+	require.True(t, ok)
+	assert.Len(t, replay, occupancySubscriberBuffer+1)
+}
+
+func TestOccupancyHubTopics(t *testing.T) {
+	hub := NewOccupancyHub()
+	sub := hub.Connect()
+	hub.Follow(sub, OccupancyTopic{forms.ScopeCity, 1})
+	hub.Follow(sub, OccupancyTopic{forms.ScopeAuditorium, 10})
+
+	publishN(hub, 1, 1)
+	events, _ := drain(sub)
+	assert.Len(t, events, 1, "once, though it matches two topics")
+
+	hub.Unfollow(sub, OccupancyTopic{forms.ScopeCity, 1})
+	hub.Publish(forms.OccupancyUpdate{CityID: 1, BuildingID: 1, AuditoriumID: 11})
+	events, _ = drain(sub)
+	assert.Empty(t, events)
+
+	hub.Close()
+	_, closed := drain(sub)
+	assert.True(t, closed)
+	late, _, _ := hub.Subscribe(1, "")
+	_, closed = drain(late)
+	assert.True(t, closed, "subscriptions after Close start closed")
+}
@@ -2,29 +2,117 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"time"
 	"web_backend_v2/forms"
 	"web_backend_v2/models"
 )
 
-var occupancyModel = new(models.OccupancyModel)
-
 // ProcessCameraEvent parses and stores occupancy data from RabbitMQ message.
-func ProcessCameraEvent(messageBody []byte) error {
+// Malformed JSON is a forms.ErrInvalidCameraEvent, so it is dead-lettered
+// rather than redelivered forever.
+func (s *Stores) ProcessCameraEvent(messageBody []byte) error {
 	var event forms.CameraEvent
 	if err := json.Unmarshal(messageBody, &event); err != nil {
-		return fmt.Errorf("failed to parse camera event (raw len=%d): %w", len(messageBody), err)
+		return fmt.Errorf("%w: failed to parse camera event (raw len=%d): %w", forms.ErrInvalidCameraEvent, len(messageBody), err)
 	}
 
+	err := s.storeCameraEvent(&event)
+	if errors.Is(err, models.ErrDuplicateEvent) {
+		// Already stored: a redelivery or a camera retransmission; ack it.
+		return nil
+	}
+	return err
+}
+
+// storeCameraEvent validates and stores one event; it is shared by the RabbitMQ
+// consumer and HTTP ingestion. ErrDuplicateEvent is returned unwrapped.
+func (s *Stores) storeCameraEvent(event *forms.CameraEvent) error {
 	if err := event.Validate(); err != nil {
 		return fmt.Errorf("camera %s validation failed: %w", event.IDCamera, err)
 	}
 
-	if err := occupancyModel.SaveEvent(&event); err != nil {
+	seenAt := time.Now()
+	if err := s.Occupancy.SaveEvent(event); err != nil {
+		if errors.Is(err, models.ErrDuplicateEvent) {
+			log.Printf("Skipped duplicate event from camera %s (%s)", event.IDCamera, event.DedupKey())
+			return models.ErrDuplicateEvent
+		}
 		return fmt.Errorf("camera %s save failed: %w", event.IDCamera, err)
 	}
 
+	// Only stored events count as heartbeats: a redelivered or replayed duplicate
+	// would inflate events_total and move last_event_at back in time.
+	if err := s.Cameras.RecordHeartbeat(event.IDCamera, *event.PersonCount, event.Timestamp, seenAt); err != nil {
+		log.Printf("camera %s heartbeat not recorded: %v", event.IDCamera, err)
+	}
+
 	log.Printf("Stored occupancy from camera %s: %d persons at %s", event.IDCamera, *event.PersonCount, event.Timestamp.UTC().Format("2006-01-02T15:04:05Z07:00"))
 	return nil
 }
+
+// ProcessCameraEvents parses a batch of RabbitMQ messages and stores them in one
+// transaction. It returns one error per message; a non-nil second result means
+// nothing was stored.
+func (s *Stores) ProcessCameraEvents(bodies [][]byte) ([]error, error) {
+	results := make([]error, len(bodies))
+	events := make([]*forms.CameraEvent, 0, len(bodies))
+	index := make([]int, 0, len(bodies))
+	beats := make([]models.Heartbeat, 0, len(bodies))
+	seenAt := time.Now()
+
+	for i, body := range bodies {
+		var event forms.CameraEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			results[i] = fmt.Errorf("%w: failed to parse camera event (raw len=%d): %w", forms.ErrInvalidCameraEvent, len(body), err)
+			continue
+		}
+		if err := event.Validate(); err != nil {
+			results[i] = fmt.Errorf("camera %s validation failed: %w", event.IDCamera, err)
+			continue
+		}
+		events = append(events, &event)
+		index = append(index, i)
+		beats = append(beats, models.Heartbeat{
+			Mac:         event.IDCamera,
+			PersonCount: *event.PersonCount,
+			EventTime:   event.Timestamp,
+			SeenAt:      seenAt,
+		})
+	}
+	if len(events) == 0 {
+		return results, nil
+	}
+
+	saveErrs, err := s.Occupancy.SaveEvents(events)
+	if err != nil {
+		return nil, fmt.Errorf("batch of %d events not saved: %w", len(events), err)
+	}
+
+	stored, duplicates := 0, 0
+	storedBeats := beats[:0]
+	for j, saveErr := range saveErrs {
+		if errors.Is(saveErr, models.ErrDuplicateEvent) {
+			duplicates++
+			continue
+		}
+		if saveErr != nil {
+			results[index[j]] = fmt.Errorf("camera %s save failed: %w", events[j].IDCamera, saveErr)
+			continue
+		}
+		stored++
+		storedBeats = append(storedBeats, beats[j])
+	}
+
+	// Only stored events count as heartbeats, as in storeCameraEvent.
+	if len(storedBeats) > 0 {
+		if err := s.Cameras.RecordHeartbeats(storedBeats); err != nil {
+			log.Printf("heartbeats of %d events not recorded: %v", len(storedBeats), err)
+		}
+	}
+
+	log.Printf("Stored occupancy from a batch of %d events (%d duplicates skipped, %d rejected)", stored, duplicates, len(bodies)-stored-duplicates)
+	return results, nil
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"net/http"
+	"web_backend_v2/auth"
+	"web_backend_v2/forms"
+	"web_backend_v2/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errOutOfScope is the 403 message for requests outside the principal's scope.
+const errOutOfScope = "forbidden: outside the cities and buildings of the principal"
+
+// scopeFilteredRoutes are the /v1/cities lists a scoped principal may read
+// although they are not limited to its cities and buildings; their handlers
+// leave out what is not in scope.
+var scopeFilteredRoutes = map[string]bool{
+	"/v1/cities/":                   true,
+	"/v1/cities/:city_id/buildings": true,
+}
+
+// RequireScope keeps scoped principals (see auth.Scope) within their cities
+// and buildings on the /v1/cities routes. Routes of a city need the whole
+// city in scope; routes of a building need the building or its city. Since
+// not every handler looks past its innermost path parameter, the :building_id
+// and :auditorium_id of the path are first checked to belong to :city_id and
+// :building_id. Unrestricted principals are not checked at all.
+func RequireScope(s *Stores) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scope := scopeOf(c)
+		if scope.Unrestricted() || (isRead(c) && scopeFilteredRoutes[c.FullPath()]) {
+			c.Next()
+			return
+		}
+		if c.Param("city_id") == "" {
+			forbidOutOfScope(c)
+			return
+		}
+		cityID, err := parseUintParam(c, "city_id")
+		if err != nil {
+			c.Abort()
+			return
+		}
+		if c.Param("building_id") == "" {
+			if !scope.AllowsCity(cityID) {
+				forbidOutOfScope(c)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		building, ok := s.scopedBuilding(c)
+		if !ok {
+			c.Abort()
+			return
+		}
+		if !scope.AllowsBuilding(building.CityID, building.ID) {
+			forbidOutOfScope(c)
+			return
+		}
+		if c.Param("auditorium_id") != "" {
+			auditoriumID, err := parseUintParam(c, "auditorium_id")
+			if err != nil {
+				c.Abort()
+				return
+			}
+			if _, err := s.Auditoriums.GetAuditorium(building.ID, auditoriumID); err != nil {
+				if err == gorm.ErrRecordNotFound {
+					c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "auditorium not found"})
+				} else {
+					c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				}
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// RequireCameraScope keeps scoped principals on the /v1/cameras routes away
+// from cameras attached in other cities and buildings; the camera's auditorium
+// is resolved to its building and city. Unattached cameras are a pool shared
+// by all principals.
+func RequireCameraScope(s *Stores) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scope := scopeOf(c)
+		if scope.Unrestricted() || c.Param("camera_id") == "" {
+			c.Next()
+			return
+		}
+		cameraID, err := parseUintParam(c, "camera_id")
+		if err != nil {
+			c.Abort()
+			return
+		}
+		camera, err := s.Cameras.GetCameraWithAssignment(cameraID)
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "camera not found"})
+			} else {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			return
+		}
+		inScope, err := s.cameraInScope(scope, camera)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !inScope {
+			forbidOutOfScope(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireUnscoped rejects scoped principals with 403, for routes that are not
+// about any one city or building.
+func RequireUnscoped() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !scopeOf(c).Unrestricted() {
+			forbidOutOfScope(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// scopeOf returns the scope of the principal set by Authenticate; requests
+// without a principal are only let through by routes that need none.
+func scopeOf(c *gin.Context) auth.Scope {
+	if principal := PrincipalFrom(c); principal != nil {
+		return principal.Scope
+	}
+	return auth.Scope{}
+}
+
+// cameraInScope reports whether the camera is unattached or attached in scope.
+func (s *Stores) cameraInScope(scope auth.Scope, camera *models.CameraWithAssignment) (bool, error) {
+	if scope.Unrestricted() || camera.AuditoriumID == nil {
+		return true, nil
+	}
+	locations, err := s.Auditoriums.GetAuditoriumLocations([]uint{*camera.AuditoriumID})
+	if err != nil {
+		return false, err
+	}
+	location, ok := locations[*camera.AuditoriumID]
+	return ok && scope.AllowsBuilding(location.CityID, location.BuildingID), nil
+}
+
+// citiesInScope keeps the cities that are in scope or hold a building in scope.
+func (s *Stores) citiesInScope(scope auth.Scope, cities []forms.City) ([]forms.City, error) {
+	if scope.Unrestricted() {
+		return cities, nil
+	}
+	buildingCities, err := s.Buildings.GetBuildingCities(scope.BuildingIDs)
+	if err != nil {
+		return nil, err
+	}
+	hosts := make(map[uint]bool, len(buildingCities))
+	for _, cityID := range buildingCities {
+		hosts[cityID] = true
+	}
+	kept := cities[:0]
+	for _, city := range cities {
+		if scope.AllowsCity(city.ID) || hosts[city.ID] {
+			kept = append(kept, city)
+		}
+	}
+	return kept, nil
+}
+
+func isRead(c *gin.Context) bool {
+	return c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead
+}
+
+func forbidOutOfScope(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": errOutOfScope})
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"web_backend_v2/auth"
+	"web_backend_v2/config"
+	"web_backend_v2/forms"
+	"web_backend_v2/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	adminKey    = "admin-key-0123456789"
+	cityKey     = "city-1-key-0123456789"
+	buildingKey = "building-3-key-0123456789"
+)
+
+// newScopeTestAPI sets up two cities: city 1 with building 1, city 2 with
+// buildings 2 and 3. Building n holds auditorium n with camera n attached. All
+// keys are facility admins; cityKey is limited to city 1 and buildingKey to
+// building 3. Each camera has reported at readingTime. Requests are sent with
+// adminKey unless they set X-API-Key.
+func newScopeTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	keys := `keys:
+  - {name: admin, role: facility-admin, key: ` + adminKey + `}
+  - {name: city-1, role: facility-admin, cities: [1], key: ` + cityKey + `}
+  - {name: building-3, role: facility-admin, buildings: [3], key: ` + buildingKey + `}
+`
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(keys), 0o600))
+	authenticator, err := auth.New(config.AuthConfig{Enabled: true, APIKeysFile: path})
+	require.NoError(t, err)
+
+	api := newAuthTestAPI(t, authenticator)
+	api.header = []string{"X-API-Key", adminKey}
+	cities := []forms.CityResponse{api.createCity("Москва", "Moscow"), api.createCity("Казань", "Kazan")}
+	for i, cityID := range []uint{cities[0].ID, cities[1].ID, cities[1].ID} {
+		b := api.createBuilding(cityID, 3)
+		a := api.createAuditorium(b, "101", "classroom", 1, 30)
+		camera, token := api.createCamera(fmt.Sprintf("aa:bb:cc:dd:ee:0%d", i+1), b, &a)
+		require.Equal(t, []uint{uint(i + 1), uint(i + 1), uint(i + 1)}, []uint{b.ID, a.ID, camera.ID})
+		api.postEvent(token, camera.Mac, readingTime, 5, http.StatusCreated)
+	}
+	return api
+}
+
+func TestScopedPrincipals(t *testing.T) {
+	api := newScopeTestAPI(t)
+	occupancy := "/occupancy?timestamp=" + readingTime.Add(time.Minute).Format(time.RFC3339)
+	tests := []struct {
+		name       string
+		key        string
+		method     string
+		path       string
+		wantStatus int
+		wantError  string
+	}{
+		{"city key: other city", cityKey, http.MethodPut, "/v1/cities/2", http.StatusForbidden, errOutOfScope},
+		{"city key: summary of other city", cityKey, http.MethodGet, "/v1/cities/2/occupancy/summary", http.StatusForbidden, errOutOfScope},
+		{"city key: building of other city", cityKey, http.MethodGet, "/v1/cities/2/buildings/2/auditories", http.StatusForbidden, errOutOfScope},
+		{"city key: auditorium of other city", cityKey, http.MethodGet, "/v1/cities/2/buildings/3/auditories/3" + occupancy, http.StatusForbidden, errOutOfScope},
+		{"city key: own building", cityKey, http.MethodGet, "/v1/cities/1/buildings/1/auditories", http.StatusOK, ""},
+		{"city key: own auditorium", cityKey, http.MethodGet, "/v1/cities/1/buildings/1/auditories/1" + occupancy, http.StatusOK, ""},
+		{"building key: its city", buildingKey, http.MethodGet, "/v1/cities/2/occupancy/summary", http.StatusForbidden, errOutOfScope},
+		{"building key: other building of its city", buildingKey, http.MethodGet, "/v1/cities/2/buildings/2/auditories", http.StatusForbidden, errOutOfScope},
+		{"building key: auditorium of other building", buildingKey, http.MethodGet, "/v1/cities/2/buildings/2/auditories/2" + occupancy, http.StatusForbidden, errOutOfScope},
+		{"building key: other city", buildingKey, http.MethodPut, "/v1/cities/1", http.StatusForbidden, errOutOfScope},
+		{"building key: own building", buildingKey, http.MethodGet, "/v1/cities/2/buildings/3/auditories", http.StatusOK, ""},
+		{"building key: own auditorium", buildingKey, http.MethodGet, "/v1/cities/2/buildings/3/auditories/3" + occupancy, http.StatusOK, ""},
+		// The path must hold together before the scope is checked, or a
+		// building in scope could be reached through another city.
+		{"building not in city", buildingKey, http.MethodGet, "/v1/cities/1/buildings/3/auditories", http.StatusNotFound, "building not found"},
+		{"building not in own city", cityKey, http.MethodGet, "/v1/cities/1/buildings/2/auditories", http.StatusNotFound, "building not found"},
+		{"auditorium not in building", buildingKey, http.MethodGet, "/v1/cities/2/buildings/3/auditories/2" + occupancy, http.StatusNotFound, "auditorium not found"},
+		{"auditorium not in own building", cityKey, http.MethodGet, "/v1/cities/1/buildings/1/auditories/3" + occupancy, http.StatusNotFound, "auditorium not found"},
+		{"city key: camera of other city", cityKey, http.MethodGet, "/v1/cameras/3", http.StatusForbidden, errOutOfScope},
+		{"city key: detach camera of other city", cityKey, http.MethodDelete, "/v1/cameras/2/attachment", http.StatusForbidden, errOutOfScope},
+		{"city key: delete camera of other city", cityKey, http.MethodDelete, "/v1/cameras/3", http.StatusForbidden, errOutOfScope},
+		{"building key: detach camera of other city", buildingKey, http.MethodDelete, "/v1/cameras/1/attachment", http.StatusForbidden, errOutOfScope},
+		{"building key: delete camera of other building", buildingKey, http.MethodDelete, "/v1/cameras/2", http.StatusForbidden, errOutOfScope},
+		{"city key: admin", cityKey, http.MethodGet, "/v1/admin/ingestion/stats", http.StatusForbidden, errOutOfScope},
+		{"building key: admin", buildingKey, http.MethodGet, "/v1/admin/ingestion/stats", http.StatusForbidden, errOutOfScope},
+		{"unscoped key: admin", adminKey, http.MethodGet, "/v1/admin/ingestion/stats", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api.t = t
+			var body struct {
+				Error string `json:"error"`
+			}
+			var out any
+			if tt.wantError != "" {
+				out = &body
+			}
+			api.do(tt.method, tt.path, nil, tt.wantStatus, out, "X-API-Key", tt.key)
+			assert.Equal(t, tt.wantError, body.Error)
+		})
+	}
+	api.t = t
+
+	// In scope, the same routes go through.
+	api.do(http.MethodDelete, "/v1/cameras/1/attachment", nil, http.StatusNoContent, nil, "X-API-Key", cityKey)
+	api.do(http.MethodDelete, "/v1/cameras/3?confirm=true", nil, http.StatusNoContent, nil, "X-API-Key", buildingKey)
+}
+
+func TestScopedLists(t *testing.T) {
+	api := newScopeTestAPI(t)
+	ids := func(key, path string) []uint {
+		t.Helper()
+		var rows []struct {
+			ID uint `json:"id"`
+		}
+		api.do(http.MethodGet, path, nil, http.StatusOK, &rows, "X-API-Key", key)
+		got := []uint{}
+		for _, row := range rows {
+			got = append(got, row.ID)
+		}
+		return got
+	}
+
+	assert.Equal(t, []uint{1, 2}, ids(adminKey, "/v1/cities/"))
+	assert.Equal(t, []uint{1}, ids(cityKey, "/v1/cities/"))
+	assert.Equal(t, []uint{2}, ids(buildingKey, "/v1/cities/"), "the city of a building in scope")
+	assert.Equal(t, []uint{3}, ids(buildingKey, "/v1/cities/2/buildings"))
+	assert.Equal(t, []uint{}, ids(buildingKey, "/v1/cities/1/buildings"))
+
+	assert.Equal(t, []uint{1, 2, 3}, ids(adminKey, "/v1/cameras/attached"))
+	assert.Equal(t, []uint{1}, ids(cityKey, "/v1/cameras/attached"))
+	assert.Equal(t, []uint{3}, ids(buildingKey, "/v1/cameras/attached"))
+}
+
+func TestScopedAlerts(t *testing.T) {
+	api := newScopeTestAPI(t)
+	// An hour after their readings every camera is stale: one alert per auditorium.
+	_, _, err := api.store.EvaluateAlerts(readingTime.Add(time.Hour), models.AlertRules{StaleAfter: 10 * time.Minute, SpikePercent: 50, SpikeWindow: 15 * time.Minute})
+	require.NoError(t, err)
+	alertOf := make(map[uint]uint64)
+	var all []forms.AlertResponse
+	api.do(http.MethodGet, "/v1/alerts", nil, http.StatusOK, &all)
+	require.Len(t, all, 3)
+	for _, alert := range all {
+		alertOf[alert.AuditoriumID] = alert.ID
+	}
+
+	auditoriums := func(key string) []uint {
+		t.Helper()
+		var alerts []forms.AlertResponse
+		api.do(http.MethodGet, "/v1/alerts", nil, http.StatusOK, &alerts, "X-API-Key", key)
+		got := []uint{}
+		for _, alert := range alerts {
+			got = append(got, alert.AuditoriumID)
+		}
+		return got
+	}
+	assert.Equal(t, []uint{1}, auditoriums(cityKey))
+	assert.Equal(t, []uint{3}, auditoriums(buildingKey))
+
+	var body gin.H
+	api.do(http.MethodGet, fmt.Sprintf("/v1/alerts/%d", alertOf[3]), nil, http.StatusForbidden, &body, "X-API-Key", cityKey)
+	assert.Equal(t, gin.H{"error": errOutOfScope}, body)
+	api.do(http.MethodPost, fmt.Sprintf("/v1/alerts/%d/ack", alertOf[3]), nil, http.StatusForbidden, nil, "X-API-Key", cityKey)
+	api.do(http.MethodPost, fmt.Sprintf("/v1/alerts/%d/ack", alertOf[2]), nil, http.StatusForbidden, nil, "X-API-Key", buildingKey)
+
+	var acked forms.AlertResponse
+	api.do(http.MethodPost, fmt.Sprintf("/v1/alerts/%d/ack", alertOf[3]), gin.H{"note": "on it"}, http.StatusOK, &acked, "X-API-Key", buildingKey)
+	assert.True(t, acked.Acknowledged)
+	assert.Equal(t, "on it", *acked.AckNote, "the first acknowledgement, so the refused one was not stored")
+	api.do(http.MethodGet, fmt.Sprintf("/v1/alerts/%d", alertOf[2]), nil, http.StatusOK, &acked)
+	assert.False(t, acked.Acknowledged, "the refused acknowledgements left the alert alone")
+}
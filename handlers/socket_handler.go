@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+	"web_backend_v2/auth"
+	"web_backend_v2/forms"
+	"web_backend_v2/models"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// maxSocketSubscriptions limits the subscriptions of one socket connection.
+	maxSocketSubscriptions = 100
+	// maxSocketMessageBytes limits the size of a control message.
+	maxSocketMessageBytes = 4096
+	// socketWriteTimeout is how long one message may take to reach a client
+	// before the connection is considered dead and closed.
+	socketWriteTimeout = 10 * time.Second
+)
+
+// SubscriptionController serves the occupancy WebSocket.
+type SubscriptionController struct {
+	*Stores
+}
+
+// OccupancySocket handles GET /v1/occupancy/ws
+// A WebSocket on which the client subscribes to auditoriums, buildings and
+// cities with forms.SubscriptionRequest messages and receives a
+// forms.OccupancyMessage for every reading stored for any of them. Every
+// control message is answered with a forms.SubscriptionReply. A client that
+// reads slower than readings arrive only gets the latest reading of each
+// auditorium; one that stops reading is disconnected. A scoped principal only
+// receives the readings of its cities and buildings, whatever it subscribes to.
+func (h *SubscriptionController) OccupancySocket(c *gin.Context) {
+	scope := scopeOf(c)
+	server := websocket.Server{
+		// Any origin is accepted: CORS does not apply to WebSocket, and the
+		// client authenticates with a token rather than cookies.
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler:   func(ws *websocket.Conn) { h.serveOccupancySocket(ws, scope) },
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+func (h *SubscriptionController) serveOccupancySocket(ws *websocket.Conn, scope auth.Scope) {
+	ws.MaxPayloadBytes = maxSocketMessageBytes
+	sub := h.Live.Connect()
+	defer h.Live.Unsubscribe(sub)
+	defer ws.Close()
+
+	conn := &occupancySocket{
+		ws:      ws,
+		hub:     h.Live,
+		sub:     sub,
+		topics:  make(map[OccupancyTopic]bool),
+		pending: make(map[uint]forms.OccupancyUpdate),
+		wake:    make(chan struct{}, 1),
+		replies: make(chan forms.SubscriptionReply, 16),
+		done:    make(chan struct{}),
+	}
+	defer close(conn.done)
+
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+		conn.read()
+	}()
+	pumpDone := make(chan struct{})
+	go func() {
+		defer close(pumpDone)
+		conn.pump()
+	}()
+
+	for {
+		select {
+		case <-readerDone:
+			return
+		case <-pumpDone:
+			// Dropped by the hub or shutting down.
+			return
+		case reply := <-conn.replies:
+			if err := conn.send(reply); err != nil {
+				return
+			}
+		case <-conn.wake:
+			for _, update := range conn.takePending() {
+				if !scope.AllowsBuilding(update.CityID, update.BuildingID) {
+					continue
+				}
+				msg := forms.OccupancyMessage{
+					Type:                        forms.MessageOccupancy,
+					CityID:                      update.CityID,
+					BuildingID:                  update.BuildingID,
+					AuditoriumOccupancyResponse: models.OccupancyUpdateResponse(update, time.Now(), maxFreshMinutes),
+				}
+				if err := conn.send(msg); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// occupancySocket is one socket connection. The reader goroutine applies
+// control messages, the pump goroutine moves hub events into pending, and
+// the connection goroutine is the only one writing to ws.
+type occupancySocket struct {
+	ws     *websocket.Conn
+	hub    *OccupancyHub
+	sub    *OccupancySubscription
+	topics map[OccupancyTopic]bool // owned by the reader
+
+	mu      sync.Mutex
+	pending map[uint]forms.OccupancyUpdate // latest unsent update per auditorium
+	order   []uint                         // auditoriums of pending in arrival order
+	wake    chan struct{}
+
+	replies chan forms.SubscriptionReply
+	done    chan struct{} // closed when the connection goroutine returns
+}
+
+// read applies control messages until the client goes away.
+func (s *occupancySocket) read() {
+	for {
+		var req forms.SubscriptionRequest
+		err := websocket.JSON.Receive(s.ws, &req)
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case err == nil:
+			err = req.Validate()
+		case errors.Is(err, websocket.ErrFrameTooLarge):
+			err = fmt.Errorf("message must not exceed %d bytes", maxSocketMessageBytes)
+		case errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+			err = fmt.Errorf("invalid JSON: %w", err)
+		default:
+			if !errors.Is(err, io.EOF) {
+				log.Printf("occupancy socket: %v", err)
+			}
+			return
+		}
+
+		var reply forms.SubscriptionReply
+		if err != nil {
+			reply = forms.SubscriptionReply{Type: forms.MessageError, Error: err.Error(), Subscriptions: len(s.topics)}
+		} else {
+			reply = s.apply(req)
+		}
+		select {
+		case s.replies <- reply:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// apply subscribes or unsubscribes; subscribing twice to the same topic is
+// not an error.
+func (s *occupancySocket) apply(req forms.SubscriptionRequest) forms.SubscriptionReply {
+	topic := OccupancyTopic{Scope: req.Scope, ID: req.ID}
+	reply := forms.SubscriptionReply{Action: req.Action, Scope: req.Scope, ID: req.ID}
+	switch req.Action {
+	case forms.ActionSubscribe:
+		if !s.topics[topic] && len(s.topics) >= maxSocketSubscriptions {
+			reply.Type = forms.MessageError
+			reply.Error = fmt.Sprintf("at most %d subscriptions per connection", maxSocketSubscriptions)
+			break
+		}
+		s.topics[topic] = true
+		s.hub.Follow(s.sub, topic)
+		reply.Type = forms.MessageSubscribed
+	case forms.ActionUnsubscribe:
+		delete(s.topics, topic)
+		s.hub.Unfollow(s.sub, topic)
+		reply.Type = forms.MessageUnsubscribed
+	}
+	reply.Subscriptions = len(s.topics)
+	return reply
+}
+
+// pump keeps the hub's channel drained so that a slow client is not dropped
+// by the hub; updates of an auditorium that was not sent yet are replaced.
+func (s *occupancySocket) pump() {
+	for event := range s.sub.C {
+		id := event.Update.AuditoriumID
+		s.mu.Lock()
+		if _, ok := s.pending[id]; !ok {
+			s.order = append(s.order, id)
+		}
+		s.pending[id] = event.Update
+		s.mu.Unlock()
+
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// takePending returns the unsent updates in arrival order and clears them.
+func (s *occupancySocket) takePending() []forms.OccupancyUpdate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	updates := make([]forms.OccupancyUpdate, len(s.order))
+	for i, id := range s.order {
+		updates[i] = s.pending[id]
+	}
+	s.pending = make(map[uint]forms.OccupancyUpdate)
+	s.order = nil
+	return updates
+}
+
+// send writes one JSON message, giving up after socketWriteTimeout.
+func (s *occupancySocket) send(v interface{}) error {
+	s.ws.SetWriteDeadline(time.Now().Add(socketWriteTimeout))
+	if err := websocket.JSON.Send(s.ws, v); err != nil {
+		log.Printf("occupancy socket: %v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"web_backend_v2/forms"
+	"web_backend_v2/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+// dialOccupancySocket serves OccupancySocket on a test server and connects to it.
+func dialOccupancySocket(t *testing.T) (*websocket.Conn, *OccupancyHub) {
+	t.Helper()
+	stores := NewMemoryStores(models.NewMemoryStore(models.FusionMax, models.DefaultFusionWindow))
+	router := gin.New()
+	router.GET("/v1/occupancy/ws", Authenticate(nil), (&SubscriptionController{Stores: stores}).OccupancySocket)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/v1/occupancy/ws", "", server.URL)
+	require.NoError(t, err)
+	t.Cleanup(func() { ws.Close() })
+	require.NoError(t, ws.SetReadDeadline(time.Now().Add(5*time.Second)))
+	return ws, stores.Live
+}
+
+func socketRequest(t *testing.T, ws *websocket.Conn, action, scope string, id uint) forms.SubscriptionReply {
+	t.Helper()
+	require.NoError(t, websocket.JSON.Send(ws, forms.SubscriptionRequest{Action: action, Scope: scope, ID: id}))
+	var reply forms.SubscriptionReply
+	require.NoError(t, websocket.JSON.Receive(ws, &reply))
+	return reply
+}
+
+func TestOccupancySocketSubscriptionLimit(t *testing.T) {
+	ws, _ := dialOccupancySocket(t)
+
+	for id := uint(1); id <= maxSocketSubscriptions; id++ {
+		reply := socketRequest(t, ws, forms.ActionSubscribe, forms.ScopeAuditorium, id)
+		require.Equal(t, forms.MessageSubscribed, reply.Type, reply.Error)
+		require.Equal(t, int(id), reply.Subscriptions)
+	}
+
+	reply := socketRequest(t, ws, forms.ActionSubscribe, forms.ScopeBuilding, 1)
+	assert.Equal(t, forms.SubscriptionReply{
+		Type: forms.MessageError, Action: forms.ActionSubscribe, Scope: forms.ScopeBuilding, ID: 1,
+		Subscriptions: maxSocketSubscriptions, Error: "at most 100 subscriptions per connection",
+	}, reply)
+
+	reply = socketRequest(t, ws, forms.ActionSubscribe, forms.ScopeAuditorium, 1)
+	assert.Equal(t, forms.MessageSubscribed, reply.Type, "subscribing again is not a new subscription")
+	assert.Equal(t, maxSocketSubscriptions, reply.Subscriptions)
+
+	reply = socketRequest(t, ws, forms.ActionUnsubscribe, forms.ScopeAuditorium, 1)
+	assert.Equal(t, forms.MessageUnsubscribed, reply.Type)
+	assert.Equal(t, maxSocketSubscriptions-1, reply.Subscriptions)
+	reply = socketRequest(t, ws, forms.ActionSubscribe, forms.ScopeBuilding, 1)
+	assert.Equal(t, forms.MessageSubscribed, reply.Type)
+	assert.Equal(t, maxSocketSubscriptions, reply.Subscriptions)
+
+	reply = socketRequest(t, ws, "watch", forms.ScopeCity, 1)
+	assert.Equal(t, forms.MessageError, reply.Type)
+	assert.Equal(t, maxSocketSubscriptions, reply.Subscriptions)
+}
+
+func TestOccupancySocketDelivers(t *testing.T) {
+	ws, hub := dialOccupancySocket(t)
+	socketRequest(t, ws, forms.ActionSubscribe, forms.ScopeBuilding, 2)
+
+	hub.Publish(forms.OccupancyUpdate{CityID: 1, BuildingID: 3, AuditoriumID: 30, PersonCount: 1})
+	hub.Publish(forms.OccupancyUpdate{CityID: 1, BuildingID: 2, AuditoriumID: 20, PersonCount: 7, Timestamp: time.Now()})
+	var msg forms.OccupancyMessage
+	require.NoError(t, websocket.JSON.Receive(ws, &msg))
+	assert.Equal(t, forms.MessageOccupancy, msg.Type)
+	assert.Equal(t, uint(1), msg.CityID)
+	assert.Equal(t, uint(2), msg.BuildingID)
+	assert.Equal(t, uint(20), msg.AuditoriumID)
+	assert.Equal(t, 7, msg.PersonCount)
+	assert.True(t, msg.IsFresh)
+}
+
+func TestOccupancySocketCoalesces(t *testing.T) {
+	hub := NewOccupancyHub()
+	sub := hub.Connect()
+	hub.Follow(sub, OccupancyTopic{forms.ScopeBuilding, 1})
+	s := &occupancySocket{hub: hub, sub: sub, pending: make(map[uint]forms.OccupancyUpdate), wake: make(chan struct{}, 1)}
+
+	// A slow client: nothing is taken from pending while the updates arrive.
+	for i, auditoriumID := range []uint{10, 11, 10, 12, 11, 10} {
+		hub.Publish(forms.OccupancyUpdate{CityID: 1, BuildingID: 1, AuditoriumID: auditoriumID, PersonCount: i})
+	}
+	hub.Unsubscribe(sub)
+	s.pump()
+
+	assert.Len(t, s.wake, 1, "woken once")
+	assert.Equal(t, []forms.OccupancyUpdate{
+		{CityID: 1, BuildingID: 1, AuditoriumID: 10, PersonCount: 5},
+		{CityID: 1, BuildingID: 1, AuditoriumID: 11, PersonCount: 4},
+		{CityID: 1, BuildingID: 1, AuditoriumID: 12, PersonCount: 3},
+	}, s.takePending(), "latest reading per auditorium, in order of first arrival")
+	assert.Empty(t, s.takePending())
+}
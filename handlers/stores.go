@@ -0,0 +1,44 @@
+package handlers
+
+import "web_backend_v2/models"
+
+// Stores is the data layer the controllers work with. Controllers embed it, so
+// the same router can be served from PostgreSQL or from models.MemoryStore.
+type Stores struct {
+	Cities      models.CityStore
+	Buildings   models.BuildingStore
+	Auditoriums models.AuditoriumStore
+	Cameras     models.CameraStore
+	Occupancy   models.OccupancyStore
+	Imports     models.ImportStore
+	Exports     models.ExportStore
+	Webhooks    models.WebhookStore
+	Alerts      models.AlertStore
+	// Live passes the readings stored through Occupancy on to the occupancy streams.
+	Live *OccupancyHub
+}
+
+// NewPostgresStores returns stores backed by the database opened with db.InitDB.
+func NewPostgresStores(occupancy *models.OccupancyModel) *Stores {
+	s := &Stores{
+		Cities:      new(models.CityModel),
+		Buildings:   new(models.BuildingModel),
+		Auditoriums: new(models.AuditoryModel),
+		Cameras:     new(models.CameraModel),
+		Occupancy:   occupancy,
+		Imports:     new(models.ImportModel),
+		Exports:     new(models.ExportModel),
+		Webhooks:    new(models.WebhookModel),
+		Alerts:      new(models.AlertModel),
+		Live:        NewOccupancyHub(),
+	}
+	occupancy.OnStored(s.Live.Publish)
+	return s
+}
+
+// NewMemoryStores returns stores that keep everything in m.
+func NewMemoryStores(m *models.MemoryStore) *Stores {
+	s := &Stores{Cities: m, Buildings: m, Auditoriums: m, Cameras: m, Occupancy: m, Imports: m, Exports: m, Webhooks: m, Alerts: m, Live: NewOccupancyHub()}
+	m.OnStored(s.Live.Publish)
+	return s
+}
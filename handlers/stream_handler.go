@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+	"web_backend_v2/forms"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// streamHeartbeat is how often an idle stream sends a comment, so that proxies
+// keep the connection open and clients notice a dead one.
+const streamHeartbeat = 15 * time.Second
+
+// StreamOccupancy handles GET /v1/cities/:city_id/buildings/:building_id/occupancy/stream
+// A server-sent event stream of the building's new occupancy readings. It opens
+// with a "snapshot" event holding the latest occupancy of every auditorium (as
+// GET .../auditories/occupancy at the current time), followed by one "occupancy"
+// event per stored reading. A client reconnecting with the Last-Event-ID header
+// (or ?last_event_id=) gets the readings it missed instead of a new snapshot,
+// as long as the server still has them.
+func (b *AuditoriumController) StreamOccupancy(c *gin.Context) {
+	building, ok := b.scopedBuilding(c)
+	if !ok {
+		return
+	}
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+
+	// Subscribe before reading the snapshot so no reading falls in between.
+	sub, replay, resumed := b.Live.Subscribe(building.ID, lastEventID)
+	defer b.Live.Unsubscribe(sub)
+
+	var snapshot []forms.AuditoriumOccupancyResponse
+	if !resumed {
+		var err error
+		snapshot, err = b.Auditoriums.GetLatestOccupancyByBuilding(building.ID, time.Now(), maxFreshMinutes)
+		if err != nil && err != gorm.ErrRecordNotFound {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if snapshot == nil {
+			snapshot = []forms.AuditoriumOccupancyResponse{}
+		}
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	if !resumed {
+		writeStreamEvent(c, "snapshot", sub.StartID, snapshot)
+	}
+	for _, event := range replay {
+		writeStreamEvent(c, "occupancy", event.ID, event.Update)
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-sub.C:
+			if !ok {
+				// Dropped for falling behind, or shutting down; the client
+				// reconnects with Last-Event-ID.
+				return
+			}
+			writeStreamEvent(c, "occupancy", event.ID, event.Update)
+		case <-heartbeat.C:
+			fmt.Fprint(c.Writer, ": heartbeat\n\n")
+		}
+		c.Writer.Flush()
+	}
+}
+
+// writeStreamEvent writes one server-sent event with a JSON payload.
+func writeStreamEvent(c *gin.Context, name, id string, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	fmt.Fprintf(c.Writer, "id: %s\nevent: %s\ndata: %s\n\n", id, name, payload)
+}
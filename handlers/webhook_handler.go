@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"web_backend_v2/forms"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// Defaults of WebhookRequest.ThresholdPercent and HysteresisPercent.
+	defaultWebhookThreshold  = 90
+	defaultWebhookHysteresis = 10
+	// webhookSecretBytes is the amount of randomness in a generated secret.
+	webhookSecretBytes = 32
+)
+
+// WebhookController manages the webhooks of a building and shows their
+// delivery log. Calls are made by webhook.Dispatcher.
+type WebhookController struct {
+	*Stores
+}
+
+// CreateWebhook handles POST /v1/cities/:city_id/buildings/:building_id/webhooks
+// The secret signing the calls is generated unless given and is returned only
+// in this response.
+func (h *WebhookController) CreateWebhook(c *gin.Context) {
+	building, ok := h.scopedBuilding(c)
+	if !ok {
+		return
+	}
+	var req forms.WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hook := forms.Webhook{
+		BuildingID:        building.ID,
+		AuditoriumID:      req.AuditoriumID,
+		URL:               req.URL,
+		Secret:            req.Secret,
+		Rule:              req.Rule,
+		ThresholdPercent:  defaultWebhookThreshold,
+		HysteresisPercent: defaultWebhookHysteresis,
+	}
+	if req.ThresholdPercent != nil {
+		hook.ThresholdPercent = *req.ThresholdPercent
+	}
+	if req.HysteresisPercent != nil {
+		hook.HysteresisPercent = *req.HysteresisPercent
+	}
+	if hook.Rule == forms.RuleAbove && hook.HysteresisPercent >= hook.ThresholdPercent {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "hysteresis_percent must be less than threshold_percent"})
+		return
+	}
+	if hook.AuditoriumID != nil {
+		if _, err := h.Auditoriums.GetAuditorium(building.ID, *hook.AuditoriumID); err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"error": "auditorium not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			return
+		}
+	}
+	if hook.Secret == "" {
+		raw := make([]byte, webhookSecretBytes)
+		if _, err := rand.Read(raw); err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate secret"})
+			return
+		}
+		hook.Secret = hex.EncodeToString(raw)
+	}
+
+	if err := h.Webhooks.CreateWebhook(&hook); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	resp := hook.ToWebhookResponse()
+	resp.Secret = hook.Secret
+	c.JSON(http.StatusCreated, resp)
+}
+
+// GetWebhooks handles GET /v1/cities/:city_id/buildings/:building_id/webhooks
+func (h *WebhookController) GetWebhooks(c *gin.Context) {
+	building, ok := h.scopedBuilding(c)
+	if !ok {
+		return
+	}
+	hooks, err := h.Webhooks.GetWebhooksByBuilding(building.ID)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	resp := make([]forms.WebhookResponse, 0, len(hooks))
+	for i := range hooks {
+		resp = append(resp, hooks[i].ToWebhookResponse())
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetWebhook handles GET /v1/cities/:city_id/buildings/:building_id/webhooks/:webhook_id
+func (h *WebhookController) GetWebhook(c *gin.Context) {
+	hook, ok := h.scopedWebhook(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, hook.ToWebhookResponse())
+}
+
+// DeleteWebhook handles DELETE /v1/cities/:city_id/buildings/:building_id/webhooks/:webhook_id
+// Pending deliveries are dropped with the delivery log.
+func (h *WebhookController) DeleteWebhook(c *gin.Context) {
+	hook, ok := h.scopedWebhook(c)
+	if !ok {
+		return
+	}
+	if err := h.Webhooks.DeleteWebhook(hook.BuildingID, hook.ID); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "webhook not found"})
+		} else {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetWebhookDeliveries handles GET /v1/cities/:city_id/buildings/:building_id/webhooks/:webhook_id/deliveries
+// The latest deliveries first (?limit=, default 100), optionally only those
+// with ?status=pending|delivered|failed.
+func (h *WebhookController) GetWebhookDeliveries(c *gin.Context) {
+	hook, ok := h.scopedWebhook(c)
+	if !ok {
+		return
+	}
+	var q forms.WebhookDeliveryQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be pending, delivered or failed, limit must be between 1 and 1000"})
+		return
+	}
+	if q.Limit == 0 {
+		q.Limit = 100
+	}
+
+	deliveries, err := h.Webhooks.GetWebhookDeliveries(hook.ID, q.Status, q.Limit)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	resp := make([]forms.WebhookDeliveryResponse, 0, len(deliveries))
+	for i := range deliveries {
+		resp = append(resp, deliveries[i].ToWebhookDeliveryResponse())
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// scopedWebhook resolves :webhook_id within the building, writing the error response itself.
+func (h *WebhookController) scopedWebhook(c *gin.Context) (*forms.Webhook, bool) {
+	building, ok := h.scopedBuilding(c)
+	if !ok {
+		return nil, false
+	}
+	webhookID, err := parseUintParam(c, "webhook_id")
+	if err != nil {
+		return nil, false
+	}
+	hook, err := h.Webhooks.GetWebhook(building.ID, webhookID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "webhook not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return nil, false
+	}
+	return hook, true
+}
@@ -10,10 +10,15 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	"web_backend_v2/alerting"
+	"web_backend_v2/auth"
 	"web_backend_v2/config"
 	"web_backend_v2/db"
+	"web_backend_v2/events"
 	"web_backend_v2/handlers"
+	"web_backend_v2/models"
 	"web_backend_v2/rabbit"
+	"web_backend_v2/webhook"
 
 	"github.com/gin-gonic/gin"
 )
@@ -25,11 +30,28 @@ func main() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(cfg, os.Args[2:]); err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
+	}
+
 	// Set Gin mode
 	gin.SetMode(cfg.GinMode)
 
 	log.Println("Starting camera event processor service...")
 
+	// Load API keys and JWT keys before anything else starts
+	var authenticator *auth.Authenticator
+	if cfg.Auth.Enabled {
+		if authenticator, err = auth.New(cfg.Auth); err != nil {
+			log.Fatalf("Failed to set up authentication: %v", err)
+		}
+	} else {
+		log.Println("WARNING: authentication is disabled, every request is treated as facility-admin")
+	}
+
 	// Initialize database connection
 	if err := db.InitDB(cfg, false); err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
@@ -42,35 +64,72 @@ func main() {
 		}
 	}()
 
-	// Initialize RabbitMQ connection and start consumer
-	if err := rabbit.InitRabbitMQ(cfg); err != nil {
-		log.Fatalf("Failed to initialize RabbitMQ: %v", err)
-	}
-	rabbitCtx, rabbitCancel := context.WithCancel(context.Background())
+	stores := handlers.NewPostgresStores(&models.OccupancyModel{
+		FusionStrategy: cfg.Fusion.Strategy,
+		FusionWindow:   cfg.Fusion.Window,
+	})
+
+	// Evaluate webhooks on stored readings and send their calls
+	dispatcher := webhook.NewDispatcher(stores.Webhooks, cfg.Webhook)
+	stores.Occupancy.OnStored(dispatcher.Notify)
+	webhookCtx, webhookCancel := context.WithCancel(context.Background())
+	webhookDone := make(chan struct{})
+	go func() {
+		defer close(webhookDone)
+		dispatcher.Run(webhookCtx)
+	}()
+	defer func() {
+		webhookCancel()
+		<-webhookDone
+		log.Println("Webhook dispatcher stopped")
+	}()
+
+	// Evaluate alert rules in the background
+	alertCtx, alertCancel := context.WithCancel(context.Background())
+	alertDone := make(chan struct{})
+	go func() {
+		defer close(alertDone)
+		alerting.NewEvaluator(stores.Alerts, cfg.Alert).Run(alertCtx)
+	}()
+	defer func() {
+		alertCancel()
+		<-alertDone
+		log.Println("Alert evaluator stopped")
+	}()
+
+	// Start consuming camera events; the RabbitMQ consumer (re)connects in the background
+	source := newEventSource(cfg)
+	sourceCtx, sourceCancel := context.WithCancel(context.Background())
 	consumerErrCh := make(chan error, 1)
 	go func() {
-		consumerErrCh <- rabbit.StartConsumer(rabbitCtx, cfg.QueueName, handlers.ProcessCameraEvent)
+		if cfg.Ingest.Mode == config.IngestModeBatch {
+			consumerErrCh <- source.RunBatch(sourceCtx, stores.ProcessCameraEvents)
+			return
+		}
+		consumerErrCh <- source.Run(sourceCtx, stores.ProcessCameraEvent)
 	}()
 	defer func() {
-		rabbitCancel()
+		sourceCancel()
 		if err := <-consumerErrCh; err != nil && !errors.Is(err, context.Canceled) {
-			log.Printf("RabbitMQ consumer stopped with error: %v", err)
+			log.Printf("Event consumer stopped with error: %v", err)
 		}
-		if err := rabbit.CloseRabbitMQ(); err != nil {
-			log.Printf("Error closing RabbitMQ: %v", err)
+		if err := source.Close(); err != nil {
+			log.Printf("Error closing event source %s: %v", cfg.EventSource, err)
 		} else {
-			log.Println("RabbitMQ connection closed")
+			log.Printf("Event source %s closed", cfg.EventSource)
 		}
 	}()
 
 	// Setup HTTP router and API endpoints
-	router := setupRouter()
+	router := setupRouter(source, stores, authenticator, cfg.CORSOrigins)
 
 	// Create HTTP server
 	server := &http.Server{
 		Addr:    fmt.Sprintf(":%s", cfg.ServerPort),
 		Handler: router,
 	}
+	// Occupancy streams never end on their own; close them when shutdown starts
+	server.RegisterOnShutdown(stores.Live.Close)
 
 	// Start HTTP server in a goroutine
 	go func() {
@@ -108,56 +167,140 @@ func main() {
 	}
 }
 
-// setupRouter configures all HTTP routes
-func setupRouter() *gin.Engine {
-	router := gin.Default()
+// newEventSource creates the event source selected by EVENT_SOURCE.
+func newEventSource(cfg *config.Config) events.EventSource {
+	if cfg.EventSource == events.KindMemory {
+		log.Println("Using in-memory event source; events are lost on restart")
+		return events.NewMemoryBroker(cfg.MemoryQueueSize, cfg.RabbitMQ.Workers, cfg.Ingest.BatchSize, cfg.Ingest.BatchWindow)
+	}
+	return rabbit.NewConsumer(cfg)
+}
+
+// setupRouter registers the API. Viewers may read, facility admins may also
+// write and use /v1/admin and webhooks, devices may post events; a nil
+// authenticator disables authentication.
+func setupRouter(source events.EventSource, stores *handlers.Stores, authenticator *auth.Authenticator, corsOrigins []string) *gin.Engine {
+	// gin.Default() without its logger, which would write ?access_token= to the log
+	router := gin.New()
+	router.Use(handlers.RequestLogger(), gin.Recovery())
 
 	// Allow cross-origin requests (useful for remote frontend testing).
-	router.Use(handlers.CORSMiddleware())
+	router.Use(handlers.CORSMiddleware(corsOrigins))
+	authenticate := handlers.Authenticate(authenticator)
+	readOrAdmin := handlers.Authorize(handlers.ReadRoles, handlers.AdminRoles)
+	adminOnly := handlers.RequireRole(handlers.AdminRoles...)
+	// Principals scoped to some cities or buildings stay within them
+	inScope := handlers.RequireScope(stores)
 
 	// API v1 routes
 	v1 := router.Group("/v1")
 	{
 		// Cities endpoints
-		cities := v1.Group("/cities")
+		cities := v1.Group("/cities", authenticate, readOrAdmin, inScope)
 		{
-			city := new(handlers.CityController)
+			city := &handlers.CityController{Stores: stores}
 			cities.GET("/", city.GetCities)
+			cities.POST("/", city.CreateCity)
+			cities.PUT("/:city_id", city.UpdateCity)
+			cities.PATCH("/:city_id", city.PatchCity)
+			cities.DELETE("/:city_id", city.DeleteCity)
 			// Buildings endpoints
-			building := new(handlers.BuildingController)
+			building := &handlers.BuildingController{Stores: stores}
 			cities.GET("/:city_id/buildings", building.GetBuildingsByCity)
-			auditorium := new(handlers.AuditoriumController)
+			cities.POST("/:city_id/buildings", building.CreateBuilding)
+			cities.PUT("/:city_id/buildings/:building_id", building.UpdateBuilding)
+			cities.PATCH("/:city_id/buildings/:building_id", building.PatchBuilding)
+			cities.DELETE("/:city_id/buildings/:building_id", building.DeleteBuilding)
+			auditorium := &handlers.AuditoriumController{Stores: stores}
 			cities.GET("/:city_id/buildings/:building_id/auditories", auditorium.GetAuditoriumsByBuilding)
+			cities.POST("/:city_id/buildings/:building_id/auditories", auditorium.CreateAuditorium)
+			cities.PUT("/:city_id/buildings/:building_id/auditories/:auditorium_id", auditorium.UpdateAuditorium)
+			cities.PATCH("/:city_id/buildings/:building_id/auditories/:auditorium_id", auditorium.PatchAuditorium)
+			cities.DELETE("/:city_id/buildings/:building_id/auditories/:auditorium_id", auditorium.DeleteAuditorium)
 			cities.GET("/:city_id/buildings/:building_id/auditories/occupancy", auditorium.GetOccupancyByBuilding)
 			cities.GET("/:city_id/buildings/:building_id/auditories/:auditorium_id/occupancy", auditorium.GetOccupancyByAuditorium)
+			cities.GET("/:city_id/buildings/:building_id/auditories/:auditorium_id/occupancy/series", auditorium.GetOccupancySeries)
 			cities.GET("/:city_id/buildings/:building_id/auditories/:auditorium_id/statistics", auditorium.GetStatisticsByAuditorium)
-			camera := new(handlers.CameraController)
+			cities.GET("/:city_id/free-auditoriums", auditorium.GetFreeAuditoriums)
+			cities.GET("/:city_id/occupancy/summary", auditorium.GetCityOccupancySummary)
+			cities.GET("/:city_id/buildings/:building_id/occupancy/summary", auditorium.GetBuildingOccupancySummary)
+			cities.GET("/:city_id/buildings/:building_id/occupancy/stream", auditorium.StreamOccupancy)
+			camera := &handlers.CameraController{Stores: stores}
 			cities.GET("/:city_id/buildings/:building_id/auditories/:auditorium_id/cameras", camera.GetCamerasByAuditorium)
 			cities.POST("/:city_id/buildings/:building_id/auditories/:auditorium_id/cameras", camera.AttachCamera)
+			imports := &handlers.ImportController{Stores: stores}
+			cities.POST("/:city_id/import/buildings", imports.ImportBuildings)
+			cities.POST("/:city_id/import/auditoriums", imports.ImportAuditoriums)
+			exports := &handlers.ExportController{Stores: stores}
+			cities.GET("/:city_id/buildings/:building_id/auditories/occupancy/export", exports.ExportBuildingOccupancy)
+			cities.GET("/:city_id/buildings/:building_id/auditories/:auditorium_id/statistics/export", exports.ExportAuditoriumStatistics)
+			cities.GET("/:city_id/buildings/:building_id/occupancy/export", exports.ExportOccupancy)
+			// Webhooks are managed by facility admins only
+			webhooks := &handlers.WebhookController{Stores: stores}
+			hooks := cities.Group("/:city_id/buildings/:building_id/webhooks", adminOnly)
+			hooks.POST("", webhooks.CreateWebhook)
+			hooks.GET("", webhooks.GetWebhooks)
+			hooks.GET("/:webhook_id", webhooks.GetWebhook)
+			hooks.DELETE("/:webhook_id", webhooks.DeleteWebhook)
+			hooks.GET("/:webhook_id/deliveries", webhooks.GetWebhookDeliveries)
 
 		}
-		// Cameras endpoints 
-		cameras := v1.Group("/cameras")
+		// Occupancy WebSocket
+		subscriptions := &handlers.SubscriptionController{Stores: stores}
+		v1.GET("/occupancy/ws", authenticate, readOrAdmin, subscriptions.OccupancySocket)
+		// Alerts endpoints
+		alerts := v1.Group("/alerts", authenticate, readOrAdmin)
 		{
-			camera := new(handlers.CameraController)
-			cameras.GET("/", camera.GetFreeCameras)
+			alert := &handlers.AlertController{Stores: stores}
+			alerts.GET("", alert.GetAlerts)
+			alerts.GET("/:alert_id", alert.GetAlert)
+			alerts.POST("/:alert_id/ack", alert.AcknowledgeAlert)
+		}
+		// Cameras endpoints
+		cameras := v1.Group("/cameras", authenticate, readOrAdmin, handlers.RequireCameraScope(stores))
+		{
+			camera := &handlers.CameraController{Stores: stores}
+			cameras.GET("/", camera.GetCameras)
 			cameras.GET("/attached", camera.GetAttachedCameras)
 			cameras.GET("/:camera_id", camera.GetCamera)
+			cameras.GET("/:camera_id/health", camera.GetCameraHealth)
 			cameras.POST("/", camera.CreateCamera)
 			cameras.DELETE("/:camera_id", camera.DeleteCamera)
 			cameras.DELETE("/:camera_id/attachment", camera.DetachCamera)
+			cameras.POST("/:camera_id/token", camera.IssueCameraToken)
+			cameras.DELETE("/:camera_id/token", camera.RevokeCameraToken)
+		}
+		// Camera events over HTTP, for devices without AMQP
+		event := &handlers.EventController{Stores: stores}
+		v1.POST("/events", handlers.DeviceAuth(authenticator, stores.Cameras), event.PostEvents)
+		// Admin endpoints
+		admin := v1.Group("/admin", authenticate, adminOnly, handlers.RequireUnscoped())
+		{
+			deadLetter := &handlers.DeadLetterController{Source: source, Stores: stores}
+			admin.GET("/dead-letters", deadLetter.ListDeadLetters)
+			admin.POST("/dead-letters/replay", deadLetter.ReplayDeadLetters)
+			admin.GET("/dead-letters/:message_id", deadLetter.GetDeadLetter)
+			admin.POST("/dead-letters/:message_id/replay", deadLetter.ReplayDeadLetter)
+			admin.DELETE("/dead-letters/:message_id", deadLetter.DiscardDeadLetter)
+			ingestion := &handlers.IngestionController{Source: source}
+			admin.GET("/ingestion/stats", ingestion.GetIngestionStats)
+			admin.POST("/ingestion/publish", ingestion.PublishEvent)
 		}
-		// Cameras endpoints (global)
-
-
-		// // Auditoriums endpoints
-		// v1.GET("/buildings/:building_id/auditoriums", handlers.GetAuditoriumsByBuilding)
 	}
 
-	// Health check endpoint
+	// Health check endpoint; degraded (503) while the event source is not consuming
 	router.GET("/health", func(c *gin.Context) {
+		sourceStatus := source.Status()
+		if sourceStatus.State != events.StateConnected {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"status":       "degraded",
+				"event_source": sourceStatus,
+			})
+			return
+		}
 		c.JSON(http.StatusOK, gin.H{
-			"status": "ok",
+			"status":       "ok",
+			"event_source": sourceStatus,
 		})
 	})
 
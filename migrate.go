@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+	"web_backend_v2/config"
+	"web_backend_v2/db"
+)
+
+const migrateUsage = "usage: web_backend_v2 migrate up | down [N] | status"
+
+// runMigrate handles the migrate subcommand. The server applies pending
+// migrations on start as well; the subcommand is for rollbacks and inspection.
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	if err := db.InitDB(cfg, true); err != nil {
+		return fmt.Errorf("init db: %w", err)
+	}
+	defer db.CloseDB()
+
+	sqlDB, err := db.GetDB().DB()
+	if err != nil {
+		return fmt.Errorf("get sql.DB: %w", err)
+	}
+	migrator := db.NewMigrator(sqlDB, db.MigrationsDir)
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("applied %d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+		return nil
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of migrations to roll back %q; %s", args[1], migrateUsage)
+			}
+		}
+		rolledBack, err := migrator.Down(ctx, steps)
+		for _, m := range rolledBack {
+			fmt.Printf("rolled back %d_%s\n", m.Version, m.Name)
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, s := range statuses {
+			state, appliedAt := "pending", ""
+			if s.Applied {
+				state = "applied"
+				appliedAt = s.AppliedAt.UTC().Format(time.RFC3339)
+			}
+			if s.Modified {
+				state += " (modified)"
+			}
+			if s.Missing {
+				state += " (file missing)"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q; %s", args[0], migrateUsage)
+	}
+}
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS CamerasInAuditorium;
+DROP TABLE IF EXISTS Camera;
+DROP TABLE IF EXISTS DailyLoad;
+DROP TABLE IF EXISTS Occupancy;
+DROP TABLE IF EXISTS Auditorium;
+DROP TABLE IF EXISTS Building;
+DROP TABLE IF EXISTS City;
+DROP TYPE IF EXISTS auditorium_type_enum;
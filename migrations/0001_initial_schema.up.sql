@@ -0,0 +1,85 @@
+-- Initial schema: the tables created by the original migration.sql.
+-- IF NOT EXISTS keeps it safe on databases created before versioned migrations.
+
+-- Create enum type for auditorium type (English values only)
+DO $$ 
+BEGIN
+    CREATE TYPE auditorium_type_enum AS ENUM ('coworking', 'classroom', 'lecture_hall');
+EXCEPTION
+    WHEN duplicate_object THEN null;
+END $$;
+
+-- Create City table
+-- Localized fields: name_ru, name_en (no base 'name' column)
+CREATE TABLE IF NOT EXISTS City (
+    id SERIAL PRIMARY KEY,
+    name_ru VARCHAR(255) NOT NULL,
+    name_en VARCHAR(255) NOT NULL
+);
+
+
+-- Create Building table
+-- Localized fields: address_ru, address_en (no base 'address' column)
+CREATE TABLE IF NOT EXISTS Building (
+    id SERIAL PRIMARY KEY,
+    city_id INTEGER NOT NULL,
+    address_ru VARCHAR(255) NOT NULL,
+    address_en VARCHAR(255) NOT NULL,
+    floor_count INTEGER NOT NULL,
+    CONSTRAINT fk_building_city FOREIGN KEY (city_id) REFERENCES City(id) ON DELETE CASCADE
+);
+
+-- Create Auditorium table
+-- Type field: enum 'type' column plus localized type_ru and type_en columns
+CREATE TABLE IF NOT EXISTS Auditorium (
+    id SERIAL PRIMARY KEY,
+    building_id INTEGER NOT NULL,
+    floor_number INTEGER NOT NULL,
+    capacity INTEGER NOT NULL,
+    auditorium_number VARCHAR(50) NOT NULL,
+    type auditorium_type_enum NOT NULL,
+    type_ru VARCHAR(50) NOT NULL,
+    image_url VARCHAR(500),
+    CONSTRAINT fk_auditorium_building FOREIGN KEY (building_id) REFERENCES Building(id) ON DELETE CASCADE
+);
+
+-- Create Occupancy table
+CREATE TABLE IF NOT EXISTS Occupancy (
+    id SERIAL PRIMARY KEY,
+    auditorium_id SERIAL NOT NULL,
+    person_count INTEGER NOT NULL,
+    timestamp TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    CONSTRAINT fk_occupancy_auditorium FOREIGN KEY (auditorium_id) REFERENCES Auditorium(id) ON DELETE CASCADE,
+    CONSTRAINT chk_occupancy_person_count_nonnegative CHECK (person_count >= 0)
+);
+
+-- DailyLoad table stores per-day, per-hour average occupancy per auditorium.
+CREATE TABLE IF NOT EXISTS DailyLoad (
+    id SERIAL PRIMARY KEY,
+    auditorium_id INTEGER NOT NULL,
+    day DATE NOT NULL,
+    hour INTEGER NOT NULL CHECK (hour >= 0 AND hour <= 23),
+    avg_person_count DOUBLE PRECISION NOT NULL CHECK (avg_person_count >= 0),
+    CONSTRAINT fk_dailyload_auditorium FOREIGN KEY (auditorium_id) REFERENCES Auditorium(id) ON DELETE CASCADE,
+    CONSTRAINT uq_dailyload_unique UNIQUE (auditorium_id, day, hour)
+);
+
+CREATE TABLE IF NOT EXISTS Camera (
+    id SERIAL  PRIMARY KEY ,
+    mac CHAR(17) NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS CamerasInAuditorium (
+    camera_id SERIAL,
+    auditorium_id SERIAL NOT NULL,
+    UNIQUE(camera_id),
+    CONSTRAINT fk_cameras_in_auditorium_camera FOREIGN KEY (camera_id) REFERENCES Camera(id) ON DELETE CASCADE,
+    CONSTRAINT fk_cameras_in_auditorium_auditorium FOREIGN KEY (auditorium_id) REFERENCES Auditorium(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_building_city_id ON Building(city_id);
+CREATE INDEX IF NOT EXISTS idx_auditorium_building_id ON Auditorium(building_id);
+CREATE INDEX IF NOT EXISTS idx_auditorium_number ON Auditorium(auditorium_number);
+CREATE INDEX IF NOT EXISTS idx_occupancy_auditorium_id ON Occupancy(auditorium_id);
+CREATE INDEX IF NOT EXISTS idx_occupancy_auditorium_ts_desc ON Occupancy(auditorium_id, timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_cameras_in_auditorium_auditorium_id ON CamerasInAuditorium(auditorium_id);
@@ -0,0 +1,4 @@
+ALTER TABLE Occupancy DROP COLUMN IF EXISTS contributions;
+ALTER TABLE Occupancy DROP COLUMN IF EXISTS fusion_strategy;
+ALTER TABLE Auditorium DROP COLUMN IF EXISTS fusion_strategy;
+DROP TABLE IF EXISTS CameraReading;
@@ -0,0 +1,14 @@
+-- Latest reading per camera; used to fuse several cameras covering one auditorium.
+CREATE TABLE IF NOT EXISTS CameraReading (
+    camera_id INTEGER PRIMARY KEY,
+    person_count INTEGER NOT NULL CHECK (person_count >= 0),
+    timestamp TIMESTAMP WITH TIME ZONE NOT NULL,
+    CONSTRAINT fk_camera_reading_camera FOREIGN KEY (camera_id) REFERENCES Camera(id) ON DELETE CASCADE
+);
+
+-- Fusion settings: per-auditorium strategy override (NULL = service default),
+-- strategy used and per-camera contributions of every stored occupancy row.
+ALTER TABLE Auditorium ADD COLUMN IF NOT EXISTS fusion_strategy VARCHAR(16)
+    CHECK (fusion_strategy IN ('max', 'sum', 'median'));
+ALTER TABLE Occupancy ADD COLUMN IF NOT EXISTS fusion_strategy VARCHAR(16);
+ALTER TABLE Occupancy ADD COLUMN IF NOT EXISTS contributions JSONB;
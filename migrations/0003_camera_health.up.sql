@@ -0,0 +1,11 @@
+-- Heartbeat of every camera that sends events (attached or not).
+-- avg_interval_seconds is an exponential moving average of the time between events.
+CREATE TABLE IF NOT EXISTS CameraHealth (
+    camera_id INTEGER PRIMARY KEY,
+    last_seen_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_event_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_person_count INTEGER NOT NULL,
+    events_total BIGINT NOT NULL DEFAULT 0,
+    avg_interval_seconds DOUBLE PRECISION,
+    CONSTRAINT fk_camera_health_camera FOREIGN KEY (camera_id) REFERENCES Camera(id) ON DELETE CASCADE
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS ux_occupancy_event_key;
+ALTER TABLE Occupancy DROP COLUMN IF EXISTS event_key;
@@ -0,0 +1,4 @@
+-- Deduplication key of the camera event a row was stored from (NULL for legacy rows);
+-- a redelivered or retransmitted event cannot create a second row.
+ALTER TABLE Occupancy ADD COLUMN IF NOT EXISTS event_key VARCHAR(160);
+CREATE UNIQUE INDEX IF NOT EXISTS ux_occupancy_event_key ON Occupancy(event_key);
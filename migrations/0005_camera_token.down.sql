@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS ux_camera_token_hash;
+ALTER TABLE Camera DROP COLUMN IF EXISTS token_hash;
@@ -0,0 +1,3 @@
+-- SHA-256 of the camera's token for HTTP ingestion (NULL = HTTP ingestion disabled).
+ALTER TABLE Camera ADD COLUMN IF NOT EXISTS token_hash VARCHAR(64);
+CREATE UNIQUE INDEX IF NOT EXISTS ux_camera_token_hash ON Camera(token_hash);
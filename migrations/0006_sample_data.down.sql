@@ -0,0 +1,2 @@
+-- Nothing to undo; rows loaded with cmd/seed are never removed by migrations.
+SELECT 1;
@@ -0,0 +1,3 @@
+-- Sample data is not part of the schema; it is loaded with cmd/seed from
+-- fixtures/campus.yaml and fixtures/demo.yaml. Kept so that versions do not shift.
+SELECT 1;
@@ -0,0 +1,2 @@
+-- Removed demo rows are not restored; load them with: go run ./cmd/seed -file fixtures/demo.yaml
+SELECT 1;
@@ -0,0 +1,19 @@
+-- Demo data now lives in fixtures/demo.yaml (see cmd/seed). Databases created
+-- from the old migration.sql still have its fake cameras and placeholder
+-- auditoriums; remove exactly those rows (matched by id and natural key, so
+-- nothing created through the API is touched). Cities and buildings are the
+-- real campus (fixtures/campus.yaml) and stay.
+DELETE FROM camera
+WHERE (id, mac) IN (
+  (1, 'AA:BB:CC:DD:EE:01'),
+  (2, 'AA:BB:CC:DD:EE:02'),
+  (3, 'AA:BB:CC:DD:EE:03'),
+  (4, 'AA:BB:CC:DD:EE:04')
+);
+DELETE FROM auditorium
+WHERE (id, building_id, auditorium_number) IN (
+  (1, 1, '506'),
+  (2, 1, 'Актовый зал'),
+  (3, 1, '306'),
+  (4, 1, '308')
+);
@@ -0,0 +1,50 @@
+-- Webhook subscriptions: a rule evaluated on every stored reading of the
+-- building's auditoriums (or of one auditorium when auditorium_id is set).
+-- rule 'above' triggers at occupancy >= threshold_percent of capacity and clears
+-- below threshold_percent - hysteresis_percent; rule 'empty' triggers at 0 people
+-- and clears above hysteresis_percent of capacity (at least one person).
+CREATE TABLE IF NOT EXISTS Webhook (
+    id SERIAL PRIMARY KEY,
+    building_id INTEGER NOT NULL,
+    auditorium_id INTEGER,
+    url VARCHAR(2000) NOT NULL,
+    secret VARCHAR(255) NOT NULL,
+    rule VARCHAR(16) NOT NULL CHECK (rule IN ('above', 'empty')),
+    threshold_percent DOUBLE PRECISION NOT NULL DEFAULT 90,
+    hysteresis_percent DOUBLE PRECISION NOT NULL DEFAULT 10,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    CONSTRAINT fk_webhook_building FOREIGN KEY (building_id) REFERENCES Building(id) ON DELETE CASCADE,
+    CONSTRAINT fk_webhook_auditorium FOREIGN KEY (auditorium_id) REFERENCES Auditorium(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS ix_webhook_building ON Webhook(building_id);
+
+-- Whether a webhook's rule is currently triggered for an auditorium, as of the
+-- reading at reading_at; older readings arriving late do not change it.
+CREATE TABLE IF NOT EXISTS WebhookState (
+    webhook_id INTEGER NOT NULL,
+    auditorium_id INTEGER NOT NULL,
+    active BOOLEAN NOT NULL,
+    reading_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (webhook_id, auditorium_id),
+    CONSTRAINT fk_webhook_state_webhook FOREIGN KEY (webhook_id) REFERENCES Webhook(id) ON DELETE CASCADE,
+    CONSTRAINT fk_webhook_state_auditorium FOREIGN KEY (auditorium_id) REFERENCES Auditorium(id) ON DELETE CASCADE
+);
+
+-- Outbox and log of webhook calls. Pending rows are sent when next_attempt_at
+-- is due and retried with backoff until delivered or out of attempts (failed).
+CREATE TABLE IF NOT EXISTS WebhookDelivery (
+    id BIGSERIAL PRIMARY KEY,
+    webhook_id INTEGER NOT NULL,
+    event VARCHAR(32) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    last_status_code INTEGER,
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    delivered_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT fk_webhook_delivery_webhook FOREIGN KEY (webhook_id) REFERENCES Webhook(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS ix_webhook_delivery_due ON WebhookDelivery(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS ix_webhook_delivery_webhook ON WebhookDelivery(webhook_id, id);
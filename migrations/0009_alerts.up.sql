@@ -0,0 +1,23 @@
+-- Alerts raised by the background evaluator: 'stale' for an attached camera
+-- (camera_id set) without events for too long, 'over_capacity' and 'spike' for
+-- an auditorium. An alert is firing until its condition is gone and then stays
+-- resolved; when the condition comes back a new alert is raised.
+CREATE TABLE IF NOT EXISTS Alert (
+    id BIGSERIAL PRIMARY KEY,
+    kind VARCHAR(16) NOT NULL CHECK (kind IN ('stale', 'over_capacity', 'spike')),
+    auditorium_id INTEGER NOT NULL,
+    camera_id INTEGER,
+    status VARCHAR(16) NOT NULL DEFAULT 'firing' CHECK (status IN ('firing', 'resolved')),
+    message TEXT NOT NULL,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    resolved_at TIMESTAMP WITH TIME ZONE,
+    acknowledged_at TIMESTAMP WITH TIME ZONE,
+    ack_note TEXT,
+    CONSTRAINT fk_alert_auditorium FOREIGN KEY (auditorium_id) REFERENCES Auditorium(id) ON DELETE CASCADE,
+    CONSTRAINT fk_alert_camera FOREIGN KEY (camera_id) REFERENCES Camera(id) ON DELETE CASCADE
+);
+-- At most one firing alert per condition.
+CREATE UNIQUE INDEX IF NOT EXISTS ux_alert_firing ON Alert(kind, auditorium_id, COALESCE(camera_id, 0)) WHERE status = 'firing';
+CREATE INDEX IF NOT EXISTS ix_alert_started ON Alert(started_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS ix_alert_auditorium ON Alert(auditorium_id);
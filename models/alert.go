@@ -0,0 +1,326 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+	"web_backend_v2/db"
+	"web_backend_v2/forms"
+
+	"gorm.io/gorm"
+)
+
+// alertLockKey is the advisory lock taken by EvaluateAlerts, so that replicas
+// evaluate one after another.
+const alertLockKey = 0x616c657274
+
+// AlertRules decides when the alert evaluator raises alerts.
+type AlertRules struct {
+	// StaleAfter is the silence after which an attached camera is stale; also
+	// the max age of a reading checked for over-capacity.
+	StaleAfter time.Duration
+	// SpikePercent is the jump between consecutive readings, in % of capacity,
+	// that counts as a spike.
+	SpikePercent float64
+	// SpikeWindow is how long a jump keeps its spike alert firing.
+	SpikeWindow time.Duration
+}
+
+// AlertModel evaluates alert rules and stores the alerts.
+type AlertModel struct{}
+
+// alertRow is an alert joined with the building and city of its auditorium.
+type alertRow struct {
+	forms.Alert `gorm:"embedded"`
+	BuildingID  uint
+	CityID      uint
+}
+
+// cameraSilence is an attached camera with the time of its latest event.
+type cameraSilence struct {
+	CameraID     uint
+	AuditoriumID uint
+	LastEventAt  *time.Time
+}
+
+// auditoriumLoad is the latest fresh reading of an auditorium.
+type auditoriumLoad struct {
+	AuditoriumID uint
+	Capacity     int
+	PersonCount  int
+	Timestamp    time.Time
+}
+
+// auditoriumJump is the largest change between consecutive readings of an
+// auditorium within the spike window.
+type auditoriumJump struct {
+	AuditoriumID uint
+	Capacity     int
+	PrevCount    int
+	PersonCount  int
+	Timestamp    time.Time
+}
+
+// alertKey identifies the condition of an alert; CameraID is 0 for auditorium alerts.
+type alertKey struct {
+	Kind         string
+	AuditoriumID uint
+	CameraID     uint
+}
+
+type alertCondition struct {
+	alertKey
+	Message string
+}
+
+// alertChanges is what an evaluation does to the stored alerts.
+type alertChanges struct {
+	raised   []forms.Alert
+	updated  []forms.Alert // still firing with a new message
+	resolved []uint64
+}
+
+// EvaluateAlerts checks every attached camera and every auditorium against the
+// rules at now: raises an alert for each new condition, updates the message of
+// those still firing and resolves those whose condition is gone. It returns the
+// numbers of raised and resolved alerts.
+func (a *AlertModel) EvaluateAlerts(now time.Time, rules AlertRules) (int, int, error) {
+	var changes alertChanges
+	err := db.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", alertLockKey).Error; err != nil {
+			return fmt.Errorf("failed to lock alerts: %w", err)
+		}
+
+		var silences []cameraSilence
+		if err := tx.Raw(`
+			SELECT cia.camera_id, cia.auditorium_id, h.last_event_at
+			FROM camerasinauditorium cia
+			LEFT JOIN camerahealth h ON h.camera_id = cia.camera_id
+			ORDER BY cia.camera_id`).Scan(&silences).Error; err != nil {
+			return fmt.Errorf("failed to fetch camera activity: %w", err)
+		}
+		var loads []auditoriumLoad
+		if err := tx.Raw(`
+			SELECT a.id AS auditorium_id, a.capacity, o.person_count, o.timestamp
+			FROM auditorium a
+			JOIN LATERAL (
+				SELECT person_count, timestamp FROM occupancy
+				WHERE auditorium_id = a.id AND timestamp <= ?
+				ORDER BY timestamp DESC LIMIT 1
+			) o ON true
+			WHERE o.timestamp > ?
+			ORDER BY a.id`, now, now.Add(-rules.StaleAfter)).Scan(&loads).Error; err != nil {
+			return fmt.Errorf("failed to fetch latest occupancy: %w", err)
+		}
+		var jumps []auditoriumJump
+		if err := tx.Raw(`
+			SELECT DISTINCT ON (j.auditorium_id) j.auditorium_id, a.capacity, j.prev_count, j.person_count, j.timestamp
+			FROM (
+				SELECT auditorium_id, person_count, timestamp,
+					LAG(person_count) OVER (PARTITION BY auditorium_id ORDER BY timestamp, id) AS prev_count
+				FROM occupancy
+				WHERE timestamp > ? AND timestamp <= ?
+			) j
+			JOIN auditorium a ON a.id = j.auditorium_id
+			WHERE j.prev_count IS NOT NULL
+			ORDER BY j.auditorium_id, ABS(j.person_count - j.prev_count) DESC, j.timestamp DESC`,
+			now.Add(-rules.SpikeWindow), now).Scan(&jumps).Error; err != nil {
+			return fmt.Errorf("failed to fetch occupancy changes: %w", err)
+		}
+
+		var firing []forms.Alert
+		if err := tx.Where("status = ?", forms.AlertFiring).Find(&firing).Error; err != nil {
+			return fmt.Errorf("failed to fetch firing alerts: %w", err)
+		}
+		changes = diffAlerts(firing, alertConditions(now, rules, silences, loads, jumps), now)
+
+		if len(changes.raised) > 0 {
+			if err := tx.Create(&changes.raised).Error; err != nil {
+				return fmt.Errorf("failed to raise alerts: %w", err)
+			}
+		}
+		for _, alert := range changes.updated {
+			if err := tx.Model(&forms.Alert{}).Where("id = ?", alert.ID).
+				Updates(map[string]interface{}{"message": alert.Message, "updated_at": now}).Error; err != nil {
+				return fmt.Errorf("failed to update alert %d: %w", alert.ID, err)
+			}
+		}
+		if len(changes.resolved) > 0 {
+			if err := tx.Model(&forms.Alert{}).Where("id IN ?", changes.resolved).
+				Updates(map[string]interface{}{"status": forms.AlertResolved, "resolved_at": now, "updated_at": now}).Error; err != nil {
+				return fmt.Errorf("failed to resolve alerts: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return len(changes.raised), len(changes.resolved), nil
+}
+
+// GetAlerts returns the alerts matching the query, latest first.
+func (a *AlertModel) GetAlerts(q forms.AlertQuery) ([]forms.AlertResponse, error) {
+	query := alertQuery()
+	if q.Status != "" {
+		query = query.Where("al.status = ?", q.Status)
+	}
+	if q.Kind != "" {
+		query = query.Where("al.kind = ?", q.Kind)
+	}
+	if q.CityID != 0 {
+		query = query.Where("b.city_id = ?", q.CityID)
+	}
+	if q.BuildingID != 0 {
+		query = query.Where("a.building_id = ?", q.BuildingID)
+	}
+	if q.AuditoriumID != 0 {
+		query = query.Where("al.auditorium_id = ?", q.AuditoriumID)
+	}
+	if q.CameraID != 0 {
+		query = query.Where("al.camera_id = ?", q.CameraID)
+	}
+	if len(q.ScopeCityIDs) > 0 || len(q.ScopeBuildingIDs) > 0 {
+		query = query.Where("(b.city_id IN ? OR b.id IN ?)", q.ScopeCityIDs, q.ScopeBuildingIDs)
+	}
+	if q.Acknowledged != nil {
+		if *q.Acknowledged {
+			query = query.Where("al.acknowledged_at IS NOT NULL")
+		} else {
+			query = query.Where("al.acknowledged_at IS NULL")
+		}
+	}
+
+	var rows []alertRow
+	if err := query.Order("al.started_at DESC, al.id DESC").Limit(q.Limit).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch alerts: %w", err)
+	}
+	result := make([]forms.AlertResponse, 0, len(rows))
+	for i := range rows {
+		result = append(result, rows[i].ToAlertResponse(rows[i].CityID, rows[i].BuildingID))
+	}
+	return result, nil
+}
+
+// GetAlert returns a single alert or gorm.ErrRecordNotFound.
+func (a *AlertModel) GetAlert(alertID uint64) (*forms.AlertResponse, error) {
+	var row alertRow
+	if err := alertQuery().Where("al.id = ?", alertID).Take(&row).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, gorm.ErrRecordNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch alert: %w", err)
+	}
+	resp := row.ToAlertResponse(row.CityID, row.BuildingID)
+	return &resp, nil
+}
+
+// AcknowledgeAlert marks an alert as acknowledged at the given time with an
+// optional note. An alert that is already acknowledged keeps its first
+// acknowledgement.
+func (a *AlertModel) AcknowledgeAlert(alertID uint64, note string, at time.Time) (*forms.AlertResponse, error) {
+	var ackNote *string
+	if note != "" {
+		ackNote = &note
+	}
+	if err := db.GetDB().Model(&forms.Alert{}).
+		Where("id = ? AND acknowledged_at IS NULL", alertID).
+		Updates(map[string]interface{}{"acknowledged_at": at, "ack_note": ackNote}).Error; err != nil {
+		return nil, fmt.Errorf("failed to acknowledge alert %d: %w", alertID, err)
+	}
+	return a.GetAlert(alertID)
+}
+
+func alertQuery() *gorm.DB {
+	return db.GetDB().
+		Table("alert al").
+		Select("al.*, a.building_id, b.city_id").
+		Joins("JOIN auditorium a ON a.id = al.auditorium_id").
+		Joins("JOIN building b ON b.id = a.building_id")
+}
+
+// alertConditions lists the conditions that hold at now:
+//   - stale: an attached camera without events, or whose latest event is older than StaleAfter;
+//   - over_capacity: the latest fresh reading has more people than the capacity;
+//   - spike: within SpikeWindow the count changed by at least SpikePercent of the
+//     capacity from one reading to the next.
+func alertConditions(now time.Time, rules AlertRules, silences []cameraSilence, loads []auditoriumLoad, jumps []auditoriumJump) []alertCondition {
+	var conds []alertCondition
+	for _, s := range silences {
+		key := alertKey{Kind: forms.AlertStale, AuditoriumID: s.AuditoriumID, CameraID: s.CameraID}
+		switch {
+		case s.LastEventAt == nil:
+			conds = append(conds, alertCondition{key, fmt.Sprintf("camera %d has never sent an event", s.CameraID)})
+		case now.Sub(*s.LastEventAt) > rules.StaleAfter:
+			conds = append(conds, alertCondition{key, fmt.Sprintf("camera %d has sent no events since %s",
+				s.CameraID, s.LastEventAt.UTC().Format(time.RFC3339))})
+		}
+	}
+	for _, l := range loads {
+		if l.Capacity > 0 && l.PersonCount > l.Capacity {
+			conds = append(conds, alertCondition{
+				alertKey{Kind: forms.AlertOverCapacity, AuditoriumID: l.AuditoriumID},
+				fmt.Sprintf("%d people in an auditorium for %d at %s", l.PersonCount, l.Capacity, l.Timestamp.UTC().Format(time.RFC3339)),
+			})
+		}
+	}
+	for _, j := range jumps {
+		if j.Capacity <= 0 {
+			continue
+		}
+		change := math.Abs(float64(j.PersonCount-j.PrevCount)) / float64(j.Capacity) * 100
+		if change >= rules.SpikePercent {
+			conds = append(conds, alertCondition{
+				alertKey{Kind: forms.AlertSpike, AuditoriumID: j.AuditoriumID},
+				fmt.Sprintf("person count jumped from %d to %d (%.0f%% of capacity %d) at %s",
+					j.PrevCount, j.PersonCount, change, j.Capacity, j.Timestamp.UTC().Format(time.RFC3339)),
+			})
+		}
+	}
+	return conds
+}
+
+// diffAlerts compares the firing alerts with the conditions that hold at now.
+func diffAlerts(firing []forms.Alert, conds []alertCondition, now time.Time) alertChanges {
+	byKey := make(map[alertKey]forms.Alert, len(firing))
+	for _, alert := range firing {
+		key := alertKey{Kind: alert.Kind, AuditoriumID: alert.AuditoriumID}
+		if alert.CameraID != nil {
+			key.CameraID = *alert.CameraID
+		}
+		byKey[key] = alert
+	}
+
+	var changes alertChanges
+	for _, cond := range conds {
+		alert, ok := byKey[cond.alertKey]
+		if !ok {
+			raised := forms.Alert{
+				Kind:         cond.Kind,
+				AuditoriumID: cond.AuditoriumID,
+				Status:       forms.AlertFiring,
+				Message:      cond.Message,
+				StartedAt:    now,
+				UpdatedAt:    now,
+			}
+			if cond.CameraID != 0 {
+				cameraID := cond.CameraID
+				raised.CameraID = &cameraID
+			}
+			changes.raised = append(changes.raised, raised)
+			continue
+		}
+		delete(byKey, cond.alertKey)
+		if alert.Message != cond.Message {
+			alert.Message = cond.Message
+			changes.updated = append(changes.updated, alert)
+		}
+	}
+	for _, alert := range byKey {
+		changes.resolved = append(changes.resolved, alert.ID)
+	}
+	sort.Slice(changes.resolved, func(i, j int) bool { return changes.resolved[i] < changes.resolved[j] })
+	return changes
+}
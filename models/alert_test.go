@@ -0,0 +1,289 @@
+package models
+
+import (
+	"testing"
+	"time"
+	"web_backend_v2/forms"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testRules = AlertRules{StaleAfter: 10 * time.Minute, SpikePercent: 50, SpikeWindow: 15 * time.Minute}
+
+func TestAlertConditions(t *testing.T) {
+	now := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		ts := now.Add(-d)
+		return &ts
+	}
+
+	tests := []struct {
+		name     string
+		silences []cameraSilence
+		loads    []auditoriumLoad
+		jumps    []auditoriumJump
+		want     []alertCondition
+	}{
+		{
+			name:     "camera never reported",
+			silences: []cameraSilence{{CameraID: 5, AuditoriumID: 2}},
+			want: []alertCondition{
+				{alertKey{Kind: forms.AlertStale, AuditoriumID: 2, CameraID: 5}, "camera 5 has never sent an event"},
+			},
+		},
+		{
+			name:     "camera silent for longer than StaleAfter",
+			silences: []cameraSilence{{CameraID: 5, AuditoriumID: 2, LastEventAt: at(11 * time.Minute)}},
+			want: []alertCondition{
+				{alertKey{Kind: forms.AlertStale, AuditoriumID: 2, CameraID: 5}, "camera 5 has sent no events since 2026-03-02T09:49:00Z"},
+			},
+		},
+		{
+			name:     "camera silent for exactly StaleAfter",
+			silences: []cameraSilence{{CameraID: 5, AuditoriumID: 2, LastEventAt: at(10 * time.Minute)}},
+		},
+		{
+			name:  "over capacity",
+			loads: []auditoriumLoad{{AuditoriumID: 2, Capacity: 30, PersonCount: 31, Timestamp: now}},
+			want: []alertCondition{
+				{alertKey{Kind: forms.AlertOverCapacity, AuditoriumID: 2}, "31 people in an auditorium for 30 at 2026-03-02T10:00:00Z"},
+			},
+		},
+		{
+			name:  "at capacity",
+			loads: []auditoriumLoad{{AuditoriumID: 2, Capacity: 30, PersonCount: 30, Timestamp: now}},
+		},
+		{
+			name:  "no capacity",
+			loads: []auditoriumLoad{{AuditoriumID: 2, PersonCount: 30, Timestamp: now}},
+		},
+		{
+			name:  "jump of SpikePercent",
+			jumps: []auditoriumJump{{AuditoriumID: 2, Capacity: 40, PrevCount: 30, PersonCount: 10, Timestamp: now}},
+			want: []alertCondition{
+				{alertKey{Kind: forms.AlertSpike, AuditoriumID: 2}, "person count jumped from 30 to 10 (50% of capacity 40) at 2026-03-02T10:00:00Z"},
+			},
+		},
+		{
+			name:  "jump below SpikePercent",
+			jumps: []auditoriumJump{{AuditoriumID: 2, Capacity: 40, PrevCount: 10, PersonCount: 29, Timestamp: now}},
+		},
+		{
+			name:  "jump without capacity",
+			jumps: []auditoriumJump{{AuditoriumID: 2, PrevCount: 0, PersonCount: 50, Timestamp: now}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, alertConditions(now, testRules, tt.silences, tt.loads, tt.jumps))
+		})
+	}
+}
+
+func TestDiffAlerts(t *testing.T) {
+	now := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	started := now.Add(-time.Hour)
+	cameraID := uint(5)
+	stale := alertKey{Kind: forms.AlertStale, AuditoriumID: 2, CameraID: cameraID}
+	over := alertKey{Kind: forms.AlertOverCapacity, AuditoriumID: 2}
+	firingStale := forms.Alert{ID: 8, Kind: forms.AlertStale, AuditoriumID: 2, CameraID: &cameraID,
+		Status: forms.AlertFiring, Message: "old", StartedAt: started, UpdatedAt: started}
+	firingOver := forms.Alert{ID: 3, Kind: forms.AlertOverCapacity, AuditoriumID: 2,
+		Status: forms.AlertFiring, Message: "old", StartedAt: started, UpdatedAt: started}
+
+	tests := []struct {
+		name   string
+		firing []forms.Alert
+		conds  []alertCondition
+		want   alertChanges
+	}{
+		{
+			name:  "new conditions are raised",
+			conds: []alertCondition{{stale, "silent"}, {over, "crowded"}},
+			want: alertChanges{raised: []forms.Alert{
+				{Kind: forms.AlertStale, AuditoriumID: 2, CameraID: &cameraID, Status: forms.AlertFiring, Message: "silent", StartedAt: now, UpdatedAt: now},
+				{Kind: forms.AlertOverCapacity, AuditoriumID: 2, Status: forms.AlertFiring, Message: "crowded", StartedAt: now, UpdatedAt: now},
+			}},
+		},
+		{
+			name:   "same message is left alone",
+			firing: []forms.Alert{firingStale},
+			conds:  []alertCondition{{stale, "old"}},
+		},
+		{
+			name:   "new message updates the alert",
+			firing: []forms.Alert{firingStale},
+			conds:  []alertCondition{{stale, "silent"}},
+			want: alertChanges{updated: []forms.Alert{
+				{ID: 8, Kind: forms.AlertStale, AuditoriumID: 2, CameraID: &cameraID, Status: forms.AlertFiring, Message: "silent", StartedAt: started, UpdatedAt: started},
+			}},
+		},
+		{
+			name:   "conditions gone are resolved",
+			firing: []forms.Alert{firingStale, firingOver},
+			want:   alertChanges{resolved: []uint64{3, 8}},
+		},
+		{
+			name:   "stale alert of another camera is a different alert",
+			firing: []forms.Alert{firingStale},
+			conds:  []alertCondition{{alertKey{Kind: forms.AlertStale, AuditoriumID: 2, CameraID: 6}, "silent"}},
+			want: alertChanges{
+				raised: []forms.Alert{
+					{Kind: forms.AlertStale, AuditoriumID: 2, CameraID: ptr(uint(6)), Status: forms.AlertFiring, Message: "silent", StartedAt: now, UpdatedAt: now},
+				},
+				resolved: []uint64{8},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, diffAlerts(tt.firing, tt.conds, now))
+		})
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+// alertFixture is an auditorium for 10 with one attached camera.
+func alertFixture(t *testing.T) (*MemoryStore, forms.Camera, uint) {
+	t.Helper()
+	m := NewMemoryStore(FusionMax, DefaultFusionWindow)
+	city := forms.City{NameRU: "Москва", NameEN: "Moscow"}
+	require.NoError(t, m.CreateCity(&city))
+	building := forms.Building{CityID: city.ID, AddressRU: "ул. Ленина, 1", AddressEN: "1 Lenina St", FloorCount: 3}
+	require.NoError(t, m.CreateBuilding(&building))
+	auditorium := forms.Auditorium{BuildingID: building.ID, FloorNumber: 1, Capacity: 10, AuditoriumNumber: "101"}
+	require.NoError(t, m.CreateAuditorium(&auditorium))
+	camera, err := m.CreateCamera("aa:bb:cc:dd:ee:01")
+	require.NoError(t, err)
+	require.NoError(t, m.AttachCameraToAuditorium(camera.ID, auditorium.ID))
+	return m, *camera, auditorium.ID
+}
+
+// report saves a reading of the camera the way the events handler does.
+func report(t *testing.T, m *MemoryStore, camera forms.Camera, at time.Time, count int) {
+	t.Helper()
+	require.NoError(t, m.SaveEvent(&forms.CameraEvent{IDCamera: camera.Mac, Timestamp: at, PersonCount: &count}))
+	require.NoError(t, m.RecordHeartbeat(camera.Mac, count, at, at))
+}
+
+func alertsOf(t *testing.T, m *MemoryStore, kind string) []forms.AlertResponse {
+	t.Helper()
+	alerts, err := m.GetAlerts(forms.AlertQuery{Kind: kind})
+	require.NoError(t, err)
+	return alerts
+}
+
+func TestMemoryEvaluateAlerts(t *testing.T) {
+	now := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+
+	t.Run("stale camera raises and recovery resolves", func(t *testing.T) {
+		m, camera, auditoriumID := alertFixture(t)
+		raised, resolved, err := m.EvaluateAlerts(now, testRules)
+		require.NoError(t, err)
+		assert.Equal(t, [2]int{1, 0}, [2]int{raised, resolved})
+		alerts := alertsOf(t, m, forms.AlertStale)
+		require.Len(t, alerts, 1)
+		assert.Equal(t, forms.AlertFiring, alerts[0].Status)
+		assert.Equal(t, auditoriumID, alerts[0].AuditoriumID)
+		assert.Equal(t, &camera.ID, alerts[0].CameraID)
+		assert.Equal(t, "camera 1 has never sent an event", alerts[0].Message)
+
+		// Still silent: the alert keeps firing and no second one is raised.
+		raised, resolved, err = m.EvaluateAlerts(now.Add(time.Minute), testRules)
+		require.NoError(t, err)
+		assert.Equal(t, [2]int{0, 0}, [2]int{raised, resolved})
+
+		report(t, m, camera, now.Add(2*time.Minute), 3)
+		raised, resolved, err = m.EvaluateAlerts(now.Add(3*time.Minute), testRules)
+		require.NoError(t, err)
+		assert.Equal(t, [2]int{0, 1}, [2]int{raised, resolved})
+		alerts = alertsOf(t, m, forms.AlertStale)
+		require.Len(t, alerts, 1)
+		assert.Equal(t, forms.AlertResolved, alerts[0].Status)
+		require.NotNil(t, alerts[0].ResolvedAt)
+		assert.Equal(t, now.Add(3*time.Minute), *alerts[0].ResolvedAt)
+
+		// Silent again for longer than StaleAfter: a new alert.
+		raised, _, err = m.EvaluateAlerts(now.Add(13*time.Minute), testRules)
+		require.NoError(t, err)
+		assert.Equal(t, 1, raised)
+		alerts = alertsOf(t, m, forms.AlertStale)
+		require.Len(t, alerts, 2)
+		assert.Equal(t, "camera 1 has sent no events since 2026-03-02T10:02:00Z", alerts[0].Message)
+	})
+
+	t.Run("over capacity", func(t *testing.T) {
+		m, camera, _ := alertFixture(t)
+		report(t, m, camera, now.Add(-time.Minute), 12)
+		_, _, err := m.EvaluateAlerts(now, testRules)
+		require.NoError(t, err)
+		alerts := alertsOf(t, m, forms.AlertOverCapacity)
+		require.Len(t, alerts, 1)
+		assert.Equal(t, "12 people in an auditorium for 10 at 2026-03-02T09:59:00Z", alerts[0].Message)
+
+		// The reading gets older than StaleAfter: no longer a current load.
+		_, _, err = m.EvaluateAlerts(now.Add(10*time.Minute), testRules)
+		require.NoError(t, err)
+		alerts = alertsOf(t, m, forms.AlertOverCapacity)
+		require.Len(t, alerts, 1)
+		assert.Equal(t, forms.AlertResolved, alerts[0].Status)
+	})
+
+	t.Run("spike inside and outside SpikeWindow", func(t *testing.T) {
+		m, camera, _ := alertFixture(t)
+		report(t, m, camera, now.Add(-20*time.Minute), 1)
+		report(t, m, camera, now.Add(-16*time.Minute), 9)
+		report(t, m, camera, now.Add(-time.Minute), 8)
+		_, _, err := m.EvaluateAlerts(now, testRules)
+		require.NoError(t, err)
+		assert.Empty(t, alertsOf(t, m, forms.AlertSpike), "the jump is older than SpikeWindow")
+
+		report(t, m, camera, now.Add(time.Minute), 2)
+		_, _, err = m.EvaluateAlerts(now.Add(2*time.Minute), testRules)
+		require.NoError(t, err)
+		alerts := alertsOf(t, m, forms.AlertSpike)
+		require.Len(t, alerts, 1)
+		assert.Equal(t, forms.AlertFiring, alerts[0].Status)
+		assert.Equal(t, "person count jumped from 8 to 2 (60% of capacity 10) at 2026-03-02T10:01:00Z", alerts[0].Message)
+
+		// Readings keep coming without a jump; the spike leaves the window.
+		report(t, m, camera, now.Add(10*time.Minute), 2)
+		_, _, err = m.EvaluateAlerts(now.Add(16*time.Minute), testRules)
+		require.NoError(t, err)
+		alerts = alertsOf(t, m, forms.AlertSpike)
+		require.Len(t, alerts, 1)
+		assert.Equal(t, forms.AlertResolved, alerts[0].Status)
+	})
+}
+
+func TestMemoryAcknowledgeAlert(t *testing.T) {
+	now := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	m, camera, _ := alertFixture(t)
+	_, _, err := m.EvaluateAlerts(now, testRules)
+	require.NoError(t, err)
+	report(t, m, camera, now.Add(time.Minute), 3)
+	_, _, err = m.EvaluateAlerts(now.Add(2*time.Minute), testRules)
+	require.NoError(t, err)
+	alerts := alertsOf(t, m, forms.AlertStale)
+	require.Len(t, alerts, 1)
+	require.Equal(t, forms.AlertResolved, alerts[0].Status)
+
+	ackAt := now.Add(5 * time.Minute)
+	alert, err := m.AcknowledgeAlert(alerts[0].ID, "cable replaced", ackAt)
+	require.NoError(t, err)
+	assert.Equal(t, forms.AlertResolved, alert.Status, "acknowledging does not reopen or change the status")
+	assert.True(t, alert.Acknowledged)
+	assert.Equal(t, &ackAt, alert.AcknowledgedAt)
+	assert.Equal(t, ptr("cable replaced"), alert.AckNote)
+
+	// A second acknowledgement keeps the first one.
+	alert, err = m.AcknowledgeAlert(alerts[0].ID, "again", ackAt.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, &ackAt, alert.AcknowledgedAt)
+	assert.Equal(t, ptr("cable replaced"), alert.AckNote)
+
+	_, err = m.AcknowledgeAlert(alerts[0].ID+1, "", ackAt)
+	assert.Error(t, err)
+}
@@ -1,7 +1,9 @@
 package models
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"time"
 	"web_backend_v2/db"
 	"web_backend_v2/forms"
@@ -9,6 +11,9 @@ import (
 	"gorm.io/gorm"
 )
 
+// ErrCapacityNotSet is returned when utilisation is requested for an auditorium without capacity.
+var ErrCapacityNotSet = errors.New("auditorium capacity is not set")
+
 type AuditoryModel struct{}
 
 // Exists checks if auditorium with given ID exists.
@@ -20,6 +25,34 @@ func (a *AuditoryModel) Exists(auditoriumID uint) (bool, error) {
 	return count > 0, nil
 }
 
+// AuditoriumLocation is the building and city of an auditorium.
+type AuditoriumLocation struct {
+	AuditoriumID uint `gorm:"column:auditorium_id"`
+	BuildingID   uint `gorm:"column:building_id"`
+	CityID       uint `gorm:"column:city_id"`
+}
+
+// GetAuditoriumLocations maps each existing auditorium of auditoriumIDs to its
+// building and city.
+func (a *AuditoryModel) GetAuditoriumLocations(auditoriumIDs []uint) (map[uint]AuditoriumLocation, error) {
+	locations := make(map[uint]AuditoriumLocation, len(auditoriumIDs))
+	if len(auditoriumIDs) == 0 {
+		return locations, nil
+	}
+	var rows []AuditoriumLocation
+	if err := db.GetDB().Table("auditorium a").
+		Select("a.id AS auditorium_id, a.building_id, b.city_id").
+		Joins("JOIN building b ON b.id = a.building_id").
+		Where("a.id IN ?", auditoriumIDs).
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("error fetching locations of auditoriums: %w", err)
+	}
+	for _, row := range rows {
+		locations[row.AuditoriumID] = row
+	}
+	return locations, nil
+}
+
 func (a *AuditoryModel) GetAuditoriumsByBuilding(uidBuilding uint) ([]forms.Auditorium, error) {
 	var auditories []forms.Auditorium
 
@@ -35,6 +68,94 @@ func (a *AuditoryModel) GetAuditoriumsByBuilding(uidBuilding uint) ([]forms.Audi
 	return auditories, nil
 }
 
+// GetAuditorium returns an auditorium of the given building or gorm.ErrRecordNotFound.
+func (a *AuditoryModel) GetAuditorium(buildingID, auditoriumID uint) (*forms.Auditorium, error) {
+	var auditorium forms.Auditorium
+	result := db.GetDB().Table("auditorium").
+		Where("id = ? AND building_id = ?", auditoriumID, buildingID).
+		First(&auditorium)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, gorm.ErrRecordNotFound
+		}
+		return nil, fmt.Errorf("error fetching auditorium %d: %w", auditoriumID, result.Error)
+	}
+	return &auditorium, nil
+}
+
+// CreateAuditorium inserts an auditorium after checking its floor against the building.
+// Returns gorm.ErrRecordNotFound when the building does not exist.
+func (a *AuditoryModel) CreateAuditorium(auditorium *forms.Auditorium) error {
+	return db.GetDB().Transaction(func(tx *gorm.DB) error {
+		var building forms.Building
+		if err := tx.Table("building").Where("id = ?", auditorium.BuildingID).First(&building).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return gorm.ErrRecordNotFound
+			}
+			return fmt.Errorf("failed to load building %d: %w", auditorium.BuildingID, err)
+		}
+		if err := checkFloor(auditorium.FloorNumber, building.FloorCount); err != nil {
+			return err
+		}
+
+		auditorium.ID = 0
+		if err := tx.Table("auditorium").Create(auditorium).Error; err != nil {
+			return fmt.Errorf("failed to create auditorium: %w", err)
+		}
+		return nil
+	})
+}
+
+// UpdateAuditorium overwrites all editable fields of an auditorium.
+func (a *AuditoryModel) UpdateAuditorium(auditorium *forms.Auditorium) error {
+	return db.GetDB().Transaction(func(tx *gorm.DB) error {
+		var building forms.Building
+		if err := tx.Table("building").Where("id = ?", auditorium.BuildingID).First(&building).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return gorm.ErrRecordNotFound
+			}
+			return fmt.Errorf("failed to load building %d: %w", auditorium.BuildingID, err)
+		}
+		if err := checkFloor(auditorium.FloorNumber, building.FloorCount); err != nil {
+			return err
+		}
+
+		res := tx.Table("auditorium").
+			Where("id = ? AND building_id = ?", auditorium.ID, auditorium.BuildingID).
+			Updates(map[string]interface{}{
+				"floor_number":      auditorium.FloorNumber,
+				"capacity":          auditorium.Capacity,
+				"auditorium_number": auditorium.AuditoriumNumber,
+				"type":              auditorium.Type,
+				"type_ru":           auditorium.TypeRU,
+				"image_url":         auditorium.ImageURL,
+				"fusion_strategy":   auditorium.FusionStrategy,
+			})
+		if res.Error != nil {
+			return fmt.Errorf("failed to update auditorium %d: %w", auditorium.ID, res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// DeleteAuditorium removes an auditorium; camera attachments and occupancy go via FK cascade.
+func (a *AuditoryModel) DeleteAuditorium(buildingID, auditoriumID uint) error {
+	tx := db.GetDB().Table("auditorium").
+		Where("id = ? AND building_id = ?", auditoriumID, buildingID).
+		Delete(&forms.Auditorium{})
+	if tx.Error != nil {
+		return fmt.Errorf("failed to delete auditorium %d: %w", auditoriumID, tx.Error)
+	}
+	if tx.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	cameraRoutes.invalidate()
+	return nil
+}
+
 func (a *AuditoryModel) GetOccupancyForAuditorium(auditoriumID uint, queryTimestamp time.Time, maxTimeDiffMinutes int) (forms.Occupancy, error) {
 	var occupancy forms.Occupancy
 	result := db.GetDB().Table("occupancy").
@@ -56,21 +177,18 @@ func (a *AuditoryModel) GetOccupancyForAuditorium(auditoriumID uint, queryTimest
 // auditorium in a building at or before the provided timestamp.
 func (a *AuditoryModel) GetLatestOccupancyByBuilding(buildingID uint, queryTimestamp time.Time, maxTimeDiffMinutes int) ([]forms.AuditoriumOccupancyResponse, error) {
 	type row struct {
-		AuditoriumID uint
-		PersonCount  int
-		Timestamp    time.Time
+		AuditoriumID   uint
+		PersonCount    int
+		Timestamp      time.Time
+		FusionStrategy *string
+		Contributions  forms.CameraContributions
 	}
 
 	var rows []row
-	// subquery: latest timestamp per auditorium in building up to queryTimestamp
-	sub := db.GetDB().Table("occupancy AS o").
-		Select("o.auditorium_id, MAX(o.timestamp) AS max_ts").
-		Joins("JOIN auditorium a ON a.id = o.auditorium_id").
-		Where("a.building_id = ? AND o.timestamp <= ?", buildingID, queryTimestamp).
-		Group("o.auditorium_id")
+	sub := latestOccupancySubquery(queryTimestamp, "a.building_id = ?", buildingID)
 
 	result := db.GetDB().Table("occupancy AS o").
-		Select("o.auditorium_id, o.person_count, o.timestamp").
+		Select("o.auditorium_id, o.person_count, o.timestamp, o.fusion_strategy, o.contributions").
 		Joins("JOIN (?) latest ON latest.auditorium_id = o.auditorium_id AND latest.max_ts = o.timestamp", sub).
 		Order("o.auditorium_id").
 		Scan(&rows)
@@ -83,36 +201,37 @@ func (a *AuditoryModel) GetLatestOccupancyByBuilding(buildingID uint, queryTimes
 
 	responses := make([]forms.AuditoriumOccupancyResponse, 0, len(rows))
 	for _, r := range rows {
-		timeDiff := queryTimestamp.Sub(r.Timestamp).Minutes()
-		isFresh := timeDiff <= float64(maxTimeDiffMinutes)
-		var warning *string
-		if !isFresh {
-			msg := fmt.Sprintf("Data is stale by %.1f minutes (max %d)", timeDiff, maxTimeDiffMinutes)
-			warning = &msg
-		}
-		responses = append(responses, forms.AuditoriumOccupancyResponse{
-			AuditoriumID:    r.AuditoriumID,
-			PersonCount:     r.PersonCount,
-			ActualTimestamp: r.Timestamp,
-			IsFresh:         isFresh,
-			TimeDiffMinutes: timeDiff,
-			Warning:         warning,
-		})
+		responses = append(responses, occupancyResponse(r.AuditoriumID, r.PersonCount, r.Timestamp,
+			r.FusionStrategy, r.Contributions, queryTimestamp, maxTimeDiffMinutes))
 	}
 	return responses, nil
 }
 
+// latestOccupancySubquery selects (auditorium_id, max_ts): the latest reading
+// time up to queryTimestamp of every auditorium matching scope, a condition on
+// the auditorium aliased as a.
+func latestOccupancySubquery(queryTimestamp time.Time, scope string, args ...interface{}) *gorm.DB {
+	return db.GetDB().Table("occupancy AS o").
+		Select("o.auditorium_id, MAX(o.timestamp) AS max_ts").
+		Joins("JOIN auditorium a ON a.id = o.auditorium_id").
+		Where(scope, args...).
+		Where("o.timestamp <= ?", queryTimestamp).
+		Group("o.auditorium_id")
+}
+
 // GetLatestOccupancyForAuditorium returns the most recent occupancy record for a
 // single auditorium at or before the provided timestamp.
 func (a *AuditoryModel) GetLatestOccupancyForAuditorium(auditoriumID uint, queryTimestamp time.Time, maxTimeDiffMinutes int) (*forms.AuditoriumOccupancyResponse, error) {
 	var row struct {
-		AuditoriumID uint
-		PersonCount  int
-		Timestamp    time.Time
+		AuditoriumID   uint
+		PersonCount    int
+		Timestamp      time.Time
+		FusionStrategy *string
+		Contributions  forms.CameraContributions
 	}
 
 	result := db.GetDB().Table("occupancy").
-		Select("auditorium_id, person_count, timestamp").
+		Select("auditorium_id, person_count, timestamp, fusion_strategy, contributions").
 		Where("auditorium_id = ? AND timestamp <= ?", auditoriumID, queryTimestamp).
 		Order("timestamp DESC").
 		Limit(1).
@@ -124,7 +243,15 @@ func (a *AuditoryModel) GetLatestOccupancyForAuditorium(auditoriumID uint, query
 		return nil, gorm.ErrRecordNotFound
 	}
 
-	timeDiff := queryTimestamp.Sub(row.Timestamp).Minutes()
+	resp := occupancyResponse(row.AuditoriumID, row.PersonCount, row.Timestamp,
+		row.FusionStrategy, row.Contributions, queryTimestamp, maxTimeDiffMinutes)
+	return &resp, nil
+}
+
+// occupancyResponse describes an occupancy reading as seen at queryTimestamp,
+// flagging it stale when older than maxTimeDiffMinutes.
+func occupancyResponse(auditoriumID uint, personCount int, ts time.Time, strategy *string, contributions forms.CameraContributions, queryTimestamp time.Time, maxTimeDiffMinutes int) forms.AuditoriumOccupancyResponse {
+	timeDiff := queryTimestamp.Sub(ts).Minutes()
 	isFresh := timeDiff <= float64(maxTimeDiffMinutes)
 	var warning *string
 	if !isFresh {
@@ -132,22 +259,147 @@ func (a *AuditoryModel) GetLatestOccupancyForAuditorium(auditoriumID uint, query
 		warning = &msg
 	}
 
-	resp := &forms.AuditoriumOccupancyResponse{
-		AuditoriumID:    row.AuditoriumID,
-		PersonCount:     row.PersonCount,
-		ActualTimestamp: row.Timestamp,
+	return forms.AuditoriumOccupancyResponse{
+		AuditoriumID:    auditoriumID,
+		PersonCount:     personCount,
+		ActualTimestamp: ts,
 		IsFresh:         isFresh,
 		TimeDiffMinutes: timeDiff,
 		Warning:         warning,
+		FusionStrategy:  derefString(strategy),
+		Contributions:   contributions,
 	}
-	return resp, nil
 }
 
-// GetAuditoriumStats returns hourly statistics for a specific auditorium on a specific day.
-// statsType: 1 = Absolute Count (Average), 2 = Occupancy Rate (Percentage)
+// OccupancyUpdateResponse presents a stored reading the way the latest
+// occupancy endpoints do at queryTimestamp.
+func OccupancyUpdateResponse(u forms.OccupancyUpdate, queryTimestamp time.Time, maxTimeDiffMinutes int) forms.AuditoriumOccupancyResponse {
+	return occupancyResponse(u.AuditoriumID, u.PersonCount, u.Timestamp, &u.FusionStrategy, u.Contributions, queryTimestamp, maxTimeDiffMinutes)
+}
+
+// FindFreeAuditoriums returns the auditoriums of a city matching q with their
+// latest reading at or before q.At, ranked by free seats (see rankFreeAuditoriums).
+func (a *AuditoryModel) FindFreeAuditoriums(cityID uint, q forms.FreeAuditoriumsQuery, maxTimeDiffMinutes int) ([]forms.FreeAuditoriumResponse, error) {
+	query := db.GetDB().Table("auditorium AS a").
+		Select(`a.id AS auditorium_id, a.auditorium_number, a.floor_number, a.type, a.type_ru, a.capacity, a.image_url,
+			b.id AS building_id, b.city_id, b.address_ru, b.address_en, b.floor_count,
+			o.person_count, o.timestamp`).
+		Joins("JOIN building b ON b.id = a.building_id").
+		Joins(`LEFT JOIN LATERAL (
+			SELECT person_count, timestamp FROM occupancy
+			WHERE auditorium_id = a.id AND timestamp <= ?
+			ORDER BY timestamp DESC
+			LIMIT 1
+		) o ON true`, q.At).
+		Where("b.city_id = ?", cityID)
+	if q.BuildingID != 0 {
+		query = query.Where("b.id = ?", q.BuildingID)
+	}
+	if q.Type != "" {
+		query = query.Where("a.type = ?", q.Type)
+	}
+	if q.Floor != nil {
+		query = query.Where("a.floor_number = ?", *q.Floor)
+	}
+
+	var rows []freeAuditoriumRow
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return rankFreeAuditoriums(rows, q, maxTimeDiffMinutes), nil
+}
+
+// freeAuditoriumRow is an auditorium with its building and latest reading, if any.
+type freeAuditoriumRow struct {
+	AuditoriumID     uint
+	AuditoriumNumber string
+	FloorNumber      int
+	Type             string
+	TypeRU           string
+	Capacity         int
+	ImageURL         string
+	BuildingID       uint
+	CityID           uint
+	AddressRU        string
+	AddressEN        string
+	FloorCount       int
+	PersonCount      *int
+	Timestamp        *time.Time
+}
+
+// rankFreeAuditoriums applies the freshness and min_seats filters of q and
+// orders the rooms: fresh before stale, then by free seats (most first), then
+// by building, floor and number. Free seats are capacity minus the latest
+// person count, never below zero; rooms without readings or without a capacity
+// have none and come last.
+func rankFreeAuditoriums(rows []freeAuditoriumRow, q forms.FreeAuditoriumsQuery, maxTimeDiffMinutes int) []forms.FreeAuditoriumResponse {
+	result := make([]forms.FreeAuditoriumResponse, 0, len(rows))
+	for _, r := range rows {
+		building := forms.Building{ID: r.BuildingID, CityID: r.CityID, AddressRU: r.AddressRU, AddressEN: r.AddressEN, FloorCount: r.FloorCount}
+		room := forms.FreeAuditoriumResponse{
+			AuditoriumID:     r.AuditoriumID,
+			AuditoriumNumber: r.AuditoriumNumber,
+			Type:             forms.LocalizedString{RU: r.TypeRU, EN: r.Type},
+			FloorNumber:      r.FloorNumber,
+			ImageURL:         r.ImageURL,
+			Building:         building.ToBuildingResponse(),
+			Capacity:         r.Capacity,
+		}
+
+		if r.PersonCount != nil {
+			reading := occupancyResponse(r.AuditoriumID, *r.PersonCount, *r.Timestamp, nil, nil, q.At, maxTimeDiffMinutes)
+			room.PersonCount = r.PersonCount
+			room.ActualTimestamp = r.Timestamp
+			room.IsFresh = reading.IsFresh
+			room.TimeDiffMinutes = &reading.TimeDiffMinutes
+			room.Warning = reading.Warning
+			if r.Capacity > 0 {
+				free := max(r.Capacity-*r.PersonCount, 0)
+				room.FreeSeats = &free
+				rate := float64(*r.PersonCount) / float64(r.Capacity) * 100
+				room.OccupancyRate = &rate
+			}
+		} else {
+			warning := "No occupancy data for this auditorium"
+			room.Warning = &warning
+		}
+
+		if !room.IsFresh && !q.IncludeStale {
+			continue
+		}
+		if q.MinSeats > 0 && (room.FreeSeats == nil || *room.FreeSeats < q.MinSeats) {
+			continue
+		}
+		result = append(result, room)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		x, y := result[i], result[j]
+		if x.IsFresh != y.IsFresh {
+			return x.IsFresh
+		}
+		if (x.FreeSeats == nil) != (y.FreeSeats == nil) {
+			return x.FreeSeats != nil
+		}
+		if x.FreeSeats != nil && *x.FreeSeats != *y.FreeSeats {
+			return *x.FreeSeats > *y.FreeSeats
+		}
+		if x.Building.ID != y.Building.ID {
+			return x.Building.ID < y.Building.ID
+		}
+		if x.FloorNumber != y.FloorNumber {
+			return x.FloorNumber < y.FloorNumber
+		}
+		return x.AuditoriumNumber < y.AuditoriumNumber
+	})
+	return result
+}
+
+// GetAuditoriumStats returns hourly average person counts (statistics type 1) for a
+// specific auditorium on a specific day.
 // Returns strictly hours 9 to 21.
 // The boolean flag noData is true when neither aggregated nor raw data exist for that day.
-func (a *AuditoryModel) GetAuditoriumStats(auditoriumID uint, day time.Time, statsType int) ([]forms.HourlyStatsResponse, bool, error) {
+func (a *AuditoryModel) GetAuditoriumStats(auditoriumID uint, day time.Time) ([]forms.HourlyStatsResponse, bool, error) {
 	// Initialize map for hours 9-21
 	statsMap := make(map[int]float64)
 	// We want to return data for 9..21, but if no data exists, we might return 0.
@@ -195,21 +447,159 @@ func (a *AuditoryModel) GetAuditoriumStats(auditoriumID uint, day time.Time, sta
 		}
 	}
 
-	// 3. Construct response sorted by hour
+	noData := len(dailyRows) == 0 && len(occupancyRows) == 0
+	return hourlyStats(statsMap), noData, nil
+}
+
+// hourlyStats lists hours 9 to 21 with their averages (0 where missing).
+func hourlyStats(statsMap map[int]float64) []forms.HourlyStatsResponse {
 	var response []forms.HourlyStatsResponse
 	for h := 9; h <= 21; h++ {
 		val := statsMap[h] // 0 if missing
 
-		// Currently only returning absolute count regardless of type
-		// If future stats types need different processing or different response fields,
-		// logic will diverge here or in different method.
-		
 		response = append(response, forms.HourlyStatsResponse{
 			Hour:           h,
 			AvgPersonCount: val,
 		})
 	}
+	return response
+}
 
-	noData := len(dailyRows) == 0 && len(occupancyRows) == 0
-	return response, noData, nil
+// GetAuditoriumOccupancyRate returns hourly utilisation (statistics type 2) for a
+// specific auditorium on a specific day: the hourly average person count as a
+// percentage of auditorium.capacity. Rates above 100% are returned as is and
+// flagged with OverCapacity instead of being clamped.
+// Returns ErrCapacityNotSet when the auditorium has no positive capacity.
+func (a *AuditoryModel) GetAuditoriumOccupancyRate(auditoriumID uint, day time.Time) ([]forms.HourlyRateStatsResponse, bool, error) {
+	var capacity int
+	if err := db.GetDB().Table("auditorium").
+		Select("capacity").
+		Where("id = ?", auditoriumID).
+		Scan(&capacity).Error; err != nil {
+		return nil, false, fmt.Errorf("error fetching auditorium capacity: %w", err)
+	}
+	if capacity <= 0 {
+		return nil, false, ErrCapacityNotSet
+	}
+
+	stats, noData, err := a.GetAuditoriumStats(auditoriumID, day)
+	if err != nil {
+		return nil, false, err
+	}
+	return occupancyRates(stats, capacity), noData, nil
+}
+
+// occupancyRates turns hourly averages into percentages of capacity.
+func occupancyRates(stats []forms.HourlyStatsResponse, capacity int) []forms.HourlyRateStatsResponse {
+	response := make([]forms.HourlyRateStatsResponse, 0, len(stats))
+	for _, s := range stats {
+		rate := s.AvgPersonCount / float64(capacity) * 100
+		response = append(response, forms.HourlyRateStatsResponse{
+			Hour:           s.Hour,
+			AvgPersonCount: s.AvgPersonCount,
+			Capacity:       capacity,
+			OccupancyRate:  rate,
+			OverCapacity:   s.AvgPersonCount > float64(capacity),
+		})
+	}
+	return response
+}
+
+// Sources reported in forms.OccupancySeriesPoint.
+const (
+	SeriesSourceOccupancy = "occupancy"
+	SeriesSourceDailyLoad = "dailyload"
+)
+
+// GetOccupancySeries returns bucketed avg/min/max/sample counts for an auditorium
+// in [from, to). Buckets are aligned to the Unix epoch (so 1h buckets start on the hour, UTC).
+// Days already compacted by AggregateDailyOccupancy (i.e. having dailyload rows) are
+// served from dailyload with hourly resolution regardless of the requested bucket.
+func (a *AuditoryModel) GetOccupancySeries(auditoriumID uint, from, to time.Time, bucket time.Duration) ([]forms.OccupancySeriesPoint, error) {
+	from, to = from.UTC(), to.UTC()
+	bucketSeconds := int64(bucket / time.Second)
+
+	// 1. Compacted days from dailyload
+	fromDay := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	var dailyRows []forms.DailyLoad
+	if err := db.GetDB().Table("dailyload").
+		Where("auditorium_id = ? AND day >= ? AND day < ?", auditoriumID, fromDay, to).
+		Order("day, hour").
+		Find(&dailyRows).Error; err != nil {
+		return nil, fmt.Errorf("error fetching dailyload series: %w", err)
+	}
+
+	// 2. Raw occupancy buckets
+	type bucketRow struct {
+		BucketStart time.Time
+		Avg         float64
+		Min         int
+		Max         int
+		SampleCount int
+	}
+	var rawRows []bucketRow
+	if err := db.GetDB().Table("occupancy").
+		Select(`to_timestamp(floor(extract(epoch FROM timestamp) / ?) * ?) AS bucket_start,
+			AVG(person_count)::float8 AS avg,
+			MIN(person_count) AS min,
+			MAX(person_count) AS max,
+			COUNT(*) AS sample_count`, bucketSeconds, bucketSeconds).
+		Where("auditorium_id = ? AND timestamp >= ? AND timestamp < ?", auditoriumID, from, to).
+		Group("bucket_start").
+		Order("bucket_start").
+		Scan(&rawRows).Error; err != nil {
+		return nil, fmt.Errorf("error fetching occupancy series: %w", err)
+	}
+
+	raw := make([]forms.OccupancySeriesPoint, 0, len(rawRows))
+	for _, r := range rawRows {
+		start := r.BucketStart.UTC()
+		minCount, maxCount := r.Min, r.Max
+		raw = append(raw, forms.OccupancySeriesPoint{
+			BucketStart: start,
+			BucketEnd:   start.Add(bucket),
+			Avg:         r.Avg,
+			Min:         &minCount,
+			Max:         &maxCount,
+			SampleCount: r.SampleCount,
+			Source:      SeriesSourceOccupancy,
+		})
+	}
+	return mergeSeries(dailyRows, raw, from, to), nil
+}
+
+// mergeSeries combines the dailyload rows and raw buckets of a series in
+// [from, to): a day with dailyload rows is served from them only, and raw
+// buckets are used for the other days.
+func mergeSeries(dailyRows []forms.DailyLoad, raw []forms.OccupancySeriesPoint, from, to time.Time) []forms.OccupancySeriesPoint {
+	compactedDays := make(map[string]bool)
+	var points []forms.OccupancySeriesPoint
+	for _, r := range dailyRows {
+		day := time.Date(r.Day.Year(), r.Day.Month(), r.Day.Day(), 0, 0, 0, 0, time.UTC)
+		compactedDays[day.Format("2006-01-02")] = true
+
+		start := day.Add(time.Duration(r.Hour) * time.Hour)
+		if start.Before(from.Truncate(time.Hour)) || !start.Before(to) {
+			continue
+		}
+		points = append(points, forms.OccupancySeriesPoint{
+			BucketStart: start,
+			BucketEnd:   start.Add(time.Hour),
+			Avg:         r.AvgPersonCount,
+			Source:      SeriesSourceDailyLoad,
+		})
+	}
+
+	for _, p := range raw {
+		if compactedDays[p.BucketStart.Format("2006-01-02")] {
+			// Late readings on an already compacted day; dailyload is authoritative.
+			continue
+		}
+		points = append(points, p)
+	}
+
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].BucketStart.Before(points[j].BucketStart)
+	})
+	return points
 }
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"testing"
+	"time"
+	"web_backend_v2/forms"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeSeries(t *testing.T) {
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	at := func(days, hours, minutes int) time.Time {
+		return day.AddDate(0, 0, days).Add(time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute)
+	}
+	daily := func(days, hour int, avg float64) forms.DailyLoad {
+		return forms.DailyLoad{AuditoriumID: 1, Day: day.AddDate(0, 0, days), Hour: hour, AvgPersonCount: avg}
+	}
+	raw := func(start time.Time, avg float64) forms.OccupancySeriesPoint {
+		count := int(avg)
+		return forms.OccupancySeriesPoint{
+			BucketStart: start, BucketEnd: start.Add(15 * time.Minute),
+			Avg: avg, Min: &count, Max: &count, SampleCount: 1, Source: SeriesSourceOccupancy,
+		}
+	}
+	type point struct {
+		start  time.Time
+		source string
+		avg    float64
+	}
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		daily    []forms.DailyLoad
+		raw      []forms.OccupancySeriesPoint
+		want     []point
+	}{
+		{
+			name: "raw only",
+			from: at(0, 0, 0), to: at(1, 0, 0),
+			raw:  []forms.OccupancySeriesPoint{raw(at(0, 10, 15), 7), raw(at(0, 10, 0), 5)},
+			want: []point{{at(0, 10, 0), SeriesSourceOccupancy, 5}, {at(0, 10, 15), SeriesSourceOccupancy, 7}},
+		},
+		{
+			name: "compacted day is served from dailyload only",
+			from: at(0, 0, 0), to: at(1, 0, 0),
+			daily: []forms.DailyLoad{daily(0, 10, 6), daily(0, 11, 8)},
+			raw:   []forms.OccupancySeriesPoint{raw(at(0, 10, 15), 40)},
+			want:  []point{{at(0, 10, 0), SeriesSourceDailyLoad, 6}, {at(0, 11, 0), SeriesSourceDailyLoad, 8}},
+		},
+		{
+			name: "dailyload and raw days side by side",
+			from: at(0, 0, 0), to: at(2, 0, 0),
+			daily: []forms.DailyLoad{daily(0, 9, 3)},
+			raw:   []forms.OccupancySeriesPoint{raw(at(1, 9, 30), 4)},
+			want:  []point{{at(0, 9, 0), SeriesSourceDailyLoad, 3}, {at(1, 9, 30), SeriesSourceOccupancy, 4}},
+		},
+		{
+			name: "dailyload hours outside the range are dropped",
+			from: at(0, 10, 30), to: at(0, 12, 0),
+			daily: []forms.DailyLoad{daily(0, 9, 1), daily(0, 10, 2), daily(0, 11, 3), daily(0, 12, 4)},
+			want:  []point{{at(0, 10, 0), SeriesSourceDailyLoad, 2}, {at(0, 11, 0), SeriesSourceDailyLoad, 3}},
+		},
+		{
+			name: "dailyload hours outside the range still mark the day compacted",
+			from: at(0, 12, 0), to: at(0, 14, 0),
+			daily: []forms.DailyLoad{daily(0, 9, 1)},
+			raw:   []forms.OccupancySeriesPoint{raw(at(0, 12, 0), 5)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []point
+			for _, p := range mergeSeries(tt.daily, tt.raw, tt.from, tt.to) {
+				got = append(got, point{p.BucketStart, p.Source, p.Avg})
+				if p.Source == SeriesSourceDailyLoad {
+					assert.Equal(t, time.Hour, p.BucketEnd.Sub(p.BucketStart))
+					assert.Nil(t, p.Min)
+					assert.Zero(t, p.SampleCount)
+				}
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -1,11 +1,18 @@
 package models
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"web_backend_v2/db"
 	"web_backend_v2/forms"
+
+	"gorm.io/gorm"
 )
 
+// ErrFloorOutOfRange is returned when an auditorium floor does not fit into its building.
+var ErrFloorOutOfRange = errors.New("floor_number is out of the building's floor range")
+
 type BuildingModel struct{}
 
 func (b *BuildingModel) GetBuildingsByCity(uidCity uint) ([]forms.Building, error) {
@@ -25,3 +32,125 @@ func (b *BuildingModel) GetBuildingsByCity(uidCity uint) ([]forms.Building, erro
 
 	return buildings, nil
 }
+
+// GetBuilding returns a building of the given city or gorm.ErrRecordNotFound.
+func (b *BuildingModel) GetBuilding(cityID, buildingID uint) (*forms.Building, error) {
+	var building forms.Building
+	result := db.GetDB().Table("building").
+		Where("id = ? AND city_id = ?", buildingID, cityID).
+		First(&building)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, gorm.ErrRecordNotFound
+		}
+		return nil, fmt.Errorf("error fetching building %d: %w", buildingID, result.Error)
+	}
+	return &building, nil
+}
+
+// CreateBuilding inserts a building into an existing city.
+// Returns gorm.ErrRecordNotFound when the city does not exist.
+func (b *BuildingModel) CreateBuilding(building *forms.Building) error {
+	return db.GetDB().Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Table("city").Where("id = ?", building.CityID).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to check city existence: %w", err)
+		}
+		if count == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		building.ID = 0
+		if err := tx.Table("building").Create(building).Error; err != nil {
+			return fmt.Errorf("failed to create building: %w", err)
+		}
+		return nil
+	})
+}
+
+// UpdateBuilding overwrites all editable fields of a building.
+// floor_count cannot be lowered below the highest floor that already has an auditorium.
+func (b *BuildingModel) UpdateBuilding(building *forms.Building) error {
+	return db.GetDB().Transaction(func(tx *gorm.DB) error {
+		var maxFloor sql.NullInt64
+		if err := tx.Table("auditorium").
+			Select("MAX(floor_number)").
+			Where("building_id = ?", building.ID).
+			Scan(&maxFloor).Error; err != nil {
+			return fmt.Errorf("failed to check auditorium floors: %w", err)
+		}
+		if maxFloor.Valid && int(maxFloor.Int64) > building.FloorCount {
+			return fmt.Errorf("%w: auditoriums exist on floor %d, floor_count %d is too low",
+				ErrFloorOutOfRange, maxFloor.Int64, building.FloorCount)
+		}
+
+		res := tx.Table("building").
+			Where("id = ? AND city_id = ?", building.ID, building.CityID).
+			Updates(map[string]interface{}{
+				"address_ru":  building.AddressRU,
+				"address_en":  building.AddressEN,
+				"floor_count": building.FloorCount,
+			})
+		if res.Error != nil {
+			return fmt.Errorf("failed to update building %d: %w", building.ID, res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// CountAuditoriums returns how many auditoriums belong to the building.
+// GetBuildingCities maps each existing building of buildingIDs to its city.
+func (b *BuildingModel) GetBuildingCities(buildingIDs []uint) (map[uint]uint, error) {
+	cities := make(map[uint]uint, len(buildingIDs))
+	if len(buildingIDs) == 0 {
+		return cities, nil
+	}
+	var rows []forms.Building
+	if err := db.GetDB().Table("building").
+		Select("id, city_id").
+		Where("id IN ?", buildingIDs).
+		Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("error fetching cities of buildings: %w", err)
+	}
+	for _, row := range rows {
+		cities[row.ID] = row.CityID
+	}
+	return cities, nil
+}
+
+func (b *BuildingModel) CountAuditoriums(buildingID uint) (int64, error) {
+	var count int64
+	if err := db.GetDB().Table("auditorium").Where("building_id = ?", buildingID).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count auditoriums for building %d: %w", buildingID, err)
+	}
+	return count, nil
+}
+
+// DeleteBuilding removes a building; auditoriums and their data go via FK cascade.
+func (b *BuildingModel) DeleteBuilding(cityID, buildingID uint) error {
+	tx := db.GetDB().Table("building").
+		Where("id = ? AND city_id = ?", buildingID, cityID).
+		Delete(&forms.Building{})
+	if tx.Error != nil {
+		return fmt.Errorf("failed to delete building %d: %w", buildingID, tx.Error)
+	}
+	if tx.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	cameraRoutes.invalidate()
+	return nil
+}
+
+// checkFloor validates an auditorium floor against the building's floor_count.
+// Floors are numbered 1..floor_count above ground and -1, -2, ... below (the
+// number of basement levels is not recorded); there is no floor 0.
+func checkFloor(floorNumber, floorCount int) error {
+	if floorNumber == 0 || floorNumber > floorCount {
+		return fmt.Errorf("%w: got %d, allowed 1..%d or a negative basement level",
+			ErrFloorOutOfRange, floorNumber, floorCount)
+	}
+	return nil
+}
@@ -26,6 +26,7 @@ func (m *CameraModel) CreateCamera(mac string) (*forms.Camera, error) {
 	if result.Error != nil {
 		return nil, fmt.Errorf("failed to create camera: %w", result.Error)
 	}
+	cameraRoutes.invalidate()
 	return &camera, nil
 }
 
@@ -47,6 +48,25 @@ func (m *CameraModel) GetCameraWithAssignment(id uint) (*CameraWithAssignment, e
 	return &cam, nil
 }
 
+// GetCameraByMac returns the camera with the exact MAC and its auditorium
+// assignment, or gorm.ErrRecordNotFound.
+func (m *CameraModel) GetCameraByMac(mac string) (*CameraWithAssignment, error) {
+	var cam CameraWithAssignment
+	tx := db.GetDB().
+		Table("camera c").
+		Select("c.id, c.mac, cia.auditorium_id").
+		Joins("LEFT JOIN camerasinauditorium cia ON cia.camera_id = c.id").
+		Where("c.mac = ?", mac).
+		First(&cam)
+	if tx.Error != nil {
+		if errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+			return nil, gorm.ErrRecordNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch camera: %w", tx.Error)
+	}
+	return &cam, nil
+}
+
 // GetCamerasByAuditorium returns all cameras attached to the given auditorium.
 func (m *CameraModel) GetCamerasByAuditorium(auditoriumID uint) ([]forms.Camera, error) {
 	var cams []forms.Camera
@@ -95,6 +115,7 @@ func (m *CameraModel) GetAttachedCameras() ([]CameraWithAssignment, error) {
 // AttachCameraToAuditorium links camera to an auditorium, ensuring a camera is linked only once.
 func (m *CameraModel) AttachCameraToAuditorium(cameraID, auditoriumID uint) error {
 	dbConn := db.GetDB()
+	defer cameraRoutes.invalidate()
 
 	return dbConn.Transaction(func(tx *gorm.DB) error {
 		// Ensure camera exists
@@ -151,6 +172,7 @@ func (m *CameraModel) DetachCameraFromAuditorium(cameraID uint) error {
 	if tx.Error != nil {
 		return fmt.Errorf("failed to detach camera: %w", tx.Error)
 	}
+	cameraRoutes.invalidate()
 	return nil
 }
 
@@ -163,7 +185,6 @@ func (m *CameraModel) DeleteCamera(cameraID uint) error {
 	if tx.RowsAffected == 0 {
 		return gorm.ErrRecordNotFound
 	}
+	cameraRoutes.invalidate()
 	return nil
 }
-
-
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// cameraRouteTTL bounds how long another replica's attach/detach may go unnoticed.
+const cameraRouteTTL = 30 * time.Second
+
+// cameraRoute is what ingestion needs to know about a camera MAC.
+// CameraID is 0 for unknown MACs; AuditoriumID is nil for unattached cameras.
+type cameraRoute struct {
+	CameraID     uint
+	Mac          string
+	AuditoriumID *uint
+	expires      time.Time
+}
+
+// cameraRouteCache caches MAC → camera/auditorium lookups for batched ingestion.
+// Camera and hierarchy changes made through this process invalidate it at once.
+type cameraRouteCache struct {
+	mu      sync.RWMutex
+	entries map[string]cameraRoute
+}
+
+var cameraRoutes = &cameraRouteCache{entries: make(map[string]cameraRoute)}
+
+// invalidate drops all cached routes.
+func (c *cameraRouteCache) invalidate() {
+	c.mu.Lock()
+	c.entries = make(map[string]cameraRoute)
+	c.mu.Unlock()
+}
+
+// resolve returns routes for all MACs, querying the database once for cache misses.
+func (c *cameraRouteCache) resolve(tx *gorm.DB, macs []string) (map[string]cameraRoute, error) {
+	now := time.Now()
+	result := make(map[string]cameraRoute, len(macs))
+	var missing []string
+
+	c.mu.RLock()
+	for _, mac := range macs {
+		if _, seen := result[mac]; seen {
+			continue
+		}
+		if r, ok := c.entries[mac]; ok && now.Before(r.expires) {
+			result[mac] = r
+			continue
+		}
+		result[mac] = cameraRoute{}
+		missing = append(missing, mac)
+	}
+	c.mu.RUnlock()
+
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	var rows []cameraRoute
+	if err := tx.Table("camera c").
+		Select("c.id AS camera_id, c.mac, cia.auditorium_id").
+		Joins("LEFT JOIN camerasinauditorium cia ON cia.camera_id = c.id").
+		Where("c.mac IN ?", missing).
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to resolve cameras: %w", err)
+	}
+
+	expires := now.Add(cameraRouteTTL)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, mac := range missing {
+		// Unknown MACs are cached too, so a misconfigured camera does not cost a query per event.
+		c.entries[mac] = cameraRoute{Mac: mac, expires: expires}
+		result[mac] = c.entries[mac]
+	}
+	for _, r := range rows {
+		r.expires = expires
+		c.entries[r.Mac] = r
+		result[r.Mac] = r
+	}
+	return result, nil
+}
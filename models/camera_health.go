@@ -0,0 +1,229 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"web_backend_v2/db"
+	"web_backend_v2/forms"
+
+	"gorm.io/gorm"
+)
+
+// heartbeatSmoothing is the weight of the newest interval in the moving average.
+const heartbeatSmoothing = 0.2
+
+// HealthThresholds decides when a camera is considered degraded or offline.
+type HealthThresholds struct {
+	// DegradedAfter is the silence (or camera clock skew) after which a camera is degraded.
+	DegradedAfter time.Duration
+	// OfflineAfter is the silence after which a camera is offline.
+	OfflineAfter time.Duration
+}
+
+// cameraHealthRow is a camera joined with its heartbeat and location.
+type cameraHealthRow struct {
+	ID                 uint
+	Mac                string
+	AuditoriumID       *uint
+	BuildingID         *uint
+	CityID             *uint
+	LastSeenAt         *time.Time
+	LastEventAt        *time.Time
+	LastPersonCount    *int
+	EventsTotal        *int64
+	AvgIntervalSeconds *float64
+}
+
+// RecordHeartbeat notes that a camera with the given MAC sent an event.
+// Unknown MACs are ignored; attachment is not required.
+func (m *CameraModel) RecordHeartbeat(mac string, personCount int, eventTime, seenAt time.Time) error {
+	err := db.GetDB().Exec(`
+		INSERT INTO camerahealth (camera_id, last_seen_at, last_event_at, last_person_count, events_total)
+		SELECT id, $2::timestamptz, $3::timestamptz, $4::int, 1 FROM camera WHERE mac = $1
+		ON CONFLICT (camera_id) DO UPDATE SET
+			avg_interval_seconds = CASE
+				WHEN camerahealth.avg_interval_seconds IS NULL
+					THEN EXTRACT(epoch FROM EXCLUDED.last_seen_at - camerahealth.last_seen_at)
+				ELSE camerahealth.avg_interval_seconds * (1 - $5::float8)
+					+ EXTRACT(epoch FROM EXCLUDED.last_seen_at - camerahealth.last_seen_at) * $5::float8
+			END,
+			last_seen_at = EXCLUDED.last_seen_at,
+			last_event_at = EXCLUDED.last_event_at,
+			last_person_count = EXCLUDED.last_person_count,
+			events_total = camerahealth.events_total + 1
+	`, mac, seenAt.UTC(), eventTime.UTC(), personCount, heartbeatSmoothing).Error
+	if err != nil {
+		return fmt.Errorf("failed to record heartbeat for camera %s: %w", mac, err)
+	}
+	return nil
+}
+
+// Heartbeat is one camera event as seen by RecordHeartbeats.
+type Heartbeat struct {
+	Mac         string
+	PersonCount int
+	EventTime   time.Time
+	SeenAt      time.Time
+}
+
+// RecordHeartbeats records heartbeats of a batch of events with a single statement.
+// Several events of one camera count as several events; the moving average gets
+// one update with their mean interval.
+func (m *CameraModel) RecordHeartbeats(beats []Heartbeat) error {
+	type cameraBeat struct {
+		last Heartbeat
+		n    int
+	}
+	byMac := make(map[string]*cameraBeat)
+	order := make([]string, 0, len(beats))
+	for _, b := range beats {
+		cb, ok := byMac[b.Mac]
+		if !ok {
+			cb = &cameraBeat{}
+			byMac[b.Mac] = cb
+			order = append(order, b.Mac)
+		}
+		cb.n++
+		if !b.SeenAt.Before(cb.last.SeenAt) {
+			cb.last = b
+		}
+	}
+	if len(order) == 0 {
+		return nil
+	}
+
+	values := make([]string, 0, len(order))
+	args := make([]interface{}, 0, len(order)*5+1)
+	for _, mac := range order {
+		cb := byMac[mac]
+		values = append(values, "(?::text, ?::timestamptz, ?::timestamptz, ?::int, ?::int)")
+		args = append(args, mac, cb.last.SeenAt.UTC(), cb.last.EventTime.UTC(), cb.last.PersonCount, cb.n)
+	}
+	args = append(args, heartbeatSmoothing, heartbeatSmoothing)
+
+	err := db.GetDB().Exec(`
+		INSERT INTO camerahealth (camera_id, last_seen_at, last_event_at, last_person_count, events_total)
+		SELECT c.id, v.seen_at, v.event_at, v.person_count, v.n
+		FROM (VALUES `+strings.Join(values, ", ")+`) AS v(mac, seen_at, event_at, person_count, n), camera c
+		WHERE c.mac = v.mac
+		ON CONFLICT (camera_id) DO UPDATE SET
+			avg_interval_seconds = CASE
+				WHEN camerahealth.avg_interval_seconds IS NULL
+					THEN EXTRACT(epoch FROM EXCLUDED.last_seen_at - camerahealth.last_seen_at) / EXCLUDED.events_total
+				ELSE camerahealth.avg_interval_seconds * (1 - ?::float8)
+					+ EXTRACT(epoch FROM EXCLUDED.last_seen_at - camerahealth.last_seen_at) / EXCLUDED.events_total * ?::float8
+			END,
+			last_seen_at = EXCLUDED.last_seen_at,
+			last_event_at = EXCLUDED.last_event_at,
+			last_person_count = EXCLUDED.last_person_count,
+			events_total = camerahealth.events_total + EXCLUDED.events_total
+	`, args...).Error
+	if err != nil {
+		return fmt.Errorf("failed to record heartbeats of %d cameras: %w", len(order), err)
+	}
+	return nil
+}
+
+// GetCameraHealth returns the health of a single camera or gorm.ErrRecordNotFound.
+func (m *CameraModel) GetCameraHealth(cameraID uint, now time.Time, th HealthThresholds) (*forms.CameraHealthResponse, error) {
+	var row cameraHealthRow
+	tx := cameraHealthQuery().Where("c.id = ?", cameraID).Take(&row)
+	if tx.Error != nil {
+		if errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+			return nil, gorm.ErrRecordNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch camera health: %w", tx.Error)
+	}
+	resp := row.toResponse(now, th)
+	return &resp, nil
+}
+
+// ListCameraHealth returns health of all cameras, optionally filtered by status ("" = all).
+func (m *CameraModel) ListCameraHealth(status string, now time.Time, th HealthThresholds) ([]forms.CameraHealthResponse, error) {
+	var rows []cameraHealthRow
+	if err := cameraHealthQuery().Order("c.id").Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch camera health: %w", err)
+	}
+
+	result := make([]forms.CameraHealthResponse, 0, len(rows))
+	for i := range rows {
+		resp := rows[i].toResponse(now, th)
+		if status != "" && resp.Status != status {
+			continue
+		}
+		result = append(result, resp)
+	}
+	return result, nil
+}
+
+func cameraHealthQuery() *gorm.DB {
+	return db.GetDB().
+		Table("camera c").
+		Select(`c.id, c.mac, cia.auditorium_id, a.building_id, b.city_id,
+			h.last_seen_at, h.last_event_at, h.last_person_count, h.events_total, h.avg_interval_seconds`).
+		Joins("LEFT JOIN camerasinauditorium cia ON cia.camera_id = c.id").
+		Joins("LEFT JOIN auditorium a ON a.id = cia.auditorium_id").
+		Joins("LEFT JOIN building b ON b.id = a.building_id").
+		Joins("LEFT JOIN camerahealth h ON h.camera_id = c.id")
+}
+
+// toResponse classifies the camera:
+//   - offline: never seen or silent for longer than OfflineAfter;
+//   - degraded: silent for longer than DegradedAfter, or its clock is skewed by more than DegradedAfter;
+//   - ok otherwise.
+func (r *cameraHealthRow) toResponse(now time.Time, th HealthThresholds) forms.CameraHealthResponse {
+	resp := forms.CameraHealthResponse{
+		CameraID:        r.ID,
+		Mac:             r.Mac,
+		AuditoriumID:    r.AuditoriumID,
+		BuildingID:      r.BuildingID,
+		CityID:          r.CityID,
+		LastSeenAt:      r.LastSeenAt,
+		LastEventAt:     r.LastEventAt,
+		LastPersonCount: r.LastPersonCount,
+		Status:          forms.CameraStatusOK,
+	}
+	if r.EventsTotal != nil {
+		resp.EventsTotal = *r.EventsTotal
+	}
+	if r.AvgIntervalSeconds != nil && *r.AvgIntervalSeconds > 0 {
+		rate := 60 / *r.AvgIntervalSeconds
+		resp.EventsPerMinute = &rate
+	}
+
+	if r.LastSeenAt == nil {
+		resp.Status = forms.CameraStatusOffline
+		resp.Reasons = []string{"camera has never sent an event"}
+		return resp
+	}
+
+	silent := now.Sub(*r.LastSeenAt)
+	silentSeconds := silent.Seconds()
+	resp.SilentSeconds = &silentSeconds
+
+	switch {
+	case silent > th.OfflineAfter:
+		resp.Status = forms.CameraStatusOffline
+		resp.Reasons = append(resp.Reasons, fmt.Sprintf("no events for %.0f seconds (offline after %.0f)", silentSeconds, th.OfflineAfter.Seconds()))
+	case silent > th.DegradedAfter:
+		resp.Status = forms.CameraStatusDegraded
+		resp.Reasons = append(resp.Reasons, fmt.Sprintf("no events for %.0f seconds (degraded after %.0f)", silentSeconds, th.DegradedAfter.Seconds()))
+	}
+
+	if r.LastEventAt != nil {
+		skew := r.LastSeenAt.Sub(*r.LastEventAt)
+		if math.Abs(skew.Seconds()) > th.DegradedAfter.Seconds() {
+			if resp.Status == forms.CameraStatusOK {
+				resp.Status = forms.CameraStatusDegraded
+			}
+			resp.Reasons = append(resp.Reasons, fmt.Sprintf("camera clock is off by %.0f seconds", skew.Seconds()))
+		}
+	}
+	if r.AuditoriumID == nil {
+		resp.Reasons = append(resp.Reasons, "camera is not attached to an auditorium")
+	}
+	return resp
+}
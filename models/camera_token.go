@@ -0,0 +1,84 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"web_backend_v2/db"
+	"web_backend_v2/forms"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidCameraToken is returned when no camera has the presented token.
+var ErrInvalidCameraToken = errors.New("invalid camera token")
+
+// cameraTokenBytes is the amount of randomness in an issued token.
+const cameraTokenBytes = 32
+
+// IssueToken generates a new HTTP ingestion token for the camera, replacing the
+// previous one. Only its hash is stored, so the token cannot be shown again.
+func (m *CameraModel) IssueToken(cameraID uint) (*forms.Camera, string, error) {
+	token, hash, err := newCameraToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	var camera forms.Camera
+	if err := db.GetDB().Table("camera").Where("id = ?", cameraID).Take(&camera).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", gorm.ErrRecordNotFound
+		}
+		return nil, "", fmt.Errorf("failed to load camera %d: %w", cameraID, err)
+	}
+	if err := db.GetDB().Table("camera").Where("id = ?", cameraID).Update("token_hash", hash).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to store token for camera %d: %w", cameraID, err)
+	}
+	camera.TokenHash = &hash
+	return &camera, token, nil
+}
+
+// RevokeToken disables HTTP ingestion for the camera.
+func (m *CameraModel) RevokeToken(cameraID uint) error {
+	tx := db.GetDB().Table("camera").Where("id = ?", cameraID).Update("token_hash", nil)
+	if tx.Error != nil {
+		return fmt.Errorf("failed to revoke token of camera %d: %w", cameraID, tx.Error)
+	}
+	if tx.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// AuthenticateToken returns the camera the token was issued to or ErrInvalidCameraToken.
+func (m *CameraModel) AuthenticateToken(token string) (*forms.Camera, error) {
+	if token == "" {
+		return nil, ErrInvalidCameraToken
+	}
+	var camera forms.Camera
+	tx := db.GetDB().Table("camera").Where("token_hash = ?", hashCameraToken(token)).Take(&camera)
+	if tx.Error != nil {
+		if errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidCameraToken
+		}
+		return nil, fmt.Errorf("failed to authenticate camera: %w", tx.Error)
+	}
+	return &camera, nil
+}
+
+// newCameraToken generates a token and the hash to store for it.
+func newCameraToken() (token, hash string, err error) {
+	raw := make([]byte, cameraTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	token = hex.EncodeToString(raw)
+	return token, hashCameraToken(token), nil
+}
+
+func hashCameraToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,9 +1,12 @@
 package models
 
 import (
+	"errors"
 	"fmt"
 	"web_backend_v2/db"
 	"web_backend_v2/forms"
+
+	"gorm.io/gorm"
 )
 
 type CityModel struct{}
@@ -23,3 +26,62 @@ func (c *CityModel) GetCities() ([]forms.City, error) {
 
 	return cities, nil
 }
+
+// GetCity returns a single city or gorm.ErrRecordNotFound.
+func (c *CityModel) GetCity(cityID uint) (*forms.City, error) {
+	var city forms.City
+	result := db.GetDB().Table("city").Where("id = ?", cityID).First(&city)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, gorm.ErrRecordNotFound
+		}
+		return nil, fmt.Errorf("error fetching city %d: %w", cityID, result.Error)
+	}
+	return &city, nil
+}
+
+// CreateCity inserts a new city; the generated ID is written back into city.
+func (c *CityModel) CreateCity(city *forms.City) error {
+	city.ID = 0
+	if err := db.GetDB().Table("city").Create(city).Error; err != nil {
+		return fmt.Errorf("failed to create city: %w", err)
+	}
+	return nil
+}
+
+// UpdateCity overwrites all editable fields of an existing city.
+func (c *CityModel) UpdateCity(city *forms.City) error {
+	tx := db.GetDB().Table("city").Where("id = ?", city.ID).Updates(map[string]interface{}{
+		"name_ru": city.NameRU,
+		"name_en": city.NameEN,
+	})
+	if tx.Error != nil {
+		return fmt.Errorf("failed to update city %d: %w", city.ID, tx.Error)
+	}
+	if tx.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// CountBuildings returns how many buildings belong to the city.
+func (c *CityModel) CountBuildings(cityID uint) (int64, error) {
+	var count int64
+	if err := db.GetDB().Table("building").Where("city_id = ?", cityID).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count buildings for city %d: %w", cityID, err)
+	}
+	return count, nil
+}
+
+// DeleteCity removes a city; buildings, auditoriums and their data go via FK cascade.
+func (c *CityModel) DeleteCity(cityID uint) error {
+	tx := db.GetDB().Table("city").Where("id = ?", cityID).Delete(&forms.City{})
+	if tx.Error != nil {
+		return fmt.Errorf("failed to delete city %d: %w", cityID, tx.Error)
+	}
+	if tx.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	cameraRoutes.invalidate()
+	return nil
+}
@@ -0,0 +1,188 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+	"web_backend_v2/db"
+	"web_backend_v2/forms"
+)
+
+// ExportModel reads the rows of spreadsheet exports. Rows are passed to fn one
+// at a time straight from the database cursor; an error from fn stops the export
+// and is returned as is.
+type ExportModel struct{}
+
+// ExportBuildingOccupancy lists every auditorium of a building, ordered by floor
+// and number, with its latest reading at or before queryTimestamp.
+func (e *ExportModel) ExportBuildingOccupancy(buildingID uint, queryTimestamp time.Time, fn func(forms.AuditoriumOccupancyExportRow) error) error {
+	rows, err := db.GetDB().Raw(`
+		SELECT a.id, a.auditorium_number, a.floor_number, a.type, a.type_ru, a.capacity, o.person_count, o.timestamp
+		FROM auditorium a
+		LEFT JOIN LATERAL (
+			SELECT person_count, timestamp FROM occupancy
+			WHERE auditorium_id = a.id AND timestamp <= ?
+			ORDER BY timestamp DESC
+			LIMIT 1
+		) o ON true
+		WHERE a.building_id = ?
+		ORDER BY a.floor_number, a.auditorium_number, a.id`, queryTimestamp, buildingID).Rows()
+	if err != nil {
+		return fmt.Errorf("error exporting building occupancy: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			row         forms.AuditoriumOccupancyExportRow
+			personCount sql.NullInt64
+			timestamp   sql.NullTime
+		)
+		if err := rows.Scan(&row.AuditoriumID, &row.AuditoriumNumber, &row.FloorNumber,
+			&row.Type.EN, &row.Type.RU, &row.Capacity, &personCount, &timestamp); err != nil {
+			return fmt.Errorf("error exporting building occupancy: %w", err)
+		}
+		if personCount.Valid {
+			count := int(personCount.Int64)
+			row.PersonCount = &count
+			row.Timestamp = &timestamp.Time
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error exporting building occupancy: %w", err)
+	}
+	return nil
+}
+
+// ExportAuditoriumStats lists the hourly statistics of every day from fromDay to
+// toDay inclusive, with the same rules as GetAuditoriumStats: hours 9 to 21,
+// raw occupancy preferred over dailyload, 0 where there is no data.
+func (e *ExportModel) ExportAuditoriumStats(auditoriumID uint, fromDay, toDay time.Time, fn func(forms.HourlyStatsExportRow) error) error {
+	start := statsDay(fromDay)
+	end := statsDay(toDay).AddDate(0, 0, 1)
+
+	// prio orders raw averages before dailyload ones for the same hour; the
+	// filler keeps the first value of each hour.
+	rows, err := db.GetDB().Raw(`
+		SELECT day, hour, avg FROM (
+			SELECT day, hour, avg_person_count::float8 AS avg, 1 AS prio
+			FROM dailyload
+			WHERE auditorium_id = ? AND day >= ? AND day < ?
+			UNION ALL
+			SELECT timestamp::date, EXTRACT(hour FROM timestamp)::int, AVG(person_count)::float8, 0
+			FROM occupancy
+			WHERE auditorium_id = ? AND timestamp >= ? AND timestamp < ?
+			GROUP BY timestamp::date, EXTRACT(hour FROM timestamp)
+		) s
+		ORDER BY day, hour, prio`, auditoriumID, start, end, auditoriumID, start, end).Rows()
+	if err != nil {
+		return fmt.Errorf("error exporting auditorium statistics: %w", err)
+	}
+	defer rows.Close()
+
+	filler := newStatsFiller(start, end, fn)
+	for rows.Next() {
+		var (
+			day  time.Time
+			hour int
+			avg  float64
+		)
+		if err := rows.Scan(&day, &hour, &avg); err != nil {
+			return fmt.Errorf("error exporting auditorium statistics: %w", err)
+		}
+		if err := filler.add(day, hour, avg); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error exporting auditorium statistics: %w", err)
+	}
+	return filler.finish()
+}
+
+// ExportOccupancy lists the stored readings of all auditoriums of a building with
+// from <= timestamp < to, oldest first. Days already compacted into dailyload
+// have no raw readings left.
+func (e *ExportModel) ExportOccupancy(buildingID uint, from, to time.Time, fn func(forms.OccupancyExportRow) error) error {
+	rows, err := db.GetDB().Raw(`
+		SELECT o.timestamp, o.auditorium_id, a.auditorium_number, o.person_count, COALESCE(o.fusion_strategy, '')
+		FROM occupancy o
+		JOIN auditorium a ON a.id = o.auditorium_id
+		WHERE a.building_id = ? AND o.timestamp >= ? AND o.timestamp < ?
+		ORDER BY o.timestamp, o.auditorium_id`, buildingID, from, to).Rows()
+	if err != nil {
+		return fmt.Errorf("error exporting occupancy: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row forms.OccupancyExportRow
+		if err := rows.Scan(&row.Timestamp, &row.AuditoriumID, &row.AuditoriumNumber,
+			&row.PersonCount, &row.FusionStrategy); err != nil {
+			return fmt.Errorf("error exporting occupancy: %w", err)
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error exporting occupancy: %w", err)
+	}
+	return nil
+}
+
+// statsDay returns midnight UTC of the day of t.
+func statsDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// statsFiller turns (day, hour, avg) values ordered by day and hour into export
+// rows for hours 9 to 21 of every day in [start, end): values outside those
+// hours and repeated hours are dropped, missing hours are emitted as 0.
+type statsFiller struct {
+	next time.Time // the next hour to emit
+	end  time.Time
+	fn   func(forms.HourlyStatsExportRow) error
+}
+
+func newStatsFiller(start, end time.Time, fn func(forms.HourlyStatsExportRow) error) *statsFiller {
+	return &statsFiller{next: start.Add(9 * time.Hour), end: end, fn: fn}
+}
+
+func (f *statsFiller) add(day time.Time, hour int, avg float64) error {
+	at := statsDay(day).Add(time.Duration(hour) * time.Hour)
+	if hour < 9 || hour > 21 || at.Before(f.next) || !at.Before(f.end) {
+		return nil
+	}
+	if err := f.fillUntil(at); err != nil {
+		return err
+	}
+	return f.emit(avg)
+}
+
+func (f *statsFiller) finish() error {
+	return f.fillUntil(f.end)
+}
+
+func (f *statsFiller) fillUntil(at time.Time) error {
+	for f.next.Before(at) {
+		if err := f.emit(0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// emit writes the row of f.next and moves to the following hour.
+func (f *statsFiller) emit(avg float64) error {
+	row := forms.HourlyStatsExportRow{Day: statsDay(f.next), Hour: f.next.Hour(), AvgPersonCount: avg}
+	if f.next.Hour() >= 21 {
+		f.next = statsDay(f.next).AddDate(0, 0, 1).Add(9 * time.Hour)
+	} else {
+		f.next = f.next.Add(time.Hour)
+	}
+	return f.fn(row)
+}
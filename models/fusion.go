@@ -0,0 +1,109 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+	"web_backend_v2/forms"
+)
+
+// Multi-camera fusion strategies.
+const (
+	// FusionMax takes the highest count; suits cameras with overlapping views.
+	FusionMax = "max"
+	// FusionSum adds counts up; suits cameras watching disjoint zones.
+	FusionSum = "sum"
+	// FusionMedian takes the median count; suits 3+ cameras where one may misfire.
+	FusionMedian = "median"
+)
+
+// DefaultFusionWindow is used when no window is configured.
+const DefaultFusionWindow = 60 * time.Second
+
+// fuseCounts combines person counts of several cameras using the given strategy.
+func fuseCounts(strategy string, counts []int) (int, error) {
+	if len(counts) == 0 {
+		return 0, fmt.Errorf("no readings to fuse")
+	}
+
+	switch strategy {
+	case FusionMax:
+		result := counts[0]
+		for _, c := range counts[1:] {
+			if c > result {
+				result = c
+			}
+		}
+		return result, nil
+	case FusionSum:
+		result := 0
+		for _, c := range counts {
+			result += c
+		}
+		return result, nil
+	case FusionMedian:
+		sorted := append([]int(nil), counts...)
+		sort.Ints(sorted)
+		mid := len(sorted) / 2
+		if len(sorted)%2 == 1 {
+			return sorted[mid], nil
+		}
+		return int(math.Round(float64(sorted[mid-1]+sorted[mid]) / 2)), nil
+	default:
+		return 0, fmt.Errorf("unknown fusion strategy %q", strategy)
+	}
+}
+
+// fuseReadings combines the reading of one camera with the latest readings of the
+// other cameras in its auditorium. Readings of the same camera, and readings outside
+// [self.Timestamp-window, self.Timestamp], are ignored.
+func fuseReadings(strategy string, window time.Duration, self forms.CameraContribution, latest []forms.CameraContribution) (int, forms.CameraContributions, error) {
+	contributions := forms.CameraContributions{self}
+	for _, r := range latest {
+		if r.CameraID == self.CameraID {
+			continue
+		}
+		if r.Timestamp.Before(self.Timestamp.Add(-window)) || r.Timestamp.After(self.Timestamp) {
+			continue
+		}
+		contributions = append(contributions, r)
+	}
+
+	counts := make([]int, len(contributions))
+	for i, c := range contributions {
+		counts[i] = c.PersonCount
+	}
+	fused, err := fuseCounts(strategy, counts)
+	if err != nil {
+		return 0, nil, err
+	}
+	return fused, contributions, nil
+}
+
+// strategyFor returns the auditorium override, the model default or FusionMax.
+func (o *OccupancyModel) strategyFor(override *string) string {
+	if override != nil && *override != "" {
+		return *override
+	}
+	if o.FusionStrategy != "" {
+		return o.FusionStrategy
+	}
+	return FusionMax
+}
+
+// window returns the configured fusion window or DefaultFusionWindow.
+func (o *OccupancyModel) window() time.Duration {
+	if o.FusionWindow > 0 {
+		return o.FusionWindow
+	}
+	return DefaultFusionWindow
+}
+
+// derefString returns the pointed-to string or "" for nil (NULL columns).
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
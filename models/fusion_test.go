@@ -0,0 +1,126 @@
+package models
+
+import (
+	"testing"
+	"time"
+	"web_backend_v2/forms"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFuseCounts(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		counts   []int
+		want     int
+		wantErr  string
+	}{
+		{name: "max", strategy: FusionMax, counts: []int{4, 9, 7}, want: 9},
+		{name: "max of one", strategy: FusionMax, counts: []int{3}, want: 3},
+		{name: "sum", strategy: FusionSum, counts: []int{4, 9, 7}, want: 20},
+		{name: "median odd", strategy: FusionMedian, counts: []int{40, 2, 9}, want: 9},
+		{name: "median even rounds half up", strategy: FusionMedian, counts: []int{5, 2, 9, 1}, want: 4},
+		{name: "median even exact", strategy: FusionMedian, counts: []int{6, 2}, want: 4},
+		{name: "median of one", strategy: FusionMedian, counts: []int{5}, want: 5},
+		{name: "unknown strategy", strategy: "mean", counts: []int{1, 2}, wantErr: `unknown fusion strategy "mean"`},
+		{name: "empty strategy", strategy: "", counts: []int{1}, wantErr: `unknown fusion strategy ""`},
+		{name: "no readings", strategy: FusionMax, wantErr: "no readings to fuse"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counts := append([]int(nil), tt.counts...)
+			got, err := fuseCounts(tt.strategy, tt.counts)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, counts, tt.counts, "input left unsorted")
+		})
+	}
+}
+
+func TestFuseReadings(t *testing.T) {
+	now := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	reading := func(cameraID uint, count int, age time.Duration) forms.CameraContribution {
+		return forms.CameraContribution{CameraID: cameraID, PersonCount: count, Timestamp: now.Add(-age)}
+	}
+	self := reading(1, 10, 0)
+
+	tests := []struct {
+		name        string
+		strategy    string
+		latest      []forms.CameraContribution
+		want        int
+		wantCameras []uint
+		wantErr     string
+	}{
+		{
+			name:        "alone",
+			strategy:    FusionSum,
+			want:        10,
+			wantCameras: []uint{1},
+		},
+		{
+			name:        "other cameras within the window",
+			strategy:    FusionSum,
+			latest:      []forms.CameraContribution{reading(2, 5, 30*time.Second), reading(3, 1, time.Minute)},
+			want:        16,
+			wantCameras: []uint{1, 2, 3},
+		},
+		{
+			name:        "older than the window",
+			strategy:    FusionSum,
+			latest:      []forms.CameraContribution{reading(2, 5, time.Minute+time.Second)},
+			want:        10,
+			wantCameras: []uint{1},
+		},
+		{
+			name:        "newer than the reading",
+			strategy:    FusionMax,
+			latest:      []forms.CameraContribution{reading(2, 50, -time.Second)},
+			want:        10,
+			wantCameras: []uint{1},
+		},
+		{
+			name:        "previous reading of the same camera",
+			strategy:    FusionSum,
+			latest:      []forms.CameraContribution{reading(1, 7, 10*time.Second), reading(2, 2, 0)},
+			want:        12,
+			wantCameras: []uint{1, 2},
+		},
+		{
+			name:        "median of an even number of cameras",
+			strategy:    FusionMedian,
+			latest:      []forms.CameraContribution{reading(2, 3, 0), reading(3, 13, 0), reading(4, 30, 0)},
+			want:        12,
+			wantCameras: []uint{1, 2, 3, 4},
+		},
+		{
+			name:     "unknown strategy",
+			strategy: "avg",
+			latest:   []forms.CameraContribution{reading(2, 3, 0)},
+			wantErr:  `unknown fusion strategy "avg"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, contributions, err := fuseReadings(tt.strategy, DefaultFusionWindow, self, tt.latest)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				assert.Nil(t, contributions)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			var cameras []uint
+			for _, c := range contributions {
+				cameras = append(cameras, c.CameraID)
+			}
+			assert.Equal(t, tt.wantCameras, cameras)
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"web_backend_v2/db"
+	"web_backend_v2/forms"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ImportModel applies parsed CSV imports to the database.
+type ImportModel struct{}
+
+// ImportBuildings creates or updates the buildings of a city, matching existing
+// ones by address_en. Every row is checked before anything is written; with row
+// errors, or on a dry run, nothing is written. Returns gorm.ErrRecordNotFound
+// when the city does not exist.
+func (m *ImportModel) ImportBuildings(cityID uint, imp *forms.BuildingImport, dryRun bool) (*forms.ImportResult, error) {
+	result := newImportResult(imp.Total, imp.Errors, dryRun)
+	err := db.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := lockCity(tx, cityID); err != nil {
+			return err
+		}
+
+		var existing []forms.Building
+		if err := tx.Table("building").Where("city_id = ?", cityID).Find(&existing).Error; err != nil {
+			return fmt.Errorf("failed to load buildings: %w", err)
+		}
+		var floors []struct {
+			BuildingID uint
+			MaxFloor   int
+		}
+		if err := tx.Table("auditorium a").
+			Select("a.building_id, MAX(a.floor_number) AS max_floor").
+			Joins("JOIN building b ON b.id = a.building_id").
+			Where("b.city_id = ?", cityID).
+			Group("a.building_id").
+			Scan(&floors).Error; err != nil {
+			return fmt.Errorf("failed to check auditorium floors: %w", err)
+		}
+		maxFloors := make(map[uint]int, len(floors))
+		for _, f := range floors {
+			maxFloors[f.BuildingID] = f.MaxFloor
+		}
+
+		changes := planBuildingImport(cityID, existing, maxFloors, imp.Rows, result)
+		if dryRun || len(result.Errors) > 0 {
+			return nil
+		}
+		for _, c := range changes {
+			if c.create {
+				if err := tx.Table("building").Create(&c.building).Error; err != nil {
+					return fmt.Errorf("row %d: failed to create building: %w", c.row, err)
+				}
+				continue
+			}
+			if err := tx.Table("building").Where("id = ?", c.building.ID).Updates(map[string]interface{}{
+				"address_ru":  c.building.AddressRU,
+				"floor_count": c.building.FloorCount,
+			}).Error; err != nil {
+				return fmt.Errorf("row %d: failed to update building %d: %w", c.row, c.building.ID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return finishImport(result), nil
+}
+
+// ImportAuditoriums creates or updates auditoriums of the city's buildings,
+// matching buildings by address_en and auditoriums by auditorium_number within
+// the building. All-or-nothing like ImportBuildings.
+func (m *ImportModel) ImportAuditoriums(cityID uint, imp *forms.AuditoriumImport, dryRun bool) (*forms.ImportResult, error) {
+	result := newImportResult(imp.Total, imp.Errors, dryRun)
+	err := db.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := lockCity(tx, cityID); err != nil {
+			return err
+		}
+
+		var buildings []forms.Building
+		if err := tx.Table("building").Where("city_id = ?", cityID).Find(&buildings).Error; err != nil {
+			return fmt.Errorf("failed to load buildings: %w", err)
+		}
+		var existing []forms.Auditorium
+		if err := tx.Table("auditorium a").
+			Select("a.*").
+			Joins("JOIN building b ON b.id = a.building_id").
+			Where("b.city_id = ?", cityID).
+			Find(&existing).Error; err != nil {
+			return fmt.Errorf("failed to load auditoriums: %w", err)
+		}
+
+		changes := planAuditoriumImport(buildings, existing, imp.Rows, result)
+		if dryRun || len(result.Errors) > 0 {
+			return nil
+		}
+		for _, c := range changes {
+			if c.create {
+				if err := tx.Table("auditorium").Create(&c.auditorium).Error; err != nil {
+					return fmt.Errorf("row %d: failed to create auditorium: %w", c.row, err)
+				}
+				continue
+			}
+			if err := tx.Table("auditorium").Where("id = ?", c.auditorium.ID).Updates(map[string]interface{}{
+				"floor_number":    c.auditorium.FloorNumber,
+				"capacity":        c.auditorium.Capacity,
+				"type":            c.auditorium.Type,
+				"type_ru":         c.auditorium.TypeRU,
+				"image_url":       c.auditorium.ImageURL,
+				"fusion_strategy": c.auditorium.FusionStrategy,
+			}).Error; err != nil {
+				return fmt.Errorf("row %d: failed to update auditorium %d: %w", c.row, c.auditorium.ID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return finishImport(result), nil
+}
+
+// lockCity locks the city row so that concurrent imports into one city run one
+// after another. Returns gorm.ErrRecordNotFound when the city does not exist.
+func lockCity(tx *gorm.DB, cityID uint) error {
+	var city forms.City
+	err := tx.Table("city").
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", cityID).
+		First(&city).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock city %d: %w", cityID, err)
+	}
+	return nil
+}
+
+func newImportResult(total int, parseErrors []forms.ImportRowError, dryRun bool) *forms.ImportResult {
+	return &forms.ImportResult{
+		DryRun: dryRun,
+		Rows:   total,
+		Errors: append([]forms.ImportRowError{}, parseErrors...),
+	}
+}
+
+// finishImport marks the result applied when it was neither a dry run nor had errors.
+func finishImport(result *forms.ImportResult) *forms.ImportResult {
+	result.SortErrors()
+	result.Applied = !result.DryRun && len(result.Errors) == 0
+	return result
+}
+
+type buildingChange struct {
+	row      int
+	building forms.Building
+	create   bool
+}
+
+// planBuildingImport decides what each row does to the existing buildings of the
+// city and counts it in result; rows that cannot be applied become row errors.
+// maxFloors holds the highest auditorium floor of each building.
+func planBuildingImport(cityID uint, existing []forms.Building, maxFloors map[uint]int, rows []forms.BuildingImportRow, result *forms.ImportResult) []buildingChange {
+	byAddress := make(map[string]forms.Building, len(existing))
+	for _, b := range existing {
+		byAddress[b.AddressEN] = b
+	}
+
+	seen := make(map[string]int, len(rows))
+	changes := make([]buildingChange, 0, len(rows))
+	for _, r := range rows {
+		building := r.Building.ToBuilding(0, cityID)
+		if first, ok := seen[building.AddressEN]; ok {
+			result.Errors = append(result.Errors, forms.ImportRowError{
+				Row: r.Row, Column: "address_en", Error: fmt.Sprintf("same building as row %d", first),
+			})
+			continue
+		}
+		seen[building.AddressEN] = r.Row
+
+		current, ok := byAddress[building.AddressEN]
+		if !ok {
+			result.Created++
+			changes = append(changes, buildingChange{row: r.Row, building: building, create: true})
+			continue
+		}
+		building.ID = current.ID
+		if building == current {
+			result.Unchanged++
+			continue
+		}
+		if maxFloor, ok := maxFloors[current.ID]; ok && maxFloor > building.FloorCount {
+			result.Errors = append(result.Errors, forms.ImportRowError{
+				Row: r.Row, Column: "floors_count",
+				Error: fmt.Sprintf("auditoriums exist on floor %d, floors_count %d is too low", maxFloor, building.FloorCount),
+			})
+			continue
+		}
+		result.Updated++
+		changes = append(changes, buildingChange{row: r.Row, building: building})
+	}
+	return changes
+}
+
+type auditoriumChange struct {
+	row        int
+	auditorium forms.Auditorium
+	create     bool
+}
+
+type auditoriumKey struct {
+	buildingID uint
+	number     string
+}
+
+// planAuditoriumImport is planBuildingImport for auditoriums of the city's buildings.
+func planAuditoriumImport(buildings []forms.Building, existing []forms.Auditorium, rows []forms.AuditoriumImportRow, result *forms.ImportResult) []auditoriumChange {
+	byAddress := make(map[string]forms.Building, len(buildings))
+	for _, b := range buildings {
+		byAddress[b.AddressEN] = b
+	}
+	byKey := make(map[auditoriumKey]forms.Auditorium, len(existing))
+	for _, a := range existing {
+		byKey[auditoriumKey{a.BuildingID, a.AuditoriumNumber}] = a
+	}
+
+	seen := make(map[auditoriumKey]int, len(rows))
+	changes := make([]auditoriumChange, 0, len(rows))
+	for _, r := range rows {
+		building, ok := byAddress[r.BuildingAddressEN]
+		if !ok {
+			result.Errors = append(result.Errors, forms.ImportRowError{
+				Row: r.Row, Column: "building_address_en", Error: "no building with this address_en in the city",
+			})
+			continue
+		}
+		auditorium := r.Auditorium.ToAuditorium(0, building.ID)
+		if err := checkFloor(auditorium.FloorNumber, building.FloorCount); err != nil {
+			result.Errors = append(result.Errors, forms.ImportRowError{Row: r.Row, Column: "floor_number", Error: err.Error()})
+			continue
+		}
+		key := auditoriumKey{building.ID, auditorium.AuditoriumNumber}
+		if first, ok := seen[key]; ok {
+			result.Errors = append(result.Errors, forms.ImportRowError{
+				Row: r.Row, Column: "auditorium_number", Error: fmt.Sprintf("same auditorium as row %d", first),
+			})
+			continue
+		}
+		seen[key] = r.Row
+
+		current, ok := byKey[key]
+		if !ok {
+			result.Created++
+			changes = append(changes, auditoriumChange{row: r.Row, auditorium: auditorium, create: true})
+			continue
+		}
+		auditorium.ID = current.ID
+		if sameAuditorium(&current, &auditorium) {
+			result.Unchanged++
+			continue
+		}
+		result.Updated++
+		changes = append(changes, auditoriumChange{row: r.Row, auditorium: auditorium})
+	}
+	return changes
+}
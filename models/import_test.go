@@ -0,0 +1,180 @@
+package models
+
+import (
+	"testing"
+	"web_backend_v2/forms"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanBuildingImport(t *testing.T) {
+	existing := []forms.Building{
+		{ID: 1, CityID: 7, AddressRU: "ул. Ленина, 1", AddressEN: "1 Lenina St", FloorCount: 5},
+		{ID: 2, CityID: 7, AddressRU: "пр. Мира, 2", AddressEN: "2 Mira Ave", FloorCount: 4},
+	}
+	maxFloors := map[uint]int{1: 4, 2: 4}
+	row := func(n int, ru, en string, floors int) forms.BuildingImportRow {
+		return forms.BuildingImportRow{Row: n, Building: forms.BuildingRequest{
+			Address: forms.LocalizedStringRequest{RU: ru, EN: en}, FloorsCount: floors,
+		}}
+	}
+
+	tests := []struct {
+		name        string
+		rows        []forms.BuildingImportRow
+		want        forms.ImportResult
+		wantChanges []buildingChange
+	}{
+		{
+			name: "create, update and unchanged",
+			rows: []forms.BuildingImportRow{
+				row(2, "ул. Новая, 3", "3 Novaya St", 2),
+				row(3, "ул. Ленина, д. 1", "1 Lenina St", 6),
+				row(4, "пр. Мира, 2", "2 Mira Ave", 4),
+			},
+			want: forms.ImportResult{Created: 1, Updated: 1, Unchanged: 1},
+			wantChanges: []buildingChange{
+				{row: 2, create: true, building: forms.Building{CityID: 7, AddressRU: "ул. Новая, 3", AddressEN: "3 Novaya St", FloorCount: 2}},
+				{row: 3, building: forms.Building{ID: 1, CityID: 7, AddressRU: "ул. Ленина, д. 1", AddressEN: "1 Lenina St", FloorCount: 6}},
+			},
+		},
+		{
+			name: "duplicate rows",
+			rows: []forms.BuildingImportRow{
+				row(2, "ул. Новая, 3", "3 Novaya St", 2),
+				row(3, "Новая 3", "3 Novaya St", 3),
+			},
+			want: forms.ImportResult{Created: 1, Errors: []forms.ImportRowError{
+				{Row: 3, Column: "address_en", Error: "same building as row 2"},
+			}},
+			wantChanges: []buildingChange{
+				{row: 2, create: true, building: forms.Building{CityID: 7, AddressRU: "ул. Новая, 3", AddressEN: "3 Novaya St", FloorCount: 2}},
+			},
+		},
+		{
+			name: "floors_count below the highest auditorium",
+			rows: []forms.BuildingImportRow{
+				row(2, "ул. Ленина, 1", "1 Lenina St", 3),
+				row(3, "пр. Мира, 2", "2 Mira Ave", 4),
+			},
+			want: forms.ImportResult{Unchanged: 1, Errors: []forms.ImportRowError{
+				{Row: 2, Column: "floors_count", Error: "auditoriums exist on floor 4, floors_count 3 is too low"},
+			}},
+			wantChanges: []buildingChange{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := newImportResult(len(tt.rows), nil, false)
+			changes := planBuildingImport(7, existing, maxFloors, tt.rows, result)
+			assert.Equal(t, tt.wantChanges, changes)
+			assert.Equal(t, tt.want.Created, result.Created)
+			assert.Equal(t, tt.want.Updated, result.Updated)
+			assert.Equal(t, tt.want.Unchanged, result.Unchanged)
+			assert.Equal(t, tt.want.Errors, nilIfEmpty(result.Errors))
+		})
+	}
+}
+
+func TestPlanAuditoriumImport(t *testing.T) {
+	buildings := []forms.Building{
+		{ID: 1, CityID: 7, AddressEN: "1 Lenina St", FloorCount: 3},
+		{ID: 2, CityID: 7, AddressEN: "2 Mira Ave", FloorCount: 1},
+	}
+	existing := []forms.Auditorium{
+		{ID: 10, BuildingID: 1, FloorNumber: 1, Capacity: 30, AuditoriumNumber: "101", Type: "classroom", TypeRU: "учебная"},
+		{ID: 11, BuildingID: 1, FloorNumber: 2, Capacity: 80, AuditoriumNumber: "201", Type: "lecture_hall", TypeRU: "лекционная"},
+	}
+	row := func(n int, address, number string, floor, capacity int, kind string) forms.AuditoriumImportRow {
+		return forms.AuditoriumImportRow{Row: n, BuildingAddressEN: address, Auditorium: forms.AuditoriumRequest{
+			FloorNumber: floor, Capacity: capacity, AuditoriumNumber: number, Type: kind,
+		}}
+	}
+
+	tests := []struct {
+		name        string
+		rows        []forms.AuditoriumImportRow
+		want        forms.ImportResult
+		wantChanges []auditoriumChange
+	}{
+		{
+			name: "create, update and unchanged",
+			rows: []forms.AuditoriumImportRow{
+				row(2, "1 Lenina St", "101", 1, 30, "classroom"),
+				row(3, "1 Lenina St", "201", 2, 90, "lecture_hall"),
+				row(4, "2 Mira Ave", "101", -2, 20, "coworking"),
+			},
+			want: forms.ImportResult{Created: 1, Updated: 1, Unchanged: 1},
+			wantChanges: []auditoriumChange{
+				{row: 3, auditorium: forms.Auditorium{ID: 11, BuildingID: 1, FloorNumber: 2, Capacity: 90, AuditoriumNumber: "201", Type: "lecture_hall", TypeRU: "лекционная"}},
+				{row: 4, create: true, auditorium: forms.Auditorium{BuildingID: 2, FloorNumber: -2, Capacity: 20, AuditoriumNumber: "101", Type: "coworking", TypeRU: "коворкинг"}},
+			},
+		},
+		{
+			name: "row errors",
+			rows: []forms.AuditoriumImportRow{
+				row(2, "9 Unknown St", "1", 1, 10, "classroom"),
+				row(3, "2 Mira Ave", "102", 2, 10, "classroom"),
+				row(4, "2 Mira Ave", "103", 0, 10, "classroom"),
+				row(5, "1 Lenina St", "301", 3, 10, "classroom"),
+				row(6, "1 Lenina St", "301", 3, 12, "classroom"),
+			},
+			want: forms.ImportResult{Created: 1, Errors: []forms.ImportRowError{
+				{Row: 2, Column: "building_address_en", Error: "no building with this address_en in the city"},
+				{Row: 3, Column: "floor_number", Error: "floor_number is out of the building's floor range: got 2, allowed 1..1 or a negative basement level"},
+				{Row: 4, Column: "floor_number", Error: "floor_number is out of the building's floor range: got 0, allowed 1..1 or a negative basement level"},
+				{Row: 6, Column: "auditorium_number", Error: "same auditorium as row 5"},
+			}},
+			wantChanges: []auditoriumChange{
+				{row: 5, create: true, auditorium: forms.Auditorium{BuildingID: 1, FloorNumber: 3, Capacity: 10, AuditoriumNumber: "301", Type: "classroom", TypeRU: "учебная"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := newImportResult(len(tt.rows), nil, false)
+			changes := planAuditoriumImport(buildings, existing, tt.rows, result)
+			assert.Equal(t, tt.wantChanges, changes)
+			assert.Equal(t, tt.want.Created, result.Created)
+			assert.Equal(t, tt.want.Updated, result.Updated)
+			assert.Equal(t, tt.want.Unchanged, result.Unchanged)
+			assert.Equal(t, tt.want.Errors, nilIfEmpty(result.Errors))
+		})
+	}
+}
+
+func TestMemoryImportIsAllOrNothing(t *testing.T) {
+	m := NewMemoryStore(FusionMax, DefaultFusionWindow)
+	city := forms.City{NameRU: "Москва", NameEN: "Moscow"}
+	assert.NoError(t, m.CreateCity(&city))
+	rows := []forms.BuildingImportRow{
+		{Row: 2, Building: forms.BuildingRequest{Address: forms.LocalizedStringRequest{RU: "а", EN: "a"}, FloorsCount: 2}},
+	}
+	parseErrors := []forms.ImportRowError{{Row: 3, Column: "floors_count", Error: "must be an integer"}}
+
+	result, err := m.ImportBuildings(city.ID, &forms.BuildingImport{Total: 2, Rows: rows, Errors: parseErrors}, false)
+	assert.NoError(t, err)
+	assert.False(t, result.Applied)
+	assert.Equal(t, 1, result.Created, "counted, not written")
+	assert.Equal(t, parseErrors, result.Errors)
+
+	result, err = m.ImportBuildings(city.ID, &forms.BuildingImport{Total: 1, Rows: rows}, true)
+	assert.NoError(t, err)
+	assert.False(t, result.Applied)
+	assert.True(t, result.DryRun)
+	buildings, _ := m.GetBuildingsByCity(city.ID)
+	assert.Empty(t, buildings)
+
+	result, err = m.ImportBuildings(city.ID, &forms.BuildingImport{Total: 1, Rows: rows}, false)
+	assert.NoError(t, err)
+	assert.True(t, result.Applied)
+	buildings, _ = m.GetBuildingsByCity(city.ID)
+	assert.Len(t, buildings, 1)
+}
+
+func nilIfEmpty(errs []forms.ImportRowError) []forms.ImportRowError {
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
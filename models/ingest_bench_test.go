@@ -0,0 +1,167 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+	"web_backend_v2/config"
+	"web_backend_v2/db"
+	"web_backend_v2/forms"
+
+	"gorm.io/gorm/logger"
+)
+
+// Ingestion throughput, per event (SaveEvent) against batched (SaveEvents):
+//
+//	go test ./models -run '^$' -bench Ingest -benchmem
+//
+// The Postgres benchmarks need INGEST_BENCH_DB=1, the DB_* settings of the
+// service and at least one attached camera; their rows are removed afterwards.
+
+// benchBatchSize matches the INGEST_BATCH_SIZE default.
+const benchBatchSize = 200
+
+// benchEpoch is where synthetic events are placed, far from real data, so that
+// fusion ignores real readings and real readings are not overwritten.
+var benchEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func BenchmarkIngestMemory(b *testing.B) {
+	mem := NewMemoryStore(FusionMax, DefaultFusionWindow)
+	macs := memoryBenchCameras(b, mem, 8)
+	events := newBenchEvents(macs)
+
+	b.Run("single", func(b *testing.B) { benchSingle(b, mem, events) })
+	b.Run("batch", func(b *testing.B) { benchBatch(b, mem, events) })
+}
+
+func BenchmarkIngestPostgres(b *testing.B) {
+	if os.Getenv("INGEST_BENCH_DB") != "1" {
+		b.Skip("set INGEST_BENCH_DB=1 and DB_* to benchmark against PostgreSQL")
+	}
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := db.InitDB(cfg, false); err != nil {
+		b.Fatal(err)
+	}
+	defer db.CloseDB()
+	// Per-query logging would dominate the measurement.
+	db.DB.Logger = db.DB.Logger.LogMode(logger.Warn)
+
+	cameras, err := new(CameraModel).GetAttachedCameras()
+	if err != nil {
+		b.Fatal(err)
+	}
+	if len(cameras) == 0 {
+		b.Skip("no attached cameras: attach at least one camera to an auditorium first")
+	}
+	macs := make([]string, len(cameras))
+	for i := range cameras {
+		macs[i] = cameras[i].Mac
+	}
+	events := newBenchEvents(macs)
+	defer events.cleanup(b)
+
+	occupancy := &OccupancyModel{FusionStrategy: cfg.Fusion.Strategy, FusionWindow: cfg.Fusion.Window}
+	b.Run("single", func(b *testing.B) { benchSingle(b, occupancy, events) })
+	b.Run("single_no_route_cache", func(b *testing.B) {
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			cameraRoutes.invalidate()
+			if err := occupancy.SaveEvent(events.next()); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("batch", func(b *testing.B) { benchBatch(b, occupancy, events) })
+}
+
+func benchSingle(b *testing.B, store OccupancyStore, events *benchEvents) {
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := store.SaveEvent(events.next()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchBatch(b *testing.B, store OccupancyStore, events *benchEvents) {
+	b.ResetTimer()
+	for done := 0; done < b.N; done += benchBatchSize {
+		batch := make([]*forms.CameraEvent, min(benchBatchSize, b.N-done))
+		for i := range batch {
+			batch[i] = events.next()
+		}
+		results, err := store.SaveEvents(batch)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, err := range results {
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// benchEvents hands out events spread over the cameras, one millisecond apart
+// and never repeated, so that deduplication does not skip any.
+type benchEvents struct {
+	macs []string
+	seq  int
+}
+
+func newBenchEvents(macs []string) *benchEvents {
+	return &benchEvents{macs: macs}
+}
+
+func (e *benchEvents) next() *forms.CameraEvent {
+	personCount := e.seq % 50
+	event := &forms.CameraEvent{
+		IDCamera:    e.macs[e.seq%len(e.macs)],
+		Timestamp:   benchEpoch.Add(time.Duration(e.seq) * time.Millisecond),
+		PersonCount: &personCount,
+	}
+	e.seq++
+	return event
+}
+
+// cleanup removes the rows written to PostgreSQL.
+func (e *benchEvents) cleanup(b *testing.B) {
+	end := benchEpoch.Add(time.Duration(e.seq) * time.Millisecond)
+	for _, table := range []string{"occupancy", "camerareading"} {
+		if err := db.GetDB().Exec(`DELETE FROM `+table+` WHERE timestamp >= ? AND timestamp < ?`, benchEpoch, end).Error; err != nil {
+			b.Errorf("cleanup %s: %v", table, err)
+		}
+	}
+}
+
+// memoryBenchCameras attaches n cameras to one auditorium of mem.
+func memoryBenchCameras(b *testing.B, mem *MemoryStore, n int) []string {
+	city := forms.City{NameRU: "Город", NameEN: "City"}
+	if err := mem.CreateCity(&city); err != nil {
+		b.Fatal(err)
+	}
+	building := forms.Building{CityID: city.ID, AddressRU: "Адрес", AddressEN: "Address", FloorCount: 1}
+	if err := mem.CreateBuilding(&building); err != nil {
+		b.Fatal(err)
+	}
+	auditorium := forms.Auditorium{BuildingID: building.ID, FloorNumber: 1, Capacity: 100, AuditoriumNumber: "1", Type: "classroom"}
+	if err := mem.CreateAuditorium(&auditorium); err != nil {
+		b.Fatal(err)
+	}
+	macs := make([]string, n)
+	for i := range macs {
+		camera, err := mem.CreateCamera(fmt.Sprintf("AA:BB:CC:00:00:%02X", i))
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := mem.AttachCameraToAuditorium(camera.ID, auditorium.ID); err != nil {
+			b.Fatal(err)
+		}
+		macs[i] = camera.Mac
+	}
+	return macs
+}
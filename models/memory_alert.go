@@ -0,0 +1,185 @@
+package models
+
+import (
+	"math"
+	"sort"
+	"time"
+	"web_backend_v2/forms"
+
+	"gorm.io/gorm"
+)
+
+// EvaluateAlerts is AlertModel.EvaluateAlerts in memory.
+func (m *MemoryStore) EvaluateAlerts(now time.Time, rules AlertRules) (int, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var silences []cameraSilence
+	for _, cameraID := range sortedIDs(m.assignments) {
+		s := cameraSilence{CameraID: cameraID, AuditoriumID: m.assignments[cameraID]}
+		if h, ok := m.health[cameraID]; ok {
+			lastEventAt := h.LastEventAt
+			s.LastEventAt = &lastEventAt
+		}
+		silences = append(silences, s)
+	}
+	var loads []auditoriumLoad
+	var jumps []auditoriumJump
+	for _, id := range sortedIDs(m.auditoriums) {
+		capacity := m.auditoriums[id].Capacity
+		if o, ok := m.latestOccupancy(id, now); ok && o.Timestamp.After(now.Add(-rules.StaleAfter)) {
+			loads = append(loads, auditoriumLoad{AuditoriumID: id, Capacity: capacity, PersonCount: o.PersonCount, Timestamp: o.Timestamp})
+		}
+		if j, ok := m.largestJump(id, now.Add(-rules.SpikeWindow), now); ok {
+			j.Capacity = capacity
+			jumps = append(jumps, j)
+		}
+	}
+
+	var firing []forms.Alert
+	for _, alert := range m.alerts {
+		if alert.Status == forms.AlertFiring {
+			firing = append(firing, alert)
+		}
+	}
+	changes := diffAlerts(firing, alertConditions(now, rules, silences, loads, jumps), now)
+
+	for _, alert := range changes.raised {
+		alert.ID = uint64(m.nextID("alert"))
+		m.alerts = append(m.alerts, alert)
+	}
+	updated := make(map[uint64]string, len(changes.updated))
+	for _, alert := range changes.updated {
+		updated[alert.ID] = alert.Message
+	}
+	resolved := make(map[uint64]bool, len(changes.resolved))
+	for _, id := range changes.resolved {
+		resolved[id] = true
+	}
+	for i := range m.alerts {
+		alert := &m.alerts[i]
+		if message, ok := updated[alert.ID]; ok {
+			alert.Message, alert.UpdatedAt = message, now
+		}
+		if resolved[alert.ID] {
+			resolvedAt := now
+			alert.Status, alert.ResolvedAt, alert.UpdatedAt = forms.AlertResolved, &resolvedAt, now
+		}
+	}
+	return len(changes.raised), len(changes.resolved), nil
+}
+
+// largestJump finds the largest change between consecutive readings of an
+// auditorium in (from, to], the latest one on ties; the caller holds mu.
+func (m *MemoryStore) largestJump(auditoriumID uint, from, to time.Time) (auditoriumJump, bool) {
+	var readings []forms.Occupancy
+	for _, o := range m.occupancy {
+		if o.AuditoriumID == auditoriumID && o.Timestamp.After(from) && !o.Timestamp.After(to) {
+			readings = append(readings, o)
+		}
+	}
+	sort.SliceStable(readings, func(i, j int) bool { return readings[i].Timestamp.Before(readings[j].Timestamp) })
+
+	var jump auditoriumJump
+	found := false
+	for i := 1; i < len(readings); i++ {
+		prev, cur := readings[i-1].PersonCount, readings[i].PersonCount
+		if !found || math.Abs(float64(cur-prev)) >= math.Abs(float64(jump.PersonCount-jump.PrevCount)) {
+			jump = auditoriumJump{AuditoriumID: auditoriumID, PrevCount: prev, PersonCount: cur, Timestamp: readings[i].Timestamp}
+			found = true
+		}
+	}
+	return jump, found
+}
+
+func (m *MemoryStore) GetAlerts(q forms.AlertQuery) ([]forms.AlertResponse, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	alerts := make([]forms.Alert, 0, len(m.alerts))
+	for _, alert := range m.alerts {
+		cityID, buildingID := m.alertLocation(alert)
+		switch {
+		case q.Status != "" && alert.Status != q.Status,
+			q.Kind != "" && alert.Kind != q.Kind,
+			q.CityID != 0 && cityID != q.CityID,
+			q.BuildingID != 0 && buildingID != q.BuildingID,
+			q.AuditoriumID != 0 && alert.AuditoriumID != q.AuditoriumID,
+			q.CameraID != 0 && (alert.CameraID == nil || *alert.CameraID != q.CameraID),
+			(len(q.ScopeCityIDs) > 0 || len(q.ScopeBuildingIDs) > 0) && !containsUint(q.ScopeCityIDs, cityID) && !containsUint(q.ScopeBuildingIDs, buildingID),
+			q.Acknowledged != nil && (alert.AcknowledgedAt != nil) != *q.Acknowledged:
+			continue
+		}
+		alerts = append(alerts, alert)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if !alerts[i].StartedAt.Equal(alerts[j].StartedAt) {
+			return alerts[i].StartedAt.After(alerts[j].StartedAt)
+		}
+		return alerts[i].ID > alerts[j].ID
+	})
+	if q.Limit > 0 && len(alerts) > q.Limit {
+		alerts = alerts[:q.Limit]
+	}
+
+	result := make([]forms.AlertResponse, 0, len(alerts))
+	for i := range alerts {
+		cityID, buildingID := m.alertLocation(alerts[i])
+		result = append(result, alerts[i].ToAlertResponse(cityID, buildingID))
+	}
+	return result, nil
+}
+
+func (m *MemoryStore) GetAlert(alertID uint64) (*forms.AlertResponse, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for i := range m.alerts {
+		if m.alerts[i].ID == alertID {
+			cityID, buildingID := m.alertLocation(m.alerts[i])
+			resp := m.alerts[i].ToAlertResponse(cityID, buildingID)
+			return &resp, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *MemoryStore) AcknowledgeAlert(alertID uint64, note string, at time.Time) (*forms.AlertResponse, error) {
+	m.mu.Lock()
+	for i := range m.alerts {
+		alert := &m.alerts[i]
+		if alert.ID == alertID && alert.AcknowledgedAt == nil {
+			alert.AcknowledgedAt = &at
+			if note != "" {
+				alert.AckNote = &note
+			}
+		}
+	}
+	m.mu.Unlock()
+	return m.GetAlert(alertID)
+}
+
+// alertLocation returns the city and building of an alert's auditorium; the
+// caller holds mu.
+func (m *MemoryStore) alertLocation(alert forms.Alert) (uint, uint) {
+	buildingID := m.auditoriums[alert.AuditoriumID].BuildingID
+	return m.buildings[buildingID].CityID, buildingID
+}
+
+// deleteAlerts removes the alerts matching fn; the caller holds mu.
+func (m *MemoryStore) deleteAlerts(fn func(forms.Alert) bool) {
+	kept := m.alerts[:0]
+	for _, alert := range m.alerts {
+		if !fn(alert) {
+			kept = append(kept, alert)
+		}
+	}
+	m.alerts = kept
+}
+
+func containsUint(ids []uint, id uint) bool {
+	for _, have := range ids {
+		if have == id {
+			return true
+		}
+	}
+	return false
+}
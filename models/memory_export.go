@@ -0,0 +1,96 @@
+package models
+
+import (
+	"sort"
+	"time"
+	"web_backend_v2/forms"
+)
+
+// ExportBuildingOccupancy is ExportModel.ExportBuildingOccupancy in memory. The
+// rows are collected under the lock and passed to fn after it is released.
+func (m *MemoryStore) ExportBuildingOccupancy(buildingID uint, queryTimestamp time.Time, fn func(forms.AuditoriumOccupancyExportRow) error) error {
+	m.mu.RLock()
+	var rows []forms.AuditoriumOccupancyExportRow
+	for _, id := range sortedIDs(m.auditoriums) {
+		a := m.auditoriums[id]
+		if a.BuildingID != buildingID {
+			continue
+		}
+		row := forms.AuditoriumOccupancyExportRow{
+			AuditoriumID:     a.ID,
+			AuditoriumNumber: a.AuditoriumNumber,
+			FloorNumber:      a.FloorNumber,
+			Type:             forms.LocalizedString{RU: a.TypeRU, EN: a.Type},
+			Capacity:         a.Capacity,
+		}
+		if o, ok := m.latestOccupancy(id, queryTimestamp); ok {
+			row.PersonCount, row.Timestamp = &o.PersonCount, &o.Timestamp
+		}
+		rows = append(rows, row)
+	}
+	m.mu.RUnlock()
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].FloorNumber != rows[j].FloorNumber {
+			return rows[i].FloorNumber < rows[j].FloorNumber
+		}
+		return rows[i].AuditoriumNumber < rows[j].AuditoriumNumber
+	})
+	for _, row := range rows {
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportAuditoriumStats is ExportModel.ExportAuditoriumStats from raw occupancy,
+// computed one day at a time.
+func (m *MemoryStore) ExportAuditoriumStats(auditoriumID uint, fromDay, toDay time.Time, fn func(forms.HourlyStatsExportRow) error) error {
+	end := statsDay(toDay).AddDate(0, 0, 1)
+	for day := statsDay(fromDay); day.Before(end); day = day.AddDate(0, 0, 1) {
+		stats, _, err := m.GetAuditoriumStats(auditoriumID, day)
+		if err != nil {
+			return err
+		}
+		for _, s := range stats {
+			if err := fn(forms.HourlyStatsExportRow{Day: day, Hour: s.Hour, AvgPersonCount: s.AvgPersonCount}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ExportOccupancy is ExportModel.ExportOccupancy in memory.
+func (m *MemoryStore) ExportOccupancy(buildingID uint, from, to time.Time, fn func(forms.OccupancyExportRow) error) error {
+	m.mu.RLock()
+	var rows []forms.OccupancyExportRow
+	for _, o := range m.occupancy {
+		a, ok := m.auditoriums[o.AuditoriumID]
+		if !ok || a.BuildingID != buildingID || o.Timestamp.Before(from) || !o.Timestamp.Before(to) {
+			continue
+		}
+		rows = append(rows, forms.OccupancyExportRow{
+			Timestamp:        o.Timestamp,
+			AuditoriumID:     o.AuditoriumID,
+			AuditoriumNumber: a.AuditoriumNumber,
+			PersonCount:      o.PersonCount,
+			FusionStrategy:   derefString(o.FusionStrategy),
+		})
+	}
+	m.mu.RUnlock()
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if !rows[i].Timestamp.Equal(rows[j].Timestamp) {
+			return rows[i].Timestamp.Before(rows[j].Timestamp)
+		}
+		return rows[i].AuditoriumID < rows[j].AuditoriumID
+	})
+	for _, row := range rows {
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
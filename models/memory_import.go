@@ -0,0 +1,77 @@
+package models
+
+import (
+	"web_backend_v2/forms"
+
+	"gorm.io/gorm"
+)
+
+// ImportBuildings is ImportModel.ImportBuildings for the in-memory store.
+func (m *MemoryStore) ImportBuildings(cityID uint, imp *forms.BuildingImport, dryRun bool) (*forms.ImportResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.cities[cityID]; !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var existing []forms.Building
+	for _, b := range m.buildings {
+		if b.CityID == cityID {
+			existing = append(existing, b)
+		}
+	}
+	maxFloors := make(map[uint]int)
+	for _, a := range m.auditoriums {
+		if floor, ok := maxFloors[a.BuildingID]; !ok || a.FloorNumber > floor {
+			maxFloors[a.BuildingID] = a.FloorNumber
+		}
+	}
+
+	result := newImportResult(imp.Total, imp.Errors, dryRun)
+	changes := planBuildingImport(cityID, existing, maxFloors, imp.Rows, result)
+	if !dryRun && len(result.Errors) == 0 {
+		for _, c := range changes {
+			if c.create {
+				c.building.ID = m.nextID("building")
+			}
+			m.buildings[c.building.ID] = c.building
+		}
+	}
+	return finishImport(result), nil
+}
+
+// ImportAuditoriums is ImportModel.ImportAuditoriums for the in-memory store.
+func (m *MemoryStore) ImportAuditoriums(cityID uint, imp *forms.AuditoriumImport, dryRun bool) (*forms.ImportResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.cities[cityID]; !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var buildings []forms.Building
+	inCity := make(map[uint]bool)
+	for _, b := range m.buildings {
+		if b.CityID == cityID {
+			buildings = append(buildings, b)
+			inCity[b.ID] = true
+		}
+	}
+	var existing []forms.Auditorium
+	for _, a := range m.auditoriums {
+		if inCity[a.BuildingID] {
+			existing = append(existing, a)
+		}
+	}
+
+	result := newImportResult(imp.Total, imp.Errors, dryRun)
+	changes := planAuditoriumImport(buildings, existing, imp.Rows, result)
+	if !dryRun && len(result.Errors) == 0 {
+		for _, c := range changes {
+			if c.create {
+				c.auditorium.ID = m.nextID("auditorium")
+			}
+			m.auditoriums[c.auditorium.ID] = c.auditorium
+		}
+	}
+	return finishImport(result), nil
+}
@@ -0,0 +1,335 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"time"
+	"web_backend_v2/forms"
+
+	"gorm.io/gorm"
+)
+
+// SaveEvent fuses and stores a camera event with the same rules as OccupancyModel.SaveEvent.
+func (m *MemoryStore) SaveEvent(event *forms.CameraEvent) error {
+	if event == nil {
+		return fmt.Errorf("camera event is nil")
+	}
+	m.mu.Lock()
+	update, err := m.saveEvent(event)
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	m.fusion.notify([]forms.OccupancyUpdate{update})
+	return nil
+}
+
+// SaveEvents stores events one by one in the given order; the batch as a whole never fails.
+func (m *MemoryStore) SaveEvents(events []*forms.CameraEvent) ([]error, error) {
+	results := make([]error, len(events))
+	var updates []forms.OccupancyUpdate
+	m.mu.Lock()
+	for i, event := range events {
+		if event == nil {
+			results[i] = fmt.Errorf("camera event is nil")
+			continue
+		}
+		update, err := m.saveEvent(event)
+		if err != nil {
+			results[i] = err
+			continue
+		}
+		updates = append(updates, update)
+	}
+	m.mu.Unlock()
+	m.fusion.notify(updates)
+	return results, nil
+}
+
+// OnStored registers a listener like OccupancyModel.OnStored; it is called
+// after mu is released.
+func (m *MemoryStore) OnStored(fn func(forms.OccupancyUpdate)) {
+	m.fusion.OnStored(fn)
+}
+
+// saveEvent stores one event and describes the stored row; the caller holds mu.
+func (m *MemoryStore) saveEvent(event *forms.CameraEvent) (forms.OccupancyUpdate, error) {
+	camera, ok := m.cameraByMac(event.IDCamera)
+	if !ok {
+		return forms.OccupancyUpdate{}, ErrCameraNotFound
+	}
+	auditoriumID, ok := m.assignments[camera.ID]
+	if !ok {
+		return forms.OccupancyUpdate{}, ErrCameraNotAttached
+	}
+	key := event.DedupKey()
+	if m.eventKeys[key] {
+		return forms.OccupancyUpdate{}, ErrDuplicateEvent
+	}
+
+	strategy := m.fusion.strategyFor(m.auditoriums[auditoriumID].FusionStrategy)
+	var latest []forms.CameraContribution
+	for cameraID, audID := range m.assignments {
+		if audID != auditoriumID {
+			continue
+		}
+		if r, ok := m.readings[cameraID]; ok {
+			latest = append(latest, forms.CameraContribution{
+				CameraID:    cameraID,
+				Mac:         m.cameras[cameraID].Mac,
+				PersonCount: r.PersonCount,
+				Timestamp:   r.Timestamp,
+			})
+		}
+	}
+	sort.Slice(latest, func(i, j int) bool { return latest[i].CameraID < latest[j].CameraID })
+
+	self := forms.CameraContribution{
+		CameraID:    camera.ID,
+		Mac:         camera.Mac,
+		PersonCount: *event.PersonCount,
+		Timestamp:   event.Timestamp.UTC(),
+	}
+	fused, contributions, err := fuseReadings(strategy, m.fusion.window(), self, latest)
+	if err != nil {
+		return forms.OccupancyUpdate{}, err
+	}
+
+	if r, ok := m.readings[camera.ID]; !ok || !self.Timestamp.Before(r.Timestamp) {
+		m.readings[camera.ID] = forms.CameraReading{
+			CameraID:    camera.ID,
+			PersonCount: self.PersonCount,
+			Timestamp:   self.Timestamp,
+		}
+	}
+	m.eventKeys[key] = true
+	record := forms.Occupancy{
+		ID:             m.nextID("occupancy"),
+		AuditoriumID:   auditoriumID,
+		PersonCount:    fused,
+		Timestamp:      self.Timestamp,
+		FusionStrategy: &strategy,
+		Contributions:  contributions,
+		EventKey:       &key,
+	}
+	m.occupancy = append(m.occupancy, record)
+	buildingID := m.auditoriums[auditoriumID].BuildingID
+	return occupancyUpdate(m.buildings[buildingID].CityID, buildingID, &record), nil
+}
+
+// latestOccupancy returns the newest row of an auditorium at or before ts; the caller holds mu.
+func (m *MemoryStore) latestOccupancy(auditoriumID uint, ts time.Time) (forms.Occupancy, bool) {
+	var latest forms.Occupancy
+	found := false
+	for _, o := range m.occupancy {
+		if o.AuditoriumID != auditoriumID || o.Timestamp.After(ts) {
+			continue
+		}
+		if !found || o.Timestamp.After(latest.Timestamp) {
+			latest, found = o, true
+		}
+	}
+	return latest, found
+}
+
+func (m *MemoryStore) GetLatestOccupancyByBuilding(buildingID uint, queryTimestamp time.Time, maxTimeDiffMinutes int) ([]forms.AuditoriumOccupancyResponse, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var responses []forms.AuditoriumOccupancyResponse
+	for _, id := range sortedIDs(m.auditoriums) {
+		if m.auditoriums[id].BuildingID != buildingID {
+			continue
+		}
+		if o, ok := m.latestOccupancy(id, queryTimestamp); ok {
+			responses = append(responses, occupancyResponse(o.AuditoriumID, o.PersonCount, o.Timestamp,
+				o.FusionStrategy, o.Contributions, queryTimestamp, maxTimeDiffMinutes))
+		}
+	}
+	if len(responses) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return responses, nil
+}
+
+func (m *MemoryStore) GetLatestOccupancyForAuditorium(auditoriumID uint, queryTimestamp time.Time, maxTimeDiffMinutes int) (*forms.AuditoriumOccupancyResponse, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	o, ok := m.latestOccupancy(auditoriumID, queryTimestamp)
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	resp := occupancyResponse(o.AuditoriumID, o.PersonCount, o.Timestamp,
+		o.FusionStrategy, o.Contributions, queryTimestamp, maxTimeDiffMinutes)
+	return &resp, nil
+}
+
+// GetAuditoriumStats averages raw occupancy per UTC hour of the day.
+func (m *MemoryStore) GetAuditoriumStats(auditoriumID uint, day time.Time) ([]forms.HourlyStatsResponse, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.auditoriumStats(auditoriumID, day)
+}
+
+// auditoriumStats is GetAuditoriumStats for a caller holding mu.
+func (m *MemoryStore) auditoriumStats(auditoriumID uint, day time.Time) ([]forms.HourlyStatsResponse, bool, error) {
+	startOfDay := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	endOfDay := startOfDay.AddDate(0, 0, 1)
+
+	sums := make(map[int]float64)
+	counts := make(map[int]int)
+	for _, o := range m.occupancy {
+		ts := o.Timestamp.UTC()
+		if o.AuditoriumID != auditoriumID || ts.Before(startOfDay) || !ts.Before(endOfDay) {
+			continue
+		}
+		sums[ts.Hour()] += float64(o.PersonCount)
+		counts[ts.Hour()]++
+	}
+
+	statsMap := make(map[int]float64)
+	for h, n := range counts {
+		if h >= 9 && h <= 21 {
+			statsMap[h] = sums[h] / float64(n)
+		}
+	}
+	return hourlyStats(statsMap), len(counts) == 0, nil
+}
+
+func (m *MemoryStore) GetAuditoriumOccupancyRate(auditoriumID uint, day time.Time) ([]forms.HourlyRateStatsResponse, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	capacity := m.auditoriums[auditoriumID].Capacity
+	if capacity <= 0 {
+		return nil, false, ErrCapacityNotSet
+	}
+	stats, noData, err := m.auditoriumStats(auditoriumID, day)
+	if err != nil {
+		return nil, false, err
+	}
+	return occupancyRates(stats, capacity), noData, nil
+}
+
+// GetOccupancySeries buckets raw occupancy like AuditoryModel.GetOccupancySeries;
+// all points have source "occupancy".
+func (m *MemoryStore) GetOccupancySeries(auditoriumID uint, from, to time.Time, bucket time.Duration) ([]forms.OccupancySeriesPoint, error) {
+	from, to = from.UTC(), to.UTC()
+	bucketSeconds := int64(bucket / time.Second)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	byStart := make(map[int64]*forms.OccupancySeriesPoint)
+	sums := make(map[int64]float64)
+	for _, o := range m.occupancy {
+		ts := o.Timestamp.UTC()
+		if o.AuditoriumID != auditoriumID || ts.Before(from) || !ts.Before(to) {
+			continue
+		}
+		key := ts.Unix() / bucketSeconds * bucketSeconds
+		start := time.Unix(key, 0).UTC()
+		p, ok := byStart[key]
+		if !ok {
+			minCount, maxCount := o.PersonCount, o.PersonCount
+			p = &forms.OccupancySeriesPoint{
+				BucketStart: start,
+				BucketEnd:   start.Add(bucket),
+				Min:         &minCount,
+				Max:         &maxCount,
+				Source:      SeriesSourceOccupancy,
+			}
+			byStart[key] = p
+		}
+		if o.PersonCount < *p.Min {
+			*p.Min = o.PersonCount
+		}
+		if o.PersonCount > *p.Max {
+			*p.Max = o.PersonCount
+		}
+		p.SampleCount++
+		sums[key] += float64(o.PersonCount)
+	}
+
+	points := make([]forms.OccupancySeriesPoint, 0, len(byStart))
+	for key, p := range byStart {
+		p.Avg = sums[key] / float64(p.SampleCount)
+		points = append(points, *p)
+	}
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].BucketStart.Before(points[j].BucketStart)
+	})
+	return points, nil
+}
+
+// FindFreeAuditoriums is AuditoryModel.FindFreeAuditoriums in memory.
+func (m *MemoryStore) FindFreeAuditoriums(cityID uint, q forms.FreeAuditoriumsQuery, maxTimeDiffMinutes int) ([]forms.FreeAuditoriumResponse, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var rows []freeAuditoriumRow
+	for _, id := range sortedIDs(m.auditoriums) {
+		a := m.auditoriums[id]
+		b, ok := m.buildings[a.BuildingID]
+		if !ok || b.CityID != cityID || (q.BuildingID != 0 && b.ID != q.BuildingID) ||
+			(q.Type != "" && a.Type != q.Type) || (q.Floor != nil && a.FloorNumber != *q.Floor) {
+			continue
+		}
+		row := freeAuditoriumRow{
+			AuditoriumID:     a.ID,
+			AuditoriumNumber: a.AuditoriumNumber,
+			FloorNumber:      a.FloorNumber,
+			Type:             a.Type,
+			TypeRU:           a.TypeRU,
+			Capacity:         a.Capacity,
+			ImageURL:         a.ImageURL,
+			BuildingID:       b.ID,
+			CityID:           b.CityID,
+			AddressRU:        b.AddressRU,
+			AddressEN:        b.AddressEN,
+			FloorCount:       b.FloorCount,
+		}
+		if o, ok := m.latestOccupancy(id, q.At); ok {
+			row.PersonCount, row.Timestamp = &o.PersonCount, &o.Timestamp
+		}
+		rows = append(rows, row)
+	}
+	return rankFreeAuditoriums(rows, q, maxTimeDiffMinutes), nil
+}
+
+// GetBuildingOccupancySummary is AuditoryModel.GetBuildingOccupancySummary in memory.
+func (m *MemoryStore) GetBuildingOccupancySummary(building *forms.Building, queryTimestamp time.Time, maxTimeDiffMinutes int) (*forms.BuildingOccupancySummary, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	readings := m.latestReadings(func(b forms.Building) bool { return b.ID == building.ID }, queryTimestamp)
+	summaries := summarizeOccupancy([]forms.Building{*building}, readings, queryTimestamp, maxTimeDiffMinutes)
+	return &summaries[0], nil
+}
+
+// GetCityOccupancySummary is AuditoryModel.GetCityOccupancySummary in memory.
+func (m *MemoryStore) GetCityOccupancySummary(cityID uint, queryTimestamp time.Time, maxTimeDiffMinutes int) (*forms.CityOccupancySummary, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var buildings []forms.Building
+	for _, id := range sortedIDs(m.buildings) {
+		if m.buildings[id].CityID == cityID {
+			buildings = append(buildings, m.buildings[id])
+		}
+	}
+	readings := m.latestReadings(func(b forms.Building) bool { return b.CityID == cityID }, queryTimestamp)
+	return cityOccupancySummary(cityID, buildings, readings, queryTimestamp, maxTimeDiffMinutes), nil
+}
+
+// latestReadings lists the auditoriums of the buildings matching scope with their
+// latest reading up to queryTimestamp; the caller holds mu.
+func (m *MemoryStore) latestReadings(scope func(forms.Building) bool, queryTimestamp time.Time) []roomReading {
+	var readings []roomReading
+	for _, id := range sortedIDs(m.auditoriums) {
+		a := m.auditoriums[id]
+		if b, ok := m.buildings[a.BuildingID]; !ok || !scope(b) {
+			continue
+		}
+		r := roomReading{AuditoriumID: a.ID, BuildingID: a.BuildingID, FloorNumber: a.FloorNumber, Capacity: a.Capacity}
+		if o, ok := m.latestOccupancy(id, queryTimestamp); ok {
+			r.PersonCount, r.Timestamp = &o.PersonCount, &o.Timestamp
+		}
+		readings = append(readings, r)
+	}
+	return readings
+}
@@ -0,0 +1,627 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+	"web_backend_v2/forms"
+
+	"gorm.io/gorm"
+)
+
+// MemoryStore keeps cities, buildings, auditoriums, cameras and occupancy in memory
+// and implements every store interface, so the HTTP API can run without PostgreSQL
+// (handler tests, local experiments). Deletes cascade like the FK constraints do.
+// There is no daily aggregation: statistics and series are computed from raw
+// occupancy only.
+type MemoryStore struct {
+	fusion OccupancyModel
+
+	mu          sync.RWMutex
+	lastID      map[string]uint
+	cities      map[uint]forms.City
+	buildings   map[uint]forms.Building
+	auditoriums map[uint]forms.Auditorium
+	cameras     map[uint]forms.Camera
+	assignments map[uint]uint // camera id -> auditorium id
+	readings    map[uint]forms.CameraReading
+	health      map[uint]forms.CameraHealth
+	occupancy   []forms.Occupancy
+	eventKeys   map[string]bool
+	webhooks    map[uint]forms.Webhook
+	hookStates  map[[2]uint]forms.WebhookState // [webhook id, auditorium id]
+	deliveries  []forms.WebhookDelivery
+	alerts      []forms.Alert
+}
+
+var (
+	_ CityStore       = (*MemoryStore)(nil)
+	_ BuildingStore   = (*MemoryStore)(nil)
+	_ AuditoriumStore = (*MemoryStore)(nil)
+	_ CameraStore     = (*MemoryStore)(nil)
+	_ OccupancyStore  = (*MemoryStore)(nil)
+	_ ImportStore     = (*MemoryStore)(nil)
+	_ ExportStore     = (*MemoryStore)(nil)
+	_ WebhookStore    = (*MemoryStore)(nil)
+	_ AlertStore      = (*MemoryStore)(nil)
+)
+
+// NewMemoryStore creates an empty store; fusion settings mean the same as in OccupancyModel.
+func NewMemoryStore(fusionStrategy string, fusionWindow time.Duration) *MemoryStore {
+	return &MemoryStore{
+		fusion:      OccupancyModel{FusionStrategy: fusionStrategy, FusionWindow: fusionWindow},
+		lastID:      make(map[string]uint),
+		cities:      make(map[uint]forms.City),
+		buildings:   make(map[uint]forms.Building),
+		auditoriums: make(map[uint]forms.Auditorium),
+		cameras:     make(map[uint]forms.Camera),
+		assignments: make(map[uint]uint),
+		readings:    make(map[uint]forms.CameraReading),
+		health:      make(map[uint]forms.CameraHealth),
+		eventKeys:   make(map[string]bool),
+		webhooks:    make(map[uint]forms.Webhook),
+		hookStates:  make(map[[2]uint]forms.WebhookState),
+	}
+}
+
+// nextID returns the next value of a per-table sequence; the caller holds mu.
+func (m *MemoryStore) nextID(table string) uint {
+	m.lastID[table]++
+	return m.lastID[table]
+}
+
+// sortedIDs returns the keys of a map in ascending order.
+func sortedIDs[T any](items map[uint]T) []uint {
+	ids := make([]uint, 0, len(items))
+	for id := range items {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func (m *MemoryStore) GetCities() ([]forms.City, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	cities := make([]forms.City, 0, len(m.cities))
+	for _, id := range sortedIDs(m.cities) {
+		cities = append(cities, m.cities[id])
+	}
+	return cities, nil
+}
+
+func (m *MemoryStore) GetCity(cityID uint) (*forms.City, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	city, ok := m.cities[cityID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &city, nil
+}
+
+func (m *MemoryStore) CreateCity(city *forms.City) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	city.ID = m.nextID("city")
+	m.cities[city.ID] = *city
+	return nil
+}
+
+func (m *MemoryStore) UpdateCity(city *forms.City) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.cities[city.ID]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	m.cities[city.ID] = *city
+	return nil
+}
+
+func (m *MemoryStore) CountBuildings(cityID uint) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var count int64
+	for _, b := range m.buildings {
+		if b.CityID == cityID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *MemoryStore) DeleteCity(cityID uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.cities[cityID]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	for id, b := range m.buildings {
+		if b.CityID == cityID {
+			m.deleteBuilding(id)
+		}
+	}
+	delete(m.cities, cityID)
+	return nil
+}
+
+func (m *MemoryStore) GetBuildingsByCity(cityID uint) ([]forms.Building, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var buildings []forms.Building
+	for _, id := range sortedIDs(m.buildings) {
+		if m.buildings[id].CityID == cityID {
+			buildings = append(buildings, m.buildings[id])
+		}
+	}
+	if len(buildings) < 1 {
+		return nil, fmt.Errorf("building  with cityID:%d does not exist", cityID)
+	}
+	return buildings, nil
+}
+
+func (m *MemoryStore) GetBuilding(cityID, buildingID uint) (*forms.Building, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	building, ok := m.buildings[buildingID]
+	if !ok || building.CityID != cityID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &building, nil
+}
+
+func (m *MemoryStore) CreateBuilding(building *forms.Building) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.cities[building.CityID]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	building.ID = m.nextID("building")
+	m.buildings[building.ID] = *building
+	return nil
+}
+
+func (m *MemoryStore) UpdateBuilding(building *forms.Building) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, a := range m.auditoriums {
+		if a.BuildingID == building.ID && a.FloorNumber > building.FloorCount {
+			return fmt.Errorf("%w: auditoriums exist on floor %d, floor_count %d is too low",
+				ErrFloorOutOfRange, a.FloorNumber, building.FloorCount)
+		}
+	}
+	existing, ok := m.buildings[building.ID]
+	if !ok || existing.CityID != building.CityID {
+		return gorm.ErrRecordNotFound
+	}
+	m.buildings[building.ID] = *building
+	return nil
+}
+
+func (m *MemoryStore) GetBuildingCities(buildingIDs []uint) (map[uint]uint, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	cities := make(map[uint]uint, len(buildingIDs))
+	for _, id := range buildingIDs {
+		if building, ok := m.buildings[id]; ok {
+			cities[id] = building.CityID
+		}
+	}
+	return cities, nil
+}
+
+func (m *MemoryStore) CountAuditoriums(buildingID uint) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var count int64
+	for _, a := range m.auditoriums {
+		if a.BuildingID == buildingID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *MemoryStore) DeleteBuilding(cityID, buildingID uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	building, ok := m.buildings[buildingID]
+	if !ok || building.CityID != cityID {
+		return gorm.ErrRecordNotFound
+	}
+	m.deleteBuilding(buildingID)
+	return nil
+}
+
+// deleteBuilding removes a building with its auditoriums and webhooks; the
+// caller holds mu.
+func (m *MemoryStore) deleteBuilding(buildingID uint) {
+	for id, a := range m.auditoriums {
+		if a.BuildingID == buildingID {
+			m.deleteAuditorium(id)
+		}
+	}
+	for id, hook := range m.webhooks {
+		if hook.BuildingID == buildingID {
+			m.deleteWebhook(id)
+		}
+	}
+	delete(m.buildings, buildingID)
+}
+
+func (m *MemoryStore) Exists(auditoriumID uint) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.auditoriums[auditoriumID]
+	return ok, nil
+}
+
+func (m *MemoryStore) GetAuditoriumLocations(auditoriumIDs []uint) (map[uint]AuditoriumLocation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	locations := make(map[uint]AuditoriumLocation, len(auditoriumIDs))
+	for _, id := range auditoriumIDs {
+		if auditorium, ok := m.auditoriums[id]; ok {
+			locations[id] = AuditoriumLocation{
+				AuditoriumID: id,
+				BuildingID:   auditorium.BuildingID,
+				CityID:       m.buildings[auditorium.BuildingID].CityID,
+			}
+		}
+	}
+	return locations, nil
+}
+
+func (m *MemoryStore) GetAuditoriumsByBuilding(buildingID uint) ([]forms.Auditorium, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var auditoriums []forms.Auditorium
+	for _, id := range sortedIDs(m.auditoriums) {
+		if m.auditoriums[id].BuildingID == buildingID {
+			auditoriums = append(auditoriums, m.auditoriums[id])
+		}
+	}
+	return auditoriums, nil
+}
+
+func (m *MemoryStore) GetAuditorium(buildingID, auditoriumID uint) (*forms.Auditorium, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	auditorium, ok := m.auditoriums[auditoriumID]
+	if !ok || auditorium.BuildingID != buildingID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &auditorium, nil
+}
+
+func (m *MemoryStore) CreateAuditorium(auditorium *forms.Auditorium) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	building, ok := m.buildings[auditorium.BuildingID]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	if err := checkFloor(auditorium.FloorNumber, building.FloorCount); err != nil {
+		return err
+	}
+	auditorium.ID = m.nextID("auditorium")
+	m.auditoriums[auditorium.ID] = *auditorium
+	return nil
+}
+
+func (m *MemoryStore) UpdateAuditorium(auditorium *forms.Auditorium) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	building, ok := m.buildings[auditorium.BuildingID]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	if err := checkFloor(auditorium.FloorNumber, building.FloorCount); err != nil {
+		return err
+	}
+	existing, ok := m.auditoriums[auditorium.ID]
+	if !ok || existing.BuildingID != auditorium.BuildingID {
+		return gorm.ErrRecordNotFound
+	}
+	m.auditoriums[auditorium.ID] = *auditorium
+	return nil
+}
+
+func (m *MemoryStore) DeleteAuditorium(buildingID, auditoriumID uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	auditorium, ok := m.auditoriums[auditoriumID]
+	if !ok || auditorium.BuildingID != buildingID {
+		return gorm.ErrRecordNotFound
+	}
+	m.deleteAuditorium(auditoriumID)
+	return nil
+}
+
+// deleteAuditorium removes an auditorium with its camera attachments,
+// occupancy, webhooks and alerts; the caller holds mu.
+func (m *MemoryStore) deleteAuditorium(auditoriumID uint) {
+	m.deleteAlerts(func(a forms.Alert) bool { return a.AuditoriumID == auditoriumID })
+	for id, hook := range m.webhooks {
+		if hook.AuditoriumID != nil && *hook.AuditoriumID == auditoriumID {
+			m.deleteWebhook(id)
+		}
+	}
+	for key := range m.hookStates {
+		if key[1] == auditoriumID {
+			delete(m.hookStates, key)
+		}
+	}
+	for cameraID, audID := range m.assignments {
+		if audID == auditoriumID {
+			delete(m.assignments, cameraID)
+		}
+	}
+	kept := m.occupancy[:0]
+	for _, o := range m.occupancy {
+		if o.AuditoriumID == auditoriumID {
+			if o.EventKey != nil {
+				delete(m.eventKeys, *o.EventKey)
+			}
+			continue
+		}
+		kept = append(kept, o)
+	}
+	m.occupancy = kept
+	delete(m.auditoriums, auditoriumID)
+}
+
+func (m *MemoryStore) CreateCamera(mac string) (*forms.Camera, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range m.cameras {
+		if c.Mac == mac {
+			return nil, fmt.Errorf("failed to create camera: mac %s already exists", mac)
+		}
+	}
+	camera := forms.Camera{ID: m.nextID("camera"), Mac: mac}
+	m.cameras[camera.ID] = camera
+	return &camera, nil
+}
+
+func (m *MemoryStore) GetCameraWithAssignment(id uint) (*CameraWithAssignment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	camera, ok := m.cameras[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	cam := m.withAssignment(camera)
+	return &cam, nil
+}
+
+// withAssignment adds the camera's auditorium, if any; the caller holds mu.
+func (m *MemoryStore) withAssignment(camera forms.Camera) CameraWithAssignment {
+	cam := CameraWithAssignment{ID: camera.ID, Mac: camera.Mac}
+	if auditoriumID, ok := m.assignments[camera.ID]; ok {
+		cam.AuditoriumID = &auditoriumID
+	}
+	return cam
+}
+
+func (m *MemoryStore) GetCameraByMac(mac string) (*CameraWithAssignment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	camera, ok := m.cameraByMac(mac)
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	cam := m.withAssignment(camera)
+	return &cam, nil
+}
+
+func (m *MemoryStore) GetCamerasByAuditorium(auditoriumID uint) ([]forms.Camera, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var cams []forms.Camera
+	for _, id := range sortedIDs(m.cameras) {
+		if audID, ok := m.assignments[id]; ok && audID == auditoriumID {
+			cams = append(cams, m.cameras[id])
+		}
+	}
+	return cams, nil
+}
+
+func (m *MemoryStore) GetFreeCameras() ([]forms.Camera, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var cams []forms.Camera
+	for _, id := range sortedIDs(m.cameras) {
+		if _, attached := m.assignments[id]; !attached {
+			cams = append(cams, m.cameras[id])
+		}
+	}
+	return cams, nil
+}
+
+func (m *MemoryStore) GetAttachedCameras() ([]CameraWithAssignment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var cams []CameraWithAssignment
+	for _, id := range sortedIDs(m.cameras) {
+		if _, attached := m.assignments[id]; attached {
+			cams = append(cams, m.withAssignment(m.cameras[id]))
+		}
+	}
+	return cams, nil
+}
+
+func (m *MemoryStore) AttachCameraToAuditorium(cameraID, auditoriumID uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.cameras[cameraID]; !ok {
+		return fmt.Errorf("camera %d not found", cameraID)
+	}
+	if _, ok := m.auditoriums[auditoriumID]; !ok {
+		return fmt.Errorf("auditorium %d not found", auditoriumID)
+	}
+	if existing, ok := m.assignments[cameraID]; ok && existing != auditoriumID {
+		return fmt.Errorf("camera %d already assigned to auditorium %d", cameraID, existing)
+	}
+	m.assignments[cameraID] = auditoriumID
+	return nil
+}
+
+func (m *MemoryStore) DetachCameraFromAuditorium(cameraID uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.assignments, cameraID)
+	return nil
+}
+
+func (m *MemoryStore) DeleteCamera(cameraID uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.cameras[cameraID]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	m.deleteAlerts(func(a forms.Alert) bool { return a.CameraID != nil && *a.CameraID == cameraID })
+	delete(m.assignments, cameraID)
+	delete(m.readings, cameraID)
+	delete(m.health, cameraID)
+	delete(m.cameras, cameraID)
+	return nil
+}
+
+func (m *MemoryStore) IssueToken(cameraID uint) (*forms.Camera, string, error) {
+	token, hash, err := newCameraToken()
+	if err != nil {
+		return nil, "", err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	camera, ok := m.cameras[cameraID]
+	if !ok {
+		return nil, "", gorm.ErrRecordNotFound
+	}
+	camera.TokenHash = &hash
+	m.cameras[cameraID] = camera
+	return &camera, token, nil
+}
+
+func (m *MemoryStore) RevokeToken(cameraID uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	camera, ok := m.cameras[cameraID]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	camera.TokenHash = nil
+	m.cameras[cameraID] = camera
+	return nil
+}
+
+func (m *MemoryStore) AuthenticateToken(token string) (*forms.Camera, error) {
+	if token == "" {
+		return nil, ErrInvalidCameraToken
+	}
+	hash := hashCameraToken(token)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, camera := range m.cameras {
+		if camera.TokenHash != nil && *camera.TokenHash == hash {
+			return &camera, nil
+		}
+	}
+	return nil, ErrInvalidCameraToken
+}
+
+// cameraByMac finds a camera by its exact MAC; the caller holds mu.
+func (m *MemoryStore) cameraByMac(mac string) (forms.Camera, bool) {
+	for _, camera := range m.cameras {
+		if camera.Mac == mac {
+			return camera, true
+		}
+	}
+	return forms.Camera{}, false
+}
+
+// cameraHealthRow builds the health row of a camera; the caller holds mu.
+func (m *MemoryStore) cameraHealthRow(camera forms.Camera) cameraHealthRow {
+	row := cameraHealthRow{ID: camera.ID, Mac: camera.Mac}
+	if auditoriumID, ok := m.assignments[camera.ID]; ok {
+		row.AuditoriumID = &auditoriumID
+		if a, ok := m.auditoriums[auditoriumID]; ok {
+			buildingID := a.BuildingID
+			row.BuildingID = &buildingID
+			if b, ok := m.buildings[buildingID]; ok {
+				cityID := b.CityID
+				row.CityID = &cityID
+			}
+		}
+	}
+	if h, ok := m.health[camera.ID]; ok {
+		row.LastSeenAt = &h.LastSeenAt
+		row.LastEventAt = &h.LastEventAt
+		row.LastPersonCount = &h.LastPersonCount
+		row.EventsTotal = &h.EventsTotal
+		row.AvgIntervalSeconds = h.AvgIntervalSeconds
+	}
+	return row
+}
+
+func (m *MemoryStore) GetCameraHealth(cameraID uint, now time.Time, th HealthThresholds) (*forms.CameraHealthResponse, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	camera, ok := m.cameras[cameraID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	row := m.cameraHealthRow(camera)
+	resp := row.toResponse(now, th)
+	return &resp, nil
+}
+
+func (m *MemoryStore) ListCameraHealth(status string, now time.Time, th HealthThresholds) ([]forms.CameraHealthResponse, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	result := make([]forms.CameraHealthResponse, 0, len(m.cameras))
+	for _, id := range sortedIDs(m.cameras) {
+		row := m.cameraHealthRow(m.cameras[id])
+		resp := row.toResponse(now, th)
+		if status != "" && resp.Status != status {
+			continue
+		}
+		result = append(result, resp)
+	}
+	return result, nil
+}
+
+func (m *MemoryStore) RecordHeartbeat(mac string, personCount int, eventTime, seenAt time.Time) error {
+	return m.RecordHeartbeats([]Heartbeat{{Mac: mac, PersonCount: personCount, EventTime: eventTime, SeenAt: seenAt}})
+}
+
+// RecordHeartbeats applies heartbeats one by one with the same moving average
+// as the SQL version; unknown MACs are ignored.
+func (m *MemoryStore) RecordHeartbeats(beats []Heartbeat) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, b := range beats {
+		camera, ok := m.cameraByMac(b.Mac)
+		if !ok {
+			continue
+		}
+		h, seen := m.health[camera.ID]
+		if seen {
+			interval := b.SeenAt.UTC().Sub(h.LastSeenAt).Seconds()
+			avg := interval
+			if h.AvgIntervalSeconds != nil {
+				avg = *h.AvgIntervalSeconds*(1-heartbeatSmoothing) + interval*heartbeatSmoothing
+			}
+			h.AvgIntervalSeconds = &avg
+		}
+		h.CameraID = camera.ID
+		h.LastSeenAt = b.SeenAt.UTC()
+		h.LastEventAt = b.EventTime.UTC()
+		h.LastPersonCount = b.PersonCount
+		h.EventsTotal++
+		m.health[camera.ID] = h
+	}
+	return nil
+}
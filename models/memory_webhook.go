@@ -0,0 +1,174 @@
+package models
+
+import (
+	"sort"
+	"time"
+	"web_backend_v2/forms"
+
+	"gorm.io/gorm"
+)
+
+func (m *MemoryStore) CreateWebhook(hook *forms.Webhook) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	hook.ID = m.nextID("webhook")
+	if hook.CreatedAt.IsZero() {
+		hook.CreatedAt = time.Now()
+	}
+	m.webhooks[hook.ID] = *hook
+	return nil
+}
+
+func (m *MemoryStore) GetWebhooksByBuilding(buildingID uint) ([]forms.Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var hooks []forms.Webhook
+	for _, id := range sortedIDs(m.webhooks) {
+		if m.webhooks[id].BuildingID == buildingID {
+			hooks = append(hooks, m.webhooks[id])
+		}
+	}
+	return hooks, nil
+}
+
+func (m *MemoryStore) GetWebhook(buildingID, webhookID uint) (*forms.Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	hook, ok := m.webhooks[webhookID]
+	if !ok || hook.BuildingID != buildingID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &hook, nil
+}
+
+func (m *MemoryStore) DeleteWebhook(buildingID, webhookID uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	hook, ok := m.webhooks[webhookID]
+	if !ok || hook.BuildingID != buildingID {
+		return gorm.ErrRecordNotFound
+	}
+	m.deleteWebhook(webhookID)
+	return nil
+}
+
+// deleteWebhook removes a webhook with its state and deliveries; the caller holds mu.
+func (m *MemoryStore) deleteWebhook(webhookID uint) {
+	for key := range m.hookStates {
+		if key[0] == webhookID {
+			delete(m.hookStates, key)
+		}
+	}
+	kept := m.deliveries[:0]
+	for _, d := range m.deliveries {
+		if d.WebhookID != webhookID {
+			kept = append(kept, d)
+		}
+	}
+	m.deliveries = kept
+	delete(m.webhooks, webhookID)
+}
+
+func (m *MemoryStore) GetWebhookDeliveries(webhookID uint, status string, limit int) ([]forms.WebhookDelivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var deliveries []forms.WebhookDelivery
+	for i := len(m.deliveries) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		d := m.deliveries[i]
+		if d.WebhookID == webhookID && (status == "" || d.Status == status) {
+			deliveries = append(deliveries, d)
+		}
+	}
+	return deliveries, nil
+}
+
+// EvaluateWebhooks is WebhookModel.EvaluateWebhooks in memory.
+func (m *MemoryStore) EvaluateWebhooks(update forms.OccupancyUpdate) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	auditorium, ok := m.auditoriums[update.AuditoriumID]
+	if !ok {
+		return 0, nil
+	}
+	queued := 0
+	for _, id := range sortedIDs(m.webhooks) {
+		hook := m.webhooks[id]
+		if hook.BuildingID != update.BuildingID || (hook.AuditoriumID != nil && *hook.AuditoriumID != update.AuditoriumID) {
+			continue
+		}
+		key := [2]uint{hook.ID, update.AuditoriumID}
+		state, ok := m.hookStates[key]
+		if !ok {
+			state = forms.WebhookState{WebhookID: hook.ID, AuditoriumID: update.AuditoriumID, ReadingAt: time.Unix(0, 0)}
+		}
+		next, delivery, err := applyWebhook(&hook, state, update, auditorium.Capacity)
+		if err != nil {
+			return queued, err
+		}
+		if next == nil {
+			continue
+		}
+		m.hookStates[key] = *next
+		if delivery != nil {
+			delivery.ID = uint64(m.nextID("webhookdelivery"))
+			m.deliveries = append(m.deliveries, *delivery)
+			queued++
+		}
+	}
+	return queued, nil
+}
+
+// ClaimWebhookDeliveries is WebhookModel.ClaimWebhookDeliveries in memory.
+func (m *MemoryStore) ClaimWebhookDeliveries(now time.Time, limit int, lease time.Duration) ([]WebhookCall, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var due []int
+	for i, d := range m.deliveries {
+		if d.Status == forms.DeliveryPending && !d.NextAttemptAt.After(now) {
+			due = append(due, i)
+		}
+	}
+	sort.SliceStable(due, func(a, b int) bool {
+		return m.deliveries[due[a]].NextAttemptAt.Before(m.deliveries[due[b]].NextAttemptAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	calls := make([]WebhookCall, 0, len(due))
+	for _, i := range due {
+		d := &m.deliveries[i]
+		d.Attempts++
+		d.NextAttemptAt = now.Add(lease)
+		hook := m.webhooks[d.WebhookID]
+		calls = append(calls, WebhookCall{Delivery: *d, URL: hook.URL, Secret: hook.Secret})
+	}
+	return calls, nil
+}
+
+// FinishWebhookDelivery is WebhookModel.FinishWebhookDelivery in memory.
+func (m *MemoryStore) FinishWebhookDelivery(deliveryID uint64, attempt WebhookAttempt) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.deliveries {
+		d := &m.deliveries[i]
+		if d.ID != deliveryID {
+			continue
+		}
+		d.Status = attempt.Status
+		d.LastStatusCode = attempt.StatusCode
+		d.LastError = nil
+		if attempt.Error != "" {
+			msg := attempt.Error
+			d.LastError = &msg
+		}
+		switch attempt.Status {
+		case forms.DeliveryDelivered:
+			at := attempt.At
+			d.DeliveredAt = &at
+		case forms.DeliveryPending:
+			d.NextAttemptAt = attempt.NextAttemptAt
+		}
+		return nil
+	}
+	return nil
+}
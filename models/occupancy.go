@@ -3,10 +3,12 @@ package models
 import (
 	"errors"
 	"fmt"
+	"time"
 	"web_backend_v2/db"
 	"web_backend_v2/forms"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var (
@@ -14,18 +16,62 @@ var (
 	ErrCameraNotFound = errors.New("camera not found")
 	// ErrCameraNotAttached is returned when the camera has no auditorium assignment.
 	ErrCameraNotAttached = errors.New("camera is not attached to an auditorium")
+	// ErrDuplicateEvent is returned when the event has already been stored.
+	ErrDuplicateEvent = errors.New("duplicate camera event")
 )
 
 // OccupancyModel encapsulates occupancy-related operations.
-type OccupancyModel struct{}
+type OccupancyModel struct {
+	// FusionStrategy is the default way to combine cameras of one auditorium
+	// (FusionMax when empty); auditorium.fusion_strategy overrides it.
+	FusionStrategy string
+	// FusionWindow is how old another camera's reading may be to still take
+	// part in fusion (DefaultFusionWindow when zero).
+	FusionWindow time.Duration
+
+	listeners []func(forms.OccupancyUpdate)
+}
+
+// OnStored registers fn to be called with every occupancy row after its
+// transaction commits. Listeners must be registered before the model is used
+// and must not block: they run on the ingestion path.
+func (o *OccupancyModel) OnStored(fn func(forms.OccupancyUpdate)) {
+	o.listeners = append(o.listeners, fn)
+}
+
+// notify passes committed rows to the listeners.
+func (o *OccupancyModel) notify(updates []forms.OccupancyUpdate) {
+	for _, u := range updates {
+		for _, fn := range o.listeners {
+			fn(u)
+		}
+	}
+}
+
+// occupancyUpdate describes a stored row of an auditorium of buildingID in cityID.
+func occupancyUpdate(cityID, buildingID uint, record *forms.Occupancy) forms.OccupancyUpdate {
+	return forms.OccupancyUpdate{
+		CityID:         cityID,
+		BuildingID:     buildingID,
+		AuditoriumID:   record.AuditoriumID,
+		PersonCount:    record.PersonCount,
+		Timestamp:      record.Timestamp,
+		FusionStrategy: derefString(record.FusionStrategy),
+		Contributions:  record.Contributions,
+	}
+}
 
 // SaveEvent stores occupancy info from a camera event.
+// The camera's reading is combined with recent readings of the other cameras
+// attached to the same auditorium, and a single fused occupancy row is written.
+// An event that was already stored yields ErrDuplicateEvent and changes nothing.
 func (o *OccupancyModel) SaveEvent(event *forms.CameraEvent) error {
 	if event == nil {
 		return fmt.Errorf("camera event is nil")
 	}
 
-	return db.GetDB().Transaction(func(tx *gorm.DB) error {
+	var stored forms.OccupancyUpdate
+	err := db.GetDB().Transaction(func(tx *gorm.DB) error {
 		var camera forms.Camera
 		if err := tx.Table("camera").
 			Where("mac = ?", event.IDCamera).
@@ -46,17 +92,321 @@ func (o *OccupancyModel) SaveEvent(event *forms.CameraEvent) error {
 			return fmt.Errorf("failed to load camera assignment: %w", err)
 		}
 
-		record := forms.Occupancy{
-			AuditoriumID: assignment.AuditoriumID,
-			PersonCount:  *event.PersonCount,
-			Timestamp:    event.Timestamp.UTC(),
+		record, auditorium, err := o.fuse(tx, &camera, assignment.AuditoriumID, *event.PersonCount, event.Timestamp.UTC())
+		if err != nil {
+			return err
+		}
+		key := event.DedupKey()
+		record.EventKey = &key
+
+		result := tx.Table("occupancy").
+			Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "event_key"}}, DoNothing: true}).
+			Create(record)
+		if result.Error != nil {
+			return fmt.Errorf("failed to create occupancy record: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			// Rolls back the camera reading stored by fuse as well.
+			return ErrDuplicateEvent
+		}
+
+		stored = occupancyUpdate(auditorium.CityID, auditorium.BuildingID, record)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	o.notify([]forms.OccupancyUpdate{stored})
+	return nil
+}
+
+// occupancyInsertBatch is the number of rows per multi-row INSERT.
+const occupancyInsertBatch = 500
+
+// SaveEvents stores a batch of camera events in one transaction and returns one
+// error per event (nil when stored). Events are fused in the given order, so
+// events of one camera must keep their delivery order. Events already stored, or
+// repeated within the batch, get ErrDuplicateEvent. A non-nil second result
+// means nothing was stored and the whole batch should be retried.
+func (o *OccupancyModel) SaveEvents(events []*forms.CameraEvent) ([]error, error) {
+	results := make([]error, len(events))
+	if len(events) == 0 {
+		return results, nil
+	}
+
+	macs := make([]string, 0, len(events))
+	for _, event := range events {
+		if event != nil {
+			macs = append(macs, event.IDCamera)
+		}
+	}
+
+	var updates []forms.OccupancyUpdate
+	err := db.GetDB().Transaction(func(tx *gorm.DB) error {
+		routes, err := cameraRoutes.resolve(tx, macs)
+		if err != nil {
+			return err
+		}
+
+		auditoriumIDs := make([]uint, 0)
+		seen := make(map[uint]bool)
+		for i, event := range events {
+			if event == nil {
+				results[i] = fmt.Errorf("camera event is nil")
+				continue
+			}
+			route := routes[event.IDCamera]
+			switch {
+			case route.CameraID == 0:
+				results[i] = ErrCameraNotFound
+			case route.AuditoriumID == nil:
+				results[i] = ErrCameraNotAttached
+			case !seen[*route.AuditoriumID]:
+				seen[*route.AuditoriumID] = true
+				auditoriumIDs = append(auditoriumIDs, *route.AuditoriumID)
+			}
+		}
+		if len(auditoriumIDs) == 0 {
+			return nil
+		}
+
+		// Lock in id order so concurrent batches cannot deadlock each other.
+		auditoriums, err := lockAuditoriums(tx, auditoriumIDs)
+		if err != nil {
+			return fmt.Errorf("failed to lock auditoriums: %w", err)
+		}
+		if len(auditoriums) != len(auditoriumIDs) {
+			// A cached assignment points to a deleted auditorium; retry with fresh routes.
+			cameraRoutes.invalidate()
+			return fmt.Errorf("auditorium of a cached camera assignment no longer exists")
+		}
+		strategies := make(map[uint]string, len(auditoriums))
+		locked := make(map[uint]lockedAuditorium, len(auditoriums))
+		for _, a := range auditoriums {
+			strategies[a.ID] = o.strategyFor(a.FusionStrategy)
+			locked[a.ID] = a
+		}
+
+		// Events of these auditoriums are serialized by the locks above, so a key
+		// not stored yet cannot be stored by a concurrent batch before we commit.
+		keys := make([]string, len(events))
+		pending := make([]string, 0, len(events))
+		for i, event := range events {
+			if event != nil && results[i] == nil {
+				keys[i] = event.DedupKey()
+				pending = append(pending, keys[i])
+			}
+		}
+		var existing []string
+		if err := tx.Table("occupancy").
+			Where("event_key IN ?", pending).
+			Pluck("event_key", &existing).Error; err != nil {
+			return fmt.Errorf("failed to check for duplicate events: %w", err)
+		}
+		stored := make(map[string]bool, len(existing)+len(pending))
+		for _, key := range existing {
+			stored[key] = true
+		}
+		for i, key := range keys {
+			if key == "" {
+				continue
+			}
+			if stored[key] {
+				results[i] = ErrDuplicateEvent
+				continue
+			}
+			stored[key] = true
+		}
+
+		var readingRows []auditoriumReading
+		if err := tx.Table("camerareading cr").
+			Select("cr.camera_id, c.mac, cr.person_count, cr.timestamp, cia.auditorium_id").
+			Joins("JOIN camerasinauditorium cia ON cia.camera_id = cr.camera_id").
+			Joins("JOIN camera c ON c.id = cr.camera_id").
+			Where("cia.auditorium_id IN ?", auditoriumIDs).
+			Order("cr.camera_id").
+			Scan(&readingRows).Error; err != nil {
+			return fmt.Errorf("failed to load readings for fusion: %w", err)
+		}
+		latest := make(map[uint][]forms.CameraContribution, len(auditoriumIDs))
+		for _, r := range readingRows {
+			latest[r.AuditoriumID] = append(latest[r.AuditoriumID], r.CameraContribution)
 		}
 
-		if err := tx.Table("occupancy").Create(&record).Error; err != nil {
-			return fmt.Errorf("failed to create occupancy record: %w", err)
+		window := o.window()
+		records := make([]forms.Occupancy, 0, len(events))
+		changed := make(map[uint]*forms.CameraReading)
+		for i, event := range events {
+			if event == nil || results[i] != nil {
+				continue
+			}
+			route := routes[event.IDCamera]
+			auditoriumID := *route.AuditoriumID
+			strategy := strategies[auditoriumID]
+			self := forms.CameraContribution{
+				CameraID:    route.CameraID,
+				Mac:         route.Mac,
+				PersonCount: *event.PersonCount,
+				Timestamp:   event.Timestamp.UTC(),
+			}
+
+			fused, contributions, err := fuseReadings(strategy, window, self, latest[auditoriumID])
+			if err != nil {
+				results[i] = err
+				continue
+			}
+			records = append(records, forms.Occupancy{
+				AuditoriumID:   auditoriumID,
+				PersonCount:    fused,
+				Timestamp:      self.Timestamp,
+				FusionStrategy: &strategy,
+				Contributions:  contributions,
+				EventKey:       &keys[i],
+			})
+
+			// Same rule as the camerareading upsert: an out-of-order event does not overwrite.
+			latest[auditoriumID] = keepNewest(latest[auditoriumID], self)
+			if r, ok := changed[self.CameraID]; !ok || !self.Timestamp.Before(r.Timestamp) {
+				changed[self.CameraID] = &forms.CameraReading{
+					CameraID:    self.CameraID,
+					PersonCount: self.PersonCount,
+					Timestamp:   self.Timestamp,
+				}
+			}
+		}
+		if len(records) == 0 {
+			return nil
+		}
+
+		if err := tx.Table("occupancy").CreateInBatches(&records, occupancyInsertBatch).Error; err != nil {
+			return fmt.Errorf("failed to create occupancy records: %w", err)
+		}
+
+		readings := make([]forms.CameraReading, 0, len(changed))
+		for _, r := range changed {
+			readings = append(readings, *r)
+		}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "camera_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"person_count", "timestamp"}),
+			Where: clause.Where{Exprs: []clause.Expression{
+				clause.Expr{SQL: "camerareading.timestamp <= EXCLUDED.timestamp"},
+			}},
+		}).Create(&readings).Error; err != nil {
+			return fmt.Errorf("failed to store camera readings: %w", err)
 		}
 
+		updates = make([]forms.OccupancyUpdate, len(records))
+		for i := range records {
+			a := locked[records[i].AuditoriumID]
+			updates[i] = occupancyUpdate(a.CityID, a.BuildingID, &records[i])
+		}
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+	o.notify(updates)
+	return results, nil
+}
+
+// auditoriumReading is a stored camera reading with the camera's auditorium.
+type auditoriumReading struct {
+	forms.CameraContribution
+	AuditoriumID uint
 }
 
+// keepNewest replaces the camera's reading in readings with r unless the stored one is newer.
+func keepNewest(readings []forms.CameraContribution, r forms.CameraContribution) []forms.CameraContribution {
+	for i := range readings {
+		if readings[i].CameraID == r.CameraID {
+			if !r.Timestamp.Before(readings[i].Timestamp) {
+				readings[i] = r
+			}
+			return readings
+		}
+	}
+	return append(readings, r)
+}
+
+// fuse records the camera's reading and builds the fused occupancy row for its auditorium.
+// Must run inside a transaction: the auditorium row is locked so that concurrent
+// events of cameras in the same auditorium are fused one after another.
+// The locked auditorium is returned along with the row.
+func (o *OccupancyModel) fuse(tx *gorm.DB, camera *forms.Camera, auditoriumID uint, personCount int, ts time.Time) (*forms.Occupancy, lockedAuditorium, error) {
+	locked, err := lockAuditoriums(tx, []uint{auditoriumID})
+	if err == nil && len(locked) == 0 {
+		err = gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, lockedAuditorium{}, fmt.Errorf("failed to lock auditorium %d: %w", auditoriumID, err)
+	}
+	auditorium := locked[0]
+
+	strategy := o.strategyFor(auditorium.FusionStrategy)
+	window := o.window()
+
+	// Keep only the newest reading per camera; an out-of-order event does not overwrite it.
+	if err := tx.Exec(`
+		INSERT INTO camerareading (camera_id, person_count, timestamp)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (camera_id) DO UPDATE
+		SET person_count = EXCLUDED.person_count, timestamp = EXCLUDED.timestamp
+		WHERE camerareading.timestamp <= EXCLUDED.timestamp
+	`, camera.ID, personCount, ts).Error; err != nil {
+		return nil, lockedAuditorium{}, fmt.Errorf("failed to store camera reading: %w", err)
+	}
+
+	var others []forms.CameraContribution
+	if err := tx.Table("camerareading cr").
+		Select("cr.camera_id, c.mac, cr.person_count, cr.timestamp").
+		Joins("JOIN camerasinauditorium cia ON cia.camera_id = cr.camera_id").
+		Joins("JOIN camera c ON c.id = cr.camera_id").
+		Where("cia.auditorium_id = ? AND cr.camera_id <> ?", auditoriumID, camera.ID).
+		Where("cr.timestamp >= ? AND cr.timestamp <= ?", ts.Add(-window), ts).
+		Order("cr.camera_id").
+		Scan(&others).Error; err != nil {
+		return nil, lockedAuditorium{}, fmt.Errorf("failed to load readings for fusion: %w", err)
+	}
+
+	self := forms.CameraContribution{
+		CameraID:    camera.ID,
+		Mac:         camera.Mac,
+		PersonCount: personCount,
+		Timestamp:   ts,
+	}
+	fused, contributions, err := fuseReadings(strategy, window, self, others)
+	if err != nil {
+		return nil, lockedAuditorium{}, err
+	}
+
+	return &forms.Occupancy{
+		AuditoriumID:   auditoriumID,
+		PersonCount:    fused,
+		Timestamp:      ts,
+		FusionStrategy: &strategy,
+		Contributions:  contributions,
+	}, auditorium, nil
+}
+
+// lockedAuditorium is an auditorium row locked for fusion, with the city of its building.
+type lockedAuditorium struct {
+	ID             uint
+	BuildingID     uint
+	CityID         uint
+	FusionStrategy *string
+}
+
+// lockAuditoriums locks the auditorium rows with the given ids, in id order so
+// that concurrent transactions cannot deadlock each other.
+func lockAuditoriums(tx *gorm.DB, ids []uint) ([]lockedAuditorium, error) {
+	var auditoriums []lockedAuditorium
+	err := tx.Table("auditorium").
+		Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: "auditorium"}}).
+		Select("auditorium.id, auditorium.building_id, building.city_id, auditorium.fusion_strategy").
+		Joins("JOIN building ON building.id = auditorium.building_id").
+		Where("auditorium.id IN ?", ids).
+		Order("auditorium.id").
+		Find(&auditoriums).Error
+	return auditoriums, err
+}
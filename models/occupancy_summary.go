@@ -0,0 +1,155 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"time"
+	"web_backend_v2/db"
+	"web_backend_v2/forms"
+)
+
+// roomReading is an auditorium with its latest reading, if any.
+type roomReading struct {
+	AuditoriumID uint
+	BuildingID   uint
+	FloorNumber  int
+	Capacity     int
+	PersonCount  *int
+	Timestamp    *time.Time
+}
+
+// GetBuildingOccupancySummary sums the latest readings (at or before
+// queryTimestamp) of the building's auditoriums, in total and per floor.
+func (a *AuditoryModel) GetBuildingOccupancySummary(building *forms.Building, queryTimestamp time.Time, maxTimeDiffMinutes int) (*forms.BuildingOccupancySummary, error) {
+	readings, err := latestReadings(queryTimestamp, "a.building_id = ?", building.ID)
+	if err != nil {
+		return nil, err
+	}
+	summaries := summarizeOccupancy([]forms.Building{*building}, readings, queryTimestamp, maxTimeDiffMinutes)
+	return &summaries[0], nil
+}
+
+// GetCityOccupancySummary rolls up GetBuildingOccupancySummary over all
+// buildings of a city, including buildings without auditoriums.
+func (a *AuditoryModel) GetCityOccupancySummary(cityID uint, queryTimestamp time.Time, maxTimeDiffMinutes int) (*forms.CityOccupancySummary, error) {
+	var buildings []forms.Building
+	if err := db.GetDB().Where("city_id = ?", cityID).Order("id").Find(&buildings).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	readings, err := latestReadings(queryTimestamp, "a.building_id IN (SELECT id FROM building WHERE city_id = ?)", cityID)
+	if err != nil {
+		return nil, err
+	}
+	return cityOccupancySummary(cityID, buildings, readings, queryTimestamp, maxTimeDiffMinutes), nil
+}
+
+// latestReadings lists the auditoriums matching scope (a condition on the
+// auditorium aliased as a) with their latest reading up to queryTimestamp.
+func latestReadings(queryTimestamp time.Time, scope string, args ...interface{}) ([]roomReading, error) {
+	var readings []roomReading
+	err := db.GetDB().Table("auditorium AS a").
+		Select("a.id AS auditorium_id, a.building_id, a.floor_number, a.capacity, o.person_count, o.timestamp").
+		Joins("LEFT JOIN (?) latest ON latest.auditorium_id = a.id", latestOccupancySubquery(queryTimestamp, scope, args...)).
+		Joins("LEFT JOIN occupancy o ON o.auditorium_id = a.id AND o.timestamp = latest.max_ts").
+		Where(scope, args...).
+		Order("a.id").
+		Scan(&readings).Error
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return readings, nil
+}
+
+// cityOccupancySummary builds the city rollup from its buildings and readings.
+func cityOccupancySummary(cityID uint, buildings []forms.Building, readings []roomReading, queryTimestamp time.Time, maxTimeDiffMinutes int) *forms.CityOccupancySummary {
+	summary := &forms.CityOccupancySummary{
+		CityID:    cityID,
+		Timestamp: queryTimestamp,
+		Buildings: summarizeOccupancy(buildings, readings, queryTimestamp, maxTimeDiffMinutes),
+	}
+	for _, b := range summary.Buildings {
+		mergeTotals(&summary.OccupancyTotals, b.OccupancyTotals)
+	}
+	setUtilisation(&summary.OccupancyTotals)
+	return summary
+}
+
+// summarizeOccupancy returns one summary per building, in the given order, with
+// the readings of its auditoriums added to the building and floor totals.
+// Freshness follows occupancyResponse.
+func summarizeOccupancy(buildings []forms.Building, readings []roomReading, queryTimestamp time.Time, maxTimeDiffMinutes int) []forms.BuildingOccupancySummary {
+	summaries := make([]forms.BuildingOccupancySummary, len(buildings))
+	floors := make([]map[int]*forms.FloorOccupancySummary, len(buildings))
+	index := make(map[uint]int, len(buildings))
+	for i, b := range buildings {
+		summaries[i] = forms.BuildingOccupancySummary{Building: b.ToBuildingResponse(), Timestamp: queryTimestamp}
+		floors[i] = make(map[int]*forms.FloorOccupancySummary)
+		index[b.ID] = i
+	}
+
+	seen := make(map[uint]bool, len(readings))
+	for _, r := range readings {
+		i, ok := index[r.BuildingID]
+		// Two readings with the same latest timestamp give two rows; count the room once.
+		if !ok || seen[r.AuditoriumID] {
+			continue
+		}
+		seen[r.AuditoriumID] = true
+
+		floor := floors[i][r.FloorNumber]
+		if floor == nil {
+			floor = &forms.FloorOccupancySummary{FloorNumber: r.FloorNumber}
+			floors[i][r.FloorNumber] = floor
+		}
+		fresh := r.Timestamp != nil && queryTimestamp.Sub(*r.Timestamp).Minutes() <= float64(maxTimeDiffMinutes)
+		addReading(&summaries[i].OccupancyTotals, r, fresh)
+		addReading(&floor.OccupancyTotals, r, fresh)
+	}
+
+	for i := range summaries {
+		setUtilisation(&summaries[i].OccupancyTotals)
+		summaries[i].Floors = make([]forms.FloorOccupancySummary, 0, len(floors[i]))
+		for _, floor := range floors[i] {
+			setUtilisation(&floor.OccupancyTotals)
+			summaries[i].Floors = append(summaries[i].Floors, *floor)
+		}
+		sort.Slice(summaries[i].Floors, func(x, y int) bool {
+			return summaries[i].Floors[x].FloorNumber < summaries[i].Floors[y].FloorNumber
+		})
+	}
+	return summaries
+}
+
+func addReading(t *forms.OccupancyTotals, r roomReading, fresh bool) {
+	t.Auditoriums++
+	t.TotalCapacity += r.Capacity
+	switch {
+	case r.PersonCount == nil:
+		t.NoDataAuditoriums++
+	case fresh:
+		t.FreshAuditoriums++
+		t.FreshCapacity += r.Capacity
+		t.TotalPeople += *r.PersonCount
+	default:
+		t.StaleAuditoriums++
+	}
+}
+
+func mergeTotals(dst *forms.OccupancyTotals, src forms.OccupancyTotals) {
+	dst.Auditoriums += src.Auditoriums
+	dst.FreshAuditoriums += src.FreshAuditoriums
+	dst.StaleAuditoriums += src.StaleAuditoriums
+	dst.NoDataAuditoriums += src.NoDataAuditoriums
+	dst.TotalPeople += src.TotalPeople
+	dst.TotalCapacity += src.TotalCapacity
+	dst.FreshCapacity += src.FreshCapacity
+}
+
+// setUtilisation computes UtilisationRate from the sums.
+func setUtilisation(t *forms.OccupancyTotals) {
+	t.UtilisationRate = nil
+	if t.FreshCapacity > 0 {
+		rate := float64(t.TotalPeople) / float64(t.FreshCapacity) * 100
+		t.UtilisationRate = &rate
+	}
+}
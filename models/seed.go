@@ -0,0 +1,208 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"web_backend_v2/db"
+	"web_backend_v2/forms"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SeedResult counts what applying a fixture changed.
+type SeedResult struct {
+	CitiesCreated, CitiesUpdated           int
+	BuildingsCreated, BuildingsUpdated     int
+	AuditoriumsCreated, AuditoriumsUpdated int
+	CamerasCreated, CamerasAttached        int
+}
+
+// Seed applies a fixture in one transaction. Existing entities are matched by
+// the natural keys described in forms.SeedFixture and updated in place; nothing
+// is deleted, so applying the same fixture twice changes nothing.
+func Seed(fixture *forms.SeedFixture) (*SeedResult, error) {
+	var result SeedResult
+	err := db.GetDB().Transaction(func(tx *gorm.DB) error {
+		for _, c := range fixture.Cities {
+			city := c.ToCity(0)
+			if err := seedCity(tx, &city, &result); err != nil {
+				return fmt.Errorf("city %q: %w", city.NameEN, err)
+			}
+			for _, b := range c.Buildings {
+				if err := seedBuilding(tx, city.ID, &b, &result); err != nil {
+					return fmt.Errorf("city %q, building %q: %w", city.NameEN, b.Address.EN, err)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	cameraRoutes.invalidate()
+	return &result, nil
+}
+
+// seedCity creates the city or updates the existing one with the same name_en.
+func seedCity(tx *gorm.DB, city *forms.City, result *SeedResult) error {
+	var existing forms.City
+	err := tx.Table("city").Where("name_en = ?", city.NameEN).First(&existing).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if err := tx.Table("city").Create(city).Error; err != nil {
+			return fmt.Errorf("failed to create city: %w", err)
+		}
+		result.CitiesCreated++
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to look up city: %w", err)
+	}
+
+	city.ID = existing.ID
+	if existing == *city {
+		return nil
+	}
+	if err := tx.Table("city").Where("id = ?", city.ID).Updates(map[string]interface{}{
+		"name_ru": city.NameRU,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to update city %d: %w", city.ID, err)
+	}
+	result.CitiesUpdated++
+	return nil
+}
+
+// seedBuilding creates or updates the building with the same address_en in the
+// city, then its auditoriums and cameras.
+func seedBuilding(tx *gorm.DB, cityID uint, b *forms.SeedBuilding, result *SeedResult) error {
+	building := b.ToBuilding(0, cityID)
+	for _, a := range b.Auditoriums {
+		if err := checkFloor(a.FloorNumber, building.FloorCount); err != nil {
+			return fmt.Errorf("auditorium %q: %w", a.AuditoriumNumber, err)
+		}
+	}
+
+	var existing forms.Building
+	err := tx.Table("building").
+		Where("city_id = ? AND address_en = ?", cityID, building.AddressEN).
+		First(&existing).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if err := tx.Table("building").Create(&building).Error; err != nil {
+			return fmt.Errorf("failed to create building: %w", err)
+		}
+		result.BuildingsCreated++
+	case err != nil:
+		return fmt.Errorf("failed to look up building: %w", err)
+	default:
+		building.ID = existing.ID
+		if existing != building {
+			// Auditoriums not listed in the fixture must still fit into floor_count.
+			var maxFloor sql.NullInt64
+			if err := tx.Table("auditorium").
+				Select("MAX(floor_number)").
+				Where("building_id = ?", building.ID).
+				Scan(&maxFloor).Error; err != nil {
+				return fmt.Errorf("failed to check auditorium floors: %w", err)
+			}
+			if maxFloor.Valid && int(maxFloor.Int64) > building.FloorCount {
+				return fmt.Errorf("%w: auditoriums exist on floor %d, floor_count %d is too low",
+					ErrFloorOutOfRange, maxFloor.Int64, building.FloorCount)
+			}
+			if err := tx.Table("building").Where("id = ?", building.ID).Updates(map[string]interface{}{
+				"address_ru":  building.AddressRU,
+				"floor_count": building.FloorCount,
+			}).Error; err != nil {
+				return fmt.Errorf("failed to update building %d: %w", building.ID, err)
+			}
+			result.BuildingsUpdated++
+		}
+	}
+
+	for _, a := range b.Auditoriums {
+		auditorium := a.ToAuditorium(0, building.ID)
+		if err := seedAuditorium(tx, &auditorium, result); err != nil {
+			return fmt.Errorf("auditorium %q: %w", auditorium.AuditoriumNumber, err)
+		}
+		for _, mac := range a.Cameras {
+			if err := seedCamera(tx, mac, auditorium.ID, result); err != nil {
+				return fmt.Errorf("auditorium %q, camera %s: %w", auditorium.AuditoriumNumber, mac, err)
+			}
+		}
+	}
+	return nil
+}
+
+// seedAuditorium creates or updates the auditorium with the same number in the building.
+func seedAuditorium(tx *gorm.DB, auditorium *forms.Auditorium, result *SeedResult) error {
+	var existing forms.Auditorium
+	err := tx.Table("auditorium").
+		Where("building_id = ? AND auditorium_number = ?", auditorium.BuildingID, auditorium.AuditoriumNumber).
+		First(&existing).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if err := tx.Table("auditorium").Create(auditorium).Error; err != nil {
+			return fmt.Errorf("failed to create auditorium: %w", err)
+		}
+		result.AuditoriumsCreated++
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to look up auditorium: %w", err)
+	}
+
+	auditorium.ID = existing.ID
+	if sameAuditorium(&existing, auditorium) {
+		return nil
+	}
+	if err := tx.Table("auditorium").Where("id = ?", auditorium.ID).Updates(map[string]interface{}{
+		"floor_number":    auditorium.FloorNumber,
+		"capacity":        auditorium.Capacity,
+		"type":            auditorium.Type,
+		"type_ru":         auditorium.TypeRU,
+		"image_url":       auditorium.ImageURL,
+		"fusion_strategy": auditorium.FusionStrategy,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to update auditorium %d: %w", auditorium.ID, err)
+	}
+	result.AuditoriumsUpdated++
+	return nil
+}
+
+// sameAuditorium reports whether seeding a would leave b unchanged.
+func sameAuditorium(a, b *forms.Auditorium) bool {
+	return a.FloorNumber == b.FloorNumber &&
+		a.Capacity == b.Capacity &&
+		a.Type == b.Type &&
+		a.TypeRU == b.TypeRU &&
+		a.ImageURL == b.ImageURL &&
+		derefString(a.FusionStrategy) == derefString(b.FusionStrategy)
+}
+
+// seedCamera creates the camera if needed and attaches it to auditoriumID.
+func seedCamera(tx *gorm.DB, mac string, auditoriumID uint, result *SeedResult) error {
+	var camera forms.Camera
+	err := tx.Table("camera").Where("mac = ?", mac).First(&camera).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		camera = forms.Camera{Mac: mac}
+		if err := tx.Table("camera").Create(&camera).Error; err != nil {
+			return fmt.Errorf("failed to create camera: %w", err)
+		}
+		result.CamerasCreated++
+	} else if err != nil {
+		return fmt.Errorf("failed to look up camera: %w", err)
+	}
+
+	res := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "camera_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"auditorium_id"}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Expr{SQL: "camerasinauditorium.auditorium_id <> EXCLUDED.auditorium_id"},
+		}},
+	}).Create(&forms.CamerasInAuditorium{CameraID: camera.ID, AuditoriumID: auditoriumID})
+	if res.Error != nil {
+		return fmt.Errorf("failed to attach camera: %w", res.Error)
+	}
+	result.CamerasAttached += int(res.RowsAffected)
+	return nil
+}
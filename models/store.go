@@ -0,0 +1,124 @@
+package models
+
+import (
+	"time"
+	"web_backend_v2/forms"
+)
+
+// The store interfaces below are what the HTTP handlers need from the data layer.
+// The *Model types implement them on top of PostgreSQL; MemoryStore implements
+// all of them in memory. Not-found is reported as gorm.ErrRecordNotFound by both.
+
+// CityStore manages cities.
+type CityStore interface {
+	GetCities() ([]forms.City, error)
+	GetCity(cityID uint) (*forms.City, error)
+	CreateCity(city *forms.City) error
+	UpdateCity(city *forms.City) error
+	CountBuildings(cityID uint) (int64, error)
+	DeleteCity(cityID uint) error
+}
+
+// BuildingStore manages buildings of a city.
+type BuildingStore interface {
+	GetBuildingsByCity(cityID uint) ([]forms.Building, error)
+	GetBuilding(cityID, buildingID uint) (*forms.Building, error)
+	GetBuildingCities(buildingIDs []uint) (map[uint]uint, error)
+	CreateBuilding(building *forms.Building) error
+	UpdateBuilding(building *forms.Building) error
+	CountAuditoriums(buildingID uint) (int64, error)
+	DeleteBuilding(cityID, buildingID uint) error
+}
+
+// AuditoriumStore manages auditoriums and reads their occupancy.
+type AuditoriumStore interface {
+	Exists(auditoriumID uint) (bool, error)
+	GetAuditoriumsByBuilding(buildingID uint) ([]forms.Auditorium, error)
+	GetAuditorium(buildingID, auditoriumID uint) (*forms.Auditorium, error)
+	GetAuditoriumLocations(auditoriumIDs []uint) (map[uint]AuditoriumLocation, error)
+	CreateAuditorium(auditorium *forms.Auditorium) error
+	UpdateAuditorium(auditorium *forms.Auditorium) error
+	DeleteAuditorium(buildingID, auditoriumID uint) error
+	GetLatestOccupancyByBuilding(buildingID uint, queryTimestamp time.Time, maxTimeDiffMinutes int) ([]forms.AuditoriumOccupancyResponse, error)
+	GetLatestOccupancyForAuditorium(auditoriumID uint, queryTimestamp time.Time, maxTimeDiffMinutes int) (*forms.AuditoriumOccupancyResponse, error)
+	GetAuditoriumStats(auditoriumID uint, day time.Time) ([]forms.HourlyStatsResponse, bool, error)
+	GetAuditoriumOccupancyRate(auditoriumID uint, day time.Time) ([]forms.HourlyRateStatsResponse, bool, error)
+	GetOccupancySeries(auditoriumID uint, from, to time.Time, bucket time.Duration) ([]forms.OccupancySeriesPoint, error)
+	FindFreeAuditoriums(cityID uint, q forms.FreeAuditoriumsQuery, maxTimeDiffMinutes int) ([]forms.FreeAuditoriumResponse, error)
+	GetBuildingOccupancySummary(building *forms.Building, queryTimestamp time.Time, maxTimeDiffMinutes int) (*forms.BuildingOccupancySummary, error)
+	GetCityOccupancySummary(cityID uint, queryTimestamp time.Time, maxTimeDiffMinutes int) (*forms.CityOccupancySummary, error)
+}
+
+// CameraStore manages cameras, their attachment, health and ingestion tokens.
+type CameraStore interface {
+	CreateCamera(mac string) (*forms.Camera, error)
+	GetCameraWithAssignment(id uint) (*CameraWithAssignment, error)
+	GetCameraByMac(mac string) (*CameraWithAssignment, error)
+	GetCamerasByAuditorium(auditoriumID uint) ([]forms.Camera, error)
+	GetFreeCameras() ([]forms.Camera, error)
+	GetAttachedCameras() ([]CameraWithAssignment, error)
+	AttachCameraToAuditorium(cameraID, auditoriumID uint) error
+	DetachCameraFromAuditorium(cameraID uint) error
+	DeleteCamera(cameraID uint) error
+	RecordHeartbeat(mac string, personCount int, eventTime, seenAt time.Time) error
+	RecordHeartbeats(beats []Heartbeat) error
+	GetCameraHealth(cameraID uint, now time.Time, th HealthThresholds) (*forms.CameraHealthResponse, error)
+	ListCameraHealth(status string, now time.Time, th HealthThresholds) ([]forms.CameraHealthResponse, error)
+	IssueToken(cameraID uint) (*forms.Camera, string, error)
+	RevokeToken(cameraID uint) error
+	AuthenticateToken(token string) (*forms.Camera, error)
+}
+
+// OccupancyStore stores camera events as fused occupancy readings and reports
+// every stored reading to the OnStored listeners.
+type OccupancyStore interface {
+	SaveEvent(event *forms.CameraEvent) error
+	SaveEvents(events []*forms.CameraEvent) ([]error, error)
+	OnStored(fn func(forms.OccupancyUpdate))
+}
+
+// ImportStore applies CSV imports of a city's buildings and auditoriums.
+type ImportStore interface {
+	ImportBuildings(cityID uint, imp *forms.BuildingImport, dryRun bool) (*forms.ImportResult, error)
+	ImportAuditoriums(cityID uint, imp *forms.AuditoriumImport, dryRun bool) (*forms.ImportResult, error)
+}
+
+// ExportStore streams the rows of spreadsheet exports to fn.
+type ExportStore interface {
+	ExportBuildingOccupancy(buildingID uint, queryTimestamp time.Time, fn func(forms.AuditoriumOccupancyExportRow) error) error
+	ExportAuditoriumStats(auditoriumID uint, fromDay, toDay time.Time, fn func(forms.HourlyStatsExportRow) error) error
+	ExportOccupancy(buildingID uint, from, to time.Time, fn func(forms.OccupancyExportRow) error) error
+}
+
+// WebhookStore manages webhooks, evaluates them on stored readings and keeps
+// their delivery outbox (see WebhookModel).
+type WebhookStore interface {
+	CreateWebhook(hook *forms.Webhook) error
+	GetWebhooksByBuilding(buildingID uint) ([]forms.Webhook, error)
+	GetWebhook(buildingID, webhookID uint) (*forms.Webhook, error)
+	DeleteWebhook(buildingID, webhookID uint) error
+	GetWebhookDeliveries(webhookID uint, status string, limit int) ([]forms.WebhookDelivery, error)
+	EvaluateWebhooks(update forms.OccupancyUpdate) (int, error)
+	ClaimWebhookDeliveries(now time.Time, limit int, lease time.Duration) ([]WebhookCall, error)
+	FinishWebhookDelivery(deliveryID uint64, attempt WebhookAttempt) error
+}
+
+// AlertStore evaluates alert rules and keeps the raised alerts (see AlertModel).
+type AlertStore interface {
+	EvaluateAlerts(now time.Time, rules AlertRules) (int, int, error)
+	GetAlerts(q forms.AlertQuery) ([]forms.AlertResponse, error)
+	GetAlert(alertID uint64) (*forms.AlertResponse, error)
+	AcknowledgeAlert(alertID uint64, note string, at time.Time) (*forms.AlertResponse, error)
+}
+
+var (
+	_ CityStore       = (*CityModel)(nil)
+	_ BuildingStore   = (*BuildingModel)(nil)
+	_ AuditoriumStore = (*AuditoryModel)(nil)
+	_ CameraStore     = (*CameraModel)(nil)
+	_ OccupancyStore  = (*OccupancyModel)(nil)
+	_ ImportStore     = (*ImportModel)(nil)
+	_ ExportStore     = (*ExportModel)(nil)
+	_ WebhookStore    = (*WebhookModel)(nil)
+	_ AlertStore      = (*AlertModel)(nil)
+)
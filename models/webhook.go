@@ -0,0 +1,277 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"web_backend_v2/db"
+	"web_backend_v2/forms"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WebhookModel stores webhooks, evaluates their rules on stored readings and
+// keeps the outbox of their deliveries.
+type WebhookModel struct{}
+
+// WebhookCall is a claimed delivery with what is needed to send it.
+type WebhookCall struct {
+	Delivery forms.WebhookDelivery
+	URL      string
+	Secret   string
+}
+
+// WebhookAttempt is the outcome of sending a claimed delivery. Status is
+// DeliveryDelivered, DeliveryFailed (no more attempts) or DeliveryPending (to be
+// retried at NextAttemptAt).
+type WebhookAttempt struct {
+	Status        string
+	StatusCode    *int
+	Error         string
+	NextAttemptAt time.Time
+	At            time.Time
+}
+
+// CreateWebhook inserts a webhook; the handler has checked its building and auditorium.
+func (w *WebhookModel) CreateWebhook(hook *forms.Webhook) error {
+	if err := db.GetDB().Create(hook).Error; err != nil {
+		return fmt.Errorf("failed to create webhook: %w", err)
+	}
+	return nil
+}
+
+// GetWebhooksByBuilding returns the webhooks of a building, including those of
+// single auditoriums.
+func (w *WebhookModel) GetWebhooksByBuilding(buildingID uint) ([]forms.Webhook, error) {
+	var hooks []forms.Webhook
+	if err := db.GetDB().Where("building_id = ?", buildingID).Order("id").Find(&hooks).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return hooks, nil
+}
+
+// GetWebhook returns a webhook of the given building or gorm.ErrRecordNotFound.
+func (w *WebhookModel) GetWebhook(buildingID, webhookID uint) (*forms.Webhook, error) {
+	var hook forms.Webhook
+	if err := db.GetDB().Where("id = ? AND building_id = ?", webhookID, buildingID).Take(&hook).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, gorm.ErrRecordNotFound
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return &hook, nil
+}
+
+// DeleteWebhook removes a webhook with its state and delivery log.
+func (w *WebhookModel) DeleteWebhook(buildingID, webhookID uint) error {
+	tx := db.GetDB().Where("id = ? AND building_id = ?", webhookID, buildingID).Delete(&forms.Webhook{})
+	if tx.Error != nil {
+		return fmt.Errorf("failed to delete webhook %d: %w", webhookID, tx.Error)
+	}
+	if tx.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetWebhookDeliveries returns the latest deliveries of a webhook, newest first,
+// optionally only those with the given status.
+func (w *WebhookModel) GetWebhookDeliveries(webhookID uint, status string, limit int) ([]forms.WebhookDelivery, error) {
+	query := db.GetDB().Where("webhook_id = ?", webhookID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var deliveries []forms.WebhookDelivery
+	if err := query.Order("id DESC").Limit(limit).Find(&deliveries).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return deliveries, nil
+}
+
+// EvaluateWebhooks applies the rules of the webhooks watching the auditorium of
+// a stored reading and queues a delivery for every rule it triggers or clears.
+// It returns the number of queued deliveries.
+func (w *WebhookModel) EvaluateWebhooks(update forms.OccupancyUpdate) (int, error) {
+	queued := 0
+	err := db.GetDB().Transaction(func(tx *gorm.DB) error {
+		var hooks []forms.Webhook
+		if err := tx.Where("building_id = ? AND (auditorium_id IS NULL OR auditorium_id = ?)", update.BuildingID, update.AuditoriumID).
+			Order("id").
+			Find(&hooks).Error; err != nil {
+			return fmt.Errorf("failed to load webhooks: %w", err)
+		}
+		if len(hooks) == 0 {
+			return nil
+		}
+		var auditorium forms.Auditorium
+		if err := tx.Select("id, capacity").Where("id = ?", update.AuditoriumID).Take(&auditorium).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return fmt.Errorf("failed to load auditorium %d: %w", update.AuditoriumID, err)
+		}
+
+		for i := range hooks {
+			hook := &hooks[i]
+			// The state row is locked so that readings of one auditorium are
+			// applied one after another; a new row starts not triggered.
+			if err := tx.Exec(`
+				INSERT INTO webhookstate (webhook_id, auditorium_id, active, reading_at)
+				VALUES (?, ?, false, 'epoch')
+				ON CONFLICT DO NOTHING`, hook.ID, update.AuditoriumID).Error; err != nil {
+				return fmt.Errorf("failed to create webhook state: %w", err)
+			}
+			var state forms.WebhookState
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("webhook_id = ? AND auditorium_id = ?", hook.ID, update.AuditoriumID).
+				Take(&state).Error; err != nil {
+				return fmt.Errorf("failed to lock webhook state: %w", err)
+			}
+
+			next, delivery, err := applyWebhook(hook, state, update, auditorium.Capacity)
+			if err != nil {
+				return err
+			}
+			if next == nil {
+				continue
+			}
+			if err := tx.Model(&forms.WebhookState{}).
+				Where("webhook_id = ? AND auditorium_id = ?", hook.ID, update.AuditoriumID).
+				Updates(map[string]interface{}{"active": next.Active, "reading_at": next.ReadingAt}).Error; err != nil {
+				return fmt.Errorf("failed to update webhook state: %w", err)
+			}
+			if delivery != nil {
+				if err := tx.Create(delivery).Error; err != nil {
+					return fmt.Errorf("failed to queue webhook delivery: %w", err)
+				}
+				queued++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return queued, nil
+}
+
+// ClaimWebhookDeliveries picks up to limit pending deliveries due at now and
+// counts an attempt for each. They are not due again until now+lease, so a
+// delivery whose sender dies is retried after the lease; SKIP LOCKED lets
+// several replicas claim side by side.
+func (w *WebhookModel) ClaimWebhookDeliveries(now time.Time, limit int, lease time.Duration) ([]WebhookCall, error) {
+	rows, err := db.GetDB().Raw(`
+		UPDATE webhookdelivery d
+		SET attempts = d.attempts + 1, next_attempt_at = ?
+		FROM webhook w
+		WHERE w.id = d.webhook_id AND d.id IN (
+			SELECT id FROM webhookdelivery
+			WHERE status = 'pending' AND next_attempt_at <= ?
+			ORDER BY next_attempt_at, id
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING d.id, d.webhook_id, d.event, d.payload, d.status, d.attempts, d.next_attempt_at, d.created_at, w.url, w.secret`,
+		now.Add(lease), now, limit).Rows()
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var calls []WebhookCall
+	for rows.Next() {
+		var call WebhookCall
+		d := &call.Delivery
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts,
+			&d.NextAttemptAt, &d.CreatedAt, &call.URL, &call.Secret); err != nil {
+			return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+		}
+		calls = append(calls, call)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	return calls, nil
+}
+
+// FinishWebhookDelivery records the outcome of an attempt.
+func (w *WebhookModel) FinishWebhookDelivery(deliveryID uint64, attempt WebhookAttempt) error {
+	if err := db.GetDB().Model(&forms.WebhookDelivery{}).
+		Where("id = ?", deliveryID).
+		Updates(attemptUpdates(attempt)).Error; err != nil {
+		return fmt.Errorf("failed to record webhook delivery %d: %w", deliveryID, err)
+	}
+	return nil
+}
+
+// attemptUpdates lists the delivery columns set by an attempt.
+func attemptUpdates(attempt WebhookAttempt) map[string]interface{} {
+	var lastError *string
+	if attempt.Error != "" {
+		lastError = &attempt.Error
+	}
+	updates := map[string]interface{}{
+		"status":           attempt.Status,
+		"last_status_code": attempt.StatusCode,
+		"last_error":       lastError,
+	}
+	switch attempt.Status {
+	case forms.DeliveryDelivered:
+		updates["delivered_at"] = attempt.At
+	case forms.DeliveryPending:
+		updates["next_attempt_at"] = attempt.NextAttemptAt
+	}
+	return updates
+}
+
+// applyWebhook applies a webhook's rule to a reading. It returns the new state,
+// or nil when the reading is not newer than the state, and the delivery to queue
+// when the rule was triggered or cleared.
+func applyWebhook(hook *forms.Webhook, state forms.WebhookState, update forms.OccupancyUpdate, capacity int) (*forms.WebhookState, *forms.WebhookDelivery, error) {
+	if !update.Timestamp.After(state.ReadingAt) {
+		return nil, nil, nil
+	}
+	next := state
+	next.ReadingAt = update.Timestamp
+	next.Active = hook.Triggered(state.Active, update.PersonCount, capacity)
+	if next.Active == state.Active {
+		return &next, nil, nil
+	}
+
+	payload := forms.WebhookPayload{
+		Event:        hook.Rule + ".cleared",
+		WebhookID:    hook.ID,
+		Rule:         hook.Rule,
+		CityID:       update.CityID,
+		BuildingID:   update.BuildingID,
+		AuditoriumID: update.AuditoriumID,
+		PersonCount:  update.PersonCount,
+		Capacity:     capacity,
+		Timestamp:    update.Timestamp,
+	}
+	if next.Active {
+		payload.Event = hook.Rule + ".triggered"
+	}
+	if hook.Rule == forms.RuleAbove {
+		payload.ThresholdPercent = hook.ThresholdPercent
+	}
+	if capacity > 0 {
+		rate := float64(update.PersonCount) / float64(capacity) * 100
+		payload.OccupancyRate = &rate
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+	now := time.Now()
+	return &next, &forms.WebhookDelivery{
+		WebhookID:     hook.ID,
+		Event:         payload.Event,
+		Payload:       string(body),
+		Status:        forms.DeliveryPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}, nil
+}
@@ -0,0 +1,260 @@
+package rabbit
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+	"web_backend_v2/events"
+	"web_backend_v2/forms"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Headers attached to dead-lettered messages.
+const (
+	headerFailureReason = "x-failure-reason"
+	headerFailureError  = "x-failure-error"
+	headerOriginalQueue = "x-original-queue"
+	headerFailedAt      = "x-failed-at"
+	headerReplayCount   = "x-replay-count"
+)
+
+// DeadLetterExchange returns the name of the dead-letter exchange for a queue.
+func DeadLetterExchange(queueName string) string { return queueName + ".dlx" }
+
+// DeadLetterQueue returns the name of the dead-letter queue for a queue.
+func DeadLetterQueue(queueName string) string { return queueName + ".dead" }
+
+// declareDeadLetter declares the dead-letter exchange and queue for queueName.
+// Rejected messages are published there explicitly (see deadLetter) rather than via
+// x-dead-letter-exchange, so existing queues need not be redeclared with new arguments.
+func declareDeadLetter(ch *amqp.Channel, queueName string) error {
+	exchange := DeadLetterExchange(queueName)
+	if err := ch.ExchangeDeclare(exchange, amqp.ExchangeFanout, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare dead-letter exchange %s: %w", exchange, err)
+	}
+	dlq := DeadLetterQueue(queueName)
+	if _, err := ch.QueueDeclare(dlq, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare dead-letter queue %s: %w", dlq, err)
+	}
+	if err := ch.QueueBind(dlq, "", exchange, false, nil); err != nil {
+		return fmt.Errorf("failed to bind dead-letter queue %s: %w", dlq, err)
+	}
+	return nil
+}
+
+// deadLetter republishes a rejected delivery to the dead-letter exchange with the
+// failure reason in its headers and waits until the broker confirms it. The
+// caller acks the original delivery on success only, so a message is never
+// acked before its dead-letter copy is safe.
+func deadLetter(ctx context.Context, ch *amqp.Channel, queueName string, msg amqp.Delivery, cause error) error {
+	// Confirm mode stays on for the channel; selecting it again is a no-op.
+	if err := ch.Confirm(false); err != nil {
+		return fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+
+	headers := amqp.Table{}
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers[headerFailureReason] = events.FailureReason(cause)
+	headers[headerFailureError] = cause.Error()
+	headers[headerOriginalQueue] = queueName
+	headers[headerFailedAt] = time.Now().UTC().Format(time.RFC3339)
+
+	messageID := msg.MessageId
+	if messageID == "" {
+		messageID = events.NewMessageID()
+	}
+
+	confirm, err := ch.PublishWithDeferredConfirmWithContext(ctx, DeadLetterExchange(queueName), "", false, false, amqp.Publishing{
+		Headers:      headers,
+		ContentType:  msg.ContentType,
+		DeliveryMode: amqp.Persistent,
+		MessageId:    messageID,
+		Timestamp:    msg.Timestamp,
+		Body:         msg.Body,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", DeadLetterExchange(queueName), err)
+	}
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("no confirm from the broker for %s: %w", DeadLetterExchange(queueName), err)
+	}
+	if !acked {
+		return fmt.Errorf("broker nacked the message published to %s", DeadLetterExchange(queueName))
+	}
+	return nil
+}
+
+// ListDeadLetters returns up to limit dead-lettered events without removing them.
+func (c *Consumer) ListDeadLetters(limit int) ([]forms.DeadLetter, error) {
+	var result []forms.DeadLetter
+	err := c.scanDeadLetters(func(ch *amqp.Channel, msg amqp.Delivery) (bool, error) {
+		result = append(result, toDeadLetter(msg))
+		return len(result) < limit, nil
+	})
+	return result, err
+}
+
+// GetDeadLetter returns a single dead-lettered event by message ID.
+func (c *Consumer) GetDeadLetter(messageID string) (*forms.DeadLetter, error) {
+	var found *forms.DeadLetter
+	err := c.scanDeadLetters(func(ch *amqp.Channel, msg amqp.Delivery) (bool, error) {
+		if msg.MessageId != messageID {
+			return true, nil
+		}
+		dl := toDeadLetter(msg)
+		found = &dl
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, events.ErrDeadLetterNotFound
+	}
+	return found, nil
+}
+
+// ReplayDeadLetters feeds dead-lettered events back into handler. Only messages accepted
+// by match are replayed; those handled successfully are removed from the DLQ, the rest stay.
+func (c *Consumer) ReplayDeadLetters(handler events.Handler, match func(forms.DeadLetter) bool) ([]forms.ReplayResult, error) {
+	var results []forms.ReplayResult
+	err := c.scanDeadLetters(func(ch *amqp.Channel, msg amqp.Delivery) (bool, error) {
+		dl := toDeadLetter(msg)
+		if !match(dl) {
+			return true, nil
+		}
+
+		res := forms.ReplayResult{MessageID: dl.MessageID}
+		if err := handler(msg.Body); err != nil {
+			res.Error = err.Error()
+			results = append(results, res)
+			if !events.IsNonRetryable(err) {
+				// Infrastructure error (e.g. DB down); stop instead of failing every message.
+				return false, nil
+			}
+			// Still rejected: park it again with the new reason and a bumped replay count.
+			if msg.Headers == nil {
+				msg.Headers = amqp.Table{}
+			}
+			msg.Headers[headerReplayCount] = int32(dl.ReplayCount + 1)
+			origin := dl.OriginalQueue
+			if origin == "" {
+				origin = c.queueName
+			}
+			if err := deadLetter(context.Background(), ch, origin, msg, err); err != nil {
+				return false, fmt.Errorf("failed to re-dead-letter message %s: %w", dl.MessageID, err)
+			}
+			if err := msg.Ack(false); err != nil {
+				return false, fmt.Errorf("failed to ack message %s: %w", dl.MessageID, err)
+			}
+			return true, nil
+		}
+
+		if err := msg.Ack(false); err != nil {
+			return false, fmt.Errorf("failed to ack replayed message %s: %w", dl.MessageID, err)
+		}
+		res.Replayed = true
+		results = append(results, res)
+		return true, nil
+	})
+	return results, err
+}
+
+// DiscardDeadLetter permanently removes a dead-lettered event.
+func (c *Consumer) DiscardDeadLetter(messageID string) error {
+	found := false
+	err := c.scanDeadLetters(func(ch *amqp.Channel, msg amqp.Delivery) (bool, error) {
+		if msg.MessageId != messageID {
+			return true, nil
+		}
+		found = true
+		return false, msg.Ack(false)
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return events.ErrDeadLetterNotFound
+	}
+	return nil
+}
+
+// scanDeadLetters walks the DLQ on a dedicated channel with basic.get. Messages are
+// fetched without ack; everything not acked by visit is returned to the queue when the
+// channel is closed. visit returns false to stop early.
+func (c *Consumer) scanDeadLetters(visit func(ch *amqp.Channel, msg amqp.Delivery) (bool, error)) error {
+	conn, err := c.connection()
+	if err != nil {
+		return err
+	}
+	deadLetterQueue := DeadLetterQueue(c.queueName)
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open channel: %w", err)
+	}
+	defer func() {
+		if err := ch.Close(); err != nil {
+			log.Printf("failed to close dead-letter channel: %v", err)
+		}
+	}()
+
+	queue, err := ch.QueueInspect(deadLetterQueue)
+	if err != nil {
+		return fmt.Errorf("failed to inspect %s: %w", deadLetterQueue, err)
+	}
+
+	// Visit at most the messages present at the start, so requeued ones are not seen twice.
+	for i := 0; i < queue.Messages; i++ {
+		msg, ok, err := ch.Get(deadLetterQueue, false)
+		if err != nil {
+			return fmt.Errorf("failed to get from %s: %w", deadLetterQueue, err)
+		}
+		if !ok {
+			return nil
+		}
+		more, err := visit(ch, msg)
+		if err != nil {
+			return err
+		}
+		if !more {
+			return nil
+		}
+	}
+	return nil
+}
+
+func toDeadLetter(msg amqp.Delivery) forms.DeadLetter {
+	dl := forms.DeadLetter{
+		MessageID: msg.MessageId,
+		Body:      string(msg.Body),
+	}
+	if v, ok := msg.Headers[headerFailureReason].(string); ok {
+		dl.Reason = v
+	}
+	if v, ok := msg.Headers[headerFailureError].(string); ok {
+		dl.Error = v
+	}
+	if v, ok := msg.Headers[headerOriginalQueue].(string); ok {
+		dl.OriginalQueue = v
+	}
+	if v, ok := msg.Headers[headerFailedAt].(string); ok {
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			dl.FailedAt = &t
+		}
+	}
+	switch v := msg.Headers[headerReplayCount].(type) {
+	case int32:
+		dl.ReplayCount = int(v)
+	case int64:
+		dl.ReplayCount = int(v)
+	}
+
+	dl.Event = events.ParseEvent(msg.Body)
+	return dl
+}
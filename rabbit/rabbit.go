@@ -5,89 +5,177 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math/rand"
+	"sync"
+	"time"
 	"web_backend_v2/config"
-	"web_backend_v2/forms"
-	"web_backend_v2/models"
+	"web_backend_v2/events"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
-var (
-	rabbitConn *amqp.Connection
-	rabbitChan *amqp.Channel
-)
+// Consumer is a supervised RabbitMQ consumer and the AMQP events.EventSource. Run keeps a connection open, and when
+// the broker closes it (restart, network failure) it reconnects with exponential
+// backoff, re-declares the topology and resumes consuming.
+//
+// Deliveries are handled by a pool of workers; all events of one camera go to the
+// same worker, so they are stored in the order the broker delivered them.
+// With RunBatch each worker buffers deliveries and hands them over in batches.
+type Consumer struct {
+	url         string
+	queueName   string
+	minDelay    time.Duration
+	maxDelay    time.Duration
+	prefetch    int
+	workers     int
+	batchSize   int
+	batchWindow time.Duration
+
+	mu     sync.RWMutex
+	conn   *amqp.Connection
+	ch     *amqp.Channel
+	status events.Status
+	stats  *events.Counters
+}
 
-// InitRabbitMQ establishes connection and channel.
-func InitRabbitMQ(cfg *config.Config) error {
-	if cfg == nil {
-		return fmt.Errorf("rabbit config is nil")
+var _ events.EventSource = (*Consumer)(nil)
+
+// NewConsumer creates a consumer for cfg.QueueName; nothing is dialed until Run.
+func NewConsumer(cfg *config.Config) *Consumer {
+	workers := cfg.RabbitMQ.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	prefetch := cfg.RabbitMQ.Prefetch
+	if prefetch < workers {
+		prefetch = workers
 	}
+	batchSize := cfg.Ingest.BatchSize
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	return &Consumer{
+		url:         cfg.RabbitMQ.URL,
+		queueName:   cfg.QueueName,
+		minDelay:    cfg.RabbitMQ.ReconnectMinDelay,
+		maxDelay:    cfg.RabbitMQ.ReconnectMaxDelay,
+		prefetch:    prefetch,
+		workers:     workers,
+		batchSize:   batchSize,
+		batchWindow: cfg.Ingest.BatchWindow,
+		status:      events.Status{Kind: events.KindRabbitMQ, State: events.StateConnecting, Since: time.Now().UTC()},
+		stats:       events.NewCounters(workers),
+	}
+}
 
-	conn, err := amqp.Dial(cfg.RabbitMQ.URL)
-	if err != nil {
-		return fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+// Stats returns throughput counters of the worker pool.
+func (c *Consumer) Stats() events.Stats {
+	st := c.stats.Snapshot(c.workers, c.prefetch)
+	if st.Batches > 0 {
+		st.BatchSize = c.batchSize
 	}
+	return st
+}
 
-	ch, err := conn.Channel()
+// Status returns the current connection state.
+func (c *Consumer) Status() events.Status {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.status
+}
+
+// Connected reports whether the consumer currently holds an open channel.
+func (c *Consumer) Connected() bool {
+	return c.Status().State == events.StateConnected
+}
+
+func (c *Consumer) setState(state string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if state == events.StateReconnecting && c.status.State != events.StateReconnecting {
+		c.status.Reconnects++
+	}
+	if c.status.State != state {
+		c.status.Since = time.Now().UTC()
+	}
+	c.status.State = state
 	if err != nil {
-		conn.Close()
-		return fmt.Errorf("failed to open channel: %w", err)
+		c.status.LastError = err.Error()
 	}
+}
 
-	// Prefetch 1 message per consumer to avoid flooding.
-	if err := ch.Qos(1, 0, false); err != nil {
-		ch.Close()
-		conn.Close()
-		return fmt.Errorf("failed to set QoS: %w", err)
-	}
+// workerFunc drains one worker queue until it is closed.
+type workerFunc func(ctx context.Context, ch *amqp.Channel, worker int, in <-chan amqp.Delivery)
 
-	rabbitConn = conn
-	rabbitChan = ch
+// Run consumes messages and forwards them to handler until ctx is canceled,
+// reconnecting whenever the connection is lost. It returns ctx.Err().
+func (c *Consumer) Run(ctx context.Context, handler events.Handler) error {
+	return c.run(ctx, func(ctx context.Context, ch *amqp.Channel, worker int, in <-chan amqp.Delivery) {
+		for msg := range in {
+			if ch.IsClosed() {
+				// Cannot be acked any more; the broker redelivers it.
+				c.stats.InFlight.Add(-1)
+				continue
+			}
+			c.handle(ctx, ch, worker, msg, handler)
+		}
+	})
+}
 
-	log.Println("Connected to RabbitMQ")
-	return nil
+// RunBatch is like Run, but each worker collects up to the configured batch size of
+// deliveries (or whatever arrived within the batch window) and passes them to handler
+// at once. Deliveries are acked only after handler returns, i.e. after the batch commits.
+func (c *Consumer) RunBatch(ctx context.Context, handler events.BatchHandler) error {
+	return c.run(ctx, func(ctx context.Context, ch *amqp.Channel, worker int, in <-chan amqp.Delivery) {
+		events.CollectBatches(in, c.batchSize, c.batchWindow, func(batch []amqp.Delivery) {
+			c.handleBatch(ctx, ch, worker, batch, handler)
+		})
+	})
 }
 
-// CloseRabbitMQ closes channel and connection.
-func CloseRabbitMQ() error {
-	var errs []error
-	if rabbitChan != nil {
-		if err := rabbitChan.Close(); err != nil {
-			errs = append(errs, fmt.Errorf("channel close: %w", err))
+func (c *Consumer) run(ctx context.Context, work workerFunc) error {
+	delay := c.minDelay
+	for {
+		connected, err := c.session(ctx, work)
+		if ctx.Err() != nil {
+			c.setState(events.StateStopped, nil)
+			return ctx.Err()
 		}
-	}
-	if rabbitConn != nil {
-		if err := rabbitConn.Close(); err != nil {
-			errs = append(errs, fmt.Errorf("connection close: %w", err))
+		if connected {
+			// The previous session was healthy; start the backoff over.
+			delay = c.minDelay
 		}
-	}
 
-	if len(errs) > 0 {
-		return errors.Join(errs...)
-	}
-	return nil
-}
+		c.setState(events.StateReconnecting, err)
+		wait := jitter(delay)
+		log.Printf("RabbitMQ consumer disconnected: %v. Reconnecting in %v...", err, wait)
+		select {
+		case <-ctx.Done():
+			c.setState(events.StateStopped, nil)
+			return ctx.Err()
+		case <-time.After(wait):
+		}
 
-// StartConsumer consumes messages and forwards them to handler until context is canceled.
-func StartConsumer(ctx context.Context, queueName string, handler func([]byte) error) error {
-	if rabbitChan == nil {
-		return fmt.Errorf("RabbitMQ channel is not initialized")
+		delay *= 2
+		if delay > c.maxDelay {
+			delay = c.maxDelay
+		}
 	}
+}
 
-	queue, err := rabbitChan.QueueDeclare(
-		queueName,
-		true,  // durable
-		false, // autoDelete
-		false, // exclusive
-		false, // noWait
-		nil,   // args
-	)
+// session runs one connection lifetime. connected is true if consuming had started.
+func (c *Consumer) session(ctx context.Context, work workerFunc) (connected bool, err error) {
+	conn, ch, err := c.connect()
 	if err != nil {
-		return fmt.Errorf("failed to declare queue %s: %w", queueName, err)
+		return false, err
 	}
+	defer c.disconnect()
+
+	connClosed := conn.NotifyClose(make(chan *amqp.Error, 1))
+	chClosed := ch.NotifyClose(make(chan *amqp.Error, 1))
 
-	deliveries, err := rabbitChan.Consume(
-		queue.Name,
+	deliveries, err := ch.Consume(
+		c.queueName,
 		"",    // consumer
 		false, // autoAck
 		false, // exclusive
@@ -96,48 +184,247 @@ func StartConsumer(ctx context.Context, queueName string, handler func([]byte) e
 		nil,   // args
 	)
 	if err != nil {
-		return fmt.Errorf("failed to start consuming: %w", err)
+		return false, fmt.Errorf("failed to start consuming: %w", err)
 	}
 
+	c.setState(events.StateConnected, nil)
+	log.Printf("Consuming from RabbitMQ queue %s with %d workers (prefetch %d)", c.queueName, c.workers, c.prefetch)
+
+	// Each worker gets its own queue; a full one blocks dispatch, which is
+	// bounded anyway by the prefetch limit on unacked deliveries.
+	queues := make([]chan amqp.Delivery, c.workers)
+	var wg sync.WaitGroup
+	for i := range queues {
+		queues[i] = make(chan amqp.Delivery, c.prefetch)
+		wg.Add(1)
+		go func(worker int, in <-chan amqp.Delivery) {
+			defer wg.Done()
+			work(ctx, ch, worker, in)
+		}(i, queues[i])
+	}
+	defer func() {
+		for _, q := range queues {
+			close(q)
+		}
+		wg.Wait()
+	}()
+
 	for {
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			return true, ctx.Err()
+		case amqpErr := <-connClosed:
+			return true, fmt.Errorf("connection closed: %v", amqpErr)
+		case amqpErr := <-chClosed:
+			return true, fmt.Errorf("channel closed: %v", amqpErr)
 		case msg, ok := <-deliveries:
 			if !ok {
-				return fmt.Errorf("deliveries channel closed")
+				return true, fmt.Errorf("deliveries channel closed")
 			}
+			c.stats.InFlight.Add(1)
+			queues[events.WorkerFor(msg.Body, c.workers)] <- msg
+		}
+	}
+}
 
-			if err := handler(msg.Body); err != nil {
-				log.Printf("handler error, delivery will be %s: %v", ackAction(err), err)
-				if isNonRetryable(err) {
-					msg.Nack(false, false)
-				} else {
-					msg.Nack(false, true)
-				}
-				continue
-			}
+func (c *Consumer) handle(ctx context.Context, ch *amqp.Channel, worker int, msg amqp.Delivery, handler events.Handler) {
+	defer c.stats.InFlight.Add(-1)
+
+	start := time.Now()
+	err := handler(msg.Body)
+	c.stats.Observe(worker, time.Since(start), err != nil)
+	c.settle(ctx, ch, msg, err)
+}
+
+func (c *Consumer) handleBatch(ctx context.Context, ch *amqp.Channel, worker int, batch []amqp.Delivery, handler events.BatchHandler) {
+	defer c.stats.InFlight.Add(-int64(len(batch)))
+
+	if ch.IsClosed() {
+		// Cannot be acked any more; the broker redelivers them.
+		return
+	}
+
+	bodies := make([][]byte, len(batch))
+	for i, msg := range batch {
+		bodies[i] = msg.Body
+	}
+
+	start := time.Now()
+	errs, err := handler(bodies)
+	took := time.Since(start)
+	c.stats.Batches.Add(1)
 
-			if err := msg.Ack(false); err != nil {
-				log.Printf("failed to ack message: %v", err)
+	if err == nil && len(errs) != len(batch) {
+		err = fmt.Errorf("batch handler returned %d results for %d messages", len(errs), len(batch))
+	}
+	if err != nil {
+		log.Printf("batch of %d messages failed, requeueing it: %v", len(batch), err)
+		for _, msg := range batch {
+			c.stats.Observe(worker, took/time.Duration(len(batch)), true)
+			c.stats.Requeued.Add(1)
+			msg.Nack(false, true)
+		}
+		return
+	}
+
+	for i, msg := range batch {
+		c.stats.Observe(worker, took/time.Duration(len(batch)), errs[i] != nil)
+		c.settle(ctx, ch, msg, errs[i])
+	}
+}
+
+// settle acks a handled delivery, or dead-letters / requeues it when handling failed.
+func (c *Consumer) settle(ctx context.Context, ch *amqp.Channel, msg amqp.Delivery, err error) {
+	if err != nil {
+		log.Printf("handler error, delivery will be %s: %v", events.AckAction(err), err)
+		if events.IsNonRetryable(err) {
+			if dlErr := deadLetter(ctx, ch, c.queueName, msg, err); dlErr != nil {
+				// Requeue rather than drop it; it is dead-lettered again on redelivery.
+				log.Printf("failed to dead-letter message, requeueing it: %v", dlErr)
+				c.stats.Requeued.Add(1)
+				msg.Nack(false, true)
+				return
 			}
+			c.stats.DeadLettered.Add(1)
+			if ackErr := msg.Ack(false); ackErr != nil {
+				log.Printf("failed to ack dead-lettered message: %v", ackErr)
+			}
+		} else {
+			c.stats.Requeued.Add(1)
+			msg.Nack(false, true)
 		}
+		return
+	}
+
+	if err := msg.Ack(false); err != nil {
+		log.Printf("failed to ack message: %v", err)
+	}
+}
+
+// connect dials the broker, opens a channel and declares the queue and dead-letter topology.
+func (c *Consumer) connect() (*amqp.Connection, *amqp.Channel, error) {
+	conn, err := amqp.Dial(c.url)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to open channel: %w", err)
+	}
+
+	// Limit unacked deliveries so a slow database does not pile messages up in memory.
+	if err := ch.Qos(c.prefetch, 0, false); err != nil {
+		ch.Close()
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to set QoS: %w", err)
+	}
+
+	if _, err := ch.QueueDeclare(
+		c.queueName,
+		true,  // durable
+		false, // autoDelete
+		false, // exclusive
+		false, // noWait
+		nil,   // args
+	); err != nil {
+		ch.Close()
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to declare queue %s: %w", c.queueName, err)
+	}
+
+	if err := declareDeadLetter(ch, c.queueName); err != nil {
+		ch.Close()
+		conn.Close()
+		return nil, nil, err
+	}
+
+	c.mu.Lock()
+	c.conn = conn
+	c.ch = ch
+	c.mu.Unlock()
+
+	log.Println("Connected to RabbitMQ")
+	return conn, ch, nil
+}
+
+// disconnect closes the current channel and connection, ignoring errors from
+// already-closed ones.
+func (c *Consumer) disconnect() {
+	c.mu.Lock()
+	conn, ch := c.conn, c.ch
+	c.conn, c.ch = nil, nil
+	c.mu.Unlock()
+
+	if ch != nil && !ch.IsClosed() {
+		ch.Close()
+	}
+	if conn != nil && !conn.IsClosed() {
+		conn.Close()
+	}
+}
+
+// Publish sends an event body to the consumed queue.
+func (c *Consumer) Publish(ctx context.Context, body []byte) error {
+	conn, err := c.connection()
+	if err != nil {
+		return err
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open channel: %w", err)
 	}
+	defer ch.Close()
+
+	if err := ch.PublishWithContext(ctx, "", c.queueName, false, false, amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		MessageId:    events.NewMessageID(),
+		Timestamp:    time.Now().UTC(),
+		Body:         body,
+	}); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", c.queueName, err)
+	}
+	return nil
 }
 
-func isNonRetryable(err error) bool {
-	if err == nil {
-		return false
+// connection returns the current connection or an error while disconnected.
+func (c *Consumer) connection() (*amqp.Connection, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.conn == nil || c.conn.IsClosed() {
+		return nil, fmt.Errorf("RabbitMQ is not connected (state: %s)", c.status.State)
 	}
-	return errors.Is(err, forms.ErrInvalidCameraEvent) ||
-		errors.Is(err, models.ErrCameraNotFound) ||
-		errors.Is(err, models.ErrCameraNotAttached)
+	return c.conn, nil
 }
 
-func ackAction(err error) string {
-	if isNonRetryable(err) {
-		return "acked without requeue"
+// Close closes the channel and connection. Run should be stopped via its context first.
+func (c *Consumer) Close() error {
+	c.mu.Lock()
+	conn, ch := c.conn, c.ch
+	c.conn, c.ch = nil, nil
+	c.mu.Unlock()
+
+	var errs []error
+	if ch != nil && !ch.IsClosed() {
+		if err := ch.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("channel close: %w", err))
+		}
+	}
+	if conn != nil && !conn.IsClosed() {
+		if err := conn.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("connection close: %w", err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
 	}
-	return "requeued"
+	return nil
 }
 
+// jitter spreads reconnect attempts of several replicas by up to ±20%.
+func jitter(d time.Duration) time.Duration {
+	return d - d/5 + time.Duration(rand.Int63n(int64(d/5)*2+1))
+}
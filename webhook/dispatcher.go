@@ -0,0 +1,196 @@
+// Package webhook evaluates webhook rules on stored occupancy readings and
+// sends the resulting calls, signed and retried with backoff.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"web_backend_v2/config"
+	"web_backend_v2/forms"
+	"web_backend_v2/models"
+)
+
+const (
+	// queueSize is how many stored readings may wait for evaluation; readings
+	// beyond it are not evaluated.
+	queueSize = 10000
+	// pollInterval is how often due deliveries are looked for when no new
+	// delivery was queued in between (retries, other replicas).
+	pollInterval = 5 * time.Second
+	// maxResponseBytes is how much of a response body is read.
+	maxResponseBytes = 64 << 10
+)
+
+// Dispatcher evaluates readings passed to Notify against the webhooks and sends
+// the queued deliveries. Deliveries are stored first, so those not sent before
+// a restart are sent afterwards.
+type Dispatcher struct {
+	store  models.WebhookStore
+	cfg    config.WebhookConfig
+	client *http.Client
+
+	updates chan forms.OccupancyUpdate
+	wake    chan struct{}
+}
+
+// NewDispatcher creates a dispatcher; Run starts it.
+func NewDispatcher(store models.WebhookStore, cfg config.WebhookConfig) *Dispatcher {
+	return &Dispatcher{
+		store:   store,
+		cfg:     cfg,
+		client:  &http.Client{Timeout: cfg.Timeout},
+		updates: make(chan forms.OccupancyUpdate, queueSize),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Notify queues a stored reading for evaluation. It never blocks, so it can be
+// registered with OccupancyStore.OnStored.
+func (d *Dispatcher) Notify(update forms.OccupancyUpdate) {
+	select {
+	case d.updates <- update:
+	default:
+		log.Printf("webhook: evaluation queue is full, reading of auditorium %d at %s is not evaluated",
+			update.AuditoriumID, update.Timestamp.Format(time.RFC3339))
+	}
+}
+
+// Run evaluates readings and sends deliveries until ctx is done, then waits for
+// the calls in progress.
+func (d *Dispatcher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		d.evaluate(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		d.deliver(ctx)
+	}()
+	wg.Wait()
+}
+
+func (d *Dispatcher) evaluate(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case update := <-d.updates:
+			queued, err := d.store.EvaluateWebhooks(update)
+			if err != nil {
+				log.Printf("webhook: failed to evaluate reading of auditorium %d: %v", update.AuditoriumID, err)
+				continue
+			}
+			if queued > 0 {
+				select {
+				case d.wake <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		// Send until nothing is due, then wait for new deliveries or the next poll.
+		for d.sendDue(ctx) {
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendDue sends one batch of due deliveries concurrently and reports whether
+// the batch was full, i.e. more may be due.
+func (d *Dispatcher) sendDue(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	// A claimed delivery is retried after the lease if this process dies while
+	// sending it, so the lease must outlast the call.
+	calls, err := d.store.ClaimWebhookDeliveries(time.Now(), d.cfg.Workers, 2*d.cfg.Timeout)
+	if err != nil {
+		log.Printf("webhook: %v", err)
+		return false
+	}
+	var wg sync.WaitGroup
+	for _, call := range calls {
+		wg.Add(1)
+		go func(call models.WebhookCall) {
+			defer wg.Done()
+			attempt := d.send(call)
+			if err := d.store.FinishWebhookDelivery(call.Delivery.ID, attempt); err != nil {
+				log.Printf("webhook: %v", err)
+			}
+		}(call)
+	}
+	wg.Wait()
+	return len(calls) == d.cfg.Workers
+}
+
+// send makes one call and decides what happens to the delivery next.
+func (d *Dispatcher) send(call models.WebhookCall) models.WebhookAttempt {
+	now := time.Now()
+	attempt := models.WebhookAttempt{At: now}
+
+	body := []byte(call.Delivery.Payload)
+	req, err := http.NewRequest(http.MethodPost, call.URL, bytes.NewReader(body))
+	if err == nil {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(HeaderEvent, call.Delivery.Event)
+		req.Header.Set(HeaderDelivery, strconv.FormatUint(call.Delivery.ID, 10))
+		req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+		req.Header.Set(HeaderSignature, Sign(call.Secret, now.Unix(), body))
+
+		var resp *http.Response
+		resp, err = d.client.Do(req)
+		if err == nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBytes))
+			resp.Body.Close()
+			code := resp.StatusCode
+			attempt.StatusCode = &code
+			if code >= 200 && code < 300 {
+				attempt.Status = forms.DeliveryDelivered
+				return attempt
+			}
+			err = fmt.Errorf("unexpected status %d", code)
+		}
+	}
+
+	attempt.Error = err.Error()
+	if call.Delivery.Attempts >= d.cfg.MaxAttempts {
+		attempt.Status = forms.DeliveryFailed
+		return attempt
+	}
+	attempt.Status = forms.DeliveryPending
+	attempt.NextAttemptAt = now.Add(d.retryDelay(call.Delivery.Attempts))
+	return attempt
+}
+
+// retryDelay is the wait after the given number of failed attempts:
+// RetryMinDelay doubled after each failure, at most RetryMaxDelay.
+func (d *Dispatcher) retryDelay(attempts int) time.Duration {
+	delay := d.cfg.RetryMinDelay
+	for i := 1; i < attempts && delay < d.cfg.RetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > d.cfg.RetryMaxDelay {
+		delay = d.cfg.RetryMaxDelay
+	}
+	return delay
+}
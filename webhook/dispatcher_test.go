@@ -0,0 +1,159 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+	"web_backend_v2/config"
+	"web_backend_v2/forms"
+	"web_backend_v2/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecret = "0123456789abcdef"
+
+var testConfig = config.WebhookConfig{
+	Timeout:       time.Second,
+	MaxAttempts:   3,
+	RetryMinDelay: 50 * time.Millisecond,
+	RetryMaxDelay: time.Second,
+	Workers:       4,
+}
+
+// receivedCall is what the test server saw of a call.
+type receivedCall struct {
+	header http.Header
+	body   []byte
+	// leased is whether the delivery was claimed for the lease during the call.
+	leased bool
+}
+
+// newHookStore returns a memory store with a 100-seat auditorium 1 and an
+// "above 80%" webhook of its building calling url.
+func newHookStore(t *testing.T, url string) (*models.MemoryStore, forms.OccupancyUpdate) {
+	t.Helper()
+	store := models.NewMemoryStore(models.FusionMax, models.DefaultFusionWindow)
+	city := forms.City{NameRU: "Москва", NameEN: "Moscow"}
+	require.NoError(t, store.CreateCity(&city))
+	building := forms.Building{CityID: city.ID, AddressRU: "а", AddressEN: "a", FloorCount: 1}
+	require.NoError(t, store.CreateBuilding(&building))
+	auditorium := forms.Auditorium{BuildingID: building.ID, FloorNumber: 1, Capacity: 100, AuditoriumNumber: "101", Type: "classroom"}
+	require.NoError(t, store.CreateAuditorium(&auditorium))
+	require.NoError(t, store.CreateWebhook(&forms.Webhook{
+		BuildingID: building.ID, URL: url, Secret: testSecret, Rule: forms.RuleAbove, ThresholdPercent: 80,
+	}))
+	return store, forms.OccupancyUpdate{CityID: city.ID, BuildingID: building.ID, AuditoriumID: auditorium.ID}
+}
+
+func TestDispatcherRetries(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls []receivedCall
+		store *models.MemoryStore
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		// While a call is in flight its delivery is not due, so it is not sent twice.
+		claimed, _ := store.ClaimWebhookDeliveries(time.Now(), 10, time.Second)
+		mu.Lock()
+		calls = append(calls, receivedCall{header: r.Header.Clone(), body: body, leased: len(claimed) == 0})
+		n := len(calls)
+		mu.Unlock()
+		if n == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+	store, update := newHookStore(t, server.URL)
+	d := NewDispatcher(store, testConfig)
+	ctx := context.Background()
+
+	update.PersonCount, update.Timestamp = 85, time.Now()
+	queued, err := store.EvaluateWebhooks(update)
+	require.NoError(t, err)
+	require.Equal(t, 1, queued)
+
+	start := time.Now()
+	assert.False(t, d.sendDue(ctx))
+	deliveries, err := store.GetWebhookDeliveries(1, "", 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	delivery := deliveries[0]
+	assert.Equal(t, forms.DeliveryPending, delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+	require.NotNil(t, delivery.LastStatusCode)
+	assert.Equal(t, http.StatusInternalServerError, *delivery.LastStatusCode)
+	require.NotNil(t, delivery.LastError)
+	assert.Equal(t, "unexpected status 500", *delivery.LastError)
+	assert.WithinRange(t, delivery.NextAttemptAt, start.Add(testConfig.RetryMinDelay), time.Now().Add(testConfig.RetryMinDelay))
+
+	d.sendDue(ctx)
+	mu.Lock()
+	assert.Len(t, calls, 1, "not retried before the backoff")
+	mu.Unlock()
+
+	time.Sleep(time.Until(delivery.NextAttemptAt))
+	d.sendDue(ctx)
+	deliveries, err = store.GetWebhookDeliveries(1, "", 10)
+	require.NoError(t, err)
+	delivery = deliveries[0]
+	assert.Equal(t, forms.DeliveryDelivered, delivery.Status)
+	assert.Equal(t, 2, delivery.Attempts)
+	assert.Equal(t, http.StatusOK, *delivery.LastStatusCode)
+	assert.Nil(t, delivery.LastError)
+	assert.NotNil(t, delivery.DeliveredAt)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, calls, 2)
+	for _, call := range calls {
+		assert.True(t, call.leased)
+		assert.Equal(t, "above.triggered", call.header.Get(HeaderEvent))
+		assert.Equal(t, strconv.FormatUint(delivery.ID, 10), call.header.Get(HeaderDelivery))
+		assert.Equal(t, "application/json", call.header.Get("Content-Type"))
+		assert.JSONEq(t, delivery.Payload, string(call.body))
+		timestamp, err := strconv.ParseInt(call.header.Get(HeaderTimestamp), 10, 64)
+		require.NoError(t, err)
+		assert.Equal(t, Sign(testSecret, timestamp, call.body), call.header.Get(HeaderSignature))
+	}
+}
+
+func TestDispatcherGivesUp(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+	store, update := newHookStore(t, server.URL)
+	cfg := testConfig
+	cfg.RetryMinDelay, cfg.RetryMaxDelay = time.Millisecond, time.Millisecond
+	d := NewDispatcher(store, cfg)
+
+	update.PersonCount, update.Timestamp = 90, time.Now()
+	_, err := store.EvaluateWebhooks(update)
+	require.NoError(t, err)
+	for i := 0; i < cfg.MaxAttempts; i++ {
+		time.Sleep(2 * time.Millisecond)
+		d.sendDue(context.Background())
+	}
+
+	deliveries, err := store.GetWebhookDeliveries(1, "", 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, forms.DeliveryFailed, deliveries[0].Status)
+	assert.Equal(t, cfg.MaxAttempts, deliveries[0].Attempts)
+	assert.Equal(t, http.StatusBadGateway, *deliveries[0].LastStatusCode)
+}
+
+func TestRetryDelay(t *testing.T) {
+	d := NewDispatcher(nil, config.WebhookConfig{RetryMinDelay: time.Second, RetryMaxDelay: 10 * time.Second})
+	for attempts, want := range []time.Duration{time.Second, time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		assert.Equal(t, want, d.retryDelay(attempts), "after %d attempts", attempts)
+	}
+}
@@ -0,0 +1,71 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		body      string
+		want      string
+	}{
+		{
+			name:      "payload",
+			secret:    "0123456789abcdef",
+			timestamp: 1767225600,
+			body:      `{"event":"above.triggered"}`,
+			want:      "sha256=3071e4a29c2e0a2b43bbda47a93b6d6f3998703b9fa803d1a1f63a3ae5e4bbf8",
+		},
+		{
+			name:      "empty body",
+			secret:    "secret-secret-secret",
+			timestamp: 0,
+			body:      "",
+			want:      "sha256=16eccd32f7a7ee4db05fed035e16a7707b3448719761ab7d0feb209710eb23cd",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Sign(tt.secret, tt.timestamp, []byte(tt.body)))
+		})
+	}
+}
+
+// verify is what a receiver does with the headers of a call.
+func verify(secret, timestamp, signature string, body []byte) bool {
+	hexSum, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(hexSum)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+func TestSignVerifies(t *testing.T) {
+	const secret = "0123456789abcdef"
+	body := []byte(`{"event":"empty.cleared","person_count":3}`)
+	signature := Sign(secret, 1767225600, body)
+	require.Regexp(t, `^sha256=[0-9a-f]{64}$`, signature)
+	assert.True(t, verify(secret, "1767225600", signature, body))
+
+	assert.False(t, verify("another-secret-00", "1767225600", signature, body), "other secret")
+	assert.False(t, verify(secret, strconv.Itoa(1767225601), signature, body), "other timestamp")
+	assert.False(t, verify(secret, "1767225600", signature, append(body, ' ')), "other body")
+}